@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/LittleAksMax/bids-util/env"
 	"github.com/joho/godotenv"
@@ -19,6 +25,9 @@ const (
 	ModeProduction  = "production"
 )
 
+// shutdownTimeout bounds how long in-flight requests are given to finish on shutdown.
+const shutdownTimeout = 15 * time.Second
+
 func main() {
 	// Load development override file BEFORE config parsing if MODE indicates development.
 	mode := env.GetStrFromEnv("MODE")
@@ -52,12 +61,39 @@ func main() {
 		log.Fatalf("migration error: %v", err)
 	}
 
-	r := api.NewRouter(pool, cfg, mode == ModeProduction)
+	// Background work stops when the process is asked to shut down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var background sync.WaitGroup
+
+	r, err := api.NewRouter(ctx, &background, pool, cfg)
+	if err != nil {
+		log.Fatalf("router error: %v", err)
+	}
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
+	server := &http.Server{Addr: addr, Handler: r}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("server shutdown error: %v", err)
+		}
+	}()
+
 	log.Printf("starting server on %s (mode=%s)", addr, mode)
-	if err := http.ListenAndServe(addr, r); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		log.Printf("server stopped: %v", err)
+		stop()
+		background.Wait()
 		os.Exit(1)
 	}
+
+	// Wait for in-flight requests and background work to finish before the database pool is closed
+	<-shutdown
+	background.Wait()
+	log.Printf("server stopped")
 }
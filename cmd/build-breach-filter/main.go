@@ -0,0 +1,55 @@
+// Command build-breach-filter compiles a Have I Been Pwned SHA-1 dump ("<hash>:<count>" per
+// line) into the binary fuse filter loaded from BREACHED_PASSWORDS_FILE.
+//
+//	build-breach-filter -in pwned-passwords-sha1-ordered-by-hash.txt -out breached.bin
+//	build-breach-filter -in pwned-passwords.txt -out breached.bin -min-count 10
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/breach"
+)
+
+func main() {
+	in := flag.String("in", "", "path to the SHA-1 dump")
+	out := flag.String("out", "", "path to write the filter to")
+	minCount := flag.Int("min-count", 1, "only include hashes seen at least this many times")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		log.Fatalf("-in and -out are required")
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		log.Fatalf("open %s: %v", *in, err)
+	}
+	defer src.Close()
+
+	keys, err := breach.ReadHIBPKeys(src, *minCount)
+	if err != nil {
+		log.Fatalf("read %s: %v", *in, err)
+	}
+	log.Printf("read %d hashes", len(keys))
+
+	filter, err := breach.NewFilter(keys)
+	if err != nil {
+		log.Fatalf("build filter: %v", err)
+	}
+
+	dst, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("create %s: %v", *out, err)
+	}
+	n, err := filter.WriteTo(dst)
+	if err != nil {
+		log.Fatalf("write %s: %v", *out, err)
+	}
+	if err := dst.Close(); err != nil {
+		log.Fatalf("close %s: %v", *out, err)
+	}
+	log.Printf("wrote %d bytes to %s", n, *out)
+}
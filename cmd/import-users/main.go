@@ -0,0 +1,180 @@
+// Command import-users bulk-loads users with legacy (bcrypt, PBKDF2-SHA256, scrypt) password
+// hashes from a CSV or JSONL file.
+//
+// CSV files need a header row naming the columns username, email, role, password_algo and
+// password_hash; JSONL files hold one object per line with the same keys.
+//
+//	import-users -file users.csv
+//	import-users -file users.jsonl -format jsonl
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/LittleAksMax/bids-util/env"
+	"github.com/joho/godotenv"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/db"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+const (
+	ModeDevelopment = "development"
+	ModeProduction  = "production"
+)
+
+func main() {
+	file := flag.String("file", "", "path to the CSV or JSONL file to import")
+	format := flag.String("format", "", "input format: csv or jsonl (default: inferred from the file extension)")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatalf("-file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	mode := env.GetStrFromEnv("MODE")
+	if mode != ModeDevelopment && mode != ModeProduction {
+		log.Fatalf("invalid environment variable MODE: %s", mode)
+	}
+	if mode == ModeDevelopment {
+		if err := godotenv.Load(".env.Dev"); err != nil {
+			log.Fatalf("Failed to load .env.Dev: %v", err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("config error: %v", err)
+	}
+
+	pool, err := db.Connect(cfg.DSN())
+	if err != nil {
+		log.Fatalf("db connect error: %v", err)
+	}
+	defer func() {
+		if err := pool.Close(); err != nil {
+			log.Printf("db close error: %v", err)
+		}
+	}()
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatalf("open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	var records []service.ImportedUser
+	switch *format {
+	case "csv":
+		records, err = readCSV(f)
+	case "jsonl":
+		records, err = readJSONL(f)
+	default:
+		log.Fatalf("unsupported format %q (expected csv or jsonl)", *format)
+	}
+	if err != nil {
+		log.Fatalf("read %s: %v", *file, err)
+	}
+
+	importer := service.NewUserImportService(pool, repository.NewUserRepository(), repository.NewPasswordCredentialRepository(), repository.NewAuthEventRepository(), repository.NewOutboxRepository())
+
+	ctx := context.Background()
+	imported, skipped, failed := 0, 0, 0
+	for i, rec := range records {
+		if _, err := importer.Import(ctx, rec); err != nil {
+			if errors.Is(err, service.ErrUserExists) {
+				skipped++
+				log.Printf("record %d (%s): already exists, skipping", i+1, rec.Email)
+				continue
+			}
+			failed++
+			log.Printf("record %d (%s): %v", i+1, rec.Email, err)
+			continue
+		}
+		imported++
+	}
+
+	log.Printf("imported %d, skipped %d, failed %d of %d records", imported, skipped, failed, len(records))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func readCSV(r io.Reader) ([]service.ImportedUser, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"username", "email", "password_algo", "password_hash"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q", required)
+		}
+	}
+
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var records []service.ImportedUser
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, service.ImportedUser{
+			Username:     field(row, "username"),
+			Email:        field(row, "email"),
+			Role:         field(row, "role"),
+			PasswordAlgo: field(row, "password_algo"),
+			PasswordHash: field(row, "password_hash"),
+		})
+	}
+}
+
+func readJSONL(r io.Reader) ([]service.ImportedUser, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var records []service.ImportedUser
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var rec service.ImportedUser
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
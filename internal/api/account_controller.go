@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// AccountController houses dependencies for self-service account endpoints.
+type AccountController struct {
+	accountService service.AccountService
+	cookieProfiles service.CookieProfiles
+}
+
+// NewAccountController constructs an AccountController.
+func NewAccountController(accountService service.AccountService, cookieProfiles service.CookieProfiles) *AccountController {
+	return &AccountController{
+		accountService: accountService,
+		cookieProfiles: cookieProfiles,
+	}
+}
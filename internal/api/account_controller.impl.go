@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// RequestDeletion handler schedules the authenticated user's account for deletion and ends their sessions.
+func (c *AccountController) RequestDeletion(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[DeleteAccountRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	user, err := c.accountService.RequestDeletion(r.Context(), userID, reauthenticationFromRequest(r, body.Password))
+	if err != nil {
+		if errors.Is(err, service.ErrReauthenticationRequired) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "re-authentication required: provide your password or sign in again"})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountInactive) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "user not found"})
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to delete account"})
+		return
+	}
+
+	// Sessions were revoked with the request; drop the now useless refresh and CSRF cookies
+	setCookies(w, cookiesFor(c.cookieProfiles, r).CreateClearAuthCookies())
+
+	requests.WriteJSON(w, http.StatusAccepted, requests.APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// CancelDeletion handler restores an account that is pending deletion.
+func (c *AccountController) CancelDeletion(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CancelDeletionRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	user, err := c.accountService.CancelDeletion(r.Context(), body.Email, body.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+			return
+		}
+		if errors.Is(err, service.ErrDeletionNotPending) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "account is not pending deletion"})
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to cancel account deletion"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    user,
+	})
+}
+
+// Export handler returns the authenticated user's personal data as a downloadable JSON archive.
+func (c *AccountController) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	export, err := c.accountService.Export(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "user not found"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to export account data"})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="account-export.json"`)
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    export,
+	})
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// AdminController houses dependencies for admin endpoints.
+type AdminController struct {
+	adminService service.AdminService
+}
+
+// NewAdminController constructs an AdminController.
+func NewAdminController(adminService service.AdminService) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+	}
+}
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// PepperReport handler reports how many credentials remain on each pepper version.
+func (c *AdminController) PepperReport(w http.ResponseWriter, r *http.Request) {
+	report, err := c.adminService.PepperReport(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to build pepper report"})
+		return
+	}
+
+	data := make([]PepperReportResponse, 0, len(report))
+	for _, entry := range report {
+		data = append(data, PepperReportResponse{
+			PepperID:    entry.PepperID,
+			Credentials: entry.Credentials,
+			Current:     entry.Current,
+			Configured:  entry.Configured,
+		})
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ListUsers handler returns a page of users. Query parameters: role, status, created_after and
+// created_before (RFC 3339), q (username or email substring), limit and cursor.
+func (c *AdminController) ListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := contracts.UserFilter{
+		Role:   query.Get("role"),
+		Status: query.Get("status"),
+		Search: strings.TrimSpace(query.Get("q")),
+	}
+	if filter.Status != "" && !isUserStatus(filter.Status) {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid status"})
+		return
+	}
+	var ok bool
+	if filter.CreatedAfter, ok = parseTimeQuery(w, query.Get("created_after"), "created_after"); !ok {
+		return
+	}
+	if filter.CreatedBefore, ok = parseTimeQuery(w, query.Get("created_before"), "created_before"); !ok {
+		return
+	}
+
+	limit := defaultUserPageSize
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxUserPageSize {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	var after *contracts.UserCursor
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeUserCursor(raw)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid cursor"})
+			return
+		}
+		after = cursor
+	}
+
+	page, err := c.adminService.ListUsers(r.Context(), filter, after, limit)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list users"})
+		return
+	}
+
+	data := AdminUserListResponse{
+		Users: make([]AdminUserResponse, 0, len(page.Users)),
+		Total: page.Total,
+	}
+	for _, user := range page.Users {
+		data.Users = append(data.Users, newAdminUserResponse(user))
+	}
+	if page.NextCursor != nil {
+		data.NextCursor = encodeUserCursor(page.NextCursor)
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// GetUser handler returns a single user.
+func (c *AdminController) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.GetUser(r.Context(), userID)
+	writeAdminUser(w, user, err, "failed to get user")
+}
+
+// CreateUser handler creates a user with a password, an emailed invitation, or both.
+func (c *AdminController) CreateUser(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreateUserRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	user, inviteSent, err := c.adminService.CreateUser(r.Context(), body.Username, body.Email, body.Role, body.Password, body.SendInvite)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordOrInviteRequired) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "password or send_invite is required"})
+			return
+		}
+		if errors.Is(err, service.ErrUserExists) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "username or email already exists"})
+			return
+		}
+		if errors.Is(err, service.ErrRoleNotFound) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "unknown role"})
+			return
+		}
+		if writePasswordRejected(w, "password", err) {
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to create user"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: AdminCreateUserResponse{
+			User:       newAdminUserResponse(user),
+			InviteSent: inviteSent,
+		},
+	})
+}
+
+// UpdateUser handler changes a user's username and email.
+func (c *AdminController) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[UpdateUserRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.UpdateUser(r.Context(), userID, body.Username, body.Email)
+	writeAdminUser(w, user, err, "failed to update user")
+}
+
+// ChangeRole handler changes a user's role and revokes their sessions.
+func (c *AdminController) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[ChangeRoleRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	actorID, userID, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.ChangeRole(r.Context(), actorID, userID, body.Role)
+	writeAdminUser(w, user, err, "failed to change role")
+}
+
+// SuspendUser handler suspends a user account and revokes its sessions.
+func (c *AdminController) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[SuspendUserRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	actorID, userID, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.SuspendUser(r.Context(), actorID, userID, body.Reason)
+	writeAdminUser(w, user, err, "failed to suspend user")
+}
+
+// RestoreUser handler reactivates a user account.
+func (c *AdminController) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.adminService.RestoreUser(r.Context(), actorID, userID)
+	writeAdminUser(w, user, err, "failed to restore user")
+}
+
+// ForceLogout handler revokes all of a user's sessions.
+func (c *AdminController) ForceLogout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.adminService.ForceLogout(r.Context(), userID); err != nil {
+		writeAdminError(w, err, "failed to log out user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteUser handler deletes a user immediately.
+func (c *AdminController) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actorID, userID, ok := adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.adminService.DeleteUser(r.Context(), actorID, userID); err != nil {
+		writeAdminError(w, err, "failed to delete user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAuthEvents handler returns a page of the authentication audit log, newest first. Query
+// parameters: user_id (actor or subject), type (comma-separated), from and to (RFC 3339), limit and cursor.
+func (c *AdminController) ListAuthEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter contracts.AuthEventFilter
+	if raw := query.Get("user_id"); raw != "" {
+		userID, err := uuid.Parse(raw)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid user_id"})
+			return
+		}
+		filter.UserID = &userID
+	}
+	for _, t := range strings.Split(query.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	var ok bool
+	if filter.From, ok = parseTimeQuery(w, query.Get("from"), "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseTimeQuery(w, query.Get("to"), "to"); !ok {
+		return
+	}
+
+	limit, beforeID, ok := parseIDPageQuery(w, query, defaultEventPageSize, maxEventPageSize)
+	if !ok {
+		return
+	}
+
+	page, err := c.adminService.ListAuthEvents(r.Context(), filter, beforeID, limit)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list auth events"})
+		return
+	}
+
+	data := AuthEventListResponse{Events: make([]AuthEventResponse, 0, len(page.Events))}
+	for _, event := range page.Events {
+		data.Events = append(data.Events, newAuthEventResponse(event))
+	}
+	if page.NextCursor > 0 {
+		data.NextCursor = strconv.FormatInt(page.NextCursor, 10)
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+const (
+	defaultUserPageSize  = 50
+	maxUserPageSize      = 200
+	defaultEventPageSize = 100
+	maxEventPageSize     = 500
+)
+
+// adminTarget resolves the calling admin and the {userID} URL parameter, writing an error response
+// if either is invalid.
+func adminTarget(w http.ResponseWriter, r *http.Request) (actorID, userID uuid.UUID, ok bool) {
+	actorID, ok = userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, ok = userIDParam(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return actorID, userID, true
+}
+
+// userIDParam parses the {userID} URL parameter, writing a 400 if it is not a UUID.
+func userIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid user id"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// writeAdminUser writes the user resulting from an admin operation, or the operation's error.
+func writeAdminUser(w http.ResponseWriter, user *contracts.UserDTO, err error, failure string) {
+	if err != nil {
+		writeAdminError(w, err, failure)
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newAdminUserResponse(user),
+	})
+}
+
+// writeAdminError maps admin service errors to responses.
+func writeAdminError(w http.ResponseWriter, err error, failure string) {
+	if errors.Is(err, service.ErrUserNotFound) {
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "user not found"})
+		return
+	}
+	if errors.Is(err, service.ErrUserExists) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "username or email already exists"})
+		return
+	}
+	if errors.Is(err, service.ErrCannotModifySelf) {
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "cannot perform this action on your own account"})
+		return
+	}
+	if errors.Is(err, service.ErrRoleNotFound) {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "unknown role"})
+		return
+	}
+	requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: failure})
+}
+
+func newAdminUserResponse(user *contracts.UserDTO) AdminUserResponse {
+	return AdminUserResponse{
+		ID:                  user.ID.String(),
+		Username:            user.Username,
+		Email:               user.Email,
+		Role:                user.Role,
+		Status:              user.Status,
+		StatusReason:        user.StatusReason,
+		StatusChangedAt:     optionalTimeString(user.StatusChangedAt),
+		DeletionScheduledAt: optionalTimeString(user.DeletionScheduledAt),
+		UpdatedAt:           user.UpdatedAt.String(),
+		CreatedAt:           user.CreatedAt.String(),
+	}
+}
+
+func newAuthEventResponse(event *contracts.AuthEvent) AuthEventResponse {
+	return AuthEventResponse{
+		ID:         event.ID,
+		Type:       event.Type,
+		ActorID:    optionalUUIDString(event.ActorID),
+		SubjectID:  optionalUUIDString(event.SubjectID),
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Metadata:   event.Metadata,
+		OccurredAt: event.OccurredAt.String(),
+	}
+}
+
+func optionalUUIDString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func optionalTimeString(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.String()
+	return &s
+}
+
+func isUserStatus(status string) bool {
+	switch status {
+	case contracts.UserStatusActive, contracts.UserStatusSuspended, contracts.UserStatusDeactivated, contracts.UserStatusPendingDeletion:
+		return true
+	}
+	return false
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter, writing a 400 if it is malformed.
+func parseTimeQuery(w http.ResponseWriter, raw, name string) (*time.Time, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid " + name})
+		return nil, false
+	}
+	return &t, true
+}
+
+// parseIDPageQuery parses the limit and cursor query parameters of a listing paged by descending
+// numeric ID, writing a 400 if either is malformed.
+func parseIDPageQuery(w http.ResponseWriter, query url.Values, defaultLimit, maxLimit int) (limit int, beforeID int64, ok bool) {
+	limit = defaultLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLimit {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid limit"})
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid cursor"})
+			return 0, 0, false
+		}
+		beforeID = n
+	}
+	return limit, beforeID, true
+}
+
+// encodeUserCursor renders a keyset position as an opaque token.
+func encodeUserCursor(cursor *contracts.UserCursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeUserCursor parses a token produced by encodeUserCursor.
+func decodeUserCursor(token string) (*contracts.UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+	createdAtStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, err
+	}
+	return &contracts.UserCursor{CreatedAt: createdAt, ID: id}, nil
+}
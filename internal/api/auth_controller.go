@@ -1,21 +1,40 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/LittleAksMax/bids-auth-service/internal/service"
 )
 
+// Headers used by browser clients that keep the refresh token in a cookie.
+const (
+	// ClientIDHeader identifies the calling client, selecting its cookie profile.
+	ClientIDHeader = "X-Client-ID"
+	// CSRFTokenHeader must echo the CSRF cookie when refreshing or logging out with the refresh cookie.
+	CSRFTokenHeader = "X-CSRF-Token"
+	// TokenTransportHeader set to "cookie" keeps the refresh token out of response bodies.
+	TokenTransportHeader = "X-Token-Transport"
+)
+
 // AuthController houses dependencies for auth/token endpoints.
 type AuthController struct {
-	authService   service.AuthService
-	tokenService  service.TokenService
-	cookieService service.CookieService
+	authService         service.AuthService
+	tokenService        service.TokenService
+	organizationService service.OrganizationService
+	cookieProfiles      service.CookieProfiles
 }
 
 // NewAuthController constructs an AuthController.
-func NewAuthController(authService service.AuthService, tokenService service.TokenService, cookieService service.CookieService) *AuthController {
+func NewAuthController(authService service.AuthService, tokenService service.TokenService, organizationService service.OrganizationService, cookieProfiles service.CookieProfiles) *AuthController {
 	return &AuthController{
-		authService:   authService,
-		tokenService:  tokenService,
-		cookieService: cookieService,
+		authService:         authService,
+		tokenService:        tokenService,
+		organizationService: organizationService,
+		cookieProfiles:      cookieProfiles,
 	}
 }
+
+// cookiesFor returns the cookie service for the client making r.
+func cookiesFor(profiles service.CookieProfiles, r *http.Request) service.CookieService {
+	return profiles.For(r.Header.Get(ClientIDHeader))
+}
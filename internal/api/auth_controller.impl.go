@@ -1,8 +1,14 @@
 package api
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
 	"errors"
+	"log"
 	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
 
 	"github.com/LittleAksMax/bids-auth-service/internal/service"
 	"github.com/LittleAksMax/bids-util/requests"
@@ -23,18 +29,34 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "username or email already exists"})
 			return
 		}
+		if errors.Is(err, service.ErrRoleNotFound) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "unknown role"})
+			return
+		}
+		if errors.Is(err, service.ErrRegistrationRole) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		if writePasswordRejected(w, "password", err) {
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to register user"})
 		return
 	}
 
-	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role)
+	organizationID := c.joinOrganization(r, user.ID, body.OrganizationInvite)
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, organizationID)
 	if err != nil || tokenPair == nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to generate token pair"})
 		return
 	}
 
-	// Set refresh token cookie (for browser clients)
-	http.SetCookie(w, c.cookieService.CreateSetAuthCookie(tokenPair.RefreshToken))
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
 
 	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
 		Success: true,
@@ -47,10 +69,7 @@ func (c *AuthController) Register(w http.ResponseWriter, r *http.Request) {
 				CreatedAt: user.CreatedAt.String(),
 				Role:      user.Role,
 			},
-			Tokens: AuthTokensResponse{
-				RefreshToken: tokenPair.RefreshToken,
-				AccessToken:  tokenPair.AccessToken,
-			},
+			Tokens: authTokensResponse(r, tokenPair, false),
 		},
 	})
 }
@@ -63,6 +82,11 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	organizationID, ok := organizationIDFromRequest(w, body.OrganizationID)
+	if !ok {
+		return
+	}
+
 	// Obtain user and check password
 	user, err := c.authService.Login(r.Context(), body.Email, body.Password)
 	if err != nil {
@@ -70,19 +94,31 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
 			return
 		}
+		if errors.Is(err, service.ErrAccountInactive) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "login failed"})
 		return
 	}
 
 	// Generate token pair
-	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role)
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, organizationID)
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "not a member of the organization"})
+		return
+	}
 	if err != nil || tokenPair == nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to generate token pair"})
 		return
 	}
 
-	// Set refresh token cookie (for browser clients)
-	http.SetCookie(w, c.cookieService.CreateSetAuthCookie(tokenPair.RefreshToken))
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
 
 	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
 		Success: true,
@@ -95,10 +131,7 @@ func (c *AuthController) Login(w http.ResponseWriter, r *http.Request) {
 				CreatedAt: user.CreatedAt.String(),
 				Role:      user.Role,
 			},
-			Tokens: AuthTokensResponse{
-				RefreshToken: tokenPair.RefreshToken,
-				AccessToken:  tokenPair.AccessToken,
-			},
+			Tokens: authTokensResponse(r, tokenPair, false),
 		},
 	})
 }
@@ -111,14 +144,19 @@ func (c *AuthController) Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	refreshToken, _, ok := c.refreshTokenFromRequest(w, r, body.RefreshToken)
+	if !ok {
+		return
+	}
+
 	// Revoke the provided refresh token (idempotent)
-	if err := c.tokenService.Logout(r.Context(), body.RefreshToken); err != nil {
+	if err := c.tokenService.Logout(r.Context(), refreshToken); err != nil {
 		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to logout"})
 		return
 	}
 
-	// Clear refresh token cookie if present
-	http.SetCookie(w, c.cookieService.CreateClearAuthCookie())
+	// Clear refresh token and CSRF cookies if present
+	setCookies(w, cookiesFor(c.cookieProfiles, r).CreateClearAuthCookies())
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -131,17 +169,270 @@ func (c *AuthController) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newTokenPair, err := c.tokenService.Refresh(r.Context(), body.RefreshToken)
+	refreshToken, fromCookie, ok := c.refreshTokenFromRequest(w, r, body.RefreshToken)
+	if !ok {
+		return
+	}
+	organizationID, ok := organizationIDFromRequest(w, body.OrganizationID)
+	if !ok {
+		return
+	}
+
+	newTokenPair, err := c.tokenService.Refresh(r.Context(), refreshToken, organizationID)
+	if errors.Is(err, service.ErrAccountInactive) {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+		return
+	}
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "not a member of the organization"})
+		return
+	}
 	if err != nil || newTokenPair == nil {
 		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid or expired refresh token"})
 		return
 	}
 
+	// Rotate the cookies along with the token
+	setAuthCookies(w, r, c.cookieProfiles, newTokenPair.RefreshToken)
+
 	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
 		Success: true,
-		Data: AuthTokensResponse{
-			RefreshToken: newTokenPair.RefreshToken,
-			AccessToken:  newTokenPair.AccessToken,
+		Data:    authTokensResponse(r, newTokenPair, fromCookie),
+	})
+}
+
+// ChangePassword handler replaces the authenticated user's password and issues a fresh token pair,
+// revoking every other session.
+func (c *AuthController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[ChangePasswordRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	user, err := c.authService.ChangePassword(r.Context(), userID, body.CurrentPassword, body.NewPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountInactive) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+			return
+		}
+		if writePasswordRejected(w, "new_password", err) {
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		if errors.Is(err, service.ErrUserNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "user not found"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to change password"})
+		return
+	}
+
+	// Issuing a new pair revokes every existing refresh token for the user. The session keeps its
+	// organization, unless the user has left it since
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, organizationIDFromContext(r.Context()))
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		tokenPair, err = c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, nil)
+	}
+	if err != nil || tokenPair == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to generate token pair"})
+		return
+	}
+
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			Tokens: authTokensResponse(r, tokenPair, false),
+		},
+	})
+}
+
+// AcceptInvite handler sets an invited user's password and logs them in.
+func (c *AuthController) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[AcceptInviteRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	user, err := c.authService.AcceptInvite(r.Context(), body.Token, body.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidInvite) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invite is invalid, expired or already used"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountInactive) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+			return
+		}
+		if writePasswordRejected(w, "password", err) {
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to accept invite"})
+		return
+	}
+
+	organizationID := c.joinOrganization(r, user.ID, body.OrganizationInvite)
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, organizationID)
+	if err != nil || tokenPair == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to generate token pair"})
+		return
+	}
+
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			Tokens: authTokensResponse(r, tokenPair, false),
 		},
 	})
 }
+
+// refreshTokenFromRequest returns the refresh token from the request body or, failing that, the
+// refresh cookie, reporting which it was. A cookie is only accepted with an X-CSRF-Token header matching
+// the CSRF cookie. When ok is false an error response has been written.
+func (c *AuthController) refreshTokenFromRequest(w http.ResponseWriter, r *http.Request, bodyToken string) (token string, fromCookie, ok bool) {
+	if bodyToken != "" {
+		return bodyToken, false, true
+	}
+
+	cookie, err := r.Cookie(cookiesFor(c.cookieProfiles, r).RefreshCookieName())
+	if err != nil || cookie.Value == "" {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "refresh_token is required"})
+		return "", false, false
+	}
+
+	// Double-submit check: another site can make the browser send the cookies, but can't read the CSRF
+	// cookie to copy it into the header
+	csrfCookie, err := r.Cookie(cookiesFor(c.cookieProfiles, r).CSRFCookieName())
+	csrfHeader := r.Header.Get(CSRFTokenHeader)
+	if err != nil || csrfCookie.Value == "" || csrfHeader == "" ||
+		subtle.ConstantTimeCompare([]byte(csrfCookie.Value), []byte(csrfHeader)) != 1 {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "missing or invalid csrf token"})
+		return "", false, false
+	}
+	return cookie.Value, true, true
+}
+
+// organizationIDFromRequest parses the optional organization_id of a login or refresh request. When ok
+// is false an error response has been written.
+func organizationIDFromRequest(w http.ResponseWriter, raw string) (*uuid.UUID, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid organization id"})
+		return nil, false
+	}
+	return &id, true
+}
+
+// joinOrganization redeems the organization invite of a user who has just signed up, returning the
+// organization to start their session in. The account already exists by then, so an invite that can't
+// be redeemed doesn't fail the sign-up: the session starts without an organization, and the invite can
+// still be accepted later.
+func (c *AuthController) joinOrganization(r *http.Request, userID uuid.UUID, inviteToken string) *uuid.UUID {
+	if inviteToken == "" {
+		return nil
+	}
+	membership, err := c.organizationService.AcceptInvite(r.Context(), userID, inviteToken)
+	if err != nil {
+		log.Printf("couldn't redeem organization invite on sign-up: %v\n", err)
+		return nil
+	}
+	return &membership.OrganizationID
+}
+
+// setAuthCookies sets the refresh and CSRF cookies for a new refresh token. The CSRF token is also
+// returned in the X-CSRF-Token header for clients on another origin, which can't read the cookie.
+func setAuthCookies(w http.ResponseWriter, r *http.Request, profiles service.CookieProfiles, refreshToken string) {
+	csrfToken := rand.Text()
+	setCookies(w, cookiesFor(profiles, r).CreateSetAuthCookies(refreshToken, csrfToken))
+	w.Header().Set(CSRFTokenHeader, csrfToken)
+}
+
+// setCookies adds a Set-Cookie header for each cookie.
+func setCookies(w http.ResponseWriter, cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		http.SetCookie(w, cookie)
+	}
+}
+
+// authTokensResponse builds the tokens of an auth response. The refresh token is left out when it
+// came from the cookie or the client asked for cookie transport with X-Token-Transport: cookie, so
+// it never reaches scripts in the browser.
+func authTokensResponse(r *http.Request, pair *service.TokenPair, fromCookie bool) AuthTokensResponse {
+	resp := AuthTokensResponse{AccessToken: pair.AccessToken}
+	if !fromCookie && !strings.EqualFold(r.Header.Get(TokenTransportHeader), "cookie") {
+		resp.RefreshToken = pair.RefreshToken
+	}
+	return resp
+}
+
+// writePasswordRejected writes a 400 listing the password policy rules field failed, and reports
+// whether err was a password rejection at all.
+func writePasswordRejected(w http.ResponseWriter, field string, err error) bool {
+	var fieldErrors []FieldErrorResponse
+	var policyErr *service.PasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		for _, v := range policyErr.Violations {
+			fieldErrors = append(fieldErrors, FieldErrorResponse{Field: field, Rule: v.Rule, Message: v.Message})
+		}
+	case errors.Is(err, service.ErrBreachedPassword):
+		fieldErrors = append(fieldErrors, FieldErrorResponse{Field: field, Rule: service.PasswordRuleBreached, Message: "appears in a known data breach"})
+	default:
+		return false
+	}
+
+	requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{
+		Success: false,
+		Data:    FieldErrorsResponseData{Errors: fieldErrors},
+		Error:   "password does not meet the password policy",
+	})
+	return true
+}
+
+// writeServiceUnavailable tells the client to back off while password hashing is saturated.
+func writeServiceUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	requests.WriteJSON(w, http.StatusServiceUnavailable, requests.APIResponse{Success: false, Error: "service busy, please retry"})
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// CredentialController houses dependencies for endpoints managing how users sign in.
+type CredentialController struct {
+	credentialService service.CredentialService
+	cookieProfiles    service.CookieProfiles
+	stateTTL          time.Duration
+}
+
+// NewCredentialController constructs a CredentialController. stateTTL is how long a login started to
+// link an identity stays valid.
+func NewCredentialController(credentialService service.CredentialService, cookieProfiles service.CookieProfiles, stateTTL time.Duration) *CredentialController {
+	return &CredentialController{
+		credentialService: credentialService,
+		cookieProfiles:    cookieProfiles,
+		stateTTL:          stateTTL,
+	}
+}
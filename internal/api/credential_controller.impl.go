@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// ListCredentials handler lists the ways the authenticated user can sign in.
+func (c *CredentialController) ListCredentials(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	credentials, err := c.credentialService.List(r.Context(), userID)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list credentials"})
+		return
+	}
+
+	resp := CredentialListResponse{Credentials: make([]CredentialResponse, 0, len(credentials))}
+	for _, credential := range credentials {
+		resp.Credentials = append(resp.Credentials, newCredentialResponse(credential))
+	}
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    resp,
+	})
+}
+
+// AddPassword handler sets a password for the authenticated user, who must not have one yet.
+func (c *CredentialController) AddPassword(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[AddPasswordRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	err := c.credentialService.AddPassword(r.Context(), userID, reauthenticationFromRequest(r, ""), body.NewPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrPasswordAlreadySet) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "a password is already set; change it instead"})
+			return
+		}
+		if writePasswordRejected(w, "new_password", err) {
+			return
+		}
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to set password"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LinkIdentity handler starts a login with an identity provider that links the identity to the
+// authenticated user. The provider URL is returned rather than redirected to, since the request
+// carries the user's access token; the login state cookie is set as for a social login.
+func (c *CredentialController) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[LinkIdentityRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	authURL, state, err := c.credentialService.LinkIdentity(r.Context(), userID, reauthenticationFromRequest(r, body.Password), chi.URLParam(r, "provider"), body.Merge)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "unknown identity provider"})
+			return
+		}
+		if errors.Is(err, service.ErrProviderLogin) {
+			requests.WriteJSON(w, http.StatusBadGateway, requests.APIResponse{Success: false, Error: "identity provider is unavailable"})
+			return
+		}
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start linking"})
+		return
+	}
+
+	http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateSetLoginStateCookie(state, int(c.stateTTL.Seconds())))
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    LinkIdentityResponse{AuthorizationURL: authURL},
+	})
+}
+
+// RemoveCredential handler unlinks one of the authenticated user's credentials, refusing to remove
+// their last.
+func (c *CredentialController) RemoveCredential(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[RemoveCredentialRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	credentialID, err := uuid.Parse(chi.URLParam(r, "credentialID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid credential id"})
+		return
+	}
+
+	err = c.credentialService.Remove(r.Context(), userID, reauthenticationFromRequest(r, body.Password), credentialID)
+	if err != nil {
+		if errors.Is(err, service.ErrCredentialNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "credential not found"})
+			return
+		}
+		if errors.Is(err, service.ErrLastCredential) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "cannot remove the only way to sign in"})
+			return
+		}
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to remove credential"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MergeAccount handler merges another account that shares an email into the authenticated user's,
+// once signed in to with its username and password.
+func (c *CredentialController) MergeAccount(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[MergeAccountRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	mergedUserID, err := c.credentialService.MergeAccount(r.Context(), userID, reauthenticationFromRequest(r, body.Password), body.Username, body.AccountPassword)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMergeCredentials) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "invalid credentials for the account to merge"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountsDontShareEmail) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "the accounts share no email address"})
+			return
+		}
+		if errors.Is(err, service.ErrMergeSourceInactive) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "the account to merge is not active"})
+			return
+		}
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to merge accounts"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    MergedAccountResponse{MergedUserID: mergedUserID.String()},
+	})
+}
+
+// reauthenticationFromRequest builds the caller's proof of presence from password and the time their
+// session was authenticated, as carried by their access token.
+func reauthenticationFromRequest(r *http.Request, password string) service.Reauthentication {
+	proof := service.Reauthentication{Password: password}
+	if claims := claimsFromContext(r.Context()); claims != nil && claims.AuthTime != nil {
+		proof.AuthTime = claims.AuthTime.Time
+	}
+	return proof
+}
+
+// writeCredentialError writes the response for errors shared by the credential endpoints, reporting
+// whether err was one of them.
+func writeCredentialError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrReauthenticationRequired):
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "re-authentication required: provide your password or sign in again"})
+	case errors.Is(err, service.ErrInvalidCredentials):
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+	case errors.Is(err, service.ErrAccountInactive):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+	case errors.Is(err, service.ErrUserNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "user not found"})
+	case errors.Is(err, service.ErrHasherSaturated):
+		writeServiceUnavailable(w)
+	default:
+		return false
+	}
+	return true
+}
+
+func newCredentialResponse(credential *contracts.Credential) CredentialResponse {
+	return CredentialResponse{
+		ID:         credential.ID.String(),
+		Type:       credential.Type,
+		Provider:   credential.Provider,
+		Email:      credential.Email,
+		LastUsedAt: optionalTimeString(credential.LastUsedAt),
+		CreatedAt:  credential.CreatedAt.String(),
+	}
+}
@@ -1,18 +1,247 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/audit"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
 )
 
 // contextKey type for context keys to avoid collisions.
 type contextKey string
 
-const requestBodyKey contextKey = "requestBody"
+const (
+	requestBodyKey contextKey = "requestBody"
+	claimsKey      contextKey = "claims"
+	scimTenantKey  contextKey = "scimTenant"
+)
+
+// APIKeyHeader carries the shared API key other bids services authenticate with.
+const APIKeyHeader = "X-API-Key"
 
-// RegisterMiddleware attaches common middleware to the router.
-func RegisterMiddleware(r chi.Router) {
+// RegisterMiddleware attaches common middleware to the router. Forwarded client addresses are only
+// believed from trustedProxies.
+func RegisterMiddleware(r chi.Router, tokenService service.TokenService, trustedProxies []*net.IPNet) {
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(RealIP(trustedProxies))
 	r.Use(middleware.Recoverer)
+	r.Use(Authenticate(tokenService))
+	r.Use(AuditContext)
+}
+
+// Authenticate parses a bearer access token if one is supplied and stores its claims in the
+// request context. Requests without a valid token pass through unauthenticated, as do those with the
+// tokens of service accounts and personal access tokens, which are for the other bids services.
+func Authenticate(tokenService service.TokenService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			claims, err := tokenService.ParseAccessToken(token)
+			if err != nil || claims.SubjectType != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+		})
+	}
+}
+
+// AuditContext stores the caller, client address, user agent and request ID in the request context
+// for the audit events written while handling it. Authenticate and RealIP must run first.
+func AuditContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := audit.Request{
+			IP:        clientIP(r),
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+		}
+		if userID, ok := userIDFromContext(r.Context()); ok {
+			req.ActorID = &userID
+		}
+		next.ServeHTTP(w, r.WithContext(audit.WithRequest(r.Context(), req)))
+	})
+}
+
+// RealIP replaces the request's remote address with the client address a trusted proxy forwarded
+// in X-Forwarded-For or X-Real-IP. Headers on requests from anywhere else are ignored, as any caller
+// could set them. X-Forwarded-For is read from the right, skipping trusted proxies, so addresses a
+// client prepended are never used.
+func RealIP(trustedProxies []*net.IPNet) func(http.Handler) http.Handler {
+	trusted := func(addr string) bool {
+		ip := net.ParseIP(strings.TrimSpace(addr))
+		if ip == nil {
+			return false
+		}
+		for _, network := range trustedProxies {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trustedProxies) == 0 || !trusted(clientIP(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var forwarded []string
+			for _, header := range r.Header.Values("X-Forwarded-For") {
+				forwarded = append(forwarded, strings.Split(header, ",")...)
+			}
+			if len(forwarded) == 0 {
+				forwarded = r.Header.Values("X-Real-IP")
+			}
+			for i := len(forwarded) - 1; i >= 0; i-- {
+				addr := strings.TrimSpace(forwarded[i])
+				if net.ParseIP(addr) == nil {
+					break
+				}
+				r.RemoteAddr = addr
+				if !trusted(addr) {
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the request's remote address without the port. RealIP may already have replaced
+// it with a bare address from a proxy header.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RequireAuth rejects requests without a valid access token. Authenticate must run first.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if claimsFromContext(r.Context()) == nil {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePermission rejects requests that are not authenticated as a user whose role grants
+// permission. The role is looked up on every request rather than trusted from the access token, so
+// role changes and suspensions apply at once. Authenticate must run first.
+func RequirePermission(rbacService service.RBACService, permission string) func(http.Handler) http.Handler {
+	resource, action, _ := strings.Cut(permission, ":")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := userIDFromContext(r.Context())
+			if !ok {
+				requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+				return
+			}
+			decision, err := rbacService.Check(r.Context(), userID, resource, action)
+			if errors.Is(err, service.ErrUserNotFound) {
+				requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+				return
+			}
+			if err != nil {
+				requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to check permission"})
+				return
+			}
+			if !decision.Allowed {
+				requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "insufficient permissions"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAPIKey rejects requests that don't present key in the X-API-Key header.
+func RequireAPIKey(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented := r.Header.Get(APIKeyHeader)
+			if presented == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(key)) != 1 {
+				requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid api key"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireBearerToken rejects requests that don't present token as their bearer token.
+func RequireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			presented, ok := bearerToken(r)
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// claimsFromContext returns the access token claims stored by Authenticate, if any.
+func claimsFromContext(ctx context.Context) *service.AccessTokenClaims {
+	claims, _ := ctx.Value(claimsKey).(*service.AccessTokenClaims)
+	return claims
+}
+
+// userIDFromContext returns the authenticated user's ID from the access token subject.
+func userIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	claims := claimsFromContext(ctx)
+	if claims == nil {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// organizationIDFromContext returns the organization the authenticated session acts in, or nil when it
+// acts in none.
+func organizationIDFromContext(ctx context.Context) *uuid.UUID {
+	claims := claimsFromContext(ctx)
+	if claims == nil || claims.OrganizationID == "" {
+		return nil
+	}
+	id, err := uuid.Parse(claims.OrganizationID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
 }
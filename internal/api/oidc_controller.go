@@ -0,0 +1,28 @@
+package api
+
+import (
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// OIDCController houses dependencies for social login endpoints.
+type OIDCController struct {
+	socialLoginService service.SocialLoginService
+	tokenService       service.TokenService
+	cookieProfiles     service.CookieProfiles
+	stateTTL           time.Duration
+	loginRedirectURL   string
+}
+
+// NewOIDCController constructs an OIDCController. When loginRedirectURL is set, callbacks redirect
+// there instead of returning JSON.
+func NewOIDCController(socialLoginService service.SocialLoginService, tokenService service.TokenService, cookieProfiles service.CookieProfiles, stateTTL time.Duration, loginRedirectURL string) *OIDCController {
+	return &OIDCController{
+		socialLoginService: socialLoginService,
+		tokenService:       tokenService,
+		cookieProfiles:     cookieProfiles,
+		stateTTL:           stateTTL,
+		loginRedirectURL:   loginRedirectURL,
+	}
+}
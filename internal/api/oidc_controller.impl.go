@@ -0,0 +1,178 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+)
+
+// Providers handler lists the identity providers users can sign in with.
+func (c *OIDCController) Providers(w http.ResponseWriter, r *http.Request) {
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    OIDCProvidersResponse{Providers: c.socialLoginService.Providers()},
+	})
+}
+
+// Login handler starts a social login, redirecting the browser to the identity provider. The login
+// state is also kept in a cookie so the callback can only be completed by the browser that started it.
+// The organization_id query parameter optionally selects the organization the session acts in.
+func (c *OIDCController) Login(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := organizationIDFromRequest(w, r.URL.Query().Get("organization_id"))
+	if !ok {
+		return
+	}
+
+	authURL, state, err := c.socialLoginService.Begin(r.Context(), chi.URLParam(r, "provider"), organizationID)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "unknown identity provider"})
+			return
+		}
+		if errors.Is(err, service.ErrProviderLogin) {
+			requests.WriteJSON(w, http.StatusBadGateway, requests.APIResponse{Success: false, Error: "identity provider is unavailable"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start login"})
+		return
+	}
+
+	http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateSetLoginStateCookie(state, int(c.stateTTL.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// Callback handler completes a social login when the identity provider redirects back, and returns
+// both tokens like a password login. With a login redirect URL configured, the browser is sent there
+// with the tokens in cookies only, or with an error query parameter on failure.
+func (c *OIDCController) Callback(w http.ResponseWriter, r *http.Request) {
+	cookies := cookiesFor(c.cookieProfiles, r)
+	http.SetCookie(w, cookies.CreateClearLoginStateCookie())
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		// e.g. access_denied when the user cancels at the provider
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusUnauthorized, errCode, "identity provider login failed: "+errCode)
+		return
+	}
+
+	state := query.Get("state")
+	stateCookie, err := r.Cookie(cookies.LoginStateCookieName())
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(state)) != 1 {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "invalid_state", "login state is invalid or expired")
+		return
+	}
+
+	result, err := c.socialLoginService.Complete(r.Context(), chi.URLParam(r, "provider"), state, query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownProvider):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusNotFound, "unknown_provider", "unknown identity provider")
+		case errors.Is(err, service.ErrInvalidLoginState):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "invalid_state", "login state is invalid or expired")
+		case errors.Is(err, service.ErrProviderLogin):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusUnauthorized, "provider_error", "identity provider login failed")
+		case errors.Is(err, service.ErrIdentityEmailRequired):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusUnprocessableEntity, "email_required", err.Error())
+		case errors.Is(err, service.ErrIdentityEmailUnverified):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusUnprocessableEntity, "email_unverified", err.Error())
+		case errors.Is(err, service.ErrIdentityEmailInUse):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "email_in_use", err.Error())
+		case errors.Is(err, service.ErrUserExists):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "account_exists", "an account with this username or email already exists")
+		case errors.Is(err, service.ErrIdentityLinkedElsewhere):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "identity_in_use", err.Error())
+		case errors.Is(err, service.ErrMergeSourceInactive):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "merge_refused", err.Error())
+		case errors.Is(err, service.ErrAccountInactive), errors.Is(err, service.ErrUserNotFound):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "account_inactive", "account is not active")
+		default:
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusInternalServerError, "server_error", "login failed")
+		}
+		return
+	}
+
+	// A link was started by a user already signed in, so no new session is issued
+	if result.Linked {
+		c.writeLinked(w, r, result)
+		return
+	}
+
+	user := result.User
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, result.OrganizationID)
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "not_member", "not a member of the organization")
+		return
+	}
+	if err != nil || tokenPair == nil {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusInternalServerError, "server_error", "failed to generate token pair")
+		return
+	}
+
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
+
+	if c.loginRedirectURL != "" {
+		http.Redirect(w, r, c.loginRedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			Tokens: authTokensResponse(r, tokenPair, false),
+		},
+	})
+}
+
+// writeLinked reports a completed link: as a redirect to the login redirect URL carrying the provider
+// in the linked query parameter when one is configured, else as JSON.
+func (c *OIDCController) writeLinked(w http.ResponseWriter, r *http.Request, result *service.SocialLoginResult) {
+	provider := chi.URLParam(r, "provider")
+	if c.loginRedirectURL != "" {
+		if target, err := url.Parse(c.loginRedirectURL); err == nil {
+			q := target.Query()
+			q.Set("linked", provider)
+			target.RawQuery = q.Encode()
+			http.Redirect(w, r, target.String(), http.StatusSeeOther)
+			return
+		}
+	}
+
+	data := LinkedIdentityResponse{Provider: provider}
+	if result.MergedUserID != nil {
+		merged := result.MergedUserID.String()
+		data.MergedUserID = &merged
+	}
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{Success: true, Data: data})
+}
+
+// writeLoginError reports a failed external login: as a redirect to loginRedirectURL carrying code in
+// the error query parameter when one is configured, else as JSON.
+func writeLoginError(w http.ResponseWriter, r *http.Request, loginRedirectURL string, status int, code, message string) {
+	if loginRedirectURL == "" {
+		requests.WriteJSON(w, status, requests.APIResponse{Success: false, Error: message})
+		return
+	}
+	target, err := url.Parse(loginRedirectURL)
+	if err != nil {
+		requests.WriteJSON(w, status, requests.APIResponse{Success: false, Error: message})
+		return
+	}
+	q := target.Query()
+	q.Set("error", code)
+	target.RawQuery = q.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
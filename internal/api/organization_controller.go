@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// OrganizationController houses dependencies for organization, membership and invitation endpoints.
+type OrganizationController struct {
+	organizationService service.OrganizationService
+}
+
+// NewOrganizationController constructs an OrganizationController.
+func NewOrganizationController(organizationService service.OrganizationService) *OrganizationController {
+	return &OrganizationController{
+		organizationService: organizationService,
+	}
+}
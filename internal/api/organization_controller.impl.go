@@ -0,0 +1,388 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// ListOrganizations handler returns the organizations the caller belongs to, with their role in each.
+func (c *OrganizationController) ListOrganizations(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	memberships, err := c.organizationService.ListMemberships(r.Context(), userID)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list organizations"})
+		return
+	}
+
+	data := make([]OrganizationResponse, 0, len(memberships))
+	for _, m := range memberships {
+		data = append(data, newOrganizationResponse(m.Organization, m.Role))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreateOrganization handler creates an organization owned by the caller.
+func (c *OrganizationController) CreateOrganization(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreateOrganizationRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	org, err := c.organizationService.CreateOrganization(r.Context(), userID, body.Slug, body.Name)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to create organization")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    newOrganizationResponse(org, contracts.MembershipRoleOwner),
+	})
+}
+
+// GetOrganization handler returns an organization the caller belongs to.
+func (c *OrganizationController) GetOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	org, membership, err := c.organizationService.GetOrganization(r.Context(), userID, orgID)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to get organization")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newOrganizationResponse(org, membership.Role),
+	})
+}
+
+// UpdateOrganization handler changes an organization's slug and name.
+func (c *OrganizationController) UpdateOrganization(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[UpdateOrganizationRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	org, err := c.organizationService.UpdateOrganization(r.Context(), userID, orgID, body.Slug, body.Name)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to update organization")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newOrganizationResponse(org, ""),
+	})
+}
+
+// DeleteOrganization handler deletes an organization with its memberships and invites.
+func (c *OrganizationController) DeleteOrganization(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.organizationService.DeleteOrganization(r.Context(), userID, orgID); err != nil {
+		writeOrganizationError(w, err, "failed to delete organization")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListMembers handler returns an organization's members.
+func (c *OrganizationController) ListMembers(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	members, err := c.organizationService.ListMembers(r.Context(), userID, orgID)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to list members")
+		return
+	}
+
+	data := make([]MemberResponse, 0, len(members))
+	for _, m := range members {
+		data = append(data, newMemberResponse(m))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ChangeMemberRole handler changes a member's role in an organization.
+func (c *OrganizationController) ChangeMemberRole(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[ChangeMemberRoleRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := memberIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	membership, err := c.organizationService.ChangeMemberRole(r.Context(), userID, orgID, memberID, body.Role)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to change member role")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newMembershipResponse(membership),
+	})
+}
+
+// RemoveMember handler removes a member from an organization; members may remove themselves to leave.
+func (c *OrganizationController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+	memberID, ok := memberIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.organizationService.RemoveMember(r.Context(), userID, orgID, memberID); err != nil {
+		writeOrganizationError(w, err, "failed to remove member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListInvites handler returns an organization's pending invites.
+func (c *OrganizationController) ListInvites(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	invites, err := c.organizationService.ListInvites(r.Context(), userID, orgID)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to list invites")
+		return
+	}
+
+	data := make([]OrganizationInviteResponse, 0, len(invites))
+	for _, invite := range invites {
+		data = append(data, newOrganizationInviteResponse(invite))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// InviteMember handler emails an invitation to join an organization.
+func (c *OrganizationController) InviteMember(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[InviteMemberRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+
+	invite, sent, err := c.organizationService.InviteMember(r.Context(), userID, orgID, body.Email, body.Role)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to invite member")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: OrganizationInviteCreatedResponse{
+			Invite:     newOrganizationInviteResponse(invite),
+			InviteSent: sent,
+		},
+	})
+}
+
+// RevokeInvite handler revokes a pending invite.
+func (c *OrganizationController) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	userID, orgID, ok := organizationRequest(w, r)
+	if !ok {
+		return
+	}
+	inviteID, err := uuid.Parse(chi.URLParam(r, "inviteID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid invite id"})
+		return
+	}
+
+	if err := c.organizationService.RevokeInvite(r.Context(), userID, orgID, inviteID); err != nil {
+		writeOrganizationError(w, err, "failed to revoke invite")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite handler makes the caller a member of the organization they were invited to.
+func (c *OrganizationController) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[AcceptOrganizationInviteRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return
+	}
+
+	membership, err := c.organizationService.AcceptInvite(r.Context(), userID, body.Token)
+	if err != nil {
+		writeOrganizationError(w, err, "failed to accept invite")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newMembershipResponse(membership),
+	})
+}
+
+// requireUserID returns the caller's user ID, writing a 401 if the request isn't authenticated.
+func requireUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// organizationRequest returns the caller's user ID and the orgID path parameter, writing an error
+// response if either is missing or malformed.
+func organizationRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := requireUserID(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	orgID, err := uuid.Parse(chi.URLParam(r, "orgID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid organization id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, orgID, true
+}
+
+// memberIDParam parses the userID path parameter, writing a 400 if it is malformed.
+func memberIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid user id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeOrganizationError maps organization service errors to responses.
+func writeOrganizationError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrOrganizationNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "organization not found"})
+	case errors.Is(err, service.ErrMemberNotFound), errors.Is(err, service.ErrOrganizationInviteNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrInsufficientOrganizationRole):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "insufficient permissions"})
+	case errors.Is(err, service.ErrAccountInactive):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+	case errors.Is(err, service.ErrOrganizationExists), errors.Is(err, service.ErrAlreadyMember), errors.Is(err, service.ErrLastOwner):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidOrganizationSlug), errors.Is(err, service.ErrInvalidOrganizationName),
+		errors.Is(err, service.ErrInvalidMembershipRole), errors.Is(err, service.ErrInvalidOrganizationInvite):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: failure})
+	}
+}
+
+// newOrganizationResponse describes an organization, with the caller's role in it when known.
+func newOrganizationResponse(org *contracts.Organization, role string) OrganizationResponse {
+	return OrganizationResponse{
+		ID:        org.ID.String(),
+		Slug:      org.Slug,
+		Name:      org.Name,
+		Role:      role,
+		CreatedAt: org.CreatedAt.String(),
+		UpdatedAt: org.UpdatedAt.String(),
+	}
+}
+
+func newMemberResponse(m *contracts.Membership) MemberResponse {
+	return MemberResponse{
+		UserID:    m.UserID.String(),
+		Username:  m.Username,
+		Email:     m.Email,
+		Role:      m.Role,
+		JoinedAt:  m.CreatedAt.String(),
+		UpdatedAt: m.UpdatedAt.String(),
+	}
+}
+
+func newMembershipResponse(m *contracts.Membership) MembershipResponse {
+	return MembershipResponse{
+		OrganizationID: m.OrganizationID.String(),
+		UserID:         m.UserID.String(),
+		Role:           m.Role,
+		JoinedAt:       m.CreatedAt.String(),
+		UpdatedAt:      m.UpdatedAt.String(),
+	}
+}
+
+func newOrganizationInviteResponse(invite *contracts.OrganizationInvite) OrganizationInviteResponse {
+	var invitedBy *string
+	if invite.InvitedBy != nil {
+		id := invite.InvitedBy.String()
+		invitedBy = &id
+	}
+	return OrganizationInviteResponse{
+		ID:             invite.ID.String(),
+		OrganizationID: invite.OrganizationID.String(),
+		Email:          invite.Email,
+		Role:           invite.Role,
+		InvitedBy:      invitedBy,
+		CreatedAt:      invite.CreatedAt.String(),
+		ExpiresAt:      invite.ExpiresAt.String(),
+	}
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// PasskeyController houses dependencies for passkey registration and sign-in endpoints.
+type PasskeyController struct {
+	passkeyService service.PasskeyService
+	tokenService   service.TokenService
+	cookieProfiles service.CookieProfiles
+}
+
+// NewPasskeyController constructs a PasskeyController.
+func NewPasskeyController(passkeyService service.PasskeyService, tokenService service.TokenService, cookieProfiles service.CookieProfiles) *PasskeyController {
+	return &PasskeyController{
+		passkeyService: passkeyService,
+		tokenService:   tokenService,
+		cookieProfiles: cookieProfiles,
+	}
+}
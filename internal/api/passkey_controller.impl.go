@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// BeginRegistration handler starts registering a passkey for the authenticated user, returning the
+// options to create it with and the state to finish with.
+func (c *PasskeyController) BeginRegistration(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[AddPasskeyRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	options, state, err := c.passkeyService.BeginRegistration(r.Context(), userID, reauthenticationFromRequest(r, body.Password))
+	if err != nil {
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start passkey registration"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    PasskeyOptionsResponse{State: state, Options: options},
+	})
+}
+
+// FinishRegistration handler adds the passkey the authenticator created to the authenticated user's
+// credentials.
+func (c *PasskeyController) FinishRegistration(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[PasskeyResponseRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	passkey, err := c.passkeyService.FinishRegistration(r.Context(), userID, body.State, body.Credential)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLoginState) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "passkey registration is invalid or expired"})
+			return
+		}
+		if errors.Is(err, service.ErrPasskeyVerification) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "passkey verification failed"})
+			return
+		}
+		if errors.Is(err, service.ErrPasskeyExists) {
+			requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: "passkey is already registered"})
+			return
+		}
+		if writeCredentialError(w, err) {
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to register passkey"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: CredentialResponse{
+			ID:        passkey.ID.String(),
+			Type:      contracts.CredentialTypePasskey,
+			CreatedAt: passkey.CreatedAt.String(),
+		},
+	})
+}
+
+// BeginLogin handler starts a passkey sign-in, returning the options to request an assertion with and
+// the state to finish with. The organization_id query parameter optionally selects the organization
+// the session acts in.
+func (c *PasskeyController) BeginLogin(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := organizationIDFromRequest(w, r.URL.Query().Get("organization_id"))
+	if !ok {
+		return
+	}
+
+	options, state, err := c.passkeyService.BeginLogin(r.Context(), organizationID)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start login"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    PasskeyOptionsResponse{State: state, Options: options},
+	})
+}
+
+// FinishLogin handler verifies the authenticator's assertion and returns both tokens like a password
+// login.
+func (c *PasskeyController) FinishLogin(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[PasskeyResponseRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	result, err := c.passkeyService.FinishLogin(r.Context(), body.State, body.Credential)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidLoginState):
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "login state is invalid or expired"})
+		case errors.Is(err, service.ErrPasskeyVerification), errors.Is(err, service.ErrPasskeyCloned):
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+		case errors.Is(err, service.ErrAccountInactive), errors.Is(err, service.ErrUserNotFound):
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+		default:
+			requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "login failed"})
+		}
+		return
+	}
+
+	user := result.User
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, result.OrganizationID)
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "not a member of the organization"})
+		return
+	}
+	if err != nil || tokenPair == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to generate token pair"})
+		return
+	}
+
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			Tokens: authTokensResponse(r, tokenPair, false),
+		},
+	})
+}
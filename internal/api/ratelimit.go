@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// RateLimitStore records hits for sliding-window rate limiting. Implementations must be safe
+// for concurrent use; counters for a window should live for at least two windows.
+type RateLimitStore interface {
+	// Hit increments the counter for key in the window starting at windowStart and returns
+	// the hit counts of that window and the window immediately before it.
+	Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (current, previous int64, err error)
+}
+
+// RateLimitKeyFunc derives the identity a request is limited by (IP, user ID, client ID...).
+type RateLimitKeyFunc func(r *http.Request) string
+
+// RateLimitPolicy configures a single rate limit.
+type RateLimitPolicy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+	Key    RateLimitKeyFunc
+}
+
+// RouteRateLimits holds rate limiting middleware keyed by route name.
+type RouteRateLimits map[string]func(http.Handler) http.Handler
+
+// For returns the rate limiting middleware for a route, or a pass-through if none is configured.
+func (l RouteRateLimits) For(route string) func(http.Handler) http.Handler {
+	if mw, ok := l[route]; ok {
+		return mw
+	}
+	return func(next http.Handler) http.Handler { return next }
+}
+
+// RateLimit limits requests using a sliding window counter: the previous window's count is
+// weighted by how much of it still overlaps the sliding window and added to the current count.
+// Store errors fail open so an unavailable backend does not take the service down.
+func RateLimit(store RateLimitStore, policy RateLimitPolicy) func(http.Handler) http.Handler {
+	keyFunc := policy.Key
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	policyHeader := fmt.Sprintf("%d;w=%d", policy.Limit, int(policy.Window.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now()
+			windowStart := now.Truncate(policy.Window)
+			key := policy.Name + ":" + keyFunc(r)
+
+			current, previous, err := store.Hit(r.Context(), key, windowStart, policy.Window)
+			if err != nil {
+				log.Printf("rate limit store error for %s: %v\n", policy.Name, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			used, reset := slidingWindowUsage(now, windowStart, policy.Window, current, previous)
+			remaining := max(policy.Limit-used, 0)
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(reset))
+			w.Header().Set("RateLimit-Policy", policyHeader)
+
+			if used > policy.Limit {
+				w.Header().Set("Retry-After", strconv.Itoa(reset))
+				requests.WriteJSON(w, http.StatusTooManyRequests, requests.APIResponse{Success: false, Error: "too many requests"})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// slidingWindowUsage returns how many hits count against the limit at now, weighting the previous
+// window's hits by how much of it still overlaps the sliding window, and the seconds until the
+// current window ends.
+func slidingWindowUsage(now, windowStart time.Time, window time.Duration, current, previous int64) (used, reset int) {
+	elapsed := now.Sub(windowStart)
+	weight := 1 - float64(elapsed)/float64(window)
+	used = int(math.Ceil(float64(previous)*weight + float64(current)))
+	reset = int(math.Ceil((window - elapsed).Seconds()))
+	return used, reset
+}
+
+// KeyByIP limits by client IP. RealIP middleware must run first for proxied deployments.
+func KeyByIP(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// KeyByUserID limits by the authenticated user, falling back to the client IP.
+func KeyByUserID(r *http.Request) string {
+	if claims := claimsFromContext(r.Context()); claims != nil && claims.Subject != "" {
+		return "user:" + claims.Subject
+	}
+	return KeyByIP(r)
+}
+
+// KeyByClient limits by the authenticated caller, a SCIM tenant or user, falling back to the client
+// IP. Self-declared identities such as X-Client-ID are not used, as a caller could spread its
+// requests over made-up values.
+func KeyByClient(r *http.Request) string {
+	if tenant, _ := r.Context().Value(scimTenantKey).(string); tenant != "" {
+		return "scim:" + tenant
+	}
+	return KeyByUserID(r)
+}
+
+// KeyByClientID returns a key function limiting by the X-Client-ID header when it names one of
+// clientIDs, falling back to the client IP. Other IDs are treated as absent, so a caller can't
+// spread its requests over made-up values.
+func KeyByClientID(clientIDs []string) RateLimitKeyFunc {
+	known := make(map[string]bool, len(clientIDs))
+	for _, clientID := range clientIDs {
+		known[clientID] = true
+	}
+	return func(r *http.Request) string {
+		if clientID := r.Header.Get(ClientIDHeader); known[clientID] {
+			return "client_id:" + clientID
+		}
+		return KeyByIP(r)
+	}
+}
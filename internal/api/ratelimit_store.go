@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+// memoryRateLimitStore keeps counters in process memory; suitable for a single instance.
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+type memoryCounter struct {
+	windowStart time.Time
+	window      time.Duration
+	current     int64
+	previous    int64
+}
+
+// NewMemoryRateLimitStore creates an in-memory rate limit store.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{counters: make(map[string]*memoryCounter)}
+}
+
+func (s *memoryRateLimitStore) Hit(_ context.Context, key string, windowStart time.Time, window time.Duration) (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(windowStart)
+
+	c, ok := s.counters[key]
+	switch {
+	case !ok:
+		c = &memoryCounter{windowStart: windowStart, window: window}
+		s.counters[key] = c
+	case c.windowStart.Equal(windowStart):
+	case c.windowStart.Add(window).Equal(windowStart):
+		c.previous, c.current = c.current, 0
+		c.windowStart = windowStart
+	default:
+		c.previous, c.current = 0, 0
+		c.windowStart = windowStart
+	}
+	c.current++
+	return c.current, c.previous, nil
+}
+
+// sweep drops counters that are at least two windows old, at most once a minute.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, c := range s.counters {
+		if now.Sub(c.windowStart) >= 2*c.window {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// postgresRateLimitStore shares counters between instances through the database.
+type postgresRateLimitStore struct {
+	pool      *sql.DB
+	repo      repository.RateLimitRepository
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresRateLimitStore creates a rate limit store backed by the rate_limit_counters table.
+func NewPostgresRateLimitStore(pool *sql.DB, repo repository.RateLimitRepository) RateLimitStore {
+	return &postgresRateLimitStore{pool: pool, repo: repo}
+}
+
+func (s *postgresRateLimitStore) Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, int64, error) {
+	s.maybeSweep(ctx)
+	return s.repo.Hit(ctx, s.pool, key, windowStart, windowStart.Add(-window), windowStart.Add(2*window))
+}
+
+// maybeSweep deletes expired counters at most once a minute per instance.
+func (s *postgresRateLimitStore) maybeSweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	if err := s.repo.DeleteExpired(ctx, s.pool); err != nil {
+		log.Printf("couldn't delete expired rate limit counters: %v\n", err)
+	}
+}
+
+// redisRateLimitStore shares counters between instances through Redis.
+type redisRateLimitStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisRateLimitStore creates a rate limit store backed by Redis.
+func NewRedisRateLimitStore(client redis.UniversalClient) RateLimitStore {
+	return &redisRateLimitStore{client: client}
+}
+
+func (s *redisRateLimitStore) Hit(ctx context.Context, key string, windowStart time.Time, window time.Duration) (int64, int64, error) {
+	currentKey := fmt.Sprintf("ratelimit:%s:%d", key, windowStart.Unix())
+	previousKey := fmt.Sprintf("ratelimit:%s:%d", key, windowStart.Add(-window).Unix())
+
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, currentKey)
+	pipe.PExpire(ctx, currentKey, 2*window)
+	prev := pipe.Get(ctx, previousKey)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+
+	previous, err := prev.Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, 0, err
+	}
+	return incr.Val(), previous, nil
+}
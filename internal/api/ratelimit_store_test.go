@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimitStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) RateLimitStore{
+		"memory": func(t *testing.T) RateLimitStore {
+			return NewMemoryRateLimitStore()
+		},
+		"postgres": func(t *testing.T) RateLimitStore {
+			return NewPostgresRateLimitStore(nil, newFakeRateLimitRepository(t))
+		},
+		"redis": func(t *testing.T) RateLimitStore {
+			client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+			t.Cleanup(func() { client.Close() })
+			return NewRedisRateLimitStore(client)
+		},
+	}
+
+	const window = time.Minute
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	hits := []struct {
+		key          string
+		windows      int // windows after start
+		wantCurrent  int64
+		wantPrevious int64
+	}{
+		{key: "login:ip:a", windows: 0, wantCurrent: 1},
+		{key: "login:ip:a", windows: 0, wantCurrent: 2},
+		{key: "login:ip:b", windows: 0, wantCurrent: 1},
+		{key: "login:ip:a", windows: 1, wantCurrent: 1, wantPrevious: 2},
+		{key: "login:ip:a", windows: 1, wantCurrent: 2, wantPrevious: 2},
+		{key: "login:ip:b", windows: 1, wantCurrent: 1, wantPrevious: 1},
+		{key: "login:ip:a", windows: 3, wantCurrent: 1},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			for i, hit := range hits {
+				current, previous, err := store.Hit(context.Background(), hit.key, start.Add(time.Duration(hit.windows)*window), window)
+				if err != nil {
+					t.Fatalf("hit %d: %v", i+1, err)
+				}
+				if current != hit.wantCurrent || previous != hit.wantPrevious {
+					t.Errorf("hit %d on %s: got %d, %d, want %d, %d", i+1, hit.key, current, previous, hit.wantCurrent, hit.wantPrevious)
+				}
+			}
+		})
+	}
+}
+
+func TestRedisRateLimitStoreExpiresCounters(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisRateLimitStore(client)
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	if _, _, err := store.Hit(context.Background(), "login:ip:a", start, time.Minute); err != nil {
+		t.Fatalf("hit: %v", err)
+	}
+	key := fmt.Sprintf("ratelimit:login:ip:a:%d", start.Unix())
+	if ttl := mr.TTL(key); ttl != 2*time.Minute {
+		t.Errorf("TTL = %v, want %v", ttl, 2*time.Minute)
+	}
+	mr.FastForward(2 * time.Minute)
+	if mr.Exists(key) {
+		t.Error("counter outlived two windows")
+	}
+}
+
+// fakeRateLimitRepository keeps rate_limit_counters rows in memory, checking the windows the
+// Postgres store asks for.
+type fakeRateLimitRepository struct {
+	t    *testing.T
+	hits map[string]map[time.Time]int64
+}
+
+func newFakeRateLimitRepository(t *testing.T) *fakeRateLimitRepository {
+	return &fakeRateLimitRepository{t: t, hits: make(map[string]map[time.Time]int64)}
+}
+
+func (r *fakeRateLimitRepository) Hit(_ context.Context, _ *sql.DB, key string, windowStart, previousStart, expiresAt time.Time) (int64, int64, error) {
+	if window := windowStart.Sub(previousStart); expiresAt != windowStart.Add(2*window) {
+		r.t.Errorf("counter for %s expires at %v, want two windows after %v", key, expiresAt, windowStart)
+	}
+	if r.hits[key] == nil {
+		r.hits[key] = make(map[time.Time]int64)
+	}
+	r.hits[key][windowStart]++
+	return r.hits[key][windowStart], r.hits[key][previousStart], nil
+}
+
+func (r *fakeRateLimitRepository) DeleteExpired(context.Context, *sql.DB) error {
+	return nil
+}
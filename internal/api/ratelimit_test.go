@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowUsage(t *testing.T) {
+	windowStart := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		current   int64
+		previous  int64
+		wantUsed  int
+		wantReset int
+	}{
+		{name: "window start counts all of the previous window", current: 1, previous: 10, wantUsed: 11, wantReset: 60},
+		{name: "halfway counts half", elapsed: 30 * time.Second, current: 1, previous: 10, wantUsed: 6, wantReset: 30},
+		{name: "fractions round up", elapsed: 45 * time.Second, current: 1, previous: 10, wantUsed: 4, wantReset: 15},
+		{name: "window end", elapsed: 59500 * time.Millisecond, current: 1, previous: 10, wantUsed: 2, wantReset: 1},
+		{name: "no previous window", elapsed: 10 * time.Second, current: 3, wantUsed: 3, wantReset: 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used, reset := slidingWindowUsage(windowStart.Add(tt.elapsed), windowStart, time.Minute, tt.current, tt.previous)
+			if used != tt.wantUsed || reset != tt.wantReset {
+				t.Errorf("slidingWindowUsage() = %d, %d, want %d, %d", used, reset, tt.wantUsed, tt.wantReset)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	// A day-long window keeps the requests below in a single window
+	limit := RateLimit(NewMemoryRateLimitStore(), RateLimitPolicy{Name: "login", Limit: 2, Window: 24 * time.Hour})
+	handler := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i, want := range []struct {
+		status    int
+		remaining string
+	}{
+		{http.StatusNoContent, "1"},
+		{http.StatusNoContent, "0"},
+		{http.StatusTooManyRequests, "0"},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+		if rec.Code != want.status {
+			t.Fatalf("request %d: status = %d, want %d", i+1, rec.Code, want.status)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: RateLimit-Remaining = %q, want %q", i+1, got, want.remaining)
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=86400" {
+			t.Errorf("request %d: RateLimit-Policy = %q", i+1, got)
+		}
+		if got := rec.Header().Get("Retry-After"); (got != "") != (want.status == http.StatusTooManyRequests) {
+			t.Errorf("request %d: Retry-After = %q", i+1, got)
+		}
+	}
+
+	// Other clients have their own counters
+	req := httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("other client: status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	limit := RateLimit(failingRateLimitStore{}, RateLimitPolicy{Name: "login", Limit: 1, Window: time.Minute})
+	handler := limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/auth/login", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Hit(context.Context, string, time.Time, time.Duration) (int64, int64, error) {
+	return 0, 0, context.DeadlineExceeded
+}
+
+func TestKeyByClientID(t *testing.T) {
+	keyFunc := KeyByClientID([]string{"web", "mobile"})
+	tests := []struct {
+		name     string
+		clientID string
+		want     string
+	}{
+		{name: "configured client", clientID: "web", want: "client_id:web"},
+		{name: "unknown client falls back to the IP", clientID: "made-up", want: "ip:192.0.2.1"},
+		{name: "no client falls back to the IP", want: "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+			if tt.clientID != "" {
+				req.Header.Set(ClientIDHeader, tt.clientID)
+			}
+			if got := keyFunc(req); got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// RBACController houses dependencies for role, permission and authorization check endpoints.
+type RBACController struct {
+	rbacService service.RBACService
+}
+
+// NewRBACController constructs an RBACController.
+func NewRBACController(rbacService service.RBACService) *RBACController {
+	return &RBACController{
+		rbacService: rbacService,
+	}
+}
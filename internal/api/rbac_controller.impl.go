@@ -0,0 +1,263 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// ListRoles handler returns every role with the permissions it grants.
+func (c *RBACController) ListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := c.rbacService.ListRoles(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list roles"})
+		return
+	}
+
+	data := make([]RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		data = append(data, newRoleResponse(role))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreateRole handler creates a role granting existing permissions.
+func (c *RBACController) CreateRole(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreateRoleRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	role, err := c.rbacService.CreateRole(r.Context(), body.Name, body.Description, body.Permissions)
+	if err != nil {
+		writeRBACError(w, err, "failed to create role")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    newRoleResponse(role),
+	})
+}
+
+// GetRole handler returns a single role.
+func (c *RBACController) GetRole(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathNameParam(w, r, "role")
+	if !ok {
+		return
+	}
+
+	role, err := c.rbacService.GetRole(r.Context(), name)
+	writeRole(w, role, err, "failed to get role")
+}
+
+// UpdateRole handler changes a role's description and replaces the permissions it grants.
+func (c *RBACController) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[UpdateRoleRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	if body.Permissions == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "permissions is required"})
+		return
+	}
+	name, ok := pathNameParam(w, r, "role")
+	if !ok {
+		return
+	}
+
+	role, err := c.rbacService.UpdateRole(r.Context(), name, body.Description, body.Permissions)
+	writeRole(w, role, err, "failed to update role")
+}
+
+// DeleteRole handler removes a role nobody holds.
+func (c *RBACController) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathNameParam(w, r, "role")
+	if !ok {
+		return
+	}
+
+	if err := c.rbacService.DeleteRole(r.Context(), name); err != nil {
+		writeRBACError(w, err, "failed to delete role")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPermissions handler returns every permission.
+func (c *RBACController) ListPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions, err := c.rbacService.ListPermissions(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list permissions"})
+		return
+	}
+
+	data := make([]PermissionResponse, 0, len(permissions))
+	for _, permission := range permissions {
+		data = append(data, newPermissionResponse(permission))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreatePermission handler defines a permission roles can grant.
+func (c *RBACController) CreatePermission(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreatePermissionRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	permission, err := c.rbacService.CreatePermission(r.Context(), body.Name, body.Description)
+	if err != nil {
+		writeRBACError(w, err, "failed to create permission")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    newPermissionResponse(permission),
+	})
+}
+
+// DeletePermission handler removes a permission and revokes it from every role.
+func (c *RBACController) DeletePermission(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathNameParam(w, r, "permission")
+	if !ok {
+		return
+	}
+
+	if err := c.rbacService.DeletePermission(r.Context(), name); err != nil {
+		writeRBACError(w, err, "failed to delete permission")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UserPermissions handler returns the permissions a user holds through their role.
+func (c *RBACController) UserPermissions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	effective, err := c.rbacService.UserPermissions(r.Context(), userID)
+	if err != nil {
+		writeRBACError(w, err, "failed to get permissions")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: UserPermissionsResponse{
+			UserID:      effective.UserID.String(),
+			Role:        effective.Role,
+			Permissions: effective.Permissions,
+		},
+	})
+}
+
+// Check handler answers whether a user or service account may perform an action on a resource, for
+// other services.
+// Denials are reported in the body with a 200, not as an error.
+func (c *RBACController) Check(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[AuthzCheckRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	subject, err := uuid.Parse(body.Subject)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid subject"})
+		return
+	}
+
+	decision, err := c.rbacService.Check(r.Context(), subject, body.Resource, body.Action)
+	if err != nil {
+		writeRBACError(w, err, "failed to check permission")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthzCheckResponse{
+			Allowed:    decision.Allowed,
+			Role:       decision.Role,
+			Permission: decision.Permission,
+		},
+	})
+}
+
+// pathNameParam returns the named path parameter, unescaped, writing a 400 if it is malformed.
+// Permission names contain colons, which some clients percent-encode.
+func pathNameParam(w http.ResponseWriter, r *http.Request, key string) (string, bool) {
+	name, err := url.PathUnescape(chi.URLParam(r, key))
+	if err != nil || name == "" {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid " + key})
+		return "", false
+	}
+	return name, true
+}
+
+// writeRole writes the role resulting from an operation, or the operation's error.
+func writeRole(w http.ResponseWriter, role *contracts.Role, err error, failure string) {
+	if err != nil {
+		writeRBACError(w, err, failure)
+		return
+	}
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newRoleResponse(role),
+	})
+}
+
+// writeRBACError maps role and permission service errors to responses.
+func writeRBACError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrPermissionNotFound), errors.Is(err, service.ErrUserNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrRoleExists), errors.Is(err, service.ErrPermissionExists),
+		errors.Is(err, service.ErrBuiltinRole), errors.Is(err, service.ErrRoleInUse),
+		errors.Is(err, service.ErrLastAdminRole), errors.Is(err, service.ErrProvisioningRole):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidRoleName), errors.Is(err, service.ErrInvalidPermission), errors.Is(err, service.ErrUnknownPermission):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: failure})
+	}
+}
+
+func newRoleResponse(role *contracts.Role) RoleResponse {
+	return RoleResponse{
+		Name:        role.Name,
+		Description: role.Description,
+		Builtin:     role.Builtin,
+		Permissions: role.Permissions,
+		CreatedAt:   role.CreatedAt.String(),
+		UpdatedAt:   role.UpdatedAt.String(),
+	}
+}
+
+func newPermissionResponse(permission *contracts.Permission) PermissionResponse {
+	return PermissionResponse{
+		Name:        permission.Name,
+		Description: permission.Description,
+		CreatedAt:   permission.CreatedAt.String(),
+	}
+}
@@ -1,30 +1,241 @@
 package api
 
-// RegisterRequest represents the request body for user registration.
+import "encoding/json"
+
+// RegisterRequest represents the request body for user registration. Role must be one of the
+// registration roles. OrganizationInvite optionally redeems an organization invitation, starting the
+// session in that organization.
 type RegisterRequest struct {
-	Username string `json:"username" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,password"`
-	Role     string `json:"role" validate:"required,role"`
+	Username           string `json:"username" validate:"required"`
+	Email              string `json:"email" validate:"required,email"`
+	Password           string `json:"password" validate:"required"`
+	Role               string `json:"role" validate:"required"`
+	OrganizationInvite string `json:"organization_invite"`
 }
 
-// LoginRequest represents the request body for user login.
+// LoginRequest represents the request body for user login. OrganizationID optionally selects the
+// organization the session acts in.
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required"`
-	Password string `json:"password" validate:"required"`
+	Email          string `json:"email" validate:"required"`
+	Password       string `json:"password" validate:"required"`
+	OrganizationID string `json:"organization_id"`
 }
 
-// LogoutRequest represents the request body for user logout.
+// LogoutRequest represents the request body for user logout. RefreshToken may be omitted when the
+// refresh cookie is sent with an X-CSRF-Token header.
 type LogoutRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"`
+	RefreshToken string `json:"refresh_token"`
 }
 
-// RefreshRequest represents the request body for token refresh.
+// RefreshRequest represents the request body for token refresh. RefreshToken may be omitted when the
+// refresh cookie is sent with an X-CSRF-Token header. OrganizationID optionally switches the
+// organization the session acts in.
 type RefreshRequest struct {
-	RefreshToken string `json:"refresh_token" validate:"required"`
+	RefreshToken   string `json:"refresh_token"`
+	OrganizationID string `json:"organization_id"`
 }
 
 // InvalidateRefreshTokenRequest represents the request body for invalidating a refresh token.
 type InvalidateRefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
+
+// ChangePasswordRequest represents the request body for changing the authenticated user's password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// DeleteAccountRequest represents the request body for deleting the authenticated user's account.
+// Password may be omitted by a session authenticated within REAUTH_MAX_AGE.
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// CancelDeletionRequest represents the request body for cancelling a pending account deletion.
+type CancelDeletionRequest struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// SuspendUserRequest represents the request body for suspending a user.
+type SuspendUserRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// CreateUserRequest represents the request body for an admin creating a user. At least one of
+// Password and SendInvite must be given.
+type CreateUserRequest struct {
+	Username   string `json:"username" validate:"required"`
+	Email      string `json:"email" validate:"required,email"`
+	Role       string `json:"role" validate:"required"`
+	Password   string `json:"password"`
+	SendInvite bool   `json:"send_invite"`
+}
+
+// UpdateUserRequest represents the request body for an admin updating a user's profile.
+type UpdateUserRequest struct {
+	Username string `json:"username" validate:"required"`
+	Email    string `json:"email" validate:"required,email"`
+}
+
+// ChangeRoleRequest represents the request body for an admin changing a user's role.
+type ChangeRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// AcceptInviteRequest represents the request body for redeeming an invitation. OrganizationInvite
+// optionally redeems an organization invitation too, like RegisterRequest.
+type AcceptInviteRequest struct {
+	Token              string `json:"token" validate:"required"`
+	Password           string `json:"password" validate:"required"`
+	OrganizationInvite string `json:"organization_invite"`
+}
+
+// CreateWebhookRequest represents the request body for registering a webhook subscription. An empty
+// EventTypes subscribes to every event type.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+}
+
+// UpdateWebhookRequest represents the request body for replacing a webhook subscription's settings.
+type UpdateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+// IssueSCIMTokenRequest represents the request body for issuing a SCIM token to a tenant.
+type IssueSCIMTokenRequest struct {
+	Tenant      string `json:"tenant" validate:"required"`
+	Description string `json:"description"`
+}
+
+// CreatePersonalAccessTokenRequest represents the request body for creating a personal access token.
+// ExpiresAt is an RFC 3339 time; the default lifetime applies when it is empty.
+type CreatePersonalAccessTokenRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+// IntrospectTokenRequest represents the request body for introspecting an access token or personal
+// access token.
+type IntrospectTokenRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// CreateServiceAccountRequest represents the request body for creating a service account. The
+// default service account role applies when Role is empty.
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Role        string `json:"role"`
+}
+
+// ServiceAccountTokenRequest represents the request body for exchanging a service account API key
+// for an access token.
+type ServiceAccountTokenRequest struct {
+	APIKey string `json:"api_key" validate:"required"`
+}
+
+// CreateOrganizationRequest represents the request body for creating an organization.
+type CreateOrganizationRequest struct {
+	Slug string `json:"slug" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+// UpdateOrganizationRequest represents the request body for changing an organization's slug and name.
+type UpdateOrganizationRequest struct {
+	Slug string `json:"slug" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+// ChangeMemberRoleRequest represents the request body for changing a member's role in an organization.
+type ChangeMemberRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// InviteMemberRequest represents the request body for inviting someone to join an organization.
+type InviteMemberRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required"`
+}
+
+// AcceptOrganizationInviteRequest represents the request body for redeeming an organization invitation.
+type AcceptOrganizationInviteRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// CreateRoleRequest represents the request body for creating a role granting existing permissions.
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest represents the request body for changing a role's description and replacing the
+// permissions it grants.
+type UpdateRoleRequest struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// CreatePermissionRequest represents the request body for defining a permission.
+type CreatePermissionRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+}
+
+// AuthzCheckRequest represents the request body for asking whether a user or service account may
+// perform an action on a resource.
+type AuthzCheckRequest struct {
+	Subject  string `json:"subject" validate:"required"`
+	Resource string `json:"resource" validate:"required"`
+	Action   string `json:"action" validate:"required"`
+}
+
+// AddPasswordRequest represents the request body for setting a password on an account that signs in
+// another way. The session must have been authenticated recently.
+type AddPasswordRequest struct {
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+// LinkIdentityRequest represents the request body for linking an identity provider login to the
+// authenticated user. Password re-authenticates a session that wasn't authenticated recently; Merge
+// allows merging in another account the identity already belongs to.
+type LinkIdentityRequest struct {
+	Password string `json:"password"`
+	Merge    bool   `json:"merge"`
+}
+
+// RemoveCredentialRequest represents the request body for removing one of the authenticated user's
+// credentials. Password re-authenticates a session that wasn't authenticated recently.
+type RemoveCredentialRequest struct {
+	Password string `json:"password"`
+}
+
+// AddPasskeyRequest represents the request body for starting to register a passkey for the
+// authenticated user. Password re-authenticates a session that wasn't authenticated recently.
+type AddPasskeyRequest struct {
+	Password string `json:"password"`
+}
+
+// PasskeyResponseRequest represents the request body finishing a passkey registration or sign-in:
+// the state returned when it began and the authenticator's response, the PublicKeyCredential as JSON.
+type PasskeyResponseRequest struct {
+	State      string          `json:"state" validate:"required"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// MergeAccountRequest represents the request body for merging another account that shares an email
+// into the authenticated user's. Username and AccountPassword sign in to the other account; Password
+// re-authenticates a session that wasn't authenticated recently.
+type MergeAccountRequest struct {
+	Password        string `json:"password"`
+	Username        string `json:"username" validate:"required"`
+	AccountPassword string `json:"account_password" validate:"required"`
+}
@@ -1,5 +1,7 @@
 package api
 
+import "encoding/json"
+
 type HealthServiceStatusResponse struct {
 	Status string `json:"status"`
 	Error  string `json:"error,omitempty"`
@@ -7,6 +9,21 @@ type HealthServiceStatusResponse struct {
 
 type HealthResponseData map[string]HealthServiceStatusResponse
 
+// JSONWebKeySetResponse is a JSON Web Key Set (RFC 7517), served bare rather than in requests.APIResponse
+// for JOSE libraries to read.
+type JSONWebKeySetResponse struct {
+	Keys []JSONWebKeyResponse `json:"keys"`
+}
+
+type JSONWebKeyResponse struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
 type AuthUserResponse struct {
 	ID        string `json:"id"`
 	Username  string `json:"username"`
@@ -16,8 +33,217 @@ type AuthUserResponse struct {
 	Role      string `json:"role"`
 }
 
+type AdminUserResponse struct {
+	ID                  string  `json:"id"`
+	Username            string  `json:"username"`
+	Email               string  `json:"email"`
+	Role                string  `json:"role"`
+	Status              string  `json:"status"`
+	StatusReason        *string `json:"status_reason,omitempty"`
+	StatusChangedAt     *string `json:"status_changed_at,omitempty"`
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
+	UpdatedAt           string  `json:"updated_at"`
+	CreatedAt           string  `json:"created_at"`
+}
+
+type AdminUserListResponse struct {
+	Users      []AdminUserResponse `json:"users"`
+	Total      int64               `json:"total"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type AdminCreateUserResponse struct {
+	User       AdminUserResponse `json:"user"`
+	InviteSent bool              `json:"invite_sent"`
+}
+
+type AuthEventResponse struct {
+	ID         int64          `json:"id"`
+	Type       string         `json:"type"`
+	ActorID    *string        `json:"actor_id,omitempty"`
+	SubjectID  *string        `json:"subject_id,omitempty"`
+	IP         string         `json:"ip,omitempty"`
+	UserAgent  string         `json:"user_agent,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	OccurredAt string         `json:"occurred_at"`
+}
+
+type AuthEventListResponse struct {
+	Events     []AuthEventResponse `json:"events"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+type WebhookResponse struct {
+	ID          string   `json:"id"`
+	URL         string   `json:"url"`
+	EventTypes  []string `json:"event_types"`
+	Description string   `json:"description"`
+	Active      bool     `json:"active"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type WebhookSecretResponse struct {
+	Webhook WebhookResponse `json:"webhook"`
+	Secret  string          `json:"secret"`
+}
+
+type WebhookDeadLetterResponse struct {
+	ID         int64           `json:"id"`
+	WebhookID  string          `json:"webhook_id"`
+	EventID    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	Payload    json.RawMessage `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	LastStatus *int            `json:"last_status,omitempty"`
+	LastError  *string         `json:"last_error,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+	FailedAt   string          `json:"failed_at"`
+}
+
+type WebhookDeadLetterListResponse struct {
+	DeadLetters []WebhookDeadLetterResponse `json:"dead_letters"`
+	NextCursor  string                      `json:"next_cursor,omitempty"`
+}
+
+type OrganizationResponse struct {
+	ID        string `json:"id"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	Role      string `json:"role,omitempty"` // the caller's role in the organization
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type MemberResponse struct {
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	JoinedAt  string `json:"joined_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type MembershipResponse struct {
+	OrganizationID string `json:"organization_id"`
+	UserID         string `json:"user_id"`
+	Role           string `json:"role"`
+	JoinedAt       string `json:"joined_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+type OrganizationInviteResponse struct {
+	ID             string  `json:"id"`
+	OrganizationID string  `json:"organization_id"`
+	Email          string  `json:"email"`
+	Role           string  `json:"role"`
+	InvitedBy      *string `json:"invited_by,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+	ExpiresAt      string  `json:"expires_at"`
+}
+
+type OrganizationInviteCreatedResponse struct {
+	Invite     OrganizationInviteResponse `json:"invite"`
+	InviteSent bool                       `json:"invite_sent"`
+}
+
+type RoleResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Builtin     bool     `json:"builtin"`
+	Permissions []string `json:"permissions"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type PermissionResponse struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
+}
+
+type UserPermissionsResponse struct {
+	UserID      string   `json:"user_id"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
+}
+
+type AuthzCheckResponse struct {
+	Allowed    bool   `json:"allowed"`
+	Role       string `json:"role"`
+	Permission string `json:"permission,omitempty"` // the granted permission that allowed the action
+}
+
+type SCIMTokenResponse struct {
+	ID          string  `json:"id"`
+	Tenant      string  `json:"tenant"`
+	Description string  `json:"description"`
+	CreatedAt   string  `json:"created_at"`
+	LastUsedAt  *string `json:"last_used_at,omitempty"`
+}
+
+type IssuedSCIMTokenResponse struct {
+	SCIMToken SCIMTokenResponse `json:"scim_token"`
+	Token     string            `json:"token"`
+}
+
+type PersonalAccessTokenResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"created_at"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+}
+
+type CreatedPersonalAccessTokenResponse struct {
+	PersonalAccessToken PersonalAccessTokenResponse `json:"personal_access_token"`
+	Token               string                      `json:"token"`
+}
+
+type ServiceAccountResponse struct {
+	ID             string                      `json:"id"`
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description"`
+	Role           string                      `json:"role"`
+	OrganizationID *string                     `json:"organization_id,omitempty"`
+	CreatedBy      *string                     `json:"created_by,omitempty"`
+	CreatedAt      string                      `json:"created_at"`
+	UpdatedAt      string                      `json:"updated_at"`
+	Keys           []ServiceAccountKeyResponse `json:"keys,omitempty"` // only when getting a single account
+}
+
+type ServiceAccountKeyResponse struct {
+	ID         string  `json:"id"`
+	Prefix     string  `json:"prefix"`
+	CreatedAt  string  `json:"created_at"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
+}
+
+type CreatedServiceAccountKeyResponse struct {
+	ServiceAccountKey ServiceAccountKeyResponse `json:"service_account_key"`
+	APIKey            string                    `json:"api_key"`
+}
+
+// IntrospectionResponse follows the fields of an OAuth 2.0 token introspection response (RFC 7662).
+// Only active is set for tokens that aren't active.
+type IntrospectionResponse struct {
+	Active         bool   `json:"active"`
+	TokenType      string `json:"token_type,omitempty"`
+	Subject        string `json:"sub,omitempty"`
+	Username       string `json:"username,omitempty"`
+	Role           string `json:"role,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+	SessionID      string `json:"sid,omitempty"`
+	OrganizationID string `json:"org_id,omitempty"`
+	SubjectType    string `json:"sub_type,omitempty"`
+	IssuedAt       int64  `json:"iat,omitempty"`
+	ExpiresAt      int64  `json:"exp,omitempty"`
+}
+
 type AuthTokensResponse struct {
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token,omitempty"` // omitted for cookie transport
 	AccessToken  string `json:"access_token"`
 }
 
@@ -25,3 +251,72 @@ type AuthResponseData struct {
 	User   AuthUserResponse   `json:"user"`
 	Tokens AuthTokensResponse `json:"tokens"`
 }
+
+type SessionResponseData struct {
+	User      AuthUserResponse `json:"user"`
+	ExpiresAt string           `json:"expires_at"`
+}
+
+type SessionTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresAt   string `json:"expires_at"`
+}
+
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type SAMLConnectionsResponse struct {
+	Connections []string `json:"connections"`
+}
+
+// PasskeyOptionsResponse carries the options to pass to navigator.credentials and the state to send
+// back with the authenticator's response.
+type PasskeyOptionsResponse struct {
+	State   string          `json:"state"`
+	Options json.RawMessage `json:"options"`
+}
+
+type LinkedIdentityResponse struct {
+	Provider     string  `json:"linked"`
+	MergedUserID *string `json:"merged_user_id,omitempty"`
+}
+
+type CredentialResponse struct {
+	ID         string  `json:"id"`
+	Type       string  `json:"type"`
+	Provider   *string `json:"provider,omitempty"`
+	Email      *string `json:"email,omitempty"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+type CredentialListResponse struct {
+	Credentials []CredentialResponse `json:"credentials"`
+}
+
+type LinkIdentityResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type MergedAccountResponse struct {
+	MergedUserID string `json:"merged_user_id"`
+}
+
+type PepperReportResponse struct {
+	PepperID    *int  `json:"pepper_id"`
+	Credentials int64 `json:"credentials"`
+	Current     bool  `json:"current"`
+	Configured  bool  `json:"configured"`
+}
+
+// FieldErrorResponse describes why a single request field was rejected.
+type FieldErrorResponse struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type FieldErrorsResponseData struct {
+	Errors []FieldErrorResponse `json:"errors"`
+}
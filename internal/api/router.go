@@ -1,30 +1,68 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"maps"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
 
+	"github.com/LittleAksMax/bids-auth-service/internal/breach"
 	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/events"
 	"github.com/LittleAksMax/bids-auth-service/internal/health"
+	"github.com/LittleAksMax/bids-auth-service/internal/mail"
+	"github.com/LittleAksMax/bids-auth-service/internal/oidc"
+	"github.com/LittleAksMax/bids-auth-service/internal/passkey"
 	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/LittleAksMax/bids-auth-service/internal/saml"
 	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/passwords"
 	"github.com/LittleAksMax/bids-util/requests"
 )
 
+// rateLimitKeyFunc maps the key a rate limit rule names to how it identifies callers. Client IDs
+// are those with a cookie profile.
+func rateLimitKeyFunc(key string, cfg *config.Config) RateLimitKeyFunc {
+	switch key {
+	case config.RateLimitKeyUser:
+		return KeyByUserID
+	case config.RateLimitKeyClient:
+		return KeyByClient
+	case config.RateLimitKeyClientID:
+		return KeyByClientID(slices.Collect(maps.Keys(cfg.CookieProfiles)))
+	default:
+		return KeyByIP
+	}
+}
+
 // NewRouter constructs the main API router by wiring middleware and routes defined elsewhere.
-func NewRouter(pool *sql.DB, cfg *config.Config, secureMode bool) http.Handler {
+// Background work runs until ctx is cancelled, and is tracked by background so callers can wait
+// for it to stop.
+func NewRouter(ctx context.Context, background *sync.WaitGroup, pool *sql.DB, cfg *config.Config) (http.Handler, error) {
 	r := chi.NewRouter()
 
-	RegisterMiddleware(r)
-	
+	runInBackground := func(run func(context.Context, time.Duration), interval time.Duration) {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx, interval)
+		}()
+	}
+
 	requests.ApplyCORS(
 		r,
 		cfg.AllowedOrigins,
-		[]string{"GET", "POST", "PUT", "DELETE"},
-		[]string{"Accept", "Authorization", "Content-Type", "X-Auth-Claims", "X-Auth-Ts", "X-Auth-Sig"},
-		[]string{"Set-Cookie"},
+		[]string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		[]string{"Accept", "Authorization", "Content-Type", "X-Auth-Claims", "X-Auth-Ts", "X-Auth-Sig", ClientIDHeader, CSRFTokenHeader, TokenTransportHeader},
+		[]string{"Set-Cookie", CSRFTokenHeader, "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		true,
 		300,
 	)
@@ -32,34 +70,283 @@ func NewRouter(pool *sql.DB, cfg *config.Config, secureMode bool) http.Handler {
 	// Initialise authentication layers
 	userRepo := repository.NewUserRepository()
 	credRepo := repository.NewPasswordCredentialRepository()
-	authService := service.NewAuthService(pool, userRepo, credRepo, cfg.PasswordPepper)
+	historyRepo := repository.NewPasswordHistoryRepository()
+	inviteRepo := repository.NewUserInviteRepository()
+	auditRepo := repository.NewAuthEventRepository()
+	outboxRepo := repository.NewOutboxRepository()
+	hasher := service.NewPasswordHasher(cfg.Peppers(), cfg.PasswordPepperID, passwords.DefaultParams, cfg.HashWorkers, cfg.HashQueueDepth, cfg.HashQueueTimeout)
+	var breached service.BreachChecker
+	if cfg.BreachedPasswordsFile != "" {
+		corpus, err := breach.LoadCorpus(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("load breached passwords: %w", err)
+		}
+		breached = corpus
+	}
+	roleRepo := repository.NewRoleRepository()
+	permissionRepo := repository.NewPermissionRepository()
+	for _, role := range cfg.RegistrationRoles {
+		if err := service.CheckProvisioningRole(ctx, roleRepo, pool, role); err != nil {
+			return nil, fmt.Errorf("REGISTRATION_ROLES: %w", err)
+		}
+	}
+	authService := service.NewAuthService(pool, userRepo, roleRepo, credRepo, historyRepo, inviteRepo, auditRepo, outboxRepo, hasher, breached, cfg.PasswordPolicy, cfg.RegistrationRoles)
 
 	// Initialise token management layers
 	refreshTokenRepo := repository.NewRefreshTokenRepository()
+	membershipRepo := repository.NewMembershipRepository()
+	serviceAccountRepo := repository.NewServiceAccountRepository()
+	patRepo := repository.NewPersonalAccessTokenRepository()
 	tokenService := service.NewTokenService(
 		pool,
 		refreshTokenRepo,
 		userRepo,
+		membershipRepo,
+		serviceAccountRepo,
+		permissionRepo,
+		patRepo,
+		auditRepo,
+		outboxRepo,
+		cfg.AccessTokenScope,
 		cfg.AccessTokenSecret, cfg.RefreshTokenSecret,
 		cfg.AccessTokenTTL, cfg.RefreshTokenTTL, cfg.TokenIssuer, cfg.TokenAudience)
 
-	// Initialise cookie management services
-	cookieService := service.NewCookieService(
+	RegisterMiddleware(r, tokenService, cfg.TrustedProxies)
+
+	// Initialise personal access tokens; only their HMACs, keyed with API_KEY_SECRET, are stored
+	patService := service.NewPersonalAccessTokenService(pool, userRepo, permissionRepo, patRepo, auditRepo, cfg.APIKeySecret, cfg.PersonalTokenTTL, cfg.PersonalTokenMaxTTL)
+	tokensController := NewTokensController(tokenService, patService)
+
+	// Initialise cookie management services, with a profile per configured client ID. Refresh cookies
+	// left at their old /auth/refresh path are expired
+	cookieProfiles := service.NewCookieProfiles(
+		cfg.Cookies,
+		cfg.CookieProfiles,
 		"/auth/refresh",
-		"refresh_token",
 		int(cfg.RefreshTokenTTL.Seconds()),
-		http.SameSiteStrictMode,
-		secureMode)
+		int(cfg.BFFAbsoluteTimeout.Seconds()))
+
+	// Initialise account lifecycle layers; due deletions are purged in the background
+	accountService := service.NewAccountService(pool, userRepo, credRepo, refreshTokenRepo, auditRepo, outboxRepo, hasher, cfg.DeletionGracePeriod, cfg.ReauthMaxAge)
+	runInBackground(accountService.RunPurge, cfg.DeletionPurgeInterval)
+
+	// Initialise partner webhooks; due deliveries are sent in the background
+	webhookLease := time.Duration(cfg.WebhookBatchSize) * cfg.WebhookTimeout
+	webhookService := service.NewWebhookService(
+		pool,
+		repository.NewWebhookSubscriptionRepository(),
+		repository.NewWebhookDeliveryRepository(),
+		events.NewWebhookSender(cfg.WebhookTimeout),
+		cfg.WebhookMaxAttempts, cfg.WebhookBatchSize, webhookLease)
+	runInBackground(webhookService.Run, cfg.WebhookPollInterval)
+
+	// Initialise back-channel logout; notifications to relying clients are sent in the background,
+	// signed with a key of their own
+	var logoutSigner service.LogoutTokenSigner
+	if len(cfg.BackchannelLogoutURIs) > 0 {
+		key, err := service.LoadLogoutSigningKey(cfg.BackchannelKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load logout token signing key: %w", err)
+		}
+		if logoutSigner, err = service.NewLogoutTokenSigner(key, cfg.TokenIssuer); err != nil {
+			return nil, err
+		}
+	}
+	backchannelLogoutService := service.NewBackchannelLogoutService(
+		pool,
+		repository.NewBackchannelLogoutRepository(),
+		logoutSigner,
+		events.NewBackchannelLogoutSender(cfg.BackchannelTimeout),
+		cfg.BackchannelLogoutURIs,
+		cfg.BackchannelMaxAttempts, cfg.BackchannelBatchSize)
+	runInBackground(backchannelLogoutService.Run, cfg.BackchannelPollInterval)
+
+	// Initialise domain event delivery to the configured sink, webhook subscriptions and back-channel logout
+	sinks := []events.Sink{webhookService, backchannelLogoutService}
+	sink, err := newEventSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if sink != nil {
+		sinks = append(sinks, sink)
+	}
+	relayLease := time.Duration(cfg.OutboxBatchSize) * cfg.OutboxPublishTimeout
+	relay := service.NewOutboxRelay(pool, outboxRepo, events.NewMultiSink(sinks...), cfg.OutboxBatchSize, relayLease, cfg.OutboxRetention)
+	runInBackground(relay.Run, cfg.OutboxPollInterval)
+
+	// Initialise admin layers
+	var mailer service.Mailer = mail.NewLogMailer()
+	if cfg.SMTPHost != "" {
+		mailer = mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+	adminService := service.NewAdminService(pool, userRepo, roleRepo, credRepo, refreshTokenRepo, inviteRepo, auditRepo, outboxRepo, hasher, breached, cfg.PasswordPolicy, mailer, cfg.InviteURL, cfg.InviteTTL)
+
+	// Initialise organizations; invitations are emailed like user invitations
+	organizationService := service.NewOrganizationService(pool, userRepo, repository.NewOrganizationRepository(), membershipRepo, repository.NewOrganizationInviteRepository(), auditRepo, mailer, cfg.OrganizationInviteURL, cfg.InviteTTL)
 
 	// Initialise controllers
-	authController := NewAuthController(authService, tokenService, cookieService)
+	authController := NewAuthController(authService, tokenService, organizationService, cookieProfiles)
+	accountController := NewAccountController(accountService, cookieProfiles)
+	adminController := NewAdminController(adminService)
+	webhookController := NewWebhookController(webhookService)
+
+	organizationController := NewOrganizationController(organizationService)
+
+	// Initialise roles and permissions
+	rbacService := service.NewRBACService(pool, userRepo, serviceAccountRepo, roleRepo, permissionRepo, auditRepo, cfg.ProvisioningRoles())
+	rbacController := NewRBACController(rbacService)
+
+	// Initialise service accounts. Their roles must not grant auth:admin, and API keys share
+	// API_KEY_SECRET with personal access tokens
+	for _, role := range cfg.ServiceAccountRoles {
+		if err := service.CheckProvisioningRole(ctx, roleRepo, pool, role); err != nil {
+			return nil, fmt.Errorf("SERVICE_ACCOUNT_ROLES: %w", err)
+		}
+	}
+	for _, role := range cfg.ServiceAccountOrgRoles {
+		if err := service.CheckProvisioningRole(ctx, roleRepo, pool, role); err != nil {
+			return nil, fmt.Errorf("SERVICE_ACCOUNT_ORGANIZATION_ROLES: %w", err)
+		}
+	}
+	serviceAccountService := service.NewServiceAccountService(pool, serviceAccountRepo, repository.NewServiceAccountKeyRepository(), roleRepo, membershipRepo, auditRepo, tokenService, cfg.APIKeySecret, cfg.ServiceAccountRoles, cfg.ServiceAccountOrgRoles)
+	serviceAccountController := NewServiceAccountController(serviceAccountService)
+
+	// Initialise SCIM provisioning; tenants authenticate with tokens issued through the admin API
+	if err := service.CheckProvisioningRole(ctx, roleRepo, pool, cfg.SCIMDefaultRole); err != nil {
+		return nil, fmt.Errorf("SCIM_DEFAULT_ROLE: %w", err)
+	}
+	scimService := service.NewSCIMService(pool, userRepo, credRepo, repository.NewSCIMTokenRepository(), repository.NewSCIMUserRepository(), refreshTokenRepo, auditRepo, outboxRepo, hasher, breached, cfg.PasswordPolicy, cfg.SCIMDefaultRole)
+	scimController := NewSCIMController(scimService, cfg.SCIMMaxResults)
+
+	// Initialise backend-for-frontend sessions when enabled; ended sessions are purged in the background
+	var sessionController *SessionController
+	if cfg.BFFEnabled {
+		sessionService := service.NewSessionService(pool, repository.NewSessionRepository(), tokenService, cfg.BFFIdleTimeout, cfg.BFFAbsoluteTimeout)
+		runInBackground(sessionService.RunPurge, cfg.BFFPurgeInterval)
+		sessionController = NewSessionController(authService, sessionService, cookieProfiles)
+	}
+
+	// Initialise social login when providers are configured; provider discovery happens on first use
+	credentialRepo := repository.NewCredentialRepository()
+	var socialLoginService service.SocialLoginService
+	var oidcController *OIDCController
+	if len(cfg.OIDCProviders) > 0 {
+		providers := make(map[string]service.OIDCProvider, len(cfg.OIDCProviders))
+		names := make([]string, 0, len(cfg.OIDCProviders))
+		for _, p := range cfg.OIDCProviders {
+			redirectURL := cfg.OIDCRedirectBaseURL + "/auth/oidc/" + p.Name + "/callback"
+			if p.Type == config.OIDCProviderTypeGitHub {
+				providers[p.Name] = oidc.NewGitHubProvider(p.Name, p.Issuer, p.ClientID, p.ClientSecret, redirectURL, p.Scopes, cfg.OIDCTimeout)
+			} else {
+				providers[p.Name] = oidc.NewProvider(p.Name, p.Issuer, p.ClientID, p.ClientSecret, redirectURL, p.Scopes, cfg.OIDCTimeout)
+			}
+			names = append(names, p.Name)
+		}
+		if err := service.CheckProvisioningRole(ctx, roleRepo, pool, cfg.OIDCDefaultRole); err != nil {
+			return nil, fmt.Errorf("OIDC_DEFAULT_ROLE: %w", err)
+		}
+		socialLoginService = service.NewSocialLoginService(pool, providers, names, userRepo, repository.NewUserIdentityRepository(), credentialRepo, repository.NewOIDCLoginStateRepository(), refreshTokenRepo, auditRepo, outboxRepo, cfg.OIDCStateTTL, cfg.OIDCDefaultRole)
+		oidcController = NewOIDCController(socialLoginService, tokenService, cookieProfiles, cfg.OIDCStateTTL, cfg.OIDCLoginRedirectURL)
+	}
+
+	// Initialise sign-in method management; identities can only be linked when social login is configured
+	credentialService := service.NewCredentialService(pool, userRepo, credentialRepo, credRepo, refreshTokenRepo, auditRepo, outboxRepo, socialLoginService, hasher, breached, cfg.PasswordPolicy, cfg.ReauthMaxAge)
+	credentialController := NewCredentialController(credentialService, cookieProfiles, cfg.OIDCStateTTL)
+
+	// Initialise SAML single sign-on when connections are configured; IdP metadata given by URL is
+	// fetched on first use
+	var samlController *SAMLController
+	if len(cfg.SAMLConnections) > 0 {
+		key, cert, err := saml.LoadKeyPair(cfg.SAMLCertFile, cfg.SAMLKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load SAML service provider key pair: %w", err)
+		}
+		connections := make(map[string]service.SAMLConnection, len(cfg.SAMLConnections))
+		names := make([]string, 0, len(cfg.SAMLConnections))
+		for _, c := range cfg.SAMLConnections {
+			for _, role := range c.Roles() {
+				if err := service.CheckProvisioningRole(ctx, roleRepo, pool, role); err != nil {
+					return nil, fmt.Errorf("SAML connection %s: %w", c.Name, err)
+				}
+			}
+			conn, err := saml.NewConnection(c, cfg.SAMLBaseURL, key, cert, cfg.SAMLTimeout)
+			if err != nil {
+				return nil, err
+			}
+			connections[c.Name] = conn
+			names = append(names, c.Name)
+		}
+		samlLoginService := service.NewSAMLLoginService(pool, connections, names, userRepo, repository.NewSAMLIdentityRepository(), repository.NewSAMLLoginStateRepository(), refreshTokenRepo, auditRepo, outboxRepo, cfg.SAMLStateTTL)
+		samlController = NewSAMLController(samlLoginService, tokenService, cookieProfiles, cfg.SAMLStateTTL, cfg.SAMLLoginRedirectURL)
+	}
+
+	// Initialise passkeys when a WebAuthn relying party is configured
+	var passkeyController *PasskeyController
+	if cfg.PasskeyRPID != "" {
+		rp, err := passkey.NewRelyingParty(cfg.PasskeyRPID, cfg.PasskeyRPName, cfg.PasskeyOrigins)
+		if err != nil {
+			return nil, err
+		}
+		passkeyService := service.NewPasskeyService(pool, rp, userRepo, repository.NewPasskeyRepository(), repository.NewPasskeyCeremonyRepository(), credRepo, auditRepo, hasher, cfg.PasskeyCeremonyTTL, cfg.ReauthMaxAge)
+		passkeyController = NewPasskeyController(passkeyService, tokenService, cookieProfiles)
+	}
 
 	// Create health checkers map
 	healthCheckers := map[string]health.HealthChecker{
 		"database": health.NewDBHealthChecker(pool),
 	}
 
-	RegisterRoutes(r, authController, healthCheckers)
+	// Initialise rate limiting for abuse-prone routes
+	rateLimitStore := newRateLimitStore(pool, cfg)
+	rateLimits := RouteRateLimits{}
+	for route, rule := range cfg.RateLimits {
+		rateLimits[route] = RateLimit(rateLimitStore, RateLimitPolicy{
+			Name:   route,
+			Limit:  rule.Limit,
+			Window: rule.Window,
+			Key:    rateLimitKeyFunc(rule.Key, cfg),
+		})
+	}
+
+	// Create metrics sources map
+	metricsSources := map[string]func() any{
+		"password_hasher": func() any { return hasher.Stats() },
+	}
+
+	RegisterRoutes(r, authController, tokensController, accountController, credentialController, adminController, webhookController, organizationController, rbacController, serviceAccountController, cfg.ValidationAPIKey, scimController, sessionController, oidcController, samlController, passkeyController, healthCheckers, metricsSources, cfg.MetricsToken, logoutSigner, rateLimits)
 
-	return r
+	return r, nil
+}
+
+// newRateLimitStore selects the rate limit backend configured by RATE_LIMIT_STORE.
+func newRateLimitStore(pool *sql.DB, cfg *config.Config) RateLimitStore {
+	switch cfg.RateLimitStore {
+	case config.RateLimitStorePostgres:
+		return NewPostgresRateLimitStore(pool, repository.NewRateLimitRepository())
+	case config.RateLimitStoreRedis:
+		return NewRedisRateLimitStore(redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddr(),
+			Password: cfg.RedisPassword,
+		}))
+	default:
+		return NewMemoryRateLimitStore()
+	}
+}
+
+// newEventSink selects the domain event sink configured by OUTBOX_SINK; it returns nil for none.
+func newEventSink(cfg *config.Config) (service.EventSink, error) {
+	switch cfg.OutboxSink {
+	case config.OutboxSinkStdout:
+		return events.NewStdoutSink(), nil
+	case config.OutboxSinkWebhook:
+		return events.NewWebhookSink(cfg.OutboxWebhookURL, cfg.OutboxPublishTimeout), nil
+	case config.OutboxSinkNATS:
+		conn, err := nats.Connect(cfg.OutboxNATSURL, nats.Name(service.EventSource), nats.MaxReconnects(-1))
+		if err != nil {
+			return nil, fmt.Errorf("connect to NATS: %w", err)
+		}
+		return events.NewNATSSink(conn, cfg.OutboxNATSSubject, cfg.OutboxPublishTimeout), nil
+	default:
+		return nil, nil
+	}
 }
@@ -1,13 +1,19 @@
 package api
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math/big"
 	"net/http"
 
 	"github.com/LittleAksMax/bids-util/requests"
 	"github.com/LittleAksMax/bids-util/validation"
 	"github.com/go-chi/chi/v5"
 
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
 	"github.com/LittleAksMax/bids-auth-service/internal/health"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
 )
 
 // Health handler implementation that checks all registered services.
@@ -45,24 +51,220 @@ func Health(checkers map[string]health.HealthChecker) http.HandlerFunc {
 	}
 }
 
+// Metrics handler reports a snapshot from each registered metrics source.
+func Metrics(sources map[string]func() any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snapshot := make(map[string]any, len(sources))
+		for name, source := range sources {
+			snapshot[name] = source()
+		}
+
+		requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+			Success: true,
+			Data:    snapshot,
+		})
+	}
+}
+
+// JWKS handler publishes the public key back-channel logout tokens are verified with.
+func JWKS(signer service.LogoutTokenSigner) http.HandlerFunc {
+	key := signer.PublicKey()
+	set := JSONWebKeySetResponse{Keys: []JSONWebKeyResponse{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: signer.KeyID(),
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if err := json.NewEncoder(w).Encode(set); err != nil {
+			log.Printf("couldn't write JWKS response: %v\n", err)
+		}
+	}
+}
+
 // RegisterRoutes registers all endpoint handlers using the controller methods.
-func RegisterRoutes(r chi.Router, c *AuthController /*tc *TokensController,*/, healthCheckers map[string]health.HealthChecker) {
+func RegisterRoutes(r chi.Router, c *AuthController, tc *TokensController, acc *AccountController, cc *CredentialController, ac *AdminController, wc *WebhookController, orgc *OrganizationController, rbacc *RBACController, sac *ServiceAccountController, apiKey string, scimc *SCIMController, sc *SessionController, oc *OIDCController, samlc *SAMLController, pc *PasskeyController, healthCheckers map[string]health.HealthChecker, metricsSources map[string]func() any, metricsToken string, logoutSigner service.LogoutTokenSigner, rateLimits RouteRateLimits) {
 	// Health
 	r.Get("/health", Health(healthCheckers))
 
+	// Metrics, served only to scrapers presenting the metrics token
+	if metricsToken != "" {
+		r.With(RequireBearerToken(metricsToken)).Get("/metrics", Metrics(metricsSources))
+	}
+
+	// Key set of back-channel logout tokens, served only when relying clients are notified
+	if logoutSigner != nil {
+		r.Get("/.well-known/jwks.json", JWKS(logoutSigner))
+	}
+
 	validationFuncs := []func(any) error{
 		validation.ValidateRequiredFields,
 		validation.ValidateUUIDs,
-		validation.ValidatePasswords,
 		validation.ValidateEmails,
-		validation.ValidateRoles,
 	}
 
 	// Auth routes
 	r.Route("/auth", func(r chi.Router) {
-		r.With(requests.ValidateRequest[RegisterRequest](validationFuncs)).Post("/register", c.Register)
-		r.With(requests.ValidateRequest[LoginRequest](validationFuncs)).Post("/login", c.Login)
+		r.With(rateLimits.For("register"), requests.ValidateRequest[RegisterRequest](validationFuncs)).Post("/register", c.Register)
+		r.With(rateLimits.For("login"), requests.ValidateRequest[LoginRequest](validationFuncs)).Post("/login", c.Login)
 		r.With(requests.ValidateRequest[LogoutRequest](validationFuncs)).Post("/logout", c.Logout)
-		r.With(requests.ValidateRequest[RefreshRequest](validationFuncs)).Post("/refresh", c.Refresh)
+		r.With(rateLimits.For("refresh"), requests.ValidateRequest[RefreshRequest](validationFuncs)).Post("/refresh", c.Refresh)
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[ChangePasswordRequest](validationFuncs)).Post("/password", c.ChangePassword)
+
+		r.With(rateLimits.For("login"), requests.ValidateRequest[AcceptInviteRequest](validationFuncs)).Post("/invite/accept", c.AcceptInvite)
+
+		// Self-service account management
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[DeleteAccountRequest](validationFuncs)).Post("/me/delete", acc.RequestDeletion)
+		r.With(rateLimits.For("login"), requests.ValidateRequest[CancelDeletionRequest](validationFuncs)).Post("/me/delete/cancel", acc.CancelDeletion)
+		r.With(RequireAuth).Get("/me/export", acc.Export)
+
+		// Sign-in methods
+		r.With(RequireAuth).Get("/me/credentials", cc.ListCredentials)
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[AddPasswordRequest](validationFuncs)).Post("/me/credentials/password", cc.AddPassword)
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[LinkIdentityRequest](validationFuncs)).Post("/me/credentials/oidc/{provider}", cc.LinkIdentity)
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[RemoveCredentialRequest](validationFuncs)).Post("/me/credentials/{credentialID}/remove", cc.RemoveCredential)
+		r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[MergeAccountRequest](validationFuncs)).Post("/me/merge", cc.MergeAccount)
+
+		// Personal access tokens, managed with an access token rather than another personal access token
+		r.With(RequireAuth).Get("/tokens", tc.ListTokens)
+		r.With(RequireAuth, requests.ValidateRequest[CreatePersonalAccessTokenRequest](validationFuncs)).Post("/tokens", tc.CreateToken)
+		r.With(RequireAuth).Delete("/tokens/{tokenID}", tc.RevokeToken)
+
+		// Token validation for other bids services and forward-auth proxies
+		r.With(RequireAPIKey(apiKey), requests.ValidateRequest[IntrospectTokenRequest](validationFuncs)).Post("/introspect", tc.Introspect)
+		r.Get("/verify", tc.Verify)
+
+		// Service accounts exchange an API key for an access token
+		r.With(rateLimits.For("login"), requests.ValidateRequest[ServiceAccountTokenRequest](validationFuncs)).Post("/service-token", sac.Token)
+
+		// Backend-for-frontend sessions, only when enabled
+		if sc != nil {
+			r.With(rateLimits.For("login"), requests.ValidateRequest[LoginRequest](validationFuncs)).Post("/session", sc.Login)
+			r.With(RequireAPIKey(apiKey)).Get("/session/token", sc.Token)
+			r.Delete("/session", sc.Logout)
+		}
+
+		// Social login through external identity providers, only when configured
+		if oc != nil {
+			r.Get("/oidc/providers", oc.Providers)
+			r.With(rateLimits.For("login")).Get("/oidc/{provider}/login", oc.Login)
+			r.With(rateLimits.For("login")).Get("/oidc/{provider}/callback", oc.Callback)
+		}
+
+		// SAML single sign-on through enterprise identity providers, only when configured
+		if samlc != nil {
+			r.Get("/saml/connections", samlc.Connections)
+			r.Get("/saml/{connection}/metadata", samlc.Metadata)
+			r.With(rateLimits.For("login")).Get("/saml/{connection}/login", samlc.Login)
+			r.With(rateLimits.For("login")).Post("/saml/{connection}/acs", samlc.ACS)
+		}
+
+		// Passkeys, only when a WebAuthn relying party is configured
+		if pc != nil {
+			r.With(rateLimits.For("login")).Post("/passkey/login/begin", pc.BeginLogin)
+			r.With(rateLimits.For("login"), requests.ValidateRequest[PasskeyResponseRequest](validationFuncs)).Post("/passkey/login/finish", pc.FinishLogin)
+			r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[AddPasskeyRequest](validationFuncs)).Post("/me/credentials/passkey/begin", pc.BeginRegistration)
+			r.With(RequireAuth, rateLimits.For("password"), requests.ValidateRequest[PasskeyResponseRequest](validationFuncs)).Post("/me/credentials/passkey/finish", pc.FinishRegistration)
+		}
+	})
+
+	// Organizations, their members and invitations
+	r.Route("/organizations", func(r chi.Router) {
+		r.Use(RequireAuth)
+		r.Get("/", orgc.ListOrganizations)
+		r.With(requests.ValidateRequest[CreateOrganizationRequest](validationFuncs)).Post("/", orgc.CreateOrganization)
+		r.With(requests.ValidateRequest[AcceptOrganizationInviteRequest](validationFuncs)).Post("/invites/accept", orgc.AcceptInvite)
+		r.Get("/{orgID}", orgc.GetOrganization)
+		r.With(requests.ValidateRequest[UpdateOrganizationRequest](validationFuncs)).Put("/{orgID}", orgc.UpdateOrganization)
+		r.Delete("/{orgID}", orgc.DeleteOrganization)
+		r.Get("/{orgID}/members", orgc.ListMembers)
+		r.With(requests.ValidateRequest[ChangeMemberRoleRequest](validationFuncs)).Put("/{orgID}/members/{userID}/role", orgc.ChangeMemberRole)
+		r.Delete("/{orgID}/members/{userID}", orgc.RemoveMember)
+		r.Get("/{orgID}/invites", orgc.ListInvites)
+		r.With(requests.ValidateRequest[InviteMemberRequest](validationFuncs)).Post("/{orgID}/invites", orgc.InviteMember)
+		r.Delete("/{orgID}/invites/{inviteID}", orgc.RevokeInvite)
+		r.Get("/{orgID}/service-accounts", sac.ListServiceAccounts)
+		r.With(requests.ValidateRequest[CreateServiceAccountRequest](validationFuncs)).Post("/{orgID}/service-accounts", sac.CreateServiceAccount)
+		r.Get("/{orgID}/service-accounts/{accountID}", sac.GetServiceAccount)
+		r.Delete("/{orgID}/service-accounts/{accountID}", sac.DeleteServiceAccount)
+		r.Post("/{orgID}/service-accounts/{accountID}/keys", sac.CreateServiceAccountKey)
+		r.Delete("/{orgID}/service-accounts/{accountID}/keys/{keyID}", sac.RevokeServiceAccountKey)
+	})
+
+	// Admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(RequirePermission(rbacc.rbacService, contracts.PermissionAuthAdmin))
+		r.Get("/reports/peppers", ac.PepperReport)
+
+		// User management
+		r.Get("/users", ac.ListUsers)
+		r.With(requests.ValidateRequest[CreateUserRequest](validationFuncs)).Post("/users", ac.CreateUser)
+		r.Get("/users/{userID}", ac.GetUser)
+		r.Get("/users/{userID}/permissions", rbacc.UserPermissions)
+		r.With(requests.ValidateRequest[UpdateUserRequest](validationFuncs)).Put("/users/{userID}", ac.UpdateUser)
+		r.Delete("/users/{userID}", ac.DeleteUser)
+		r.With(requests.ValidateRequest[ChangeRoleRequest](validationFuncs)).Put("/users/{userID}/role", ac.ChangeRole)
+		r.With(requests.ValidateRequest[SuspendUserRequest](validationFuncs)).Post("/users/{userID}/suspend", ac.SuspendUser)
+		r.Post("/users/{userID}/restore", ac.RestoreUser)
+		r.Post("/users/{userID}/logout", ac.ForceLogout)
+
+		// Audit log
+		r.Get("/events", ac.ListAuthEvents)
+
+		// Partner webhooks
+		r.Get("/webhooks", wc.ListWebhooks)
+		r.With(requests.ValidateRequest[CreateWebhookRequest](validationFuncs)).Post("/webhooks", wc.CreateWebhook)
+		r.Get("/webhooks/dead-letters", wc.ListDeadLetters)
+		r.Post("/webhooks/dead-letters/{deadLetterID}/replay", wc.ReplayDeadLetter)
+		r.Get("/webhooks/{webhookID}", wc.GetWebhook)
+		r.With(requests.ValidateRequest[UpdateWebhookRequest](validationFuncs)).Put("/webhooks/{webhookID}", wc.UpdateWebhook)
+		r.Delete("/webhooks/{webhookID}", wc.DeleteWebhook)
+		r.Post("/webhooks/{webhookID}/secret", wc.RotateWebhookSecret)
+
+		// Roles and permissions
+		r.Get("/roles", rbacc.ListRoles)
+		r.With(requests.ValidateRequest[CreateRoleRequest](validationFuncs)).Post("/roles", rbacc.CreateRole)
+		r.Get("/roles/{role}", rbacc.GetRole)
+		r.With(requests.ValidateRequest[UpdateRoleRequest](validationFuncs)).Put("/roles/{role}", rbacc.UpdateRole)
+		r.Delete("/roles/{role}", rbacc.DeleteRole)
+		r.Get("/permissions", rbacc.ListPermissions)
+		r.With(requests.ValidateRequest[CreatePermissionRequest](validationFuncs)).Post("/permissions", rbacc.CreatePermission)
+		r.Delete("/permissions/{permission}", rbacc.DeletePermission)
+
+		// Service accounts
+		r.Get("/service-accounts", sac.ListServiceAccounts)
+		r.With(requests.ValidateRequest[CreateServiceAccountRequest](validationFuncs)).Post("/service-accounts", sac.CreateServiceAccount)
+		r.Get("/service-accounts/{accountID}", sac.GetServiceAccount)
+		r.Delete("/service-accounts/{accountID}", sac.DeleteServiceAccount)
+		r.Post("/service-accounts/{accountID}/keys", sac.CreateServiceAccountKey)
+		r.Delete("/service-accounts/{accountID}/keys/{keyID}", sac.RevokeServiceAccountKey)
+
+		// SCIM tokens
+		r.Get("/scim/tokens", scimc.ListSCIMTokens)
+		r.With(requests.ValidateRequest[IssueSCIMTokenRequest](validationFuncs)).Post("/scim/tokens", scimc.IssueSCIMToken)
+		r.Delete("/scim/tokens/{tokenID}", scimc.RevokeSCIMToken)
+	})
+
+	// Authorization checks for other bids services, authenticated with the shared API key
+	r.With(RequireAPIKey(apiKey), requests.ValidateRequest[AuthzCheckRequest](validationFuncs)).Post("/authz/check", rbacc.Check)
+
+	// SCIM 2.0 provisioning, authenticated with a tenant's SCIM token
+	r.Route("/scim/v2", func(r chi.Router) {
+		r.Use(scimc.RequireSCIMToken)
+		r.Get("/ServiceProviderConfig", scimc.ServiceProviderConfig)
+		r.Get("/ResourceTypes", scimc.ResourceTypes)
+		r.Get("/ResourceTypes/{resourceType}", scimc.ResourceType)
+		r.Get("/Schemas", scimc.Schemas)
+		r.Get("/Schemas/{schemaID}", scimc.Schema)
+		r.Get("/Users", scimc.ListSCIMUsers)
+		r.Post("/Users", scimc.CreateSCIMUser)
+		r.Get("/Users/{userID}", scimc.GetSCIMUser)
+		r.Put("/Users/{userID}", scimc.ReplaceSCIMUser)
+		r.Patch("/Users/{userID}", scimc.PatchSCIMUser)
+		r.Delete("/Users/{userID}", scimc.DeleteSCIMUser)
 	})
 }
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// SAMLController houses dependencies for SAML single sign-on endpoints.
+type SAMLController struct {
+	samlLoginService service.SAMLLoginService
+	tokenService     service.TokenService
+	cookieProfiles   service.CookieProfiles
+	stateTTL         time.Duration
+	loginRedirectURL string
+}
+
+// NewSAMLController constructs a SAMLController. When loginRedirectURL is set, the assertion consumer
+// service redirects there instead of returning JSON.
+func NewSAMLController(samlLoginService service.SAMLLoginService, tokenService service.TokenService, cookieProfiles service.CookieProfiles, stateTTL time.Duration, loginRedirectURL string) *SAMLController {
+	return &SAMLController{
+		samlLoginService: samlLoginService,
+		tokenService:     tokenService,
+		cookieProfiles:   cookieProfiles,
+		stateTTL:         stateTTL,
+		loginRedirectURL: loginRedirectURL,
+	}
+}
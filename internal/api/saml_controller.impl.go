@@ -0,0 +1,147 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+	"github.com/go-chi/chi/v5"
+)
+
+// Connections handler lists the SAML connections users can sign in with.
+func (c *SAMLController) Connections(w http.ResponseWriter, r *http.Request) {
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    SAMLConnectionsResponse{Connections: c.samlLoginService.Connections()},
+	})
+}
+
+// Metadata handler returns the service provider metadata to register with a connection's IdP.
+func (c *SAMLController) Metadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := c.samlLoginService.Metadata(chi.URLParam(r, "connection"))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownSAMLConnection) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "unknown SAML connection"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to build metadata"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(metadata); err != nil {
+		log.Printf("couldn't write SAML metadata: %v\n", err)
+	}
+}
+
+// Login handler starts a SAML login, redirecting the browser to the IdP with an AuthnRequest. The
+// RelayState is also kept in a cookie so the response can only be consumed by the browser that
+// started the login. The organization_id query parameter optionally selects the organization the
+// session acts in.
+func (c *SAMLController) Login(w http.ResponseWriter, r *http.Request) {
+	organizationID, ok := organizationIDFromRequest(w, r.URL.Query().Get("organization_id"))
+	if !ok {
+		return
+	}
+
+	authURL, relayState, err := c.samlLoginService.Begin(r.Context(), chi.URLParam(r, "connection"), organizationID)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownSAMLConnection) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "unknown SAML connection"})
+			return
+		}
+		if errors.Is(err, service.ErrSAMLLogin) {
+			log.Printf("couldn't start SAML login: %v\n", err)
+			requests.WriteJSON(w, http.StatusBadGateway, requests.APIResponse{Success: false, Error: "identity provider is unavailable"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start login"})
+		return
+	}
+
+	http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateSetSAMLStateCookie(relayState, int(c.stateTTL.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ACS handler is the assertion consumer service: it validates the response the IdP posts back and
+// returns both tokens like a password login. With a login redirect URL configured, the browser is
+// sent there with the tokens in cookies only, or with an error query parameter on failure.
+func (c *SAMLController) ACS(w http.ResponseWriter, r *http.Request) {
+	cookies := cookiesFor(c.cookieProfiles, r)
+	http.SetCookie(w, cookies.CreateClearSAMLStateCookie())
+
+	if err := r.ParseForm(); err != nil {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusBadRequest, "invalid_response", "malformed SAML response")
+		return
+	}
+	relayState := r.PostForm.Get("RelayState")
+	stateCookie, err := r.Cookie(cookies.SAMLStateCookieName())
+	if err != nil || relayState == "" || subtle.ConstantTimeCompare([]byte(stateCookie.Value), []byte(relayState)) != 1 {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "invalid_state", "login state is invalid or expired")
+		return
+	}
+
+	result, err := c.samlLoginService.Complete(r.Context(), chi.URLParam(r, "connection"), relayState, r.PostForm.Get("SAMLResponse"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownSAMLConnection):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusNotFound, "unknown_connection", "unknown SAML connection")
+		case errors.Is(err, service.ErrInvalidLoginState):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "invalid_state", "login state is invalid or expired")
+		case errors.Is(err, service.ErrSAMLLogin):
+			log.Printf("rejected SAML response: %v\n", err)
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusUnauthorized, "invalid_response", "identity provider login failed")
+		case errors.Is(err, service.ErrIdentityEmailRequired):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusUnprocessableEntity, "email_required", err.Error())
+		case errors.Is(err, service.ErrSAMLEmailInUse):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "email_in_use", err.Error())
+		case errors.Is(err, service.ErrSAMLEmailDomain):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "email_domain", err.Error())
+		case errors.Is(err, service.ErrUserExists):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusConflict, "account_exists", "an account with this username or email already exists")
+		case errors.Is(err, service.ErrAccountInactive):
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "account_inactive", "account is not active")
+		default:
+			writeLoginError(w, r, c.loginRedirectURL, http.StatusInternalServerError, "server_error", "login failed")
+		}
+		return
+	}
+
+	user := result.User
+	tokenPair, err := c.tokenService.CreateNewTokenPair(r.Context(), user.ID, user.Username, user.Role, result.OrganizationID)
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusForbidden, "not_member", "not a member of the organization")
+		return
+	}
+	if err != nil || tokenPair == nil {
+		writeLoginError(w, r, c.loginRedirectURL, http.StatusInternalServerError, "server_error", "failed to generate token pair")
+		return
+	}
+
+	// Set refresh token and CSRF cookies (for browser clients)
+	setAuthCookies(w, r, c.cookieProfiles, tokenPair.RefreshToken)
+
+	if c.loginRedirectURL != "" {
+		http.Redirect(w, r, c.loginRedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: AuthResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			Tokens: authTokensResponse(r, tokenPair, false),
+		},
+	})
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// SCIMController houses dependencies for the SCIM provisioning API and its token administration.
+type SCIMController struct {
+	scimService service.SCIMService
+	maxResults  int
+}
+
+// NewSCIMController constructs a SCIMController. List requests return at most maxResults users.
+func NewSCIMController(scimService service.SCIMService, maxResults int) *SCIMController {
+	return &SCIMController{
+		scimService: scimService,
+		maxResults:  maxResults,
+	}
+}
@@ -0,0 +1,502 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/scim"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// maxSCIMRequestBytes bounds the size of SCIM request bodies.
+const maxSCIMRequestBytes = 1 << 20
+
+// ListSCIMTokens handler returns every issued SCIM token, without the tokens themselves.
+func (c *SCIMController) ListSCIMTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := c.scimService.ListTokens(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list SCIM tokens"})
+		return
+	}
+
+	data := make([]SCIMTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		data = append(data, newSCIMTokenResponse(token))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// IssueSCIMToken handler issues a SCIM token to a tenant and returns it, which is not shown again.
+func (c *SCIMController) IssueSCIMToken(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[IssueSCIMTokenRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	record, token, err := c.scimService.IssueToken(r.Context(), body.Tenant, body.Description)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSCIMTenant) {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to issue SCIM token"})
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: IssuedSCIMTokenResponse{
+			SCIMToken: newSCIMTokenResponse(record),
+			Token:     token,
+		},
+	})
+}
+
+// RevokeSCIMToken handler revokes a SCIM token.
+func (c *SCIMController) RevokeSCIMToken(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid token id"})
+		return
+	}
+
+	if err := c.scimService.RevokeToken(r.Context(), id); err != nil {
+		if errors.Is(err, service.ErrSCIMTokenNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "SCIM token not found"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to revoke SCIM token"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequireSCIMToken rejects SCIM requests without a valid SCIM token, and stores the token's tenant
+// in the request context.
+func (c *SCIMController) RequireSCIMToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeSCIMError(w, http.StatusUnauthorized, "", "authentication required")
+			return
+		}
+		record, err := c.scimService.Authenticate(r.Context(), token)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidSCIMToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+				writeSCIMError(w, http.StatusUnauthorized, "", "invalid token")
+				return
+			}
+			writeSCIMError(w, http.StatusInternalServerError, "", "failed to authenticate")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), scimTenantKey, record.Tenant)))
+	})
+}
+
+// ServiceProviderConfig handler describes the SCIM features supported.
+func (c *SCIMController) ServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeSCIM(w, http.StatusOK, scim.ServiceProviderConfig(scimBaseURL(r), c.maxResults))
+}
+
+// ResourceTypes handler lists the supported resource types.
+func (c *SCIMController) ResourceTypes(w http.ResponseWriter, r *http.Request) {
+	writeSCIMList(w, 1, 1, []any{scim.UserResourceType(scimBaseURL(r))})
+}
+
+// ResourceType handler describes one resource type.
+func (c *SCIMController) ResourceType(w http.ResponseWriter, r *http.Request) {
+	if chi.URLParam(r, "resourceType") != "User" {
+		writeSCIMError(w, http.StatusNotFound, "", "resource type not found")
+		return
+	}
+	writeSCIM(w, http.StatusOK, scim.UserResourceType(scimBaseURL(r)))
+}
+
+// Schemas handler lists the supported schemas.
+func (c *SCIMController) Schemas(w http.ResponseWriter, r *http.Request) {
+	writeSCIMList(w, 1, 1, []any{scim.UserSchema(scimBaseURL(r))})
+}
+
+// Schema handler describes one schema.
+func (c *SCIMController) Schema(w http.ResponseWriter, r *http.Request) {
+	if chi.URLParam(r, "schemaID") != scim.SchemaUser {
+		writeSCIMError(w, http.StatusNotFound, "", "schema not found")
+		return
+	}
+	writeSCIM(w, http.StatusOK, scim.UserSchema(scimBaseURL(r)))
+}
+
+// ListSCIMUsers handler returns a page of the tenant's users. Query parameters: filter, startIndex
+// (1-based), count, attributes and excludedAttributes.
+func (c *SCIMController) ListSCIMUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var filter scim.Filter
+	if raw := query.Get("filter"); raw != "" {
+		var err error
+		if filter, err = scim.ParseFilter(raw); err != nil {
+			writeSCIMProtocolError(w, err)
+			return
+		}
+	}
+	// Out of range values are clamped rather than rejected, as RFC 7644 requires
+	startIndex := 1
+	if raw := query.Get("startIndex"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, scim.ErrorInvalidValue, "invalid startIndex")
+			return
+		}
+		startIndex = max(n, 1)
+	}
+	count := c.maxResults
+	if raw := query.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, scim.ErrorInvalidValue, "invalid count")
+			return
+		}
+		count = min(max(n, 0), c.maxResults)
+	}
+
+	tenant := scimTenantFromContext(r.Context())
+	page, err := c.scimService.ListUsers(r.Context(), tenant, filter, startIndex-1, count)
+	if err != nil {
+		log.Printf("couldn't list SCIM users of tenant %s: %v\n", tenant, err)
+		writeSCIMError(w, http.StatusInternalServerError, "", "failed to list users")
+		return
+	}
+
+	baseURL := scimBaseURL(r)
+	resources := make([]any, 0, len(page.Users))
+	for _, user := range page.Users {
+		resource, err := scim.Project(newSCIMUserResource(user, baseURL), query.Get("attributes"), query.Get("excludedAttributes"))
+		if err != nil {
+			writeSCIMError(w, http.StatusInternalServerError, "", "failed to list users")
+			return
+		}
+		resources = append(resources, resource)
+	}
+	writeSCIMList(w, page.Total, startIndex, resources)
+}
+
+// GetSCIMUser handler returns one of the tenant's users.
+func (c *SCIMController) GetSCIMUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := scimUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := c.scimService.GetUser(r.Context(), scimTenantFromContext(r.Context()), userID)
+	if err != nil {
+		writeSCIMServiceError(w, err, "failed to get user")
+		return
+	}
+	writeSCIMUser(w, r, http.StatusOK, user)
+}
+
+// CreateSCIMUser handler provisions a user for the tenant.
+func (c *SCIMController) CreateSCIMUser(w http.ResponseWriter, r *http.Request) {
+	var resource scim.User
+	if !decodeSCIMBody(w, r, &resource) {
+		return
+	}
+	attrs, err := scimUserAttributes(&resource)
+	if err != nil {
+		writeSCIMProtocolError(w, err)
+		return
+	}
+
+	user, err := c.scimService.CreateUser(r.Context(), scimTenantFromContext(r.Context()), attrs, resource.Password)
+	if err != nil {
+		writeSCIMServiceError(w, err, "failed to create user")
+		return
+	}
+	w.Header().Set("Location", scimBaseURL(r)+"/Users/"+user.User.ID.String())
+	writeSCIMUser(w, r, http.StatusCreated, user)
+}
+
+// ReplaceSCIMUser handler replaces one of the tenant's users. The password can't be changed.
+func (c *SCIMController) ReplaceSCIMUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := scimUserIDParam(w, r)
+	if !ok {
+		return
+	}
+	var resource scim.User
+	if !decodeSCIMBody(w, r, &resource) {
+		return
+	}
+	if resource.Password != "" {
+		writeSCIMError(w, http.StatusBadRequest, scim.ErrorMutability, "password can only be set when a user is created")
+		return
+	}
+	attrs, err := scimUserAttributes(&resource)
+	if err != nil {
+		writeSCIMProtocolError(w, err)
+		return
+	}
+
+	user, err := c.scimService.ReplaceUser(r.Context(), scimTenantFromContext(r.Context()), userID, attrs)
+	if err != nil {
+		writeSCIMServiceError(w, err, "failed to replace user")
+		return
+	}
+	writeSCIMUser(w, r, http.StatusOK, user)
+}
+
+// PatchSCIMUser handler applies PATCH operations to one of the tenant's users.
+func (c *SCIMController) PatchSCIMUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := scimUserIDParam(w, r)
+	if !ok {
+		return
+	}
+	var patch scim.PatchRequest
+	if !decodeSCIMBody(w, r, &patch) {
+		return
+	}
+
+	baseURL := scimBaseURL(r)
+	user, err := c.scimService.PatchUser(r.Context(), scimTenantFromContext(r.Context()), userID, func(current *contracts.SCIMUser) (contracts.SCIMUserAttributes, error) {
+		resource := newSCIMUserResource(current, baseURL)
+		if err := patch.Apply(resource); err != nil {
+			return contracts.SCIMUserAttributes{}, err
+		}
+		return scimUserAttributes(resource)
+	})
+	if err != nil {
+		var scimErr *scim.Error
+		if errors.As(err, &scimErr) {
+			writeSCIMProtocolError(w, err)
+			return
+		}
+		writeSCIMServiceError(w, err, "failed to patch user")
+		return
+	}
+	writeSCIMUser(w, r, http.StatusOK, user)
+}
+
+// DeleteSCIMUser handler deletes one of the tenant's users.
+func (c *SCIMController) DeleteSCIMUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := scimUserIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.scimService.DeleteUser(r.Context(), scimTenantFromContext(r.Context()), userID); err != nil {
+		writeSCIMServiceError(w, err, "failed to delete user")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scimTenantFromContext returns the tenant stored by RequireSCIMToken.
+func scimTenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(scimTenantKey).(string)
+	return tenant
+}
+
+// scimBaseURL returns the absolute URL of the SCIM API as the client addressed it.
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+// scimUserIDParam parses the userID path parameter. IDs are opaque to SCIM clients, so a malformed
+// one is reported as an unknown user.
+func scimUserIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		writeSCIMError(w, http.StatusNotFound, "", "user not found")
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// decodeSCIMBody decodes a JSON request body into v, writing a 400 if it is malformed.
+func decodeSCIMBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSCIMRequestBytes)).Decode(v); err != nil {
+		writeSCIMError(w, http.StatusBadRequest, scim.ErrorInvalidSyntax, "request body is not a valid SCIM resource")
+		return false
+	}
+	return true
+}
+
+// scimUserAttributes validates a User resource from a request and returns the attributes it sets.
+// Without an email, a userName that is an email address is used; active is only set when given.
+func scimUserAttributes(resource *scim.User) (contracts.SCIMUserAttributes, error) {
+	username := strings.TrimSpace(resource.UserName)
+	if username == "" {
+		return contracts.SCIMUserAttributes{}, &scim.Error{Type: scim.ErrorInvalidValue, Detail: "userName is required"}
+	}
+	email := strings.TrimSpace(resource.PrimaryEmail())
+	if email == "" && isEmailAddress(username) {
+		email = username
+	}
+	if !isEmailAddress(email) {
+		return contracts.SCIMUserAttributes{}, &scim.Error{Type: scim.ErrorInvalidValue, Detail: "a valid email is required"}
+	}
+
+	attrs := contracts.SCIMUserAttributes{
+		Username:    username,
+		Email:       email,
+		ExternalID:  optionalString(resource.ExternalID),
+		DisplayName: optionalString(resource.DisplayName),
+		Active:      resource.Active,
+	}
+	if resource.Name != nil {
+		attrs.GivenName = optionalString(resource.Name.GivenName)
+		attrs.FamilyName = optionalString(resource.Name.FamilyName)
+	}
+	return attrs, nil
+}
+
+// isEmailAddress reports whether s is a bare email address.
+func isEmailAddress(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// optionalString returns nil for an empty string.
+func optionalString(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// newSCIMUserResource represents a provisioned user as a SCIM User. Its single email is reported as
+// the primary work email.
+func newSCIMUserResource(user *contracts.SCIMUser, baseURL string) *scim.User {
+	active := user.User.IsActive()
+	resource := &scim.User{
+		Schemas:  []string{scim.SchemaUser},
+		ID:       user.User.ID.String(),
+		UserName: user.User.Username,
+		Emails:   []scim.Email{{Value: user.User.Email, Type: "work", Primary: true}},
+		Active:   &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      scim.FormatTime(user.User.CreatedAt),
+			LastModified: scim.FormatTime(user.User.UpdatedAt),
+			Location:     baseURL + "/Users/" + user.User.ID.String(),
+		},
+	}
+	if user.ExternalID != nil {
+		resource.ExternalID = *user.ExternalID
+	}
+	if user.DisplayName != nil {
+		resource.DisplayName = *user.DisplayName
+	}
+	if user.GivenName != nil || user.FamilyName != nil {
+		resource.Name = &scim.Name{}
+		if user.GivenName != nil {
+			resource.Name.GivenName = *user.GivenName
+		}
+		if user.FamilyName != nil {
+			resource.Name.FamilyName = *user.FamilyName
+		}
+		resource.Name.Formatted = strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName)
+	}
+	return resource
+}
+
+// writeSCIMUser writes a user, projected by the attributes and excludedAttributes query parameters.
+func writeSCIMUser(w http.ResponseWriter, r *http.Request, status int, user *contracts.SCIMUser) {
+	query := r.URL.Query()
+	resource, err := scim.Project(newSCIMUserResource(user, scimBaseURL(r)), query.Get("attributes"), query.Get("excludedAttributes"))
+	if err != nil {
+		writeSCIMError(w, http.StatusInternalServerError, "", "failed to encode user")
+		return
+	}
+	writeSCIM(w, status, resource)
+}
+
+// writeSCIMServiceError maps SCIM service errors to SCIM error responses.
+func writeSCIMServiceError(w http.ResponseWriter, err error, failure string) {
+	var policyErr *service.PasswordPolicyError
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		writeSCIMError(w, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, service.ErrUserExists):
+		writeSCIMError(w, http.StatusConflict, scim.ErrorUniqueness, "userName or email already exists")
+	case errors.As(err, &policyErr), errors.Is(err, service.ErrBreachedPassword):
+		writeSCIMError(w, http.StatusBadRequest, scim.ErrorInvalidValue, err.Error())
+	case errors.Is(err, service.ErrHasherSaturated):
+		w.Header().Set("Retry-After", "1")
+		writeSCIMError(w, http.StatusServiceUnavailable, "", "service busy, please retry")
+	default:
+		log.Printf("SCIM request failed: %v\n", err)
+		writeSCIMError(w, http.StatusInternalServerError, "", failure)
+	}
+}
+
+// writeSCIMProtocolError writes a request the SCIM protocol rejects as a 400.
+func writeSCIMProtocolError(w http.ResponseWriter, err error) {
+	var scimErr *scim.Error
+	if errors.As(err, &scimErr) {
+		writeSCIMError(w, http.StatusBadRequest, scimErr.Type, scimErr.Detail)
+		return
+	}
+	writeSCIMError(w, http.StatusBadRequest, scim.ErrorInvalidValue, err.Error())
+}
+
+// writeSCIMError writes a SCIM error response.
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeSCIM(w, status, scim.ErrorResponse{
+		Schemas:  []string{scim.SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// writeSCIMList writes a list response starting at the 1-based startIndex.
+func writeSCIMList(w http.ResponseWriter, total int64, startIndex int, resources []any) {
+	writeSCIM(w, http.StatusOK, scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	})
+}
+
+// writeSCIM writes body as a SCIM JSON response.
+func writeSCIM(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("couldn't write SCIM response: %v\n", err)
+	}
+}
+
+func newSCIMTokenResponse(token *contracts.SCIMToken) SCIMTokenResponse {
+	return SCIMTokenResponse{
+		ID:          token.ID.String(),
+		Tenant:      token.Tenant,
+		Description: token.Description,
+		CreatedAt:   token.CreatedAt.String(),
+		LastUsedAt:  optionalTimeString(token.LastUsedAt),
+	}
+}
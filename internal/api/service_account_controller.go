@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// ServiceAccountController houses dependencies for service accounts, managed by admins and by
+// organization admins, and for exchanging their API keys.
+type ServiceAccountController struct {
+	serviceAccountService service.ServiceAccountService
+}
+
+// NewServiceAccountController constructs a ServiceAccountController.
+func NewServiceAccountController(serviceAccountService service.ServiceAccountService) *ServiceAccountController {
+	return &ServiceAccountController{
+		serviceAccountService: serviceAccountService,
+	}
+}
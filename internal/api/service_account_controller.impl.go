@@ -0,0 +1,253 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// The service account handlers serve both /admin/service-accounts and
+// /organizations/{orgID}/service-accounts; the orgID path parameter, when present, scopes them to
+// the organization.
+
+// ListServiceAccounts handler returns the service accounts the caller manages.
+func (c *ServiceAccountController) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+
+	accounts, err := c.serviceAccountService.ListAccounts(r.Context(), owner)
+	if err != nil {
+		writeServiceAccountError(w, err, "failed to list service accounts")
+		return
+	}
+
+	data := make([]ServiceAccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		data = append(data, newServiceAccountResponse(account))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreateServiceAccount handler creates a service account without keys.
+func (c *ServiceAccountController) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreateServiceAccountRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := c.serviceAccountService.CreateAccount(r.Context(), owner, body.Name, body.Description, body.Role)
+	if err != nil {
+		writeServiceAccountError(w, err, "failed to create service account")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data:    newServiceAccountResponse(account),
+	})
+}
+
+// GetServiceAccount handler returns a service account with its keys, without the keys themselves.
+func (c *ServiceAccountController) GetServiceAccount(w http.ResponseWriter, r *http.Request) {
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := serviceAccountIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	account, err := c.serviceAccountService.GetAccount(r.Context(), owner, id)
+	if err != nil {
+		writeServiceAccountError(w, err, "failed to get service account")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newServiceAccountResponse(account),
+	})
+}
+
+// DeleteServiceAccount handler deletes a service account with its keys.
+func (c *ServiceAccountController) DeleteServiceAccount(w http.ResponseWriter, r *http.Request) {
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := serviceAccountIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.serviceAccountService.DeleteAccount(r.Context(), owner, id); err != nil {
+		writeServiceAccountError(w, err, "failed to delete service account")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateServiceAccountKey handler creates an API key for a service account and returns it, which is
+// not shown again.
+func (c *ServiceAccountController) CreateServiceAccountKey(w http.ResponseWriter, r *http.Request) {
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := serviceAccountIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	key, apiKey, err := c.serviceAccountService.CreateKey(r.Context(), owner, id)
+	if err != nil {
+		writeServiceAccountError(w, err, "failed to create key")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: CreatedServiceAccountKeyResponse{
+			ServiceAccountKey: newServiceAccountKeyResponse(key),
+			APIKey:            apiKey,
+		},
+	})
+}
+
+// RevokeServiceAccountKey handler revokes one of a service account's API keys.
+func (c *ServiceAccountController) RevokeServiceAccountKey(w http.ResponseWriter, r *http.Request) {
+	owner, ok := serviceAccountOwner(w, r)
+	if !ok {
+		return
+	}
+	id, ok := serviceAccountIDParam(w, r)
+	if !ok {
+		return
+	}
+	keyID, err := uuid.Parse(chi.URLParam(r, "keyID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid key id"})
+		return
+	}
+
+	if err := c.serviceAccountService.RevokeKey(r.Context(), owner, id, keyID); err != nil {
+		writeServiceAccountError(w, err, "failed to revoke key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Token handler exchanges a service account API key for a short-lived access token. There is no
+// refresh token; the key is exchanged again when the access token expires.
+func (c *ServiceAccountController) Token(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[ServiceAccountTokenRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	accessToken, err := c.serviceAccountService.IssueAccessToken(r.Context(), body.APIKey)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid api key"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to issue access token"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: SessionTokenResponse{
+			AccessToken: accessToken.Token,
+			ExpiresAt:   accessToken.ExpiresAt.String(),
+		},
+	})
+}
+
+// serviceAccountOwner returns who a service account request acts for: the caller, within the orgID
+// path parameter if there is one. It writes an error response if either is missing or malformed.
+func serviceAccountOwner(w http.ResponseWriter, r *http.Request) (service.ServiceAccountOwner, bool) {
+	if chi.URLParam(r, "orgID") == "" {
+		userID, ok := requireUserID(w, r)
+		return service.ServiceAccountOwner{UserID: userID}, ok
+	}
+	userID, orgID, ok := organizationRequest(w, r)
+	return service.ServiceAccountOwner{UserID: userID, OrganizationID: &orgID}, ok
+}
+
+// serviceAccountIDParam parses the accountID path parameter, writing a 400 if it is malformed.
+func serviceAccountIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "accountID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid service account id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeServiceAccountError maps service account service errors to responses.
+func writeServiceAccountError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrServiceAccountNotFound), errors.Is(err, service.ErrServiceAccountKeyNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrOrganizationNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "organization not found"})
+	case errors.Is(err, service.ErrInsufficientOrganizationRole):
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "insufficient permissions"})
+	case errors.Is(err, service.ErrTooManyServiceAccountKeys):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidServiceAccountName), errors.Is(err, service.ErrServiceAccountRole):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrRoleNotFound):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "unknown role"})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: failure})
+	}
+}
+
+func newServiceAccountResponse(account *contracts.ServiceAccount) ServiceAccountResponse {
+	response := ServiceAccountResponse{
+		ID:             account.ID.String(),
+		Name:           account.Name,
+		Description:    account.Description,
+		Role:           account.Role,
+		OrganizationID: optionalUUIDString(account.OrganizationID),
+		CreatedBy:      optionalUUIDString(account.CreatedBy),
+		CreatedAt:      account.CreatedAt.String(),
+		UpdatedAt:      account.UpdatedAt.String(),
+	}
+	for _, key := range account.Keys {
+		response.Keys = append(response.Keys, newServiceAccountKeyResponse(key))
+	}
+	return response
+}
+
+func newServiceAccountKeyResponse(key *contracts.ServiceAccountKey) ServiceAccountKeyResponse {
+	return ServiceAccountKeyResponse{
+		ID:         key.ID.String(),
+		Prefix:     key.Prefix,
+		CreatedAt:  key.CreatedAt.String(),
+		LastUsedAt: optionalTimeString(key.LastUsedAt),
+	}
+}
@@ -0,0 +1,21 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// SessionController houses dependencies for backend-for-frontend session endpoints.
+type SessionController struct {
+	authService    service.AuthService
+	sessionService service.SessionService
+	cookieProfiles service.CookieProfiles
+}
+
+// NewSessionController constructs a SessionController.
+func NewSessionController(authService service.AuthService, sessionService service.SessionService, cookieProfiles service.CookieProfiles) *SessionController {
+	return &SessionController{
+		authService:    authService,
+		sessionService: sessionService,
+		cookieProfiles: cookieProfiles,
+	}
+}
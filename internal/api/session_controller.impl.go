@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// Login handler authenticates a user and starts a server-side session, setting only the opaque
+// session cookie.
+func (c *SessionController) Login(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[LoginRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	organizationID, ok := organizationIDFromRequest(w, body.OrganizationID)
+	if !ok {
+		return
+	}
+
+	user, err := c.authService.Login(r.Context(), body.Email, body.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "invalid credentials"})
+			return
+		}
+		if errors.Is(err, service.ErrAccountInactive) {
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+			return
+		}
+		if errors.Is(err, service.ErrHasherSaturated) {
+			writeServiceUnavailable(w)
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "login failed"})
+		return
+	}
+
+	token, session, err := c.sessionService.Start(r.Context(), user, organizationID)
+	if errors.Is(err, service.ErrNotOrganizationMember) {
+		requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "not a member of the organization"})
+		return
+	}
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to start session"})
+		return
+	}
+
+	http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateSetSessionCookie(token))
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: SessionResponseData{
+			User: AuthUserResponse{
+				ID:        user.ID.String(),
+				Username:  user.Username,
+				Email:     user.Email,
+				UpdatedAt: user.UpdatedAt.String(),
+				CreatedAt: user.CreatedAt.String(),
+				Role:      user.Role,
+			},
+			ExpiresAt: session.ExpiresAt.String(),
+		},
+	})
+}
+
+// Token handler is a forward-auth endpoint exchanging the session cookie for a short-lived access
+// token. The token is only returned in the Authorization header, for the proxy to pass on to the
+// upstream API; it is never part of a body a browser could read.
+func (c *SessionController) Token(w http.ResponseWriter, r *http.Request) {
+	var sessionToken string
+	if cookie, err := r.Cookie(cookiesFor(c.cookieProfiles, r).SessionCookieName()); err == nil {
+		sessionToken = cookie.Value
+	}
+
+	accessToken, err := c.sessionService.Exchange(r.Context(), sessionToken)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) || errors.Is(err, service.ErrAccountInactive) || errors.Is(err, service.ErrUserNotFound) {
+			http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateClearSessionCookie())
+			requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "session is invalid or expired"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to issue access token"})
+		return
+	}
+
+	w.Header().Set("Authorization", "Bearer "+accessToken.Token)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout handler ends the session and clears the session cookie.
+func (c *SessionController) Logout(w http.ResponseWriter, r *http.Request) {
+	var sessionToken string
+	if cookie, err := r.Cookie(cookiesFor(c.cookieProfiles, r).SessionCookieName()); err == nil {
+		sessionToken = cookie.Value
+	}
+
+	// Ending an unknown session is a no-op
+	if err := c.sessionService.End(r.Context(), sessionToken); err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to logout"})
+		return
+	}
+
+	http.SetCookie(w, cookiesFor(c.cookieProfiles, r).CreateClearSessionCookie())
+
+	w.WriteHeader(http.StatusNoContent)
+}
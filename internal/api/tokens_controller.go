@@ -0,0 +1,20 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// TokensController houses dependencies for personal access tokens and for validating the tokens
+// other bids services are presented with.
+type TokensController struct {
+	tokenService service.TokenService
+	patService   service.PersonalAccessTokenService
+}
+
+// NewTokensController constructs a TokensController.
+func NewTokensController(tokenService service.TokenService, patService service.PersonalAccessTokenService) *TokensController {
+	return &TokensController{
+		tokenService: tokenService,
+		patService:   patService,
+	}
+}
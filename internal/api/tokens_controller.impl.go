@@ -0,0 +1,243 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+// Token types reported by introspection.
+const (
+	tokenTypeAccess   = "access_token"
+	tokenTypePersonal = "personal_access_token"
+)
+
+// ListTokens handler returns the authenticated user's personal access tokens, without the tokens
+// themselves.
+func (c *TokensController) ListTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	tokens, err := c.patService.ListTokens(r.Context(), userID)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list tokens"})
+		return
+	}
+
+	data := make([]PersonalAccessTokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		data = append(data, newPersonalAccessTokenResponse(token))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreateToken handler creates a personal access token for the authenticated user and returns it,
+// which is not shown again.
+func (c *TokensController) CreateToken(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreatePersonalAccessTokenRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if body.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, body.ExpiresAt)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "expires_at must be an RFC 3339 time"})
+			return
+		}
+		expiresAt = &t
+	}
+
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	record, token, err := c.patService.CreateToken(r.Context(), userID, body.Name, body.Scopes, expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidTokenName), errors.Is(err, service.ErrInvalidTokenScopes),
+			errors.Is(err, service.ErrInvalidTokenLifetime), errors.Is(err, service.ErrScopeNotGranted):
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+		case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrAccountInactive):
+			requests.WriteJSON(w, http.StatusForbidden, requests.APIResponse{Success: false, Error: "account is not active"})
+		default:
+			requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to create token"})
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: CreatedPersonalAccessTokenResponse{
+			PersonalAccessToken: newPersonalAccessTokenResponse(record),
+			Token:               token,
+		},
+	})
+}
+
+// RevokeToken handler revokes one of the authenticated user's personal access tokens.
+func (c *TokensController) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid token id"})
+		return
+	}
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	if err := c.patService.RevokeToken(r.Context(), userID, id); err != nil {
+		if errors.Is(err, service.ErrAccessTokenNotFound) {
+			requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "token not found"})
+			return
+		}
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to revoke token"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Introspect handler reports whether an access token or personal access token is active and what
+// it carries, for other services. Inactive tokens are reported in the body with a 200, not as an
+// error.
+func (c *TokensController) Introspect(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[IntrospectTokenRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	claims, tokenType, err := c.resolveToken(r, body.Token)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to introspect token"})
+		return
+	}
+
+	data := IntrospectionResponse{Active: claims != nil}
+	if claims != nil {
+		data.TokenType = tokenType
+		data.Subject = claims.Subject
+		data.Username = claims.Name
+		data.Role = claims.Role
+		data.Scope = claims.Scope
+		data.SessionID = claims.SessionID
+		data.OrganizationID = claims.OrganizationID
+		data.SubjectType = claims.SubjectType
+		if claims.IssuedAt != nil {
+			data.IssuedAt = claims.IssuedAt.Unix()
+		}
+		if claims.ExpiresAt != nil {
+			data.ExpiresAt = claims.ExpiresAt.Unix()
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// Verify handler is a forward-auth endpoint for bearer tokens. A valid access token is passed back
+// in the Authorization header for the proxy to send on to the upstream API; a personal access token
+// is exchanged for a short-lived access token carrying its scope, so upstream APIs only ever see
+// JWTs.
+func (c *TokensController) Verify(w http.ResponseWriter, r *http.Request) {
+	token, ok := bearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "authentication required"})
+		return
+	}
+
+	claims, tokenType, err := c.resolveToken(r, token)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to verify token"})
+		return
+	}
+	if claims == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		requests.WriteJSON(w, http.StatusUnauthorized, requests.APIResponse{Success: false, Error: "token is invalid or expired"})
+		return
+	}
+
+	accessToken := &service.AccessToken{Token: token, ExpiresAt: claims.ExpiresAt.Time}
+	if tokenType == tokenTypePersonal {
+		if accessToken, err = c.tokenService.IssueScopedAccessToken(claims); err != nil {
+			requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to issue access token"})
+			return
+		}
+	}
+
+	w.Header().Set("Authorization", "Bearer "+accessToken.Token)
+	w.Header().Set("Cache-Control", "no-store")
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: SessionTokenResponse{
+			AccessToken: accessToken.Token,
+			ExpiresAt:   accessToken.ExpiresAt.String(),
+		},
+	})
+}
+
+// resolveToken returns the claims of a presented access token or personal access token with its
+// type, or nil claims if it isn't valid. Access tokens are also invalid once what they were issued to
+// can no longer act, e.g. when their session or personal access token was revoked.
+func (c *TokensController) resolveToken(r *http.Request, token string) (*service.AccessTokenClaims, string, error) {
+	if strings.HasPrefix(token, service.PersonalAccessTokenPrefix) {
+		claims, err := c.patService.Authenticate(r.Context(), token)
+		if errors.Is(err, service.ErrInvalidAccessToken) {
+			return nil, "", nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return claims, tokenTypePersonal, nil
+	}
+
+	claims, err := c.tokenService.ParseAccessToken(token)
+	if err != nil {
+		return nil, "", nil
+	}
+	live, err := c.tokenService.CheckAccessToken(r.Context(), claims)
+	if err != nil {
+		return nil, "", err
+	}
+	if live == nil {
+		return nil, "", nil
+	}
+	return live, tokenTypeAccess, nil
+}
+
+func newPersonalAccessTokenResponse(token *contracts.PersonalAccessToken) PersonalAccessTokenResponse {
+	return PersonalAccessTokenResponse{
+		ID:         token.ID.String(),
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt.String(),
+		ExpiresAt:  token.ExpiresAt.String(),
+		LastUsedAt: optionalTimeString(token.LastUsedAt),
+	}
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+)
+
+// WebhookController houses dependencies for partner webhook administration endpoints.
+type WebhookController struct {
+	webhookService service.WebhookService
+}
+
+// NewWebhookController constructs a WebhookController.
+func NewWebhookController(webhookService service.WebhookService) *WebhookController {
+	return &WebhookController{
+		webhookService: webhookService,
+	}
+}
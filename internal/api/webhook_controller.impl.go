@@ -0,0 +1,251 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/service"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+const (
+	defaultDeadLetterPageSize = 50
+	maxDeadLetterPageSize     = 200
+)
+
+// ListWebhooks handler returns every webhook subscription.
+func (c *WebhookController) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := c.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list webhooks"})
+		return
+	}
+
+	data := make([]WebhookResponse, 0, len(subs))
+	for _, sub := range subs {
+		data = append(data, newWebhookResponse(sub))
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// CreateWebhook handler registers a webhook subscription and returns its signing secret, which is
+// not shown again.
+func (c *WebhookController) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[CreateWebhookRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+
+	sub, err := c.webhookService.CreateSubscription(r.Context(), body.URL, body.EventTypes, body.Description)
+	if err != nil {
+		writeWebhookError(w, err, "failed to create webhook")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusCreated, requests.APIResponse{
+		Success: true,
+		Data: WebhookSecretResponse{
+			Webhook: newWebhookResponse(sub),
+			Secret:  sub.Secret,
+		},
+	})
+}
+
+// GetWebhook handler returns a single webhook subscription.
+func (c *WebhookController) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := c.webhookService.GetSubscription(r.Context(), id)
+	writeWebhook(w, sub, err, "failed to get webhook")
+}
+
+// UpdateWebhook handler changes a webhook subscription's settings.
+func (c *WebhookController) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	body := requests.GetRequestBody[UpdateWebhookRequest](r)
+	if body == nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to parse request"})
+		return
+	}
+	if body.Active == nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "active is required"})
+		return
+	}
+	id, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := c.webhookService.UpdateSubscription(r.Context(), id, body.URL, body.EventTypes, body.Description, *body.Active)
+	writeWebhook(w, sub, err, "failed to update webhook")
+}
+
+// DeleteWebhook handler removes a webhook subscription with its pending deliveries and dead letters.
+func (c *WebhookController) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.webhookService.DeleteSubscription(r.Context(), id); err != nil {
+		writeWebhookError(w, err, "failed to delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RotateWebhookSecret handler replaces a webhook subscription's signing secret and returns the new one.
+func (c *WebhookController) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	sub, err := c.webhookService.RotateSecret(r.Context(), id)
+	if err != nil {
+		writeWebhookError(w, err, "failed to rotate webhook secret")
+		return
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data: WebhookSecretResponse{
+			Webhook: newWebhookResponse(sub),
+			Secret:  sub.Secret,
+		},
+	})
+}
+
+// ListDeadLetters handler returns a page of failed webhook deliveries, newest first. Query parameters:
+// webhook_id, limit and cursor.
+func (c *WebhookController) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var subscriptionID *uuid.UUID
+	if raw := query.Get("webhook_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid webhook_id"})
+			return
+		}
+		subscriptionID = &id
+	}
+	limit, beforeID, ok := parseIDPageQuery(w, query, defaultDeadLetterPageSize, maxDeadLetterPageSize)
+	if !ok {
+		return
+	}
+
+	page, err := c.webhookService.ListDeadLetters(r.Context(), subscriptionID, beforeID, limit)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: "failed to list dead letters"})
+		return
+	}
+
+	data := WebhookDeadLetterListResponse{DeadLetters: make([]WebhookDeadLetterResponse, 0, len(page.DeadLetters))}
+	for _, letter := range page.DeadLetters {
+		data.DeadLetters = append(data.DeadLetters, newWebhookDeadLetterResponse(letter))
+	}
+	if page.NextCursor > 0 {
+		data.NextCursor = strconv.FormatInt(page.NextCursor, 10)
+	}
+
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    data,
+	})
+}
+
+// ReplayDeadLetter handler queues a failed webhook delivery to be sent again.
+func (c *WebhookController) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "deadLetterID"), 10, 64)
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid dead letter id"})
+		return
+	}
+
+	if err := c.webhookService.ReplayDeadLetter(r.Context(), id); err != nil {
+		writeWebhookError(w, err, "failed to replay dead letter")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// webhookIDParam parses the webhookID path parameter, writing a 400 if it is malformed.
+func webhookIDParam(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "webhookID"))
+	if err != nil {
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: "invalid webhook id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeWebhook writes a subscription or the error returned instead of it.
+func writeWebhook(w http.ResponseWriter, sub *contracts.WebhookSubscription, err error, failure string) {
+	if err != nil {
+		writeWebhookError(w, err, failure)
+		return
+	}
+	requests.WriteJSON(w, http.StatusOK, requests.APIResponse{
+		Success: true,
+		Data:    newWebhookResponse(sub),
+	})
+}
+
+// writeWebhookError maps webhook service errors to responses.
+func writeWebhookError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "webhook not found"})
+	case errors.Is(err, service.ErrDeadLetterNotFound):
+		requests.WriteJSON(w, http.StatusNotFound, requests.APIResponse{Success: false, Error: "dead letter not found"})
+	case errors.Is(err, service.ErrDeadLetterQueued):
+		requests.WriteJSON(w, http.StatusConflict, requests.APIResponse{Success: false, Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidWebhookURL), errors.Is(err, service.ErrInvalidWebhookEventType):
+		requests.WriteJSON(w, http.StatusBadRequest, requests.APIResponse{Success: false, Error: err.Error()})
+	default:
+		requests.WriteJSON(w, http.StatusInternalServerError, requests.APIResponse{Success: false, Error: failure})
+	}
+}
+
+func newWebhookResponse(sub *contracts.WebhookSubscription) WebhookResponse {
+	eventTypes := sub.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return WebhookResponse{
+		ID:          sub.ID.String(),
+		URL:         sub.URL,
+		EventTypes:  eventTypes,
+		Description: sub.Description,
+		Active:      sub.Active,
+		CreatedAt:   sub.CreatedAt.String(),
+		UpdatedAt:   sub.UpdatedAt.String(),
+	}
+}
+
+func newWebhookDeadLetterResponse(letter *contracts.WebhookDeadLetter) WebhookDeadLetterResponse {
+	return WebhookDeadLetterResponse{
+		ID:         letter.ID,
+		WebhookID:  letter.SubscriptionID.String(),
+		EventID:    letter.EventID.String(),
+		EventType:  letter.EventType,
+		Payload:    letter.Payload,
+		Attempts:   letter.Attempts,
+		LastStatus: letter.LastStatus,
+		LastError:  letter.LastError,
+		CreatedAt:  letter.CreatedAt.String(),
+		FailedAt:   letter.FailedAt.String(),
+	}
+}
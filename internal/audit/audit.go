@@ -0,0 +1,31 @@
+// Package audit carries the request details recorded with authentication events from the HTTP
+// layer to the services that write them.
+package audit
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// Request describes who made a request and from where.
+type Request struct {
+	ActorID   *uuid.UUID // authenticated caller, if any
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// WithRequest returns a copy of ctx carrying req.
+func WithRequest(ctx context.Context, req Request) context.Context {
+	return context.WithValue(ctx, contextKey{}, req)
+}
+
+// RequestFrom returns the request stored in ctx, or a zero Request for work not triggered by
+// an HTTP request (e.g. background jobs).
+func RequestFrom(ctx context.Context) Request {
+	req, _ := ctx.Value(contextKey{}).(Request)
+	return req
+}
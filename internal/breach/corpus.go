@@ -0,0 +1,86 @@
+// Package breach screens passwords against an offline corpus of breached passwords, such as
+// the Have I Been Pwned SHA-1 dump, compiled into a compact binary fuse filter.
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Corpus answers whether a password appears in the breached-password corpus. Lookups need no
+// network access and cost one SHA-1 plus three memory reads.
+type Corpus struct {
+	filter *Filter
+}
+
+// LoadCorpus reads a filter file produced by cmd/build-breach-filter.
+func LoadCorpus(path string) (*Corpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	filter, err := ReadFilter(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Corpus{filter: filter}, nil
+}
+
+// NewCorpus wraps an already built filter.
+func NewCorpus(filter *Filter) *Corpus {
+	return &Corpus{filter: filter}
+}
+
+// IsBreached reports whether password is (probably) in the corpus.
+func (c *Corpus) IsBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	return c.filter.Contains(KeyFromSHA1(sum[:]))
+}
+
+// KeyFromSHA1 derives a filter key from the first 8 bytes of a SHA-1 digest.
+func KeyFromSHA1(sum []byte) uint64 {
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// ReadHIBPKeys parses a Have I Been Pwned SHA-1 dump ("<40 hex chars>:<count>" per line, the
+// count being optional) and returns a filter key per hash seen at least minCount times.
+func ReadHIBPKeys(r io.Reader, minCount int) ([]uint64, error) {
+	scanner := bufio.NewScanner(r)
+	var keys []uint64
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		hashHex, countStr, hasCount := strings.Cut(text, ":")
+		if len(hashHex) != 2*sha1.Size {
+			return nil, fmt.Errorf("line %d: expected a SHA-1 hex digest", line)
+		}
+		if hasCount && minCount > 1 {
+			count, err := strconv.Atoi(countStr)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid count %q", line, countStr)
+			}
+			if count < minCount {
+				continue
+			}
+		}
+		sum, err := hex.DecodeString(hashHex[:16])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		keys = append(keys, KeyFromSHA1(sum))
+	}
+	return keys, scanner.Err()
+}
@@ -0,0 +1,308 @@
+package breach
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// fuseMagic identifies a serialised filter file.
+var fuseMagic = [8]byte{'B', 'F', 'U', 'S', 'E', '1', '6', 0}
+
+const maxPopulateIterations = 100
+
+// Filter is a binary fuse filter with 16-bit fingerprints: a static set membership structure
+// using about 2.25 bytes per key with a false positive rate of roughly 1 in 65536 and no false
+// negatives. See Graf & Lemire, "Binary Fuse Filters: Fast and Smaller Than Xor Filters" (2022).
+type Filter struct {
+	seed               uint64
+	segmentLength      uint32
+	segmentLengthMask  uint32
+	segmentCount       uint32
+	segmentCountLength uint32
+	fingerprints       []uint16
+}
+
+// NewFilter builds a filter containing keys. Keys should already be uniformly distributed
+// (e.g. a prefix of a cryptographic hash). keys is sorted and deduplicated in place.
+func NewFilter(keys []uint64) (*Filter, error) {
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	size := uint32(len(keys))
+	f := &Filter{}
+	f.initParameters(size)
+	if size == 0 {
+		return f, nil
+	}
+
+	rng := uint64(1)
+	f.seed = splitmix64(&rng)
+	capacity := uint32(len(f.fingerprints))
+	alone := make([]uint32, capacity)
+	t2count := make([]uint8, capacity)
+	t2hash := make([]uint64, capacity)
+	reverseH := make([]uint8, size)
+	reverseOrder := make([]uint64, size+1)
+	reverseOrder[size] = 1
+
+	blockBits := 1
+	for (uint32(1) << blockBits) < f.segmentCount {
+		blockBits++
+	}
+	block := uint32(1) << blockBits
+	startPos := make([]uint32, block)
+	var h012 [5]uint32
+
+	for iteration := 0; ; iteration++ {
+		if iteration >= maxPopulateIterations {
+			return nil, errors.New("breach: failed to build filter")
+		}
+
+		// Sort hashes roughly by segment for cache-friendly construction
+		for i := uint32(0); i < block; i++ {
+			startPos[i] = uint32((uint64(i) * uint64(size)) >> blockBits)
+		}
+		blockMask := uint64(block - 1)
+		for _, key := range keys {
+			hash := mixSplit(key, f.seed)
+			segment := hash >> (64 - blockBits)
+			for reverseOrder[startPos[segment]] != 0 {
+				segment = (segment + 1) & blockMask
+			}
+			reverseOrder[startPos[segment]] = hash
+			startPos[segment]++
+		}
+
+		failed := false
+		for i := uint32(0); i < size; i++ {
+			hash := reverseOrder[i]
+			i1, i2, i3 := f.hashIndexes(hash)
+			t2count[i1] += 4
+			t2hash[i1] ^= hash
+			t2count[i2] += 4
+			t2count[i2] ^= 1
+			t2hash[i2] ^= hash
+			t2count[i3] += 4
+			t2count[i3] ^= 2
+			t2hash[i3] ^= hash
+			// Counts wrap past 63 keys per slot; retry with another seed
+			if t2count[i1] < 4 || t2count[i2] < 4 || t2count[i3] < 4 {
+				failed = true
+			}
+		}
+
+		if !failed {
+			// Peel slots that hold exactly one key
+			queued := 0
+			for i := uint32(0); i < capacity; i++ {
+				alone[queued] = i
+				if t2count[i]>>2 == 1 {
+					queued++
+				}
+			}
+			stackSize := uint32(0)
+			for queued > 0 {
+				queued--
+				index := alone[queued]
+				if t2count[index]>>2 != 1 {
+					continue
+				}
+				hash := t2hash[index]
+				found := t2count[index] & 3
+				reverseH[stackSize] = found
+				reverseOrder[stackSize] = hash
+				stackSize++
+
+				i1, i2, i3 := f.hashIndexes(hash)
+				h012[1], h012[2], h012[3], h012[4] = i2, i3, i1, i2
+
+				other := h012[found+1]
+				alone[queued] = other
+				if t2count[other]>>2 == 2 {
+					queued++
+				}
+				t2count[other] -= 4
+				t2count[other] ^= mod3(found + 1)
+				t2hash[other] ^= hash
+
+				other = h012[found+2]
+				alone[queued] = other
+				if t2count[other]>>2 == 2 {
+					queued++
+				}
+				t2count[other] -= 4
+				t2count[other] ^= mod3(found + 2)
+				t2hash[other] ^= hash
+			}
+			if stackSize == size {
+				// Assign fingerprints in reverse peeling order
+				for i := int(stackSize) - 1; i >= 0; i-- {
+					hash := reverseOrder[i]
+					i1, i2, i3 := f.hashIndexes(hash)
+					found := reverseH[i]
+					h012[0], h012[1], h012[2], h012[3], h012[4] = i1, i2, i3, i1, i2
+					f.fingerprints[h012[found]] = fingerprint(hash) ^ f.fingerprints[h012[found+1]] ^ f.fingerprints[h012[found+2]]
+				}
+				return f, nil
+			}
+		}
+
+		// Retry with a new seed
+		clear(reverseOrder[:size])
+		clear(t2count)
+		clear(t2hash)
+		f.seed = splitmix64(&rng)
+	}
+}
+
+// Contains reports whether key is (probably) in the filter.
+func (f *Filter) Contains(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+	hash := mixSplit(key, f.seed)
+	i1, i2, i3 := f.hashIndexes(hash)
+	return fingerprint(hash)^f.fingerprints[i1]^f.fingerprints[i2]^f.fingerprints[i3] == 0
+}
+
+// Len returns the number of fingerprint slots.
+func (f *Filter) Len() int {
+	return len(f.fingerprints)
+}
+
+// WriteTo serialises the filter in a little-endian binary format.
+func (f *Filter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	header := struct {
+		Magic              [8]byte
+		Seed               uint64
+		SegmentLength      uint32
+		SegmentCount       uint32
+		SegmentCountLength uint32
+		Length             uint32
+	}{fuseMagic, f.seed, f.segmentLength, f.segmentCount, f.segmentCountLength, uint32(len(f.fingerprints))}
+	if err := binary.Write(bw, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(bw, binary.LittleEndian, f.fingerprints); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return int64(binary.Size(header) + 2*len(f.fingerprints)), nil
+}
+
+// ReadFilter deserialises a filter written by WriteTo.
+func ReadFilter(r io.Reader) (*Filter, error) {
+	br := bufio.NewReader(r)
+	var header struct {
+		Magic              [8]byte
+		Seed               uint64
+		SegmentLength      uint32
+		SegmentCount       uint32
+		SegmentCountLength uint32
+		Length             uint32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("breach: read header: %w", err)
+	}
+	if header.Magic != fuseMagic {
+		return nil, errors.New("breach: not a filter file")
+	}
+	if header.SegmentLength == 0 || header.SegmentLength&(header.SegmentLength-1) != 0 ||
+		header.SegmentCountLength != header.SegmentCount*header.SegmentLength ||
+		header.Length != (header.SegmentCount+2)*header.SegmentLength {
+		return nil, errors.New("breach: corrupt filter header")
+	}
+	f := &Filter{
+		seed:               header.Seed,
+		segmentLength:      header.SegmentLength,
+		segmentLengthMask:  header.SegmentLength - 1,
+		segmentCount:       header.SegmentCount,
+		segmentCountLength: header.SegmentCountLength,
+		fingerprints:       make([]uint16, header.Length),
+	}
+	if err := binary.Read(br, binary.LittleEndian, f.fingerprints); err != nil {
+		return nil, fmt.Errorf("breach: read fingerprints: %w", err)
+	}
+	return f, nil
+}
+
+func (f *Filter) initParameters(size uint32) {
+	const arity = 3
+	f.segmentLength = 1
+	if size > 0 {
+		f.segmentLength = uint32(1) << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	}
+	f.segmentLength = min(f.segmentLength, 262144)
+	f.segmentLengthMask = f.segmentLength - 1
+
+	sizeFactor := 1.125
+	if size > 1 {
+		sizeFactor = max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+	}
+	capacity := uint32(0)
+	if size > 1 {
+		capacity = uint32(math.Round(float64(size) * sizeFactor))
+	}
+	initSegmentCount := (capacity+f.segmentLength-1)/f.segmentLength - (arity - 1)
+	arrayLength := (initSegmentCount + arity - 1) * f.segmentLength
+	f.segmentCount = (arrayLength + f.segmentLength - 1) / f.segmentLength
+	if f.segmentCount <= arity-1 {
+		f.segmentCount = 1
+	} else {
+		f.segmentCount -= arity - 1
+	}
+	arrayLength = (f.segmentCount + arity - 1) * f.segmentLength
+	f.segmentCountLength = f.segmentCount * f.segmentLength
+	f.fingerprints = make([]uint16, arrayLength)
+}
+
+func (f *Filter) hashIndexes(hash uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(hash, uint64(f.segmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + f.segmentLength
+	h2 := h1 + f.segmentLength
+	h1 ^= uint32(hash>>18) & f.segmentLengthMask
+	h2 ^= uint32(hash) & f.segmentLengthMask
+	return h0, h1, h2
+}
+
+func fingerprint(hash uint64) uint16 {
+	return uint16(hash ^ (hash >> 32))
+}
+
+func mod3(x uint8) uint8 {
+	if x > 2 {
+		x -= 3
+	}
+	return x
+}
+
+func murmur64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func mixSplit(key, seed uint64) uint64 {
+	return murmur64(key + seed)
+}
+
+func splitmix64(seed *uint64) uint64 {
+	*seed += 0x9e3779b97f4a7c15
+	z := *seed
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
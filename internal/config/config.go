@@ -4,6 +4,11 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/LittleAksMax/bids-util/env"
@@ -19,20 +24,182 @@ type Config struct {
 
 	AccessTokenSecret  string
 	RefreshTokenSecret string
+	APIKeySecret       string // keys the HMACs of personal access tokens and service account API keys, read from API_KEY_SECRET
 	AccessTokenTTL     time.Duration
 	RefreshTokenTTL    time.Duration
 	ValidationAPIKey   string
 	TokenIssuer        string
 	TokenAudience      string
+	AccessTokenScope   bool // embed the permissions of the user's role in access tokens, read from ACCESS_TOKEN_SCOPE
 
-	PasswordPepper string // Add this field for password pepper
+	PersonalTokenTTL    time.Duration // lifetime of personal access tokens created without an expiry, read from PERSONAL_ACCESS_TOKEN_TTL
+	PersonalTokenMaxTTL time.Duration // longest lifetime a personal access token may have, read from PERSONAL_ACCESS_TOKEN_MAX_TTL
+
+	RegistrationRoles []string // roles users may pick when registering themselves, read from REGISTRATION_ROLES
+
+	PasswordPepper          string         // Add this field for password pepper
+	PasswordPepperID        int            // version of PasswordPepper, read from PASSWORD_PEPPER_ID
+	PreviousPasswordPeppers map[int]string // retired peppers kept for verification, read from PASSWORD_PEPPERS_PREVIOUS
+
+	BreachedPasswordsFile string         // optional breached-password filter, read from BREACHED_PASSWORDS_FILE
+	PasswordPolicy        PasswordPolicy // rules for new passwords, read from PASSWORD_* variables
+
+	HashWorkers      int           // maximum concurrent password hashes, read from HASH_WORKERS
+	HashQueueDepth   int           // maximum callers waiting for a hash worker, read from HASH_QUEUE_DEPTH
+	HashQueueTimeout time.Duration // maximum wait for a hash worker, read from HASH_QUEUE_TIMEOUT
+
+	DeletionGracePeriod   time.Duration // delay before a requested deletion is purged, read from ACCOUNT_DELETION_GRACE_PERIOD
+	DeletionPurgeInterval time.Duration // how often due deletions are purged, read from ACCOUNT_PURGE_INTERVAL
+
+	ReauthMaxAge time.Duration // how recently a session must have been authenticated to change sign-in methods without a password, read from REAUTH_MAX_AGE
+
+	SMTPHost     string // outgoing mail server, read from SMTP_HOST; mail is only logged when unset
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	InviteURL string        // link sent in invitation emails, with the token appended, read from INVITE_URL
+	InviteTTL time.Duration // how long an invitation stays valid, read from INVITE_TTL
+
+	OrganizationInviteURL string // link sent in organization invitation emails, with the token appended, read from ORGANIZATION_INVITE_URL
+
+	OutboxSink           string        // none, stdout, webhook or nats, read from OUTBOX_SINK
+	OutboxWebhookURL     string        // read from OUTBOX_WEBHOOK_URL, required for the webhook sink
+	OutboxNATSURL        string        // read from OUTBOX_NATS_URL, required for the nats sink
+	OutboxNATSSubject    string        // subject prefix, read from OUTBOX_NATS_SUBJECT_PREFIX
+	OutboxPublishTimeout time.Duration // per-event delivery timeout, read from OUTBOX_PUBLISH_TIMEOUT
+	OutboxPollInterval   time.Duration // how often the relay looks for events, read from OUTBOX_POLL_INTERVAL
+	OutboxBatchSize      int           // maximum events delivered per poll, read from OUTBOX_BATCH_SIZE
+	OutboxRetention      time.Duration // how long delivered events are kept, read from OUTBOX_RETENTION
+
+	WebhookMaxAttempts  int           // attempts before a delivery is dead-lettered, read from WEBHOOK_MAX_ATTEMPTS
+	WebhookTimeout      time.Duration // per-delivery timeout, read from WEBHOOK_TIMEOUT
+	WebhookPollInterval time.Duration // how often due deliveries are sent, read from WEBHOOK_POLL_INTERVAL
+	WebhookBatchSize    int           // maximum deliveries sent per poll, read from WEBHOOK_BATCH_SIZE
+
+	BackchannelLogoutURIs   map[string]string // client ID -> logout URI, read from BACKCHANNEL_LOGOUT_URIS
+	BackchannelKeyFile      string            // PEM RSA private key signing logout tokens, read from BACKCHANNEL_LOGOUT_KEY_FILE
+	BackchannelMaxAttempts  int               // attempts before a logout is abandoned, read from BACKCHANNEL_LOGOUT_MAX_ATTEMPTS
+	BackchannelTimeout      time.Duration     // per-notification timeout, read from BACKCHANNEL_LOGOUT_TIMEOUT
+	BackchannelPollInterval time.Duration     // how often due logouts are sent, read from BACKCHANNEL_LOGOUT_POLL_INTERVAL
+	BackchannelBatchSize    int               // maximum logouts sent per poll, read from BACKCHANNEL_LOGOUT_BATCH_SIZE
+
+	BFFEnabled         bool          // backend-for-frontend session mode, read from BFF_ENABLED
+	BFFIdleTimeout     time.Duration // session idle timeout, read from BFF_SESSION_IDLE_TIMEOUT
+	BFFAbsoluteTimeout time.Duration // session lifetime, read from BFF_SESSION_ABSOLUTE_TIMEOUT
+	BFFPurgeInterval   time.Duration // how often ended sessions are removed, read from BFF_SESSION_PURGE_INTERVAL
+
+	OIDCProviders        []OIDCProviderConfig // social login providers, listed in OIDC_PROVIDERS
+	OIDCRedirectBaseURL  string               // public base URL of this service for provider callbacks, read from OIDC_REDIRECT_BASE_URL
+	OIDCLoginRedirectURL string               // where browsers land after a social login, read from OIDC_LOGIN_REDIRECT_URL
+	OIDCStateTTL         time.Duration        // time allowed to complete a social login, read from OIDC_STATE_TTL
+	OIDCDefaultRole      string               // role of users provisioned by social login, read from OIDC_DEFAULT_ROLE
+	OIDCTimeout          time.Duration        // per-request timeout against providers, read from OIDC_TIMEOUT
+
+	SAMLConnections      []SAMLConnectionConfig // enterprise SAML identity providers, listed in SAML_CONNECTIONS
+	SAMLBaseURL          string                 // public base URL of this service for SAML endpoints, read from SAML_BASE_URL
+	SAMLCertFile         string                 // PEM certificate of the service provider, read from SAML_SP_CERT_FILE
+	SAMLKeyFile          string                 // PEM RSA private key of the service provider, read from SAML_SP_KEY_FILE
+	SAMLLoginRedirectURL string                 // where browsers land after a SAML login, read from SAML_LOGIN_REDIRECT_URL
+	SAMLStateTTL         time.Duration          // time allowed to complete a SAML login, read from SAML_STATE_TTL
+	SAMLTimeout          time.Duration          // timeout fetching IdP metadata, read from SAML_TIMEOUT
+
+	PasskeyRPID        string        // WebAuthn relying party ID passkeys are scoped to, read from PASSKEY_RP_ID; passkeys are disabled when unset
+	PasskeyRPName      string        // relying party name shown by authenticators, read from PASSKEY_RP_NAME
+	PasskeyOrigins     []string      // origins passkey responses are accepted from, read from PASSKEY_ORIGINS (comma-separated)
+	PasskeyCeremonyTTL time.Duration // time allowed to answer a passkey challenge, read from PASSKEY_CEREMONY_TTL
+
+	SCIMDefaultRole string // role of users provisioned over SCIM, read from SCIM_DEFAULT_ROLE
+	SCIMMaxResults  int    // most users returned per SCIM list request, read from SCIM_MAX_RESULTS
+
+	ServiceAccountRoles    []string // roles admin-owned service accounts may hold, the first being the default, read from SERVICE_ACCOUNT_ROLES
+	ServiceAccountOrgRoles []string // roles organization-owned service accounts may hold, the first being the default, read from SERVICE_ACCOUNT_ORGANIZATION_ROLES
+
+	Cookies        CookieProfile            // default cookie settings, read from COOKIE_*
+	CookieProfiles map[string]CookieProfile // per X-Client-ID overrides, listed in COOKIE_PROFILES
 
 	AllowedOrigins []string // CORS allowed origins, read from ALLOWED_ORIGINS (comma-separated)
+
+	MetricsToken string // bearer token scrapers present to /metrics, read from METRICS_TOKEN; /metrics is not served when unset
+
+	TrustedProxies []*net.IPNet // proxies whose X-Forwarded-For and X-Real-IP headers are believed, read from TRUSTED_PROXIES
+
+	RateLimitStore string                   // memory, postgres or redis, read from RATE_LIMIT_STORE
+	RateLimits     map[string]RateLimitRule // per-route limits, keyed by route name
+	RedisHost      string
+	RedisPort      string
+	RedisPassword  string
+}
+
+// RateLimitRule describes how many requests are allowed per window, and what identifies a caller.
+type RateLimitRule struct {
+	Limit  int
+	Window time.Duration
+	Key    string // one of the RateLimitKey constants
+}
+
+// PasswordPolicy configures the rules new passwords must satisfy.
+type PasswordPolicy struct {
+	MinLength       int      // read from PASSWORD_MIN_LENGTH
+	MaxLength       int      // read from PASSWORD_MAX_LENGTH
+	RequiredClasses []string // subset of PasswordClasses, read from PASSWORD_REQUIRED_CLASSES (comma-separated)
+	MinStrength     int      // minimum zxcvbn score from 0 to 4, read from PASSWORD_MIN_STRENGTH
+	RejectSimilar   bool     // reject passwords resembling the username or email, read from PASSWORD_REJECT_SIMILAR
+	HistoryDepth    int      // number of most recent passwords that can't be reused, read from PASSWORD_HISTORY_DEPTH
+}
+
+// Character classes a PasswordPolicy can require.
+const (
+	PasswordClassLower  = "lower"
+	PasswordClassUpper  = "upper"
+	PasswordClassDigit  = "digit"
+	PasswordClassSymbol = "symbol"
+)
+
+// PasswordClasses lists every supported character class.
+var PasswordClasses = []string{PasswordClassLower, PasswordClassUpper, PasswordClassDigit, PasswordClassSymbol}
+
+// Outbox event sinks.
+const (
+	OutboxSinkNone    = "none"
+	OutboxSinkStdout  = "stdout"
+	OutboxSinkWebhook = "webhook"
+	OutboxSinkNATS    = "nats"
+)
+
+// Rate limit store backends.
+const (
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+	RateLimitStoreRedis    = "redis"
+)
+
+// Rate limit keys, identifying the caller a request counts against.
+const (
+	RateLimitKeyIP       = "ip"        // the client IP
+	RateLimitKeyUser     = "user"      // the authenticated user, falling back to the client IP
+	RateLimitKeyClient   = "client"    // the authenticated user or SCIM tenant, falling back to the client IP
+	RateLimitKeyClientID = "client_id" // the X-Client-ID header naming a client in COOKIE_PROFILES, falling back to the client IP
+)
+
+// RateLimitKeys lists every supported rate limit key.
+var RateLimitKeys = []string{RateLimitKeyIP, RateLimitKeyUser, RateLimitKeyClient, RateLimitKeyClientID}
+
+// defaultRateLimits are used for any route whose RATE_LIMIT_<ROUTE> variable is unset.
+var defaultRateLimits = map[string]RateLimitRule{
+	"register": {Limit: 10, Window: time.Hour, Key: RateLimitKeyIP},
+	"login":    {Limit: 10, Window: time.Minute, Key: RateLimitKeyIP},
+	"refresh":  {Limit: 30, Window: time.Minute, Key: RateLimitKeyIP},
+	"password": {Limit: 5, Window: 15 * time.Minute, Key: RateLimitKeyUser},
 }
 
 // Load reads environment variables and returns a Config.
 // Required: DATABASE_HOST, DATABASE_PORT, DATABASE_USER, DATABASE_PASSWORD, DATABASE_NAME, PORT,
-// ACCESS_TOKEN_SECRET, REFRESH_TOKEN_SECRET, VALIDATION_API_KEY, REDIS_HOST, REDIS_PORT, REDIS_PASSWORD
+// ACCESS_TOKEN_SECRET, REFRESH_TOKEN_SECRET, API_KEY_SECRET, VALIDATION_API_KEY
+// Required when RATE_LIMIT_STORE=redis: REDIS_HOST, REDIS_PORT, REDIS_PASSWORD
+// Required when SMTP_HOST is set: SMTP_FROM
+// Required when OUTBOX_SINK=webhook: OUTBOX_WEBHOOK_URL; when OUTBOX_SINK=nats: OUTBOX_NATS_URL
 func Load() (*Config, error) {
 	host := env.GetStrFromEnv("DATABASE_HOST")
 	port := env.GetStrFromEnv("DATABASE_PORT")
@@ -43,34 +210,492 @@ func Load() (*Config, error) {
 
 	accessSecret := env.GetStrFromEnv("ACCESS_TOKEN_SECRET")
 	refreshSecret := env.GetStrFromEnv("REFRESH_TOKEN_SECRET")
+	apiKeySecret := env.GetStrFromEnv("API_KEY_SECRET")
+	if apiKeySecret == refreshSecret {
+		return nil, fmt.Errorf("API_KEY_SECRET must differ from REFRESH_TOKEN_SECRET")
+	}
 	validationKey := env.GetStrFromEnv("VALIDATION_API_KEY")
 	pepper := env.GetStrFromEnv("PASSWORD_PEPPER")
+	pepperID, err := getOptionalInt("PASSWORD_PEPPER_ID", 1)
+	if err != nil {
+		return nil, err
+	}
+	if pepperID <= 0 {
+		return nil, fmt.Errorf("PASSWORD_PEPPER_ID must be positive")
+	}
+	previousPeppers, err := parsePeppers(getOptionalStr("PASSWORD_PEPPERS_PREVIOUS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PASSWORD_PEPPERS_PREVIOUS: %w", err)
+	}
+	if _, ok := previousPeppers[pepperID]; ok {
+		return nil, fmt.Errorf("PASSWORD_PEPPERS_PREVIOUS must not contain the current PASSWORD_PEPPER_ID %d", pepperID)
+	}
 
 	// Token settings
 	accessTTL := env.ParseDurationEnv("ACCESS_TOKEN_TTL")
 	refreshTTL := env.ParseDurationEnv("REFRESH_TOKEN_TTL")
 	tokenIssuer := env.GetStrFromEnv("TOKEN_ISSUER")
 	tokenAudience := env.GetStrFromEnv("TOKEN_AUDIENCE")
+	accessTokenScope, err := getOptionalBool("ACCESS_TOKEN_SCOPE", false)
+	if err != nil {
+		return nil, err
+	}
+	registrationRoles := getOptionalList("REGISTRATION_ROLES", "user")
+	if len(registrationRoles) == 0 {
+		return nil, fmt.Errorf("REGISTRATION_ROLES must list at least one role")
+	}
+	personalTokenTTL, err := getOptionalDuration("PERSONAL_ACCESS_TOKEN_TTL", 90*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	personalTokenMaxTTL, err := getOptionalDuration("PERSONAL_ACCESS_TOKEN_MAX_TTL", 365*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	if personalTokenTTL <= 0 || personalTokenTTL > personalTokenMaxTTL {
+		return nil, fmt.Errorf("PERSONAL_ACCESS_TOKEN_TTL must be positive and not exceed PERSONAL_ACCESS_TOKEN_MAX_TTL")
+	}
+
+	breachedPasswordsFile := getOptionalStr("BREACHED_PASSWORDS_FILE", "")
+	passwordPolicy, err := loadPasswordPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	// Password hashing concurrency
+	hashWorkers, err := getOptionalInt("HASH_WORKERS", runtime.NumCPU())
+	if err != nil {
+		return nil, err
+	}
+	if hashWorkers < 1 {
+		return nil, fmt.Errorf("HASH_WORKERS must be at least 1")
+	}
+	hashQueueDepth, err := getOptionalInt("HASH_QUEUE_DEPTH", 4*hashWorkers)
+	if err != nil {
+		return nil, err
+	}
+	if hashQueueDepth < 0 {
+		return nil, fmt.Errorf("HASH_QUEUE_DEPTH must not be negative")
+	}
+	hashQueueTimeout, err := getOptionalDuration("HASH_QUEUE_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Account deletion settings
+	deletionGracePeriod, err := getOptionalDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	purgeInterval, err := getOptionalDuration("ACCOUNT_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	reauthMaxAge, err := getOptionalDuration("REAUTH_MAX_AGE", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mail settings
+	smtpHost := getOptionalStr("SMTP_HOST", "")
+	var smtpPort, smtpUsername, smtpPassword, smtpFrom string
+	if smtpHost != "" {
+		smtpPort = getOptionalStr("SMTP_PORT", "587")
+		smtpUsername = getOptionalStr("SMTP_USERNAME", "")
+		smtpPassword = getOptionalStr("SMTP_PASSWORD", "")
+		smtpFrom = env.GetStrFromEnv("SMTP_FROM")
+	}
+	inviteURL := getOptionalStr("INVITE_URL", "")
+	organizationInviteURL := getOptionalStr("ORGANIZATION_INVITE_URL", "")
+	inviteTTL, err := getOptionalDuration("INVITE_TTL", 72*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Outbox settings
+	outboxSink := getOptionalStr("OUTBOX_SINK", OutboxSinkNone)
+	var outboxWebhookURL, outboxNATSURL string
+	switch outboxSink {
+	case OutboxSinkNone, OutboxSinkStdout:
+	case OutboxSinkWebhook:
+		outboxWebhookURL = env.GetStrFromEnv("OUTBOX_WEBHOOK_URL")
+	case OutboxSinkNATS:
+		outboxNATSURL = env.GetStrFromEnv("OUTBOX_NATS_URL")
+	default:
+		return nil, fmt.Errorf("invalid OUTBOX_SINK: %s", outboxSink)
+	}
+	outboxNATSSubject := getOptionalStr("OUTBOX_NATS_SUBJECT_PREFIX", "bids.auth")
+	outboxPublishTimeout, err := getOptionalDuration("OUTBOX_PUBLISH_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	outboxPollInterval, err := getOptionalDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	outboxBatchSize, err := getOptionalInt("OUTBOX_BATCH_SIZE", 100)
+	if err != nil {
+		return nil, err
+	}
+	outboxRetention, err := getOptionalDuration("OUTBOX_RETENTION", 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Partner webhook settings
+	webhookMaxAttempts, err := getOptionalInt("WEBHOOK_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, err
+	}
+	webhookTimeout, err := getOptionalDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	webhookPollInterval, err := getOptionalDuration("WEBHOOK_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	webhookBatchSize, err := getOptionalInt("WEBHOOK_BATCH_SIZE", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	// Back-channel logout settings
+	backchannelLogoutURIs, err := parseLogoutURIs(getOptionalStr("BACKCHANNEL_LOGOUT_URIS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("BACKCHANNEL_LOGOUT_URIS: %w", err)
+	}
+	backchannelKeyFile := getOptionalStr("BACKCHANNEL_LOGOUT_KEY_FILE", "")
+	if len(backchannelLogoutURIs) > 0 && backchannelKeyFile == "" {
+		return nil, fmt.Errorf("BACKCHANNEL_LOGOUT_KEY_FILE is required when BACKCHANNEL_LOGOUT_URIS is set")
+	}
+	backchannelMaxAttempts, err := getOptionalInt("BACKCHANNEL_LOGOUT_MAX_ATTEMPTS", 8)
+	if err != nil {
+		return nil, err
+	}
+	backchannelTimeout, err := getOptionalDuration("BACKCHANNEL_LOGOUT_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	backchannelPollInterval, err := getOptionalDuration("BACKCHANNEL_LOGOUT_POLL_INTERVAL", time.Second)
+	if err != nil {
+		return nil, err
+	}
+	backchannelBatchSize, err := getOptionalInt("BACKCHANNEL_LOGOUT_BATCH_SIZE", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	// Backend-for-frontend session settings
+	bffEnabled, err := getOptionalBool("BFF_ENABLED", false)
+	if err != nil {
+		return nil, err
+	}
+	bffIdleTimeout, err := getOptionalDuration("BFF_SESSION_IDLE_TIMEOUT", 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	bffAbsoluteTimeout, err := getOptionalDuration("BFF_SESSION_ABSOLUTE_TIMEOUT", 12*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	// A session's refresh token is never rotated, so the session can't outlive it
+	if bffEnabled && bffAbsoluteTimeout > refreshTTL {
+		return nil, fmt.Errorf("BFF_SESSION_ABSOLUTE_TIMEOUT must not exceed REFRESH_TOKEN_TTL")
+	}
+	bffPurgeInterval, err := getOptionalDuration("BFF_SESSION_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	// Social login settings
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		return nil, err
+	}
+	var oidcRedirectBaseURL string
+	if len(oidcProviders) > 0 {
+		oidcRedirectBaseURL = strings.TrimSuffix(env.GetStrFromEnv("OIDC_REDIRECT_BASE_URL"), "/")
+	}
+	oidcLoginRedirectURL := getOptionalStr("OIDC_LOGIN_REDIRECT_URL", "")
+	oidcStateTTL, err := getOptionalDuration("OIDC_STATE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	oidcDefaultRole := getOptionalStr("OIDC_DEFAULT_ROLE", "user")
+	oidcTimeout, err := getOptionalDuration("OIDC_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// SAML single sign-on settings
+	samlConnections, err := loadSAMLConnections()
+	if err != nil {
+		return nil, err
+	}
+	var samlBaseURL, samlCertFile, samlKeyFile string
+	if len(samlConnections) > 0 {
+		samlBaseURL = strings.TrimSuffix(env.GetStrFromEnv("SAML_BASE_URL"), "/")
+		samlCertFile = env.GetStrFromEnv("SAML_SP_CERT_FILE")
+		samlKeyFile = env.GetStrFromEnv("SAML_SP_KEY_FILE")
+	}
+	samlLoginRedirectURL := getOptionalStr("SAML_LOGIN_REDIRECT_URL", "")
+	samlStateTTL, err := getOptionalDuration("SAML_STATE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	samlTimeout, err := getOptionalDuration("SAML_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Passkey settings
+	passkeyRPID := getOptionalStr("PASSKEY_RP_ID", "")
+	passkeyRPName := getOptionalStr("PASSKEY_RP_NAME", "bids")
+	passkeyOrigins := getOptionalList("PASSKEY_ORIGINS", "")
+	if passkeyRPID != "" && len(passkeyOrigins) == 0 {
+		return nil, fmt.Errorf("PASSKEY_ORIGINS must list at least one origin when PASSKEY_RP_ID is set")
+	}
+	passkeyCeremonyTTL, err := getOptionalDuration("PASSKEY_CEREMONY_TTL", 5*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	// SCIM provisioning settings
+	scimDefaultRole := getOptionalStr("SCIM_DEFAULT_ROLE", "user")
+	scimMaxResults, err := getOptionalInt("SCIM_MAX_RESULTS", 200)
+	if err != nil {
+		return nil, err
+	}
+
+	// Service account settings
+	serviceAccountRoles, err := loadServiceAccountRoles("SERVICE_ACCOUNT_ROLES")
+	if err != nil {
+		return nil, err
+	}
+	serviceAccountOrgRoles, err := loadServiceAccountRoles("SERVICE_ACCOUNT_ORGANIZATION_ROLES")
+	if err != nil {
+		return nil, err
+	}
+
+	// Cookie settings
+	cookies, cookieProfiles, err := loadCookieProfiles()
+	if err != nil {
+		return nil, err
+	}
 
 	// CORS settings
 	allowedOrigins := env.GetStrListFromEnv("ALLOWED_ORIGINS")
 
+	// Metrics settings
+	metricsToken := getOptionalStr("METRICS_TOKEN", "")
+
+	// Proxy settings
+	trustedProxies, err := parseTrustedProxies(getOptionalStr("TRUSTED_PROXIES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// Rate limiting settings
+	rateLimitStore := getOptionalStr("RATE_LIMIT_STORE", RateLimitStoreMemory)
+	var redisHost, redisPort, redisPassword string
+	switch rateLimitStore {
+	case RateLimitStoreMemory, RateLimitStorePostgres:
+	case RateLimitStoreRedis:
+		redisHost = env.GetStrFromEnv("REDIS_HOST")
+		redisPort = env.GetStrFromEnv("REDIS_PORT")
+		redisPassword = env.GetStrFromEnv("REDIS_PASSWORD")
+	default:
+		return nil, fmt.Errorf("invalid RATE_LIMIT_STORE: %s", rateLimitStore)
+	}
+	rateLimits := make(map[string]RateLimitRule, len(defaultRateLimits))
+	for route, rule := range defaultRateLimits {
+		key := "RATE_LIMIT_" + strings.ToUpper(route)
+		if raw, ok := os.LookupEnv(key); ok {
+			parsed, err := ParseRateLimitRule(raw, rule.Key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			rule = parsed
+		}
+		rateLimits[route] = rule
+	}
+
 	return &Config{
-		DBHost:             host,
-		DBPort:             port,
-		DBUser:             user,
-		DBPassword:         pass,
-		DBName:             name,
-		Port:               appPort,
-		AccessTokenSecret:  accessSecret,
-		RefreshTokenSecret: refreshSecret,
-		ValidationAPIKey:   validationKey,
-		AccessTokenTTL:     accessTTL,
-		RefreshTokenTTL:    refreshTTL,
-		TokenIssuer:        tokenIssuer,
-		TokenAudience:      tokenAudience,
-		PasswordPepper:     pepper,
-		AllowedOrigins:     allowedOrigins,
+		DBHost:                  host,
+		DBPort:                  port,
+		DBUser:                  user,
+		DBPassword:              pass,
+		DBName:                  name,
+		Port:                    appPort,
+		AccessTokenSecret:       accessSecret,
+		RefreshTokenSecret:      refreshSecret,
+		APIKeySecret:            apiKeySecret,
+		ValidationAPIKey:        validationKey,
+		AccessTokenTTL:          accessTTL,
+		RefreshTokenTTL:         refreshTTL,
+		TokenIssuer:             tokenIssuer,
+		TokenAudience:           tokenAudience,
+		AccessTokenScope:        accessTokenScope,
+		PersonalTokenTTL:        personalTokenTTL,
+		PersonalTokenMaxTTL:     personalTokenMaxTTL,
+		RegistrationRoles:       registrationRoles,
+		PasswordPepper:          pepper,
+		PasswordPepperID:        pepperID,
+		PreviousPasswordPeppers: previousPeppers,
+		BreachedPasswordsFile:   breachedPasswordsFile,
+		PasswordPolicy:          passwordPolicy,
+		HashWorkers:             hashWorkers,
+		HashQueueDepth:          hashQueueDepth,
+		HashQueueTimeout:        hashQueueTimeout,
+		DeletionGracePeriod:     deletionGracePeriod,
+		DeletionPurgeInterval:   purgeInterval,
+		ReauthMaxAge:            reauthMaxAge,
+		SMTPHost:                smtpHost,
+		SMTPPort:                smtpPort,
+		SMTPUsername:            smtpUsername,
+		SMTPPassword:            smtpPassword,
+		SMTPFrom:                smtpFrom,
+		InviteURL:               inviteURL,
+		InviteTTL:               inviteTTL,
+		OrganizationInviteURL:   organizationInviteURL,
+		OutboxSink:              outboxSink,
+		OutboxWebhookURL:        outboxWebhookURL,
+		OutboxNATSURL:           outboxNATSURL,
+		OutboxNATSSubject:       outboxNATSSubject,
+		OutboxPublishTimeout:    outboxPublishTimeout,
+		OutboxPollInterval:      outboxPollInterval,
+		OutboxBatchSize:         outboxBatchSize,
+		OutboxRetention:         outboxRetention,
+		WebhookMaxAttempts:      webhookMaxAttempts,
+		WebhookTimeout:          webhookTimeout,
+		WebhookPollInterval:     webhookPollInterval,
+		WebhookBatchSize:        webhookBatchSize,
+		BackchannelLogoutURIs:   backchannelLogoutURIs,
+		BackchannelKeyFile:      backchannelKeyFile,
+		BackchannelMaxAttempts:  backchannelMaxAttempts,
+		BackchannelTimeout:      backchannelTimeout,
+		BackchannelPollInterval: backchannelPollInterval,
+		BackchannelBatchSize:    backchannelBatchSize,
+		BFFEnabled:              bffEnabled,
+		BFFIdleTimeout:          bffIdleTimeout,
+		BFFAbsoluteTimeout:      bffAbsoluteTimeout,
+		BFFPurgeInterval:        bffPurgeInterval,
+		OIDCProviders:           oidcProviders,
+		OIDCRedirectBaseURL:     oidcRedirectBaseURL,
+		OIDCLoginRedirectURL:    oidcLoginRedirectURL,
+		OIDCStateTTL:            oidcStateTTL,
+		OIDCDefaultRole:         oidcDefaultRole,
+		OIDCTimeout:             oidcTimeout,
+		SAMLConnections:         samlConnections,
+		SAMLBaseURL:             samlBaseURL,
+		SAMLCertFile:            samlCertFile,
+		SAMLKeyFile:             samlKeyFile,
+		SAMLLoginRedirectURL:    samlLoginRedirectURL,
+		SAMLStateTTL:            samlStateTTL,
+		SAMLTimeout:             samlTimeout,
+		PasskeyRPID:             passkeyRPID,
+		PasskeyRPName:           passkeyRPName,
+		PasskeyOrigins:          passkeyOrigins,
+		PasskeyCeremonyTTL:      passkeyCeremonyTTL,
+		SCIMDefaultRole:         scimDefaultRole,
+		ServiceAccountRoles:     serviceAccountRoles,
+		ServiceAccountOrgRoles:  serviceAccountOrgRoles,
+		SCIMMaxResults:          scimMaxResults,
+		Cookies:                 cookies,
+		CookieProfiles:          cookieProfiles,
+		AllowedOrigins:          allowedOrigins,
+		MetricsToken:            metricsToken,
+		TrustedProxies:          trustedProxies,
+		RateLimitStore:          rateLimitStore,
+		RateLimits:              rateLimits,
+		RedisHost:               redisHost,
+		RedisPort:               redisPort,
+		RedisPassword:           redisPassword,
+	}, nil
+}
+
+// ParseRateLimitRule parses a rule of the form "<limit>/<window>[,<key>]", e.g. "10/1m" or
+// "5/15m,user". Without a key the rule keeps defaultKey.
+func ParseRateLimitRule(raw, defaultKey string) (RateLimitRule, error) {
+	rule, key, hasKey := strings.Cut(strings.TrimSpace(raw), ",")
+	if !hasKey {
+		key = defaultKey
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !slices.Contains(RateLimitKeys, key) {
+		return RateLimitRule{}, fmt.Errorf("invalid key %q", key)
+	}
+	limitStr, windowStr, ok := strings.Cut(rule, "/")
+	if !ok {
+		return RateLimitRule{}, fmt.Errorf("expected <limit>/<window>[,<key>], got %q", raw)
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid limit %q", limitStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return RateLimitRule{}, fmt.Errorf("invalid window %q", windowStr)
+	}
+	return RateLimitRule{Limit: limit, Window: window, Key: key}, nil
+}
+
+// loadPasswordPolicy reads the password policy. The defaults require 8 to 128 characters with
+// lower case, upper case and digits, reject passwords resembling the user's identity and keep no history.
+func loadPasswordPolicy() (PasswordPolicy, error) {
+	minLength, err := getOptionalInt("PASSWORD_MIN_LENGTH", 8)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	maxLength, err := getOptionalInt("PASSWORD_MAX_LENGTH", 128)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	if maxLength < minLength {
+		return PasswordPolicy{}, fmt.Errorf("PASSWORD_MAX_LENGTH must not be less than PASSWORD_MIN_LENGTH")
+	}
+
+	classes := []string{PasswordClassLower, PasswordClassUpper, PasswordClassDigit}
+	if raw, ok := os.LookupEnv("PASSWORD_REQUIRED_CLASSES"); ok {
+		classes = nil
+		for _, class := range strings.Split(raw, ",") {
+			class = strings.ToLower(strings.TrimSpace(class))
+			if class == "" {
+				continue
+			}
+			if !slices.Contains(PasswordClasses, class) {
+				return PasswordPolicy{}, fmt.Errorf("invalid PASSWORD_REQUIRED_CLASSES: unknown class %q", class)
+			}
+			if !slices.Contains(classes, class) {
+				classes = append(classes, class)
+			}
+		}
+	}
+
+	minStrength, err := getOptionalNonNegativeInt("PASSWORD_MIN_STRENGTH", 0)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	if minStrength > 4 {
+		return PasswordPolicy{}, fmt.Errorf("invalid PASSWORD_MIN_STRENGTH: must be between 0 and 4")
+	}
+	rejectSimilar, err := getOptionalBool("PASSWORD_REJECT_SIMILAR", true)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+	historyDepth, err := getOptionalNonNegativeInt("PASSWORD_HISTORY_DEPTH", 0)
+	if err != nil {
+		return PasswordPolicy{}, err
+	}
+
+	return PasswordPolicy{
+		MinLength:       minLength,
+		MaxLength:       maxLength,
+		RequiredClasses: classes,
+		MinStrength:     minStrength,
+		RejectSimilar:   rejectSimilar,
+		HistoryDepth:    historyDepth,
 	}, nil
 }
 
@@ -81,3 +706,191 @@ func (c *Config) DSN() string {
 	hostPort := net.JoinHostPort(c.DBHost, c.DBPort)
 	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", userEsc, passEsc, hostPort, c.DBName)
 }
+
+// Peppers returns every configured pepper keyed by version, including the current one.
+func (c *Config) Peppers() map[int]string {
+	peppers := make(map[int]string, len(c.PreviousPasswordPeppers)+1)
+	for id, pepper := range c.PreviousPasswordPeppers {
+		peppers[id] = pepper
+	}
+	peppers[c.PasswordPepperID] = c.PasswordPepper
+	return peppers
+}
+
+// ProvisioningRoles returns every role configuration gives users or service accounts without an
+// admin choosing it: registration, service account, SCIM and enabled social login and SAML roles.
+func (c *Config) ProvisioningRoles() []string {
+	roles := slices.Concat(c.RegistrationRoles, c.ServiceAccountRoles, c.ServiceAccountOrgRoles, []string{c.SCIMDefaultRole})
+	if len(c.OIDCProviders) > 0 {
+		roles = append(roles, c.OIDCDefaultRole)
+	}
+	for _, connection := range c.SAMLConnections {
+		roles = append(roles, connection.Roles()...)
+	}
+	slices.Sort(roles)
+	return slices.Compact(roles)
+}
+
+// RedisAddr returns the host:port address of the Redis server.
+func (c *Config) RedisAddr() string {
+	return net.JoinHostPort(c.RedisHost, c.RedisPort)
+}
+
+// parsePeppers parses a comma-separated list of "<id>:<pepper>" pairs.
+func parsePeppers(raw string) (map[int]string, error) {
+	peppers := make(map[int]string)
+	if raw == "" {
+		return peppers, nil
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		idStr, pepper, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || pepper == "" {
+			return nil, fmt.Errorf("expected <id>:<pepper>, got %q", entry)
+		}
+		id, err := strconv.Atoi(idStr)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid pepper id %q", idStr)
+		}
+		if _, dup := peppers[id]; dup {
+			return nil, fmt.Errorf("duplicate pepper id %d", id)
+		}
+		peppers[id] = pepper
+	}
+	return peppers, nil
+}
+
+// parseTrustedProxies parses a comma-separated list of proxy addresses or CIDR ranges.
+func parseTrustedProxies(raw string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// parseLogoutURIs parses a comma-separated list of "<client id>=<logout uri>" pairs.
+func parseLogoutURIs(raw string) (map[string]string, error) {
+	uris := make(map[string]string)
+	if raw == "" {
+		return uris, nil
+	}
+	for _, entry := range strings.Split(raw, ",") {
+		clientID, uri, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || clientID == "" {
+			return nil, fmt.Errorf("expected <client id>=<logout uri>, got %q", entry)
+		}
+		u, err := url.Parse(uri)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid logout uri %q for client %s", uri, clientID)
+		}
+		if _, dup := uris[clientID]; dup {
+			return nil, fmt.Errorf("duplicate client id %s", clientID)
+		}
+		uris[clientID] = uri
+	}
+	return uris, nil
+}
+
+// getOptionalStr returns the value of key, or fallback when it is unset or empty.
+func getOptionalStr(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// getOptionalInt returns the positive integer value of key, or fallback when it is unset.
+func getOptionalInt(key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+// getOptionalNonNegativeInt returns the integer value of key, which may be zero, or fallback when it is unset.
+func getOptionalNonNegativeInt(key string, fallback int) (int, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+// getOptionalBool returns the boolean value of key, or fallback when it is unset.
+func getOptionalBool(key string, fallback bool) (bool, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+// getOptionalDuration returns the positive duration value of key, or fallback when it is unset.
+func getOptionalDuration(key string, fallback time.Duration) (time.Duration, error) {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, raw)
+	}
+	return v, nil
+}
+
+// getOptionalList reads a comma-separated list, trimming the items and dropping empty and repeated
+// ones, falling back to fallback when the variable is unset.
+func getOptionalList(key, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(getOptionalStr(key, fallback), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || slices.Contains(items, item) {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// loadServiceAccountRoles reads the comma-separated roles service accounts may hold from key. Roles
+// granting auth:admin are rejected when the service starts, so a leaked key can't administer it.
+func loadServiceAccountRoles(key string) ([]string, error) {
+	roles := getOptionalList(key, "user")
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("%s must list at least one role", key)
+	}
+	return roles, nil
+}
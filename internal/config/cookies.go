@@ -0,0 +1,132 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CookieProfile configures the cookies set for browser clients: the refresh and CSRF cookies, and the
+// session cookie in backend-for-frontend mode.
+type CookieProfile struct {
+	RefreshName string        // refresh token cookie name, read from COOKIE_REFRESH_NAME
+	CSRFName    string        // CSRF cookie name, read from COOKIE_CSRF_NAME
+	SessionName string        // session cookie name, read from COOKIE_SESSION_NAME
+	Domain      string        // read from COOKIE_DOMAIN; empty for host-only cookies
+	Path        string        // refresh cookie path, read from COOKIE_PATH
+	SameSite    http.SameSite // strict, lax or none, read from COOKIE_SAMESITE
+	Secure      bool          // read from COOKIE_SECURE, defaults to true when MODE=production
+	HostPrefix  bool          // prefix names with __Host-, read from COOKIE_HOST_PREFIX
+	Partitioned bool          // partitioned (CHIPS) cookies for embedding, read from COOKIE_PARTITIONED
+}
+
+// defaultCookieProfile is overridden by the COOKIE_* variables.
+var defaultCookieProfile = CookieProfile{
+	RefreshName: "refresh_token",
+	CSRFName:    "csrf_token",
+	SessionName: "session",
+	Path:        "/auth",
+	SameSite:    http.SameSiteStrictMode,
+}
+
+// loadCookieProfiles reads the default cookie profile from the COOKIE_* variables, and a profile for
+// each client ID listed in COOKIE_PROFILES from the COOKIE_PROFILE_<CLIENT ID>_* variables. Client
+// profiles start from the default profile.
+func loadCookieProfiles() (CookieProfile, map[string]CookieProfile, error) {
+	base := defaultCookieProfile
+	base.Secure = os.Getenv("MODE") == "production"
+	defaults, err := loadCookieProfile("COOKIE_", base)
+	if err != nil {
+		return CookieProfile{}, nil, err
+	}
+
+	profiles := make(map[string]CookieProfile)
+	for _, clientID := range strings.Split(getOptionalStr("COOKIE_PROFILES", ""), ",") {
+		clientID = strings.TrimSpace(clientID)
+		if clientID == "" {
+			continue
+		}
+		if _, dup := profiles[clientID]; dup {
+			return CookieProfile{}, nil, fmt.Errorf("duplicate cookie profile %s", clientID)
+		}
+		profile, err := loadCookieProfile("COOKIE_PROFILE_"+envKey(clientID)+"_", defaults)
+		if err != nil {
+			return CookieProfile{}, nil, err
+		}
+		profiles[clientID] = profile
+	}
+	return defaults, profiles, nil
+}
+
+// loadCookieProfile reads the variables <prefix>REFRESH_NAME, <prefix>DOMAIN, ... over base.
+func loadCookieProfile(prefix string, base CookieProfile) (CookieProfile, error) {
+	p := base
+	p.RefreshName = getOptionalStr(prefix+"REFRESH_NAME", p.RefreshName)
+	p.CSRFName = getOptionalStr(prefix+"CSRF_NAME", p.CSRFName)
+	p.SessionName = getOptionalStr(prefix+"SESSION_NAME", p.SessionName)
+	// Set but empty, DOMAIN makes a profile's cookies host-only even when the defaults share them
+	if domain, ok := os.LookupEnv(prefix + "DOMAIN"); ok {
+		p.Domain = strings.TrimSpace(domain)
+	}
+	p.Path = getOptionalStr(prefix+"PATH", p.Path)
+
+	if raw := getOptionalStr(prefix+"SAMESITE", ""); raw != "" {
+		switch strings.ToLower(raw) {
+		case "strict":
+			p.SameSite = http.SameSiteStrictMode
+		case "lax":
+			p.SameSite = http.SameSiteLaxMode
+		case "none":
+			p.SameSite = http.SameSiteNoneMode
+		default:
+			return CookieProfile{}, fmt.Errorf("invalid %sSAMESITE: %q", prefix, raw)
+		}
+	}
+
+	var err error
+	if p.Secure, err = getOptionalBool(prefix+"SECURE", p.Secure); err != nil {
+		return CookieProfile{}, err
+	}
+	if p.HostPrefix, err = getOptionalBool(prefix+"HOST_PREFIX", p.HostPrefix); err != nil {
+		return CookieProfile{}, err
+	}
+	if p.Partitioned, err = getOptionalBool(prefix+"PARTITIONED", p.Partitioned); err != nil {
+		return CookieProfile{}, err
+	}
+
+	if err := p.validate(); err != nil {
+		return CookieProfile{}, fmt.Errorf("invalid %s* cookie settings: %w", prefix, err)
+	}
+	return p, nil
+}
+
+// validate rejects combinations browsers would refuse to store.
+func (p CookieProfile) validate() error {
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if p.SameSite == http.SameSiteNoneMode && !p.Secure {
+		return fmt.Errorf("SameSite=None requires Secure")
+	}
+	if p.Partitioned && !p.Secure {
+		return fmt.Errorf("partitioned cookies require Secure")
+	}
+	if p.HostPrefix && (!p.Secure || p.Domain != "") {
+		return fmt.Errorf("__Host- cookies require Secure and no domain")
+	}
+	return nil
+}
+
+// envKey turns a client ID into the form used in variable names, e.g. "web-app" to "WEB_APP".
+func envKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
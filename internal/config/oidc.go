@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/LittleAksMax/bids-util/env"
+)
+
+// Kinds of external identity provider.
+const (
+	OIDCProviderTypeOIDC   = "oidc"
+	OIDCProviderTypeGitHub = "github"
+)
+
+// OIDCProviderConfig configures an external OpenID Connect provider users can sign in with, or GitHub,
+// which speaks plain OAuth2.
+type OIDCProviderConfig struct {
+	Name         string   // used in URLs and stored with linked identities
+	Type         string   // oidc or github, read from OIDC_<NAME>_TYPE
+	Issuer       string   // read from OIDC_<NAME>_ISSUER; discovery is at <issuer>/.well-known/openid-configuration. For github, the optional GitHub Enterprise Server URL
+	ClientID     string   // read from OIDC_<NAME>_CLIENT_ID
+	ClientSecret string   // read from OIDC_<NAME>_CLIENT_SECRET
+	Scopes       []string // read from OIDC_<NAME>_SCOPES (comma-separated), defaults to openid, email and profile, or read:user and user:email for github
+}
+
+// providerNamePattern keeps provider names safe to use as URL path segments.
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// loadOIDCProviders reads a provider for each name listed in OIDC_PROVIDERS.
+func loadOIDCProviders() ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(getOptionalStr("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid OIDC provider name %q: use lowercase letters, digits and -", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate OIDC provider %s", name)
+		}
+		seen[name] = true
+
+		prefix := "OIDC_" + envKey(name) + "_"
+		providerType := getOptionalStr(prefix+"TYPE", OIDCProviderTypeOIDC)
+		var issuer string
+		scopes := []string{"openid", "email", "profile"}
+		switch providerType {
+		case OIDCProviderTypeOIDC:
+			issuer = env.GetStrFromEnv(prefix + "ISSUER")
+		case OIDCProviderTypeGitHub:
+			issuer = getOptionalStr(prefix+"ISSUER", "")
+			scopes = []string{"read:user", "user:email"}
+		default:
+			return nil, fmt.Errorf("invalid %sTYPE: %q", prefix, providerType)
+		}
+		// Without an issuer, github means github.com
+		if providerType == OIDCProviderTypeOIDC || issuer != "" {
+			if u, err := url.Parse(issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("invalid %sISSUER: %q", prefix, issuer)
+			}
+		}
+		if raw := getOptionalStr(prefix+"SCOPES", ""); raw != "" {
+			scopes = nil
+			for _, scope := range strings.Split(raw, ",") {
+				if scope = strings.TrimSpace(scope); scope != "" {
+					scopes = append(scopes, scope)
+				}
+			}
+		}
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			Type:         providerType,
+			Issuer:       issuer,
+			ClientID:     env.GetStrFromEnv(prefix + "CLIENT_ID"),
+			ClientSecret: env.GetStrFromEnv(prefix + "CLIENT_SECRET"),
+			Scopes:       scopes,
+		})
+	}
+	return providers, nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// SAML NameID formats connections can request.
+const (
+	SAMLNameIDPersistent  = "persistent"
+	SAMLNameIDEmail       = "email"
+	SAMLNameIDUnspecified = "unspecified"
+)
+
+// SAMLConnectionConfig configures an enterprise SAML identity provider, typically one per customer.
+type SAMLConnectionConfig struct {
+	Name              string            // used in URLs and stored with linked identities
+	IDPMetadataURL    string            // read from SAML_<NAME>_IDP_METADATA_URL; fetched on first use
+	IDPMetadataFile   string            // read from SAML_<NAME>_IDP_METADATA_FILE, instead of the URL
+	NameIDFormat      string            // persistent, email or unspecified, read from SAML_<NAME>_NAMEID_FORMAT
+	EmailAttribute    string            // read from SAML_<NAME>_EMAIL_ATTRIBUTE, defaults to email
+	UsernameAttribute string            // read from SAML_<NAME>_USERNAME_ATTRIBUTE; usernames derive from the email when unset
+	RoleAttribute     string            // read from SAML_<NAME>_ROLE_ATTRIBUTE; roles aren't mapped when unset
+	RoleMap           []SAMLRoleMapping // read from SAML_<NAME>_ROLE_MAP as comma-separated value=role pairs
+	DefaultRole       string            // role when no value maps, read from SAML_<NAME>_DEFAULT_ROLE
+	TrustEmail        bool              // link to existing users by asserted email, read from SAML_<NAME>_TRUST_EMAIL
+	EmailDomains      []string          // domains asserted emails must be in, read from SAML_<NAME>_EMAIL_DOMAINS; any when empty
+}
+
+// Roles returns the roles the connection can give users, its default role first.
+func (c SAMLConnectionConfig) Roles() []string {
+	roles := []string{c.DefaultRole}
+	for _, mapping := range c.RoleMap {
+		if !slices.Contains(roles, mapping.Role) {
+			roles = append(roles, mapping.Role)
+		}
+	}
+	return roles
+}
+
+// SAMLRoleMapping grants Role to users whose role attribute includes Value. When several values map,
+// the mapping listed first wins.
+type SAMLRoleMapping struct {
+	Value string
+	Role  string
+}
+
+// loadSAMLConnections reads a connection for each name listed in SAML_CONNECTIONS.
+func loadSAMLConnections() ([]SAMLConnectionConfig, error) {
+	var connections []SAMLConnectionConfig
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(getOptionalStr("SAML_CONNECTIONS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !providerNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid SAML connection name %q: use lowercase letters, digits and -", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate SAML connection %s", name)
+		}
+		seen[name] = true
+
+		prefix := "SAML_" + envKey(name) + "_"
+		metadataURL := getOptionalStr(prefix+"IDP_METADATA_URL", "")
+		metadataFile := getOptionalStr(prefix+"IDP_METADATA_FILE", "")
+		switch {
+		case metadataURL == "" && metadataFile == "":
+			return nil, fmt.Errorf("%sIDP_METADATA_URL or %sIDP_METADATA_FILE is required", prefix, prefix)
+		case metadataURL != "" && metadataFile != "":
+			return nil, fmt.Errorf("set only one of %sIDP_METADATA_URL and %sIDP_METADATA_FILE", prefix, prefix)
+		case metadataURL != "":
+			if u, err := url.Parse(metadataURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("invalid %sIDP_METADATA_URL: %q", prefix, metadataURL)
+			}
+		}
+
+		nameIDFormat := getOptionalStr(prefix+"NAMEID_FORMAT", SAMLNameIDPersistent)
+		switch nameIDFormat {
+		case SAMLNameIDPersistent, SAMLNameIDEmail, SAMLNameIDUnspecified:
+		default:
+			return nil, fmt.Errorf("invalid %sNAMEID_FORMAT: %q", prefix, nameIDFormat)
+		}
+		roleMap, err := parseSAMLRoleMap(getOptionalStr(prefix+"ROLE_MAP", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sROLE_MAP: %w", prefix, err)
+		}
+		defaultRole := getOptionalStr(prefix+"DEFAULT_ROLE", "user")
+		trustEmail, err := getOptionalBool(prefix+"TRUST_EMAIL", false)
+		if err != nil {
+			return nil, err
+		}
+		emailDomains, err := parseEmailDomains(getOptionalStr(prefix+"EMAIL_DOMAINS", ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sEMAIL_DOMAINS: %w", prefix, err)
+		}
+		// Trusting every email would let this IdP sign in to any local account, including other customers'
+		if trustEmail && len(emailDomains) == 0 {
+			return nil, fmt.Errorf("%sEMAIL_DOMAINS is required with %sTRUST_EMAIL", prefix, prefix)
+		}
+
+		connections = append(connections, SAMLConnectionConfig{
+			Name:              name,
+			IDPMetadataURL:    metadataURL,
+			IDPMetadataFile:   metadataFile,
+			NameIDFormat:      nameIDFormat,
+			EmailAttribute:    getOptionalStr(prefix+"EMAIL_ATTRIBUTE", "email"),
+			UsernameAttribute: getOptionalStr(prefix+"USERNAME_ATTRIBUTE", ""),
+			RoleAttribute:     getOptionalStr(prefix+"ROLE_ATTRIBUTE", ""),
+			RoleMap:           roleMap,
+			DefaultRole:       defaultRole,
+			TrustEmail:        trustEmail,
+			EmailDomains:      emailDomains,
+		})
+	}
+	return connections, nil
+}
+
+// parseSAMLRoleMap parses comma-separated value=role pairs, e.g. "Bid Managers=manager,Bidders=user".
+func parseSAMLRoleMap(raw string) ([]SAMLRoleMapping, error) {
+	var roleMap []SAMLRoleMapping
+	for _, pair := range strings.Split(raw, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || role == "" {
+			return nil, fmt.Errorf("expected value=role, got %q", pair)
+		}
+		roleMap = append(roleMap, SAMLRoleMapping{Value: value, Role: role})
+	}
+	return roleMap, nil
+}
+
+// parseEmailDomains parses comma-separated email domains, lowercased, e.g. "acme.example,acme.test".
+func parseEmailDomains(raw string) ([]string, error) {
+	var domains []string
+	for _, domain := range strings.Split(raw, ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain == "" || slices.Contains(domains, domain) {
+			continue
+		}
+		if strings.ContainsAny(domain, "@ ") || strings.HasPrefix(domain, ".") || !strings.Contains(domain, ".") {
+			return nil, fmt.Errorf("expected a domain such as acme.example, got %q", domain)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
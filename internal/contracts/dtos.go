@@ -1,6 +1,7 @@
 package contracts
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,15 +9,88 @@ import (
 
 // UserDTO DTO for users.
 type UserDTO struct {
-	ID        uuid.UUID `json:"user_id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Role      string    `json:"role"`
+	ID                  uuid.UUID  `json:"user_id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Role                string     `json:"role"`
+	Status              string     `json:"status"`
+	StatusReason        *string    `json:"status_reason,omitempty"`
+	StatusChangedAt     *time.Time `json:"status_changed_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
 }
 
 type TokenPair struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 }
+
+// SessionDTO describes a refresh token session without its secret.
+type SessionDTO struct {
+	ID        uuid.UUID  `json:"session_id"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AccountExportDTO is the personal data archive returned to a user on request.
+type AccountExportDTO struct {
+	ExportedAt time.Time      `json:"exported_at"`
+	Profile    UserDTO        `json:"profile"`
+	Sessions   []SessionDTO   `json:"sessions"`
+	Activity   []AuthEventDTO `json:"activity"`
+}
+
+// AuthEventDTO describes an audit log entry about the user in their data export.
+type AuthEventDTO struct {
+	Type       string         `json:"type"`
+	IP         string         `json:"ip,omitempty"`
+	UserAgent  string         `json:"user_agent,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// DomainEventDTO is the wire envelope of every domain event published by this service.
+type DomainEventDTO struct {
+	ID         uuid.UUID       `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Source     string          `json:"source"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// UserRegisteredEventData is the payload of user.registered, version 1.
+type UserRegisteredEventData struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+}
+
+// UserRoleChangedEventData is the payload of user.role_changed, version 1.
+type UserRoleChangedEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   string    `json:"role"`
+}
+
+// UserDeletedEventData is the payload of user.deleted, version 1.
+type UserDeletedEventData struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// UserMergedEventData is the payload of user.merged, version 1. MergedUserID no longer exists; data
+// kept for it belongs to UserID from now on.
+type UserMergedEventData struct {
+	UserID       uuid.UUID `json:"user_id"`
+	MergedUserID uuid.UUID `json:"merged_user_id"`
+}
+
+// SessionRevokedEventData is the payload of session.revoked, version 1. SessionIDs lists the sessions
+// that ended; a session ID is the sid claim of the access tokens issued in it.
+type SessionRevokedEventData struct {
+	UserID     uuid.UUID   `json:"user_id"`
+	SessionIDs []uuid.UUID `json:"session_ids"`
+	Reason     string      `json:"reason"`
+}
@@ -8,38 +8,538 @@ import (
 
 // User represents a user entity.
 type User struct {
-	ID        uuid.UUID
-	Username  string
-	Email     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Role      string
+	ID                  uuid.UUID
+	Username            string
+	Email               string
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Role                string
+	Status              string
+	StatusReason        *string
+	StatusChangedAt     *time.Time
+	DeletionScheduledAt *time.Time // set while the user is pending_deletion
+}
+
+// IsActive reports whether the user may authenticate.
+func (u *User) IsActive() bool {
+	return u.Status == UserStatusActive
 }
 
 func (u *User) ToDTO() *UserDTO {
 	return &UserDTO{
-		ID:        u.ID,
-		Username:  u.Username,
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
-		Role:      u.Role,
+		ID:                  u.ID,
+		Username:            u.Username,
+		Email:               u.Email,
+		CreatedAt:           u.CreatedAt,
+		UpdatedAt:           u.UpdatedAt,
+		Role:                u.Role,
+		Status:              u.Status,
+		StatusReason:        u.StatusReason,
+		StatusChangedAt:     u.StatusChangedAt,
+		DeletionScheduledAt: u.DeletionScheduledAt,
 	}
 }
 
+// User account statuses.
+const (
+	UserStatusActive          = "active"
+	UserStatusSuspended       = "suspended"
+	UserStatusDeactivated     = "deactivated"
+	UserStatusPendingDeletion = "pending_deletion"
+)
+
+// UserFilter narrows a user listing. Zero-valued fields don't filter.
+type UserFilter struct {
+	Role          string
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Search        string // case-insensitive substring of username or email
+}
+
+// UserCursor is the keyset position of a user in a listing ordered by creation time, newest first.
+type UserCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// UserInvite is a single-use invitation for a user to set their password.
+type UserInvite struct {
+	TokenHash  string
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+}
+
 // RefreshToken represents a refresh token stored in the database.
 type RefreshToken struct {
 	TokenID           uuid.UUID
 	UserID            uuid.UUID
+	SessionID         uuid.UUID // shared by every token in a rotation chain
 	TokenHash         string
 	IssuedAt          time.Time
 	ExpiresAt         time.Time
 	RevokedAt         *time.Time
 	ReplacedByTokenID *uuid.UUID
+	AuthenticatedAt   *time.Time // when the user last proved who they are in the session; nil if unknown
+	OrganizationID    *uuid.UUID // the organization the session acts in; nil if none is selected
+}
+
+// UserIdentity links a user to their account at an external identity provider. It is the detail of
+// an oidc credential and shares its ID.
+type UserIdentity struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Provider  string // provider name in config
+	Subject   string // the provider's sub claim
+	Email     *string
+	CreatedAt time.Time
+}
+
+// SAMLIdentity links a user to their account at an enterprise SAML identity provider. It is the
+// detail of a saml credential and shares its ID.
+type SAMLIdentity struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Connection string // connection name in config
+	NameID     string // the IdP's NameID for the user
+	Email      *string
+	// Provisioned is set on the identity whose first sign-in created the user, whose role the
+	// connection then manages.
+	Provisioned bool
+	CreatedAt   time.Time
+}
+
+// Credential types.
+const (
+	CredentialTypePassword = "password"
+	CredentialTypeOIDC     = "oidc"
+	CredentialTypeSAML     = "saml"
+	CredentialTypePasskey  = "passkey"
+)
+
+// Credential is one way a user can sign in. Type-specific details are kept per type; Provider and
+// Email are set for oidc and saml credentials, Provider being the SAML connection for the latter.
+type Credential struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Type       string
+	Provider   *string
+	Email      *string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// Passkey is a WebAuthn public key credential a user signs in with. It is the detail of a passkey
+// credential and shares its ID.
+type Passkey struct {
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	WebAuthnID      []byte // the credential ID the authenticator chose
+	UserHandle      []byte // the user handle it was registered with, which the authenticator returns on sign-in
+	PublicKey       []byte // COSE-encoded
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	Flags           uint8 // authenticator data flags of the latest registration or sign-in
+	CreatedAt       time.Time
+}
+
+// Organization is a group of users, such as a customer, with roles of its own.
+type Organization struct {
+	ID        uuid.UUID
+	Slug      string
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Membership is a user's role in an organization. Username and Email are filled in when listing an
+// organization's members, and Organization when listing a user's memberships.
+type Membership struct {
+	OrganizationID uuid.UUID
+	UserID         uuid.UUID
+	Role           string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Username       string
+	Email          string
+	Organization   *Organization
+}
+
+// Membership roles, from most to least privileged.
+const (
+	MembershipRoleOwner  = "owner"
+	MembershipRoleAdmin  = "admin"
+	MembershipRoleMember = "member"
+	MembershipRoleViewer = "viewer"
+)
+
+// OrganizationInvite is a single-use invitation for whoever holds the email to join an organization.
+type OrganizationInvite struct {
+	ID             uuid.UUID
+	OrganizationID uuid.UUID
+	Email          string
+	Role           string
+	InvitedBy      *uuid.UUID
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	AcceptedAt     *time.Time
+}
+
+// Role is a global role users can hold, with the permissions it grants. Built-in roles can't be
+// deleted.
+type Role struct {
+	Name        string
+	Description string
+	Builtin     bool
+	Permissions []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Permission is a <resource>:<action> name roles can grant, either part of which may be
+// PermissionWildcard.
+type Permission struct {
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// PermissionWildcard matches any resource or action in a permission.
+const PermissionWildcard = "*"
+
+// PermissionAuthAdmin lets a role administer the auth service. The built-in admin role grants it
+// through *:*.
+const PermissionAuthAdmin = "auth:admin"
+
+// PersonalAccessToken is a long-lived bearer token a user scripts against the bids APIs with,
+// limited to Scopes. The token itself is only shown when it is created.
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// ServiceAccount is a non-human identity for scheduled jobs and integrations, owned by an
+// organization or, when OrganizationID is nil, by the admins. Keys is filled in when getting a single
+// account.
+type ServiceAccount struct {
+	ID             uuid.UUID
+	Name           string
+	Description    string
+	Role           string
+	OrganizationID *uuid.UUID
+	CreatedBy      *uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Keys           []*ServiceAccountKey
+}
+
+// ServiceAccountKey is an API key a service account authenticates with. The key itself is only
+// shown when it is created; Prefix identifies it afterwards.
+type ServiceAccountKey struct {
+	ID               uuid.UUID
+	ServiceAccountID uuid.UUID
+	Prefix           string
+	CreatedAt        time.Time
+	LastUsedAt       *time.Time
+}
+
+// SCIMToken is a bearer token a SCIM client provisions users of its tenant with. The token itself
+// is only shown when it is issued.
+type SCIMToken struct {
+	ID          uuid.UUID
+	Tenant      string
+	Description string
+	CreatedAt   time.Time
+	LastUsedAt  *time.Time
+}
+
+// SCIMUser is a user provisioned over SCIM by a tenant, with the SCIM attributes kept alongside it.
+type SCIMUser struct {
+	User        *User
+	Tenant      string
+	ExternalID  *string // the tenant's own ID for the user
+	GivenName   *string
+	FamilyName  *string
+	DisplayName *string
+}
+
+// SCIMUserAttributes are the attributes a tenant sets when it provisions or replaces a user.
+type SCIMUserAttributes struct {
+	Username    string
+	Email       string
+	ExternalID  *string
+	GivenName   *string
+	FamilyName  *string
+	DisplayName *string
+	Active      *bool // nil keeps a replaced user's status; new users are active
+}
+
+// ExternalIdentity is who an external identity provider says signed in, from a validated ID token.
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string // preferred_username, if any
+	Name          string
+}
+
+// SAMLAssertion is who a SAML identity provider says signed in, from a validated assertion, with its
+// attributes mapped as configured for the connection.
+type SAMLAssertion struct {
+	Connection   string
+	NameID       string
+	Email        string
+	EmailTrusted bool   // the connection is trusted to assert emails, so they may link to existing users
+	EmailAllowed bool   // the email is in one of the connection's email domains, or it restricts none
+	Username     string // from the username attribute, if configured and present
+	Role         string // the mapped role, or the connection's default role
+	RoleManaged  bool   // the connection maps roles, so Role is kept in sync on every login
+}
+
+// SAMLLoginState is an in-flight SAML login, kept between the AuthnRequest and the IdP's response.
+type SAMLLoginState struct {
+	Connection     string
+	RequestID      string     // ID of the AuthnRequest the response must answer
+	OrganizationID *uuid.UUID // organization the session is to act in, if one was selected
+	ExpiresAt      time.Time
+}
+
+// PasskeyCeremony is an in-flight passkey registration or sign-in, kept between the options sent to
+// the browser and the authenticator's response.
+type PasskeyCeremony struct {
+	UserID         *uuid.UUID // set for registrations, to the user adding the passkey
+	OrganizationID *uuid.UUID // organization a sign-in's session is to act in, if one was selected
+	Session        []byte     // the relying party's session data, holding the challenge
+	ExpiresAt      time.Time
+}
+
+// OIDCLoginState is an in-flight social login, kept between the redirect to the provider and back.
+type OIDCLoginState struct {
+	Provider       string
+	Nonce          string
+	CodeVerifier   string
+	LinkUserID     *uuid.UUID // set when a signed-in user is linking the identity instead of signing in
+	AllowMerge     bool       // whether an identity linked to another account may merge that account in
+	OrganizationID *uuid.UUID // organization the session is to act in, if one was selected
+	ExpiresAt      time.Time
+}
+
+// Session is a server-side browser session (backend-for-frontend mode). ID is the refresh session it
+// belongs to, and so the sid of the access tokens minted for it.
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time // absolute timeout
 }
 
 type PasswordCredential struct {
 	UserID       uuid.UUID
 	PasswordHash string
 	PasswordSalt string
+	Algorithm    string
+	Params       *PasswordHashParams // nil when the hash predates parameter tracking
+	PepperID     *int                // nil for unpeppered (imported legacy) hashes
+}
+
+// PepperUsage counts the password credentials hashed with a given pepper version.
+type PepperUsage struct {
+	PepperID    *int
+	Credentials int64
+}
+
+// PasswordHashParams records the Argon2 cost parameters a password hash was produced with.
+type PasswordHashParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// Password hashing algorithms.
+const (
+	PasswordAlgoArgon2id     = "argon2id"
+	PasswordAlgoBcrypt       = "bcrypt"
+	PasswordAlgoPBKDF2SHA256 = "pbkdf2-sha256"
+	PasswordAlgoScrypt       = "scrypt"
+)
+
+// AuthEvent is an entry in the authentication audit log.
+type AuthEvent struct {
+	ID         int64
+	Type       string
+	ActorID    *uuid.UUID // who performed the action; nil for anonymous or system actions
+	SubjectID  *uuid.UUID // whose account the action affected
+	IP         string
+	UserAgent  string
+	RequestID  string
+	Metadata   map[string]any
+	OccurredAt time.Time
+}
+
+func (e *AuthEvent) ToDTO() AuthEventDTO {
+	return AuthEventDTO{
+		Type:       e.Type,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		Metadata:   e.Metadata,
+		OccurredAt: e.OccurredAt,
+	}
+}
+
+// AuthEventFilter narrows an audit log query. Zero-valued fields don't filter.
+type AuthEventFilter struct {
+	UserID *uuid.UUID // matches either the actor or the subject
+	Types  []string
+	From   *time.Time
+	To     *time.Time
+}
+
+// Authentication event types.
+const (
+	AuthEventRegister                 = "register"
+	AuthEventLoginSucceeded           = "login_succeeded"
+	AuthEventLoginFailed              = "login_failed"
+	AuthEventTokenRefreshed           = "token_refreshed"
+	AuthEventRefreshTokenReused       = "refresh_token_reused"
+	AuthEventSessionsRevoked          = "sessions_revoked"
+	AuthEventLogout                   = "logout"
+	AuthEventPasswordChanged          = "password_changed"
+	AuthEventInviteAccepted           = "invite_accepted"
+	AuthEventUserCreated              = "user_created"
+	AuthEventUserImported             = "user_imported"
+	AuthEventUserUpdated              = "user_updated"
+	AuthEventRoleChanged              = "role_changed"
+	AuthEventStatusChanged            = "status_changed"
+	AuthEventUserDeleted              = "user_deleted"
+	AuthEventAccountDeletionRequested = "account_deletion_requested"
+	AuthEventAccountDeletionCancelled = "account_deletion_cancelled"
+	AuthEventIdentityLinked           = "identity_linked"
+	AuthEventCredentialAdded          = "credential_added"
+	AuthEventCredentialRemoved        = "credential_removed"
+	AuthEventAccountsMerged           = "accounts_merged"
+	AuthEventOrganizationCreated      = "organization_created"
+	AuthEventOrganizationUpdated      = "organization_updated"
+	AuthEventOrganizationDeleted      = "organization_deleted"
+	AuthEventMemberInvited            = "member_invited"
+	AuthEventMemberInviteRevoked      = "member_invite_revoked"
+	AuthEventMemberJoined             = "member_joined"
+	AuthEventMemberRoleChanged        = "member_role_changed"
+	AuthEventMemberRemoved            = "member_removed"
+	AuthEventRoleCreated              = "role_created"
+	AuthEventRoleUpdated              = "role_updated"
+	AuthEventRoleDeleted              = "role_deleted"
+	AuthEventPermissionCreated        = "permission_created"
+	AuthEventPermissionDeleted        = "permission_deleted"
+	AuthEventAccessTokenCreated       = "personal_access_token_created"
+	AuthEventAccessTokenRevoked       = "personal_access_token_revoked"
+	AuthEventServiceAccountCreated    = "service_account_created"
+	AuthEventServiceAccountDeleted    = "service_account_deleted"
+	AuthEventServiceAccountKeyCreated = "service_account_key_created"
+	AuthEventServiceAccountKeyRevoked = "service_account_key_revoked"
+)
+
+// DomainEvent is an event published to other services through the outbox. Data holds the
+// type-specific payload; its schema only changes together with Version.
+type DomainEvent struct {
+	ID         uuid.UUID
+	Type       string
+	Version    int
+	Data       any
+	OccurredAt time.Time
+}
+
+// OutboxEvent is a stored domain event awaiting or after delivery. Data is the encoded payload.
+type OutboxEvent struct {
+	ID         int64
+	EventID    uuid.UUID
+	Type       string
+	Version    int
+	Data       []byte
+	OccurredAt time.Time
+	Attempts   int
+}
+
+// Domain event types.
+const (
+	DomainEventUserRegistered  = "user.registered"
+	DomainEventUserRoleChanged = "user.role_changed"
+	DomainEventUserDeleted     = "user.deleted"
+	DomainEventSessionRevoked  = "session.revoked"
+	DomainEventUserMerged      = "user.merged"
+)
+
+// Reasons reported in SessionRevokedEventData.Reason.
+const (
+	SessionRevokedNewLogin        = "new_login"
+	SessionRevokedLogout          = "logout"
+	SessionRevokedTokenReuse      = "token_reuse"
+	SessionRevokedRoleChanged     = "role_changed"
+	SessionRevokedStatusChanged   = "status_changed"
+	SessionRevokedAdmin           = "admin"
+	SessionRevokedDeletionRequest = "deletion_requested"
+	SessionRevokedMerged          = "merged"
+)
+
+// WebhookSubscription registers a partner URL for domain events.
+type WebhookSubscription struct {
+	ID          uuid.UUID
+	URL         string
+	Secret      string   // HMAC key shared with the partner
+	EventTypes  []string // empty means every event type
+	Description string
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// BackchannelLogoutDelivery is a pending back-channel logout notification to a relying client.
+// SessionID is nil when every session of the user ended.
+type BackchannelLogoutDelivery struct {
+	ID        int64
+	ClientID  string
+	EventID   uuid.UUID
+	UserID    uuid.UUID
+	SessionID *uuid.UUID
+	Attempts  int
+}
+
+// WebhookDelivery is a pending delivery of an event to a subscription, with the details needed to send it.
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID uuid.UUID
+	URL            string
+	Secret         string
+	EventID        uuid.UUID
+	EventType      string
+	Payload        []byte
+	Attempts       int
+}
+
+// WebhookDeadLetter is a delivery that exhausted its retries.
+type WebhookDeadLetter struct {
+	ID             int64
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        []byte
+	Attempts       int
+	LastStatus     *int
+	LastError      *string
+	CreatedAt      time.Time
+	FailedAt       time.Time
 }
@@ -0,0 +1,28 @@
+package events
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// BackchannelLogoutSender delivers logout tokens to relying clients' back-channel logout URIs as
+// described by OpenID Connect Back-Channel Logout 1.0.
+type BackchannelLogoutSender struct {
+	client *http.Client
+}
+
+// NewBackchannelLogoutSender creates a sender giving up on a request after timeout.
+func NewBackchannelLogoutSender(timeout time.Duration) *BackchannelLogoutSender {
+	return &BackchannelLogoutSender{client: &http.Client{Timeout: timeout}}
+}
+
+// Send POSTs logoutToken to uri as the logout_token form parameter and returns the response status,
+// or 0 with an error when no response was received.
+func (s *BackchannelLogoutSender) Send(ctx context.Context, uri, logoutToken string) (int, error) {
+	body := url.Values{"logout_token": {logoutToken}}.Encode()
+	return post(ctx, s.client, uri, "application/x-www-form-urlencoded", []byte(body), map[string]string{
+		"Cache-Control": "no-store",
+	})
+}
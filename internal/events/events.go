@@ -0,0 +1,160 @@
+// Package events delivers domain events from the outbox to other services.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+// StdoutSink writes each event as a line of JSON, for development and log-based pipelines.
+type StdoutSink struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewStdoutSink creates a sink writing to standard output.
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{out: os.Stdout}
+}
+
+// Publish writes the event.
+func (s *StdoutSink) Publish(_ context.Context, event contracts.DomainEventDTO) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.out.Write(append(line, '\n'))
+	return err
+}
+
+// WebhookSink POSTs each event as JSON to a URL. Any 2xx response counts as delivered.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting to url, giving up on a request after timeout.
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Publish posts the event.
+func (s *WebhookSink) Publish(ctx context.Context, event contracts.DomainEventDTO) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	status, err := postJSON(ctx, s.client, s.url, body, map[string]string{
+		"X-Event-ID":   event.ID.String(),
+		"X-Event-Type": event.Type,
+	})
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("webhook responded with status %d", status)
+	}
+	return nil
+}
+
+// postJSON POSTs body with the given extra headers and returns the response status.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (int, error) {
+	return post(ctx, client, url, "application/json", body, headers)
+}
+
+// post POSTs body as contentType with the given extra headers and returns the response status.
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, headers map[string]string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// NATSSink publishes each event on the subject "<prefix>.<event type>" and waits for the server to
+// process it, so a lost connection surfaces as an error rather than a silently dropped event.
+type NATSSink struct {
+	conn    *nats.Conn
+	prefix  string
+	timeout time.Duration
+}
+
+// NewNATSSink creates a sink publishing through conn with the given subject prefix, waiting at most
+// timeout for the server to confirm each event.
+func NewNATSSink(conn *nats.Conn, prefix string, timeout time.Duration) *NATSSink {
+	return &NATSSink{conn: conn, prefix: prefix, timeout: timeout}
+}
+
+// Publish sends the event. The event ID is set as the Nats-Msg-Id header, which JetStream uses to
+// drop redelivered duplicates.
+func (s *NATSSink) Publish(ctx context.Context, event contracts.DomainEventDTO) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.prefix + "." + event.Type)
+	msg.Data = body
+	msg.Header.Set(nats.MsgIdHdr, event.ID.String())
+	if err := s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.conn.FlushWithContext(ctx)
+}
+
+// Sink is anything events can be published to.
+type Sink interface {
+	Publish(ctx context.Context, event contracts.DomainEventDTO) error
+}
+
+// MultiSink publishes each event to several sinks. An event counts as delivered only once every sink
+// has accepted it, so a failure in one sink means the others may see the event again.
+type MultiSink struct {
+	sinks []Sink
+}
+
+// NewMultiSink creates a sink fanning out to sinks.
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+// Publish sends the event to every sink and returns their combined errors.
+func (s *MultiSink) Publish(ctx context.Context, event contracts.DomainEventDTO) error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
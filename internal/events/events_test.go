@@ -0,0 +1,123 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+// runNATSServer starts an embedded NATS server on a random port, shut down with the test.
+func runNATSServer(t *testing.T) *server.Server {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{Host: "127.0.0.1", Port: server.RANDOM_PORT, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatalf("create NATS server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server not ready")
+	}
+	t.Cleanup(ns.Shutdown)
+	return ns
+}
+
+// connectNATS connects to ns, closing the connection with the test.
+func connectNATS(t *testing.T, ns *server.Server, opts ...nats.Option) *nats.Conn {
+	t.Helper()
+	conn, err := nats.Connect(ns.ClientURL(), opts...)
+	if err != nil {
+		t.Fatalf("connect to NATS: %v", err)
+	}
+	t.Cleanup(conn.Close)
+	return conn
+}
+
+func testEvent(eventType string) contracts.DomainEventDTO {
+	return contracts.DomainEventDTO{
+		ID:         uuid.New(),
+		Type:       eventType,
+		Version:    1,
+		Source:     "bids-auth-service",
+		OccurredAt: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC),
+		Data:       json.RawMessage(`{"user_id":"0ad113a4-eff0-44fd-b72d-bb1faa1f6e19"}`),
+	}
+}
+
+func TestNATSSinkPublish(t *testing.T) {
+	ns := runNATSServer(t)
+	sub, err := connectNATS(t, ns).SubscribeSync("bids.auth.>")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	sink := NewNATSSink(connectNATS(t, ns), "bids.auth", time.Second)
+	tests := []struct {
+		name      string
+		eventType string
+		subject   string
+	}{
+		{name: "user event", eventType: contracts.DomainEventUserDeleted, subject: "bids.auth." + contracts.DomainEventUserDeleted},
+		{name: "session event", eventType: contracts.DomainEventSessionRevoked, subject: "bids.auth." + contracts.DomainEventSessionRevoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := testEvent(tt.eventType)
+			if err := sink.Publish(context.Background(), event); err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+
+			msg, err := sub.NextMsg(time.Second)
+			if err != nil {
+				t.Fatalf("no message received: %v", err)
+			}
+			if msg.Subject != tt.subject {
+				t.Errorf("subject = %q, want %q", msg.Subject, tt.subject)
+			}
+			if got := msg.Header.Get(nats.MsgIdHdr); got != event.ID.String() {
+				t.Errorf("%s = %q, want %q", nats.MsgIdHdr, got, event.ID)
+			}
+			var got contracts.DomainEventDTO
+			if err := json.Unmarshal(msg.Data, &got); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			if got.ID != event.ID || got.Type != event.Type || got.Version != event.Version || !got.OccurredAt.Equal(event.OccurredAt) {
+				t.Errorf("event = %+v, want %+v", got, event)
+			}
+		})
+	}
+}
+
+func TestNATSSinkPublishFailsWithoutServer(t *testing.T) {
+	ns := runNATSServer(t)
+	// Without reconnects the connection closes as soon as the server goes away
+	conn := connectNATS(t, ns, nats.NoReconnect())
+	sink := NewNATSSink(conn, "bids.auth", time.Second)
+
+	ns.Shutdown()
+	ns.WaitForShutdown()
+
+	if err := sink.Publish(context.Background(), testEvent(contracts.DomainEventUserDeleted)); err == nil {
+		t.Fatal("Publish() error = nil, want an error once the server is gone")
+	}
+}
+
+func TestNATSSinkPublishTimesOutWhileDisconnected(t *testing.T) {
+	ns := runNATSServer(t)
+	// Reconnecting connections buffer publishes, so only the flush can report the event as undelivered
+	conn := connectNATS(t, ns, nats.MaxReconnects(-1), nats.ReconnectWait(time.Hour))
+	sink := NewNATSSink(conn, "bids.auth", 100*time.Millisecond)
+
+	ns.Shutdown()
+	ns.WaitForShutdown()
+
+	if err := sink.Publish(context.Background(), testEvent(contracts.DomainEventUserDeleted)); err == nil {
+		t.Fatal("Publish() error = nil, want an error while disconnected")
+	}
+}
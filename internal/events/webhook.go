@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers set on partner webhook deliveries. X-Webhook-Ts and X-Webhook-Sig follow the X-Auth-Ts and
+// X-Auth-Sig scheme used between bids services.
+const (
+	WebhookIDHeader        = "X-Webhook-ID"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookTimestampHeader = "X-Webhook-Ts"
+	WebhookSignatureHeader = "X-Webhook-Sig"
+)
+
+// WebhookSender delivers signed payloads to partner webhook subscriptions.
+type WebhookSender struct {
+	client *http.Client
+}
+
+// NewWebhookSender creates a sender giving up on a request after timeout.
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	return &WebhookSender{client: &http.Client{Timeout: timeout}}
+}
+
+// Send POSTs payload to url, signed with secret, and returns the response status, or 0 with an error
+// when no response was received.
+func (s *WebhookSender) Send(ctx context.Context, url, secret string, payload []byte, eventID uuid.UUID, eventType string) (int, error) {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	return postJSON(ctx, s.client, url, payload, map[string]string{
+		WebhookIDHeader:        eventID.String(),
+		WebhookEventHeader:     eventType,
+		WebhookTimestampHeader: ts,
+		WebhookSignatureHeader: SignWebhook(secret, ts, payload),
+	})
+}
+
+// SignWebhook returns the hex-encoded HMAC-SHA256 of "<timestamp>.<payload>" keyed with secret.
+// Receivers recompute it, compare in constant time and reject stale timestamps.
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// Partners verify this exact format, so it is pinned to a vector computed independently
+	got := SignWebhook("whsec_test", "1767268800", []byte(`{"type":"user.deleted"}`))
+	want := "9ff9a4be0cfbcf6f2652c6399b029526108e174cf57080400cd75068bcb14182"
+	if got != want {
+		t.Errorf("SignWebhook() = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSenderSend(t *testing.T) {
+	const secret = "whsec_test"
+	payload := []byte(`{"type":"user.deleted"}`)
+	eventID := uuid.New()
+
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	status, err := NewWebhookSender(time.Second).Send(context.Background(), server.URL, secret, payload, eventID, contracts.DomainEventUserDeleted)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", status, http.StatusAccepted)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if got := received.Header.Get(WebhookIDHeader); got != eventID.String() {
+		t.Errorf("%s = %q, want %q", WebhookIDHeader, got, eventID)
+	}
+	if got := received.Header.Get(WebhookEventHeader); got != contracts.DomainEventUserDeleted {
+		t.Errorf("%s = %q, want %q", WebhookEventHeader, got, contracts.DomainEventUserDeleted)
+	}
+
+	// Verify the signature the way the README tells partners to
+	ts := received.Header.Get(WebhookTimestampHeader)
+	sent, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)).Abs() > time.Minute {
+		t.Fatalf("%s = %q, want the current Unix time", WebhookTimestampHeader, ts)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + string(body)))
+	sig, err := hex.DecodeString(received.Header.Get(WebhookSignatureHeader))
+	if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+		t.Errorf("%s doesn't verify", WebhookSignatureHeader)
+	}
+}
@@ -0,0 +1,131 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newMockGitHub serves GitHub's OAuth2 token endpoint and the REST API under /api/v3, as GitHub
+// Enterprise Server does, answering /user/emails with emails.
+func newMockGitHub(t *testing.T, emails []map[string]any) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != testCode || r.FormValue("code_verifier") != testVerifier {
+			writeTestJSON(w, map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		writeTestJSON(w, map[string]string{"access_token": "gho_test", "token_type": "bearer", "scope": "read:user,user:email"})
+	})
+	authorized := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer gho_test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+		}
+	}
+	mux.HandleFunc("GET /api/v3/user", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]any{"id": 583231, "login": "octocat", "name": "The Octocat", "email": nil})
+	}))
+	mux.HandleFunc("GET /api/v3/user/emails", authorized(func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, emails)
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitHubProviderAuthCodeURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseURL  string
+		endpoint string
+	}{
+		{name: "github.com", endpoint: "https://github.com/login/oauth/authorize"},
+		{name: "enterprise server", baseURL: "https://github.example.com/", endpoint: "https://github.example.com/login/oauth/authorize"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewGitHubProvider("github", tt.baseURL, testClientID, testClientSecret, testRedirectURL, []string{"read:user", "user:email"}, time.Second)
+			raw, err := p.AuthCodeURL(context.Background(), "state-123", testNonce, testVerifier)
+			if err != nil {
+				t.Fatalf("AuthCodeURL() error = %v", err)
+			}
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parse authorization url: %v", err)
+			}
+			if got := u.Scheme + "://" + u.Host + u.Path; got != tt.endpoint {
+				t.Errorf("authorization endpoint = %q, want %q", got, tt.endpoint)
+			}
+			q := u.Query()
+			if q.Get("state") != "state-123" || q.Get("scope") != "read:user user:email" {
+				t.Errorf("state, scope = %q, %q", q.Get("state"), q.Get("scope"))
+			}
+			if q.Get("code_challenge") != oauth2.S256ChallengeFromVerifier(testVerifier) || q.Get("code_challenge_method") != "S256" {
+				t.Errorf("code_challenge = %q (%s), want the S256 challenge", q.Get("code_challenge"), q.Get("code_challenge_method"))
+			}
+		})
+	}
+}
+
+func TestGitHubProviderExchange(t *testing.T) {
+	tests := []struct {
+		name         string
+		emails       []map[string]any
+		code         string
+		wantErr      bool
+		wantEmail    string
+		wantVerified bool
+	}{
+		{
+			name: "verified primary email",
+			emails: []map[string]any{
+				{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+				{"email": "octocat@github.com", "primary": true, "verified": true},
+			},
+			code:         testCode,
+			wantEmail:    "octocat@github.com",
+			wantVerified: true,
+		},
+		{
+			name:      "unverified primary email",
+			emails:    []map[string]any{{"email": "octocat@github.com", "primary": true, "verified": false}},
+			code:      testCode,
+			wantEmail: "octocat@github.com",
+		},
+		{name: "no emails", emails: []map[string]any{}, code: testCode},
+		{name: "code rejected", emails: []map[string]any{}, code: "wrong-code", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockGitHub(t, tt.emails)
+			p := NewGitHubProvider("github", server.URL, testClientID, testClientSecret, testRedirectURL, []string{"read:user", "user:email"}, time.Second)
+
+			identity, err := p.Exchange(context.Background(), tt.code, testVerifier, testNonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Exchange() = %+v, want an error", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.Provider != "github" || identity.Subject != "583231" || identity.Username != "octocat" || identity.Name != "The Octocat" {
+				t.Errorf("identity = %+v", identity)
+			}
+			if identity.Email != tt.wantEmail || identity.EmailVerified != tt.wantVerified {
+				t.Errorf("email = %q (verified %v), want %q (verified %v)", identity.Email, identity.EmailVerified, tt.wantEmail, tt.wantVerified)
+			}
+		})
+	}
+}
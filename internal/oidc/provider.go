@@ -0,0 +1,145 @@
+// Package oidc signs users in through external OpenID Connect identity providers, and GitHub, using
+// the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Provider talks to one identity provider. Its discovery document is fetched on first use rather
+// than at startup, so an unreachable provider doesn't stop the service; failed discovery is retried
+// on the next login.
+type Provider struct {
+	name   string
+	issuer string
+	oauth  oauth2.Config
+	client *http.Client
+
+	mu       sync.Mutex
+	endpoint *oauth2.Endpoint
+	verifier *gooidc.IDTokenVerifier
+}
+
+// NewProvider creates a provider for issuer. Users are sent back to redirectURL after signing in, and
+// requests to the provider give up after timeout.
+func NewProvider(name, issuer, clientID, clientSecret, redirectURL string, scopes []string, timeout time.Duration) *Provider {
+	return &Provider{
+		name:   name,
+		issuer: issuer,
+		oauth: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// AuthCodeURL returns the provider's authorization URL for a login carrying state and nonce, with the
+// S256 challenge for codeVerifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	cfg, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cfg.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange redeems an authorization code and returns the identity from the verified ID token. The
+// token must be issued by the provider to this client and carry nonce.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*contracts.ExternalIdentity, error) {
+	cfg, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := cfg.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code with %s: %w", p.name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%s returned no id_token", p.name)
+	}
+
+	idToken, err := verifier.Verify(gooidc.ClientContext(ctx, p.client), rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify %s id_token: %w", p.name, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match the login")
+	}
+
+	var claims struct {
+		Email             string   `json:"email"`
+		EmailVerified     flexBool `json:"email_verified"`
+		PreferredUsername string   `json:"preferred_username"`
+		Name              string   `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("decode %s id_token claims: %w", p.name, err)
+	}
+
+	return &contracts.ExternalIdentity{
+		Provider:      p.name,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Username:      claims.PreferredUsername,
+		Name:          claims.Name,
+	}, nil
+}
+
+// discover returns the OAuth2 config and ID token verifier, fetching the discovery document if it
+// hasn't been fetched yet.
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.endpoint == nil {
+		provider, err := gooidc.NewProvider(gooidc.ClientContext(ctx, p.client), p.issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("discover %s: %w", p.name, err)
+		}
+		endpoint := provider.Endpoint()
+		p.endpoint = &endpoint
+		p.verifier = provider.Verifier(&gooidc.Config{ClientID: p.oauth.ClientID})
+	}
+
+	cfg := p.oauth
+	cfg.Endpoint = *p.endpoint
+	return &cfg, p.verifier, nil
+}
+
+// flexBool decodes booleans some providers send as the strings "true" and "false".
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case bool:
+		*b = flexBool(v)
+	case string:
+		*b = v == "true"
+	case nil:
+		*b = false
+	default:
+		return fmt.Errorf("invalid boolean %s", data)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const (
+	testClientID     = "auth-service"
+	testClientSecret = "secret"
+	testRedirectURL  = "http://localhost:8080/auth/oidc/mock/callback"
+	testCode         = "code-123"
+	testVerifier     = "verifier-0123456789-0123456789-0123456789"
+	testNonce        = "nonce-123"
+)
+
+// mockIdP is a minimal OpenID Connect provider: discovery, JWKS and a token endpoint redeeming
+// testCode for an ID token with claims, once the PKCE verifier checks out.
+type mockIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != testCode || r.FormValue("code_verifier") != testVerifier {
+			w.WriteHeader(http.StatusBadRequest)
+			writeTestJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idp.claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeTestJSON(w, map[string]any{"access_token": "access", "token_type": "Bearer", "expires_in": 60, "id_token": idToken})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// validClaims returns the claims of an ID token the provider must accept.
+func (idp *mockIdP) validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":                idp.URL,
+		"aud":                testClientID,
+		"sub":                "subject-1",
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"nonce":              testNonce,
+		"email":              "ada@example.com",
+		"email_verified":     true,
+		"preferred_username": "ada",
+		"name":               "Ada Lovelace",
+	}
+}
+
+func writeTestJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func TestProviderAuthCodeURL(t *testing.T) {
+	idp := newMockIdP(t)
+	p := NewProvider("mock", idp.URL, testClientID, testClientSecret, testRedirectURL, []string{"openid", "email"}, time.Second)
+
+	raw, err := p.AuthCodeURL(context.Background(), "state-123", testNonce, testVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	if got, want := u.Scheme+"://"+u.Host+u.Path, idp.URL+"/authorize"; got != want {
+		t.Errorf("authorization endpoint = %q, want %q", got, want)
+	}
+	q := u.Query()
+	want := map[string]string{
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"response_type":         "code",
+		"scope":                 "openid email",
+		"state":                 "state-123",
+		"nonce":                 testNonce,
+		"code_challenge":        oauth2.S256ChallengeFromVerifier(testVerifier),
+		"code_challenge_method": "S256",
+	}
+	for param, value := range want {
+		if got := q.Get(param); got != value {
+			t.Errorf("%s = %q, want %q", param, got, value)
+		}
+	}
+}
+
+func TestProviderExchange(t *testing.T) {
+	tests := []struct {
+		name          string
+		claims        func(c jwt.MapClaims)
+		code          string
+		nonce         string
+		wantErr       bool
+		wantVerified  bool
+		wantUsername  string
+		wantEmptyMail bool
+	}{
+		{name: "valid", code: testCode, nonce: testNonce, wantVerified: true, wantUsername: "ada"},
+		{name: "email_verified as string", claims: func(c jwt.MapClaims) { c["email_verified"] = "true" }, code: testCode, nonce: testNonce, wantVerified: true, wantUsername: "ada"},
+		{name: "unverified email", claims: func(c jwt.MapClaims) { c["email_verified"] = false }, code: testCode, nonce: testNonce, wantUsername: "ada"},
+		{name: "no email", claims: func(c jwt.MapClaims) { delete(c, "email"); delete(c, "email_verified") }, code: testCode, nonce: testNonce, wantUsername: "ada", wantEmptyMail: true},
+		{name: "nonce mismatch", code: testCode, nonce: "another-nonce", wantErr: true},
+		{name: "wrong audience", claims: func(c jwt.MapClaims) { c["aud"] = "another-client" }, code: testCode, nonce: testNonce, wantErr: true},
+		{name: "wrong issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, code: testCode, nonce: testNonce, wantErr: true},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, code: testCode, nonce: testNonce, wantErr: true},
+		{name: "code rejected", code: "wrong-code", nonce: testNonce, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.claims = idp.validClaims()
+			if tt.claims != nil {
+				tt.claims(idp.claims)
+			}
+			p := NewProvider("mock", idp.URL, testClientID, testClientSecret, testRedirectURL, []string{"openid"}, time.Second)
+
+			identity, err := p.Exchange(context.Background(), tt.code, testVerifier, tt.nonce)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Exchange() = %+v, want an error", identity)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if identity.Provider != "mock" || identity.Subject != "subject-1" {
+				t.Errorf("identity = %s/%s, want mock/subject-1", identity.Provider, identity.Subject)
+			}
+			if identity.EmailVerified != tt.wantVerified {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.wantVerified)
+			}
+			if (identity.Email == "") != tt.wantEmptyMail {
+				t.Errorf("Email = %q", identity.Email)
+			}
+			if identity.Username != tt.wantUsername {
+				t.Errorf("Username = %q, want %q", identity.Username, tt.wantUsername)
+			}
+		})
+	}
+}
+
+func TestProviderDiscoveryRetried(t *testing.T) {
+	idp := newMockIdP(t)
+	p := NewProvider("mock", idp.URL+"/missing", testClientID, testClientSecret, testRedirectURL, []string{"openid"}, time.Second)
+	if _, err := p.AuthCodeURL(context.Background(), "state", testNonce, testVerifier); err == nil {
+		t.Fatal("AuthCodeURL() error = nil, want a discovery error")
+	}
+
+	// A failed discovery isn't cached
+	p.issuer = idp.URL
+	if _, err := p.AuthCodeURL(context.Background(), "state", testNonce, testVerifier); err != nil {
+		t.Fatalf("AuthCodeURL() after the provider came back error = %v", err)
+	}
+}
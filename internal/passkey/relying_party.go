@@ -0,0 +1,193 @@
+// Package passkey signs users in with passkeys, acting as a WebAuthn relying party. Passkeys must be
+// discoverable and verify the user, so they sign in on their own without a username or password.
+package passkey
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// RelyingParty runs registration and sign-in ceremonies for one relying party ID.
+type RelyingParty struct {
+	webauthn *webauthn.WebAuthn
+}
+
+// NewRelyingParty creates a relying party for id, the domain passkeys are scoped to, shown to users as
+// displayName. Responses are only accepted from origins.
+func NewRelyingParty(id, displayName string, origins []string) (*RelyingParty, error) {
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          id,
+		RPDisplayName: displayName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+		AttestationPreference: protocol.PreferNoAttestation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebAuthn relying party: %w", err)
+	}
+	return &RelyingParty{webauthn: w}, nil
+}
+
+// BeginRegistration returns the options for creating a passkey for user, excluding the passkeys they
+// already have, and the session data to keep until FinishRegistration.
+func (rp *RelyingParty) BeginRegistration(user *contracts.User, passkeys []*contracts.Passkey) ([]byte, []byte, error) {
+	u := newUser(user, passkeys)
+	creation, session, err := rp.webauthn.BeginRegistration(u, webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCeremony(creation, session)
+}
+
+// FinishRegistration verifies the authenticator's response to the options BeginRegistration returned
+// with session, and returns the passkey it created for user.
+func (rp *RelyingParty) FinishRegistration(user *contracts.User, passkeys []*contracts.Passkey, session, response []byte) (*contracts.Passkey, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return nil, fmt.Errorf("decode session: %w", err)
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, describe(err)
+	}
+	u := newUser(user, passkeys)
+	credential, err := rp.webauthn.CreateCredential(u, data, parsed)
+	if err != nil {
+		return nil, describe(err)
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+	return &contracts.Passkey{
+		UserID:          user.ID,
+		WebAuthnID:      credential.ID,
+		UserHandle:      u.WebAuthnID(),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Flags:           uint8(parsed.Response.AttestationObject.AuthData.Flags),
+	}, nil
+}
+
+// BeginLogin returns the options for signing in with any of the relying party's passkeys, and the
+// session data to keep until FinishLogin.
+func (rp *RelyingParty) BeginLogin() ([]byte, []byte, error) {
+	assertion, session, err := rp.webauthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCeremony(assertion, session)
+}
+
+// FinishLogin verifies an assertion answering the options BeginLogin returned with session. The
+// passkey it was made with is looked up by its credential ID, and returned with the signature counter
+// and flags the authenticator reported; checking the counter is left to the caller.
+func (rp *RelyingParty) FinishLogin(session, response []byte, lookup func(webauthnID []byte) (*contracts.Passkey, error)) (*contracts.Passkey, error) {
+	var data webauthn.SessionData
+	if err := json.Unmarshal(session, &data); err != nil {
+		return nil, fmt.Errorf("decode session: %w", err)
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, describe(err)
+	}
+
+	var stored *contracts.Passkey
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		passkey, err := lookup(rawID)
+		if err != nil {
+			return nil, err
+		}
+		if passkey == nil {
+			return nil, errors.New("unknown passkey")
+		}
+		stored = passkey
+		// Only this passkey can answer, under the user handle it was registered with; the account
+		// it belongs to may have been merged into another since
+		return &user{id: passkey.UserHandle, credentials: []webauthn.Credential{credentialOf(passkey)}}, nil
+	}
+	if _, _, err := rp.webauthn.ValidatePasskeyLogin(handler, data, parsed); err != nil {
+		return nil, describe(err)
+	}
+
+	asserted := *stored
+	asserted.SignCount = parsed.Response.AuthenticatorData.Counter
+	asserted.Flags = uint8(parsed.Response.AuthenticatorData.Flags)
+	return &asserted, nil
+}
+
+// user adapts a user and their passkeys to webauthn.User. The user handle is the user's ID, which
+// reveals nothing about them.
+type user struct {
+	id          []byte
+	name        string
+	credentials []webauthn.Credential
+}
+
+func newUser(u *contracts.User, passkeys []*contracts.Passkey) *user {
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, p := range passkeys {
+		credentials = append(credentials, credentialOf(p))
+	}
+	id := u.ID
+	return &user{id: id[:], name: u.Username, credentials: credentials}
+}
+
+func (u *user) WebAuthnID() []byte                         { return u.id }
+func (u *user) WebAuthnName() string                       { return u.name }
+func (u *user) WebAuthnDisplayName() string                { return u.name }
+func (u *user) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// credentialOf converts a stored passkey to the credential record go-webauthn verifies against.
+func credentialOf(p *contracts.Passkey) webauthn.Credential {
+	transports := make([]protocol.AuthenticatorTransport, 0, len(p.Transports))
+	for _, t := range p.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(t))
+	}
+	return webauthn.Credential{
+		ID:              p.WebAuthnID,
+		PublicKey:       p.PublicKey,
+		AttestationType: p.AttestationType,
+		Transport:       transports,
+		Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(p.Flags)),
+		Authenticator: webauthn.Authenticator{
+			AAGUID:    p.AAGUID,
+			SignCount: p.SignCount,
+		},
+	}
+}
+
+// encodeCeremony encodes the options sent to the browser and the session data kept until they are
+// answered.
+func encodeCeremony(options any, session *webauthn.SessionData) ([]byte, []byte, error) {
+	encodedOptions, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, err
+	}
+	encodedSession, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodedOptions, encodedSession, nil
+}
+
+// describe adds the details go-webauthn keeps beside its error messages.
+func describe(err error) error {
+	var perr *protocol.Error
+	if errors.As(err, &perr) && perr.Details != "" {
+		return fmt.Errorf("%w: %s", err, perr.Details)
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+package passkey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+)
+
+const (
+	testRPID   = "example.com"
+	testOrigin = "https://app.example.com"
+)
+
+// Authenticator data flags set by the test authenticator: user present, user verified and, on
+// registration, attested credential data included.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// authenticator is a software authenticator holding one passkey.
+type authenticator struct {
+	t          *testing.T
+	key        *ecdsa.PrivateKey
+	id         []byte
+	userHandle []byte
+	counter    uint32
+}
+
+func newAuthenticator(t *testing.T) *authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return &authenticator{t: t, key: key, id: []byte("test-credential-id")}
+}
+
+// create answers registration options, as navigator.credentials.create does, from origin.
+func (a *authenticator) create(options []byte, origin string) []byte {
+	var creation struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	a.decode(options, &creation)
+	userHandle, err := b64.DecodeString(creation.PublicKey.User.ID)
+	if err != nil {
+		a.t.Fatalf("decode user handle: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         1, // P-256
+		XCoord:        a.key.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatalf("encode public key: %v", err)
+	}
+	var attested bytes.Buffer
+	attested.Write(make([]byte, 16)) // AAGUID
+	_ = binary.Write(&attested, binary.BigEndian, uint16(len(a.id)))
+	attested.Write(a.id)
+	attested.Write(publicKey)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": append(a.authData(flagUserPresent|flagUserVerified|flagAttested), attested.Bytes()...),
+	})
+	if err != nil {
+		a.t.Fatalf("encode attestation: %v", err)
+	}
+	return a.encode(map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64.EncodeToString(a.clientData("webauthn.create", creation.PublicKey.Challenge, origin)),
+			"attestationObject": b64.EncodeToString(attestation),
+			"transports":        []string{"internal"},
+		},
+	})
+}
+
+// get answers sign-in options, as navigator.credentials.get does, from origin.
+func (a *authenticator) get(options []byte, origin string) []byte {
+	var assertion struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+		} `json:"publicKey"`
+	}
+	a.decode(options, &assertion)
+
+	a.counter++
+	authData := a.authData(flagUserPresent | flagUserVerified)
+	clientData := a.clientData("webauthn.get", assertion.PublicKey.Challenge, origin)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatalf("sign assertion: %v", err)
+	}
+	return a.encode(map[string]any{
+		"id":    b64.EncodeToString(a.id),
+		"rawId": b64.EncodeToString(a.id),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    b64.EncodeToString(clientData),
+			"authenticatorData": b64.EncodeToString(authData),
+			"signature":         b64.EncodeToString(signature),
+			"userHandle":        b64.EncodeToString(a.userHandle),
+		},
+	})
+}
+
+func (a *authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.counter)
+}
+
+func (a *authenticator) clientData(typ, challenge, origin string) []byte {
+	return a.encode(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+}
+
+func (a *authenticator) encode(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		a.t.Fatalf("encode: %v", err)
+	}
+	return data
+}
+
+func (a *authenticator) decode(data []byte, v any) {
+	if err := json.Unmarshal(data, v); err != nil {
+		a.t.Fatalf("decode options: %v", err)
+	}
+}
+
+// register creates a passkey for user on a through rp.
+func register(t *testing.T, rp *RelyingParty, a *authenticator, user *contracts.User) *contracts.Passkey {
+	t.Helper()
+	options, session, err := rp.BeginRegistration(user, nil)
+	if err != nil {
+		t.Fatalf("BeginRegistration: %v", err)
+	}
+	passkey, err := rp.FinishRegistration(user, nil, session, a.create(options, testOrigin))
+	if err != nil {
+		t.Fatalf("FinishRegistration: %v", err)
+	}
+	passkey.ID = uuid.New()
+	return passkey
+}
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	rp, err := NewRelyingParty(testRPID, "bids", []string{testOrigin})
+	if err != nil {
+		t.Fatalf("NewRelyingParty: %v", err)
+	}
+	return rp
+}
+
+func TestRegistration(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	user := &contracts.User{ID: uuid.New(), Username: "ada"}
+	a := newAuthenticator(t)
+
+	passkey := register(t, rp, a, user)
+	if passkey.UserID != user.ID {
+		t.Errorf("UserID = %v, want %v", passkey.UserID, user.ID)
+	}
+	if !bytes.Equal(passkey.WebAuthnID, a.id) {
+		t.Errorf("WebAuthnID = %q, want %q", passkey.WebAuthnID, a.id)
+	}
+	if !bytes.Equal(passkey.UserHandle, user.ID[:]) {
+		t.Errorf("UserHandle = %x, want the user's ID", passkey.UserHandle)
+	}
+	if len(passkey.Transports) != 1 || passkey.Transports[0] != "internal" {
+		t.Errorf("Transports = %v, want [internal]", passkey.Transports)
+	}
+
+	t.Run("wrong origin", func(t *testing.T) {
+		options, session, err := rp.BeginRegistration(user, nil)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+		if _, err := rp.FinishRegistration(user, nil, session, newAuthenticator(t).create(options, "https://evil.example")); err == nil {
+			t.Error("registration from another origin was accepted")
+		}
+	})
+
+	t.Run("answer to another registration", func(t *testing.T) {
+		options, _, err := rp.BeginRegistration(user, nil)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+		_, session, err := rp.BeginRegistration(user, nil)
+		if err != nil {
+			t.Fatalf("BeginRegistration: %v", err)
+		}
+		if _, err := rp.FinishRegistration(user, nil, session, newAuthenticator(t).create(options, testOrigin)); err == nil {
+			t.Error("response to another challenge was accepted")
+		}
+	})
+}
+
+func TestLogin(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	user := &contracts.User{ID: uuid.New(), Username: "ada"}
+	a := newAuthenticator(t)
+	passkey := register(t, rp, a, user)
+	lookup := func(webauthnID []byte) (*contracts.Passkey, error) {
+		if bytes.Equal(webauthnID, passkey.WebAuthnID) {
+			return passkey, nil
+		}
+		return nil, nil
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		lookup  func([]byte) (*contracts.Passkey, error)
+		wantErr bool
+	}{
+		{name: "registered passkey", origin: testOrigin, lookup: lookup},
+		{name: "wrong origin", origin: "https://evil.example", lookup: lookup, wantErr: true},
+		{name: "unknown passkey", origin: testOrigin, lookup: func([]byte) (*contracts.Passkey, error) { return nil, nil }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, session, err := rp.BeginLogin()
+			if err != nil {
+				t.Fatalf("BeginLogin: %v", err)
+			}
+			asserted, err := rp.FinishLogin(session, a.get(options, tt.origin), tt.lookup)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("assertion was accepted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FinishLogin: %v", err)
+			}
+			if asserted.ID != passkey.ID || asserted.UserID != user.ID {
+				t.Errorf("asserted passkey %v of %v, want %v of %v", asserted.ID, asserted.UserID, passkey.ID, user.ID)
+			}
+			if asserted.SignCount != a.counter {
+				t.Errorf("SignCount = %d, want %d", asserted.SignCount, a.counter)
+			}
+		})
+	}
+
+	t.Run("passkey of a merged account", func(t *testing.T) {
+		// Merging moves the passkey to another user; it keeps answering with its original user handle
+		merged := *passkey
+		merged.UserID = uuid.New()
+		options, session, err := rp.BeginLogin()
+		if err != nil {
+			t.Fatalf("BeginLogin: %v", err)
+		}
+		asserted, err := rp.FinishLogin(session, a.get(options, testOrigin), func([]byte) (*contracts.Passkey, error) { return &merged, nil })
+		if err != nil {
+			t.Fatalf("FinishLogin: %v", err)
+		}
+		if asserted.UserID != merged.UserID {
+			t.Errorf("UserID = %v, want %v", asserted.UserID, merged.UserID)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+// Execer is satisfied by both *sql.DB and *sql.Tx, for writes that join a transaction when one exists.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type AuthEventRepository interface {
+	// Create appends an event to the audit log.
+	Create(ctx context.Context, db Execer, event *contracts.AuthEvent) error
+
+	// List returns up to limit events matching filter, newest first, with IDs below beforeID when it is non-zero.
+	List(ctx context.Context, db *sql.DB, filter contracts.AuthEventFilter, beforeID int64, limit int) ([]*contracts.AuthEvent, error)
+}
+
+type authEventRepository struct {
+}
+
+func NewAuthEventRepository() AuthEventRepository {
+	return &authEventRepository{}
+}
+
+// Create appends an event to the audit log.
+func (r *authEventRepository) Create(ctx context.Context, db Execer, event *contracts.AuthEvent) error {
+	metadata := []byte("{}")
+	if len(event.Metadata) > 0 {
+		var err error
+		if metadata, err = json.Marshal(event.Metadata); err != nil {
+			return err
+		}
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO auth_events (event_type, actor_id, subject_id, ip, user_agent, request_id, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.Type, event.ActorID, event.SubjectID, event.IP, event.UserAgent, event.RequestID, metadata,
+	)
+	return err
+}
+
+// List returns up to limit events matching filter, newest first, with IDs below beforeID when it is non-zero.
+func (r *authEventRepository) List(ctx context.Context, db *sql.DB, filter contracts.AuthEventFilter, beforeID int64, limit int) ([]*contracts.AuthEvent, error) {
+	var where []string
+	var args []any
+	if filter.UserID != nil {
+		args = append(args, *filter.UserID)
+		where = append(where, fmt.Sprintf("(subject_id = $%d OR actor_id = $%d)", len(args), len(args)))
+	}
+	if len(filter.Types) > 0 {
+		args = append(args, filter.Types)
+		where = append(where, fmt.Sprintf("event_type = ANY($%d)", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		where = append(where, fmt.Sprintf("occurred_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		where = append(where, fmt.Sprintf("occurred_at < $%d", len(args)))
+	}
+	if beforeID > 0 {
+		args = append(args, beforeID)
+		where = append(where, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, event_type, actor_id, subject_id, ip, user_agent, request_id, metadata, occurred_at
+		FROM auth_events`+whereSQL(where)+fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*contracts.AuthEvent
+	for rows.Next() {
+		var e contracts.AuthEvent
+		var metadata []byte
+		if err := rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.SubjectID, &e.IP, &e.UserAgent, &e.RequestID, &metadata, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(metadata, &e.Metadata); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type BackchannelLogoutRepository interface {
+	// Enqueue schedules a logout notification to a client. Enqueueing the same event and session twice
+	// for a client is a no-op. sessionID is nil when every session of the user ended.
+	Enqueue(ctx context.Context, db Execer, clientID string, eventID, userID uuid.UUID, sessionID *uuid.UUID) error
+
+	// ClaimDue locks up to limit notifications that are due, oldest first, skipping rows locked by
+	// another dispatcher.
+	ClaimDue(ctx context.Context, tx *sql.Tx, limit int) ([]*contracts.BackchannelLogoutDelivery, error)
+
+	// Delete removes a notification once it has been delivered or abandoned.
+	Delete(ctx context.Context, tx *sql.Tx, id int64) error
+
+	// MarkFailed records a failed attempt and when to try again.
+	MarkFailed(ctx context.Context, tx *sql.Tx, id int64, lastError string, nextAttemptAt time.Time) error
+}
+
+type backchannelLogoutRepository struct {
+}
+
+func NewBackchannelLogoutRepository() BackchannelLogoutRepository {
+	return &backchannelLogoutRepository{}
+}
+
+// Enqueue schedules a logout notification to a client. Enqueueing the same event and session twice
+// for a client is a no-op. sessionID is nil when every session of the user ended.
+func (r *backchannelLogoutRepository) Enqueue(ctx context.Context, db Execer, clientID string, eventID, userID uuid.UUID, sessionID *uuid.UUID) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO backchannel_logout_deliveries (client_id, event_id, user_id, session_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		clientID, eventID, userID, sessionID,
+	)
+	return err
+}
+
+// ClaimDue locks up to limit notifications that are due, oldest first, skipping rows locked by
+// another dispatcher.
+func (r *backchannelLogoutRepository) ClaimDue(ctx context.Context, tx *sql.Tx, limit int) ([]*contracts.BackchannelLogoutDelivery, error) {
+	query := `
+		SELECT id, client_id, event_id, user_id, session_id, attempts
+		FROM backchannel_logout_deliveries
+		WHERE next_attempt_at <= NOW()
+		ORDER BY next_attempt_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*contracts.BackchannelLogoutDelivery
+	for rows.Next() {
+		var d contracts.BackchannelLogoutDelivery
+		if err := rows.Scan(&d.ID, &d.ClientID, &d.EventID, &d.UserID, &d.SessionID, &d.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Delete removes a notification once it has been delivered or abandoned.
+func (r *backchannelLogoutRepository) Delete(ctx context.Context, tx *sql.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM backchannel_logout_deliveries WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed records a failed attempt and when to try again.
+func (r *backchannelLogoutRepository) MarkFailed(ctx context.Context, tx *sql.Tx, id int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE backchannel_logout_deliveries
+		SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+		WHERE id = $1`,
+		id, lastError, nextAttemptAt,
+	)
+	return err
+}
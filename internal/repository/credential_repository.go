@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type CredentialRepository interface {
+	// ListByUserID retrieves every credential of a user, oldest first.
+	ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Credential, error)
+
+	// LockByUserID retrieves every credential of a user, oldest first, locking them until tx ends so
+	// concurrent removals can't leave the user without any.
+	LockByUserID(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]*contracts.Credential, error)
+
+	// Delete removes a credential and its type-specific details, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+
+	// ReassignLinked moves every credential except the password from one user to another, returning
+	// how many were moved.
+	ReassignLinked(ctx context.Context, tx *sql.Tx, fromUserID, toUserID uuid.UUID) (int64, error)
+
+	// SharesEmail reports whether two users have an email in common, counting each user's account
+	// email and the emails of their linked identities.
+	SharesEmail(ctx context.Context, db *sql.DB, userID, otherUserID uuid.UUID) (bool, error)
+}
+
+type credentialRepository struct {
+}
+
+func NewCredentialRepository() CredentialRepository {
+	return &credentialRepository{}
+}
+
+// credentialSelect selects credentials with the details listed alongside them.
+const credentialSelect = `
+	SELECT c.id, c.user_id, c.type, COALESCE(ui.provider, si.connection), COALESCE(ui.email, si.email), c.created_at, c.last_used_at
+	FROM credentials c
+	LEFT JOIN user_identities ui ON ui.credential_id = c.id
+	LEFT JOIN saml_identities si ON si.credential_id = c.id
+	WHERE c.user_id = $1
+	ORDER BY c.created_at, c.id`
+
+// ListByUserID retrieves every credential of a user, oldest first.
+func (r *credentialRepository) ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Credential, error) {
+	rows, err := db.QueryContext(ctx, credentialSelect, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCredentials(rows)
+}
+
+// LockByUserID retrieves every credential of a user, oldest first, locking them until tx ends.
+func (r *credentialRepository) LockByUserID(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]*contracts.Credential, error) {
+	rows, err := tx.QueryContext(ctx, credentialSelect+` FOR UPDATE OF c`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCredentials(rows)
+}
+
+// Delete removes a credential and its type-specific details, reporting whether it existed.
+func (r *credentialRepository) Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM credentials WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReassignLinked moves every credential except the password from one user to another. The SAML
+// identities moved no longer count as having provisioned their user, who was created some other way.
+func (r *credentialRepository) ReassignLinked(ctx context.Context, tx *sql.Tx, fromUserID, toUserID uuid.UUID) (int64, error) {
+	var moved int64
+	err := tx.QueryRowContext(ctx,
+		`WITH moved AS (
+			UPDATE credentials SET user_id = $2 WHERE user_id = $1 AND type <> 'password' RETURNING id
+		), identities AS (
+			UPDATE user_identities SET user_id = $2 WHERE credential_id IN (SELECT id FROM moved)
+		), saml AS (
+			UPDATE saml_identities SET provisioned = FALSE WHERE credential_id IN (SELECT id FROM moved)
+		)
+		SELECT COUNT(*) FROM moved`,
+		fromUserID, toUserID,
+	).Scan(&moved)
+	return moved, err
+}
+
+// SharesEmail reports whether two users have an email in common, counting each user's account email
+// and the emails of their linked identities.
+func (r *credentialRepository) SharesEmail(ctx context.Context, db *sql.DB, userID, otherUserID uuid.UUID) (bool, error) {
+	var shares bool
+	err := db.QueryRowContext(ctx,
+		`WITH emails AS (
+			SELECT id AS user_id, email FROM users WHERE id IN ($1, $2)
+			UNION
+			SELECT c.user_id, COALESCE(ui.email, si.email)
+			FROM credentials c
+			LEFT JOIN user_identities ui ON ui.credential_id = c.id
+			LEFT JOIN saml_identities si ON si.credential_id = c.id
+			WHERE c.user_id IN ($1, $2) AND COALESCE(ui.email, si.email) IS NOT NULL
+		)
+		SELECT EXISTS (
+			SELECT 1 FROM emails a JOIN emails b ON b.email = a.email
+			WHERE a.user_id = $1 AND b.user_id = $2
+		)`,
+		userID, otherUserID,
+	).Scan(&shares)
+	return shares, err
+}
+
+func scanCredentials(rows *sql.Rows) ([]*contracts.Credential, error) {
+	defer rows.Close()
+
+	var credentials []*contracts.Credential
+	for rows.Next() {
+		var c contracts.Credential
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Type, &c.Provider, &c.Email, &c.CreatedAt, &c.LastUsedAt); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, &c)
+	}
+	return credentials, rows.Err()
+}
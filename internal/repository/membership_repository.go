@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type MembershipRepository interface {
+	// Create adds a user to an organization with a role.
+	Create(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID, role string) (*contracts.Membership, error)
+
+	// Find retrieves a user's membership of an organization, or nil if they aren't a member.
+	Find(ctx context.Context, db *sql.DB, organizationID, userID uuid.UUID) (*contracts.Membership, error)
+
+	// ListByOrganization retrieves an organization's members with their usernames and emails, oldest
+	// first.
+	ListByOrganization(ctx context.Context, db *sql.DB, organizationID uuid.UUID) ([]*contracts.Membership, error)
+
+	// ListByUser retrieves a user's memberships with their organizations, oldest first.
+	ListByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Membership, error)
+
+	// LockOwners locks the owner memberships of an organization until tx ends and returns the owners'
+	// user IDs, so changes that could leave it without an owner are serialised.
+	LockOwners(ctx context.Context, tx *sql.Tx, organizationID uuid.UUID) ([]uuid.UUID, error)
+
+	// UpdateRole changes a member's role, returning nil if they aren't a member.
+	UpdateRole(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID, role string) (*contracts.Membership, error)
+
+	// Delete removes a user from an organization, reporting whether they were a member.
+	Delete(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID) (bool, error)
+}
+
+// membershipColumns lists the columns scanMembership expects, in order.
+const membershipColumns = `organization_id, user_id, role, created_at, updated_at`
+
+type membershipRepository struct {
+}
+
+func NewMembershipRepository() MembershipRepository {
+	return &membershipRepository{}
+}
+
+// Create adds a user to an organization with a role.
+func (r *membershipRepository) Create(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID, role string) (*contracts.Membership, error) {
+	return scanMembership(tx.QueryRowContext(ctx,
+		`INSERT INTO memberships (organization_id, user_id, role) VALUES ($1, $2, $3) RETURNING `+membershipColumns,
+		organizationID, userID, role,
+	))
+}
+
+// Find retrieves a user's membership of an organization, or nil if they aren't a member.
+func (r *membershipRepository) Find(ctx context.Context, db *sql.DB, organizationID, userID uuid.UUID) (*contracts.Membership, error) {
+	membership, err := scanMembership(db.QueryRowContext(ctx,
+		`SELECT `+membershipColumns+` FROM memberships WHERE organization_id = $1 AND user_id = $2`,
+		organizationID, userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return membership, err
+}
+
+// ListByOrganization retrieves an organization's members with their usernames and emails, oldest first.
+func (r *membershipRepository) ListByOrganization(ctx context.Context, db *sql.DB, organizationID uuid.UUID) ([]*contracts.Membership, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT m.organization_id, m.user_id, m.role, m.created_at, m.updated_at, u.username, u.email
+		FROM memberships m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at, m.user_id`,
+		organizationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*contracts.Membership
+	for rows.Next() {
+		var m contracts.Membership
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt, &m.UpdatedAt, &m.Username, &m.Email); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	return memberships, rows.Err()
+}
+
+// ListByUser retrieves a user's memberships with their organizations, oldest first.
+func (r *membershipRepository) ListByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Membership, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT m.organization_id, m.user_id, m.role, m.created_at, m.updated_at, o.id, o.slug, o.name, o.created_at, o.updated_at
+		FROM memberships m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at, m.organization_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*contracts.Membership
+	for rows.Next() {
+		m := contracts.Membership{Organization: &contracts.Organization{}}
+		if err := rows.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt, &m.UpdatedAt,
+			&m.Organization.ID, &m.Organization.Slug, &m.Organization.Name, &m.Organization.CreatedAt, &m.Organization.UpdatedAt); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, &m)
+	}
+	return memberships, rows.Err()
+}
+
+// LockOwners locks the owner memberships of an organization until tx ends and returns the owners' user IDs.
+func (r *membershipRepository) LockOwners(ctx context.Context, tx *sql.Tx, organizationID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT user_id FROM memberships WHERE organization_id = $1 AND role = $2 FOR UPDATE`,
+		organizationID, contracts.MembershipRoleOwner,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owners []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owners = append(owners, id)
+	}
+	return owners, rows.Err()
+}
+
+// UpdateRole changes a member's role, returning nil if they aren't a member.
+func (r *membershipRepository) UpdateRole(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID, role string) (*contracts.Membership, error) {
+	membership, err := scanMembership(tx.QueryRowContext(ctx,
+		`UPDATE memberships SET role = $3, updated_at = NOW() WHERE organization_id = $1 AND user_id = $2 RETURNING `+membershipColumns,
+		organizationID, userID, role,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return membership, err
+}
+
+// Delete removes a user from an organization, reporting whether they were a member.
+func (r *membershipRepository) Delete(ctx context.Context, tx *sql.Tx, organizationID, userID uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM memberships WHERE organization_id = $1 AND user_id = $2`, organizationID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanMembership(row interface{ Scan(dest ...any) error }) (*contracts.Membership, error) {
+	var m contracts.Membership
+	if err := row.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
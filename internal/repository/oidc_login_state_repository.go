@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+type OIDCLoginStateRepository interface {
+	// Create stores an in-flight social login under the hash of its state.
+	Create(ctx context.Context, db *sql.DB, stateHash string, state *contracts.OIDCLoginState) error
+
+	// Consume deletes and returns the unexpired login stored under stateHash for provider, or nil when
+	// there is none, so a state can only be redeemed once.
+	Consume(ctx context.Context, db *sql.DB, stateHash, provider string) (*contracts.OIDCLoginState, error)
+
+	// DeleteExpired removes logins that were never completed.
+	DeleteExpired(ctx context.Context, db *sql.DB) error
+}
+
+type oidcLoginStateRepository struct {
+}
+
+func NewOIDCLoginStateRepository() OIDCLoginStateRepository {
+	return &oidcLoginStateRepository{}
+}
+
+// Create stores an in-flight social login under the hash of its state.
+func (r *oidcLoginStateRepository) Create(ctx context.Context, db *sql.DB, stateHash string, state *contracts.OIDCLoginState) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, link_user_id, allow_merge, organization_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		stateHash, state.Provider, state.Nonce, state.CodeVerifier, state.LinkUserID, state.AllowMerge, state.OrganizationID, state.ExpiresAt,
+	)
+	return err
+}
+
+// Consume deletes and returns the unexpired login stored under stateHash for provider, or nil when
+// there is none, so a state can only be redeemed once.
+func (r *oidcLoginStateRepository) Consume(ctx context.Context, db *sql.DB, stateHash, provider string) (*contracts.OIDCLoginState, error) {
+	var state contracts.OIDCLoginState
+	err := db.QueryRowContext(ctx,
+		`DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+		RETURNING provider, nonce, code_verifier, link_user_id, allow_merge, organization_id, expires_at`,
+		stateHash, provider,
+	).Scan(&state.Provider, &state.Nonce, &state.CodeVerifier, &state.LinkUserID, &state.AllowMerge, &state.OrganizationID, &state.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// DeleteExpired removes logins that were never completed.
+func (r *oidcLoginStateRepository) DeleteExpired(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at <= NOW()`)
+	return err
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type OrganizationInviteRepository interface {
+	// Create stores a new invite to an organization by its token hash.
+	Create(ctx context.Context, tx *sql.Tx, organizationID uuid.UUID, email, role, tokenHash string, invitedBy *uuid.UUID, expiresAt time.Time) (*contracts.OrganizationInvite, error)
+
+	// FindByHash retrieves an invite by its token hash, or nil if there is none.
+	FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.OrganizationInvite, error)
+
+	// ListPending retrieves an organization's invites that are neither accepted nor expired, newest first.
+	ListPending(ctx context.Context, db *sql.DB, organizationID uuid.UUID) ([]*contracts.OrganizationInvite, error)
+
+	// MarkAccepted marks an invite as used, reporting false if it was already accepted or revoked.
+	MarkAccepted(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+
+	// Delete revokes one of an organization's invites, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, organizationID, id uuid.UUID) (bool, error)
+}
+
+// organizationInviteColumns lists the columns scanOrganizationInvite expects, in order.
+const organizationInviteColumns = `id, organization_id, email, role, invited_by, created_at, expires_at, accepted_at`
+
+type organizationInviteRepository struct {
+}
+
+func NewOrganizationInviteRepository() OrganizationInviteRepository {
+	return &organizationInviteRepository{}
+}
+
+// Create stores a new invite to an organization by its token hash.
+func (r *organizationInviteRepository) Create(ctx context.Context, tx *sql.Tx, organizationID uuid.UUID, email, role, tokenHash string, invitedBy *uuid.UUID, expiresAt time.Time) (*contracts.OrganizationInvite, error) {
+	return scanOrganizationInvite(tx.QueryRowContext(ctx,
+		`INSERT INTO organization_invites (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+organizationInviteColumns,
+		organizationID, email, role, tokenHash, invitedBy, expiresAt,
+	))
+}
+
+// FindByHash retrieves an invite by its token hash, or nil if there is none.
+func (r *organizationInviteRepository) FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.OrganizationInvite, error) {
+	invite, err := scanOrganizationInvite(db.QueryRowContext(ctx,
+		`SELECT `+organizationInviteColumns+` FROM organization_invites WHERE token_hash = $1`,
+		tokenHash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return invite, err
+}
+
+// ListPending retrieves an organization's invites that are neither accepted nor expired, newest first.
+func (r *organizationInviteRepository) ListPending(ctx context.Context, db *sql.DB, organizationID uuid.UUID) ([]*contracts.OrganizationInvite, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+organizationInviteColumns+`
+		FROM organization_invites
+		WHERE organization_id = $1 AND accepted_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC, id DESC`,
+		organizationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []*contracts.OrganizationInvite
+	for rows.Next() {
+		invite, err := scanOrganizationInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	return invites, rows.Err()
+}
+
+// MarkAccepted marks an invite as used, reporting false if it was already accepted or revoked.
+func (r *organizationInviteRepository) MarkAccepted(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE organization_invites SET accepted_at = NOW() WHERE id = $1 AND accepted_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Delete revokes one of an organization's invites, reporting whether it existed.
+func (r *organizationInviteRepository) Delete(ctx context.Context, tx *sql.Tx, organizationID, id uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		`DELETE FROM organization_invites WHERE organization_id = $1 AND id = $2`,
+		organizationID, id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanOrganizationInvite(row interface{ Scan(dest ...any) error }) (*contracts.OrganizationInvite, error) {
+	var invite contracts.OrganizationInvite
+	if err := row.Scan(&invite.ID, &invite.OrganizationID, &invite.Email, &invite.Role, &invite.InvitedBy,
+		&invite.CreatedAt, &invite.ExpiresAt, &invite.AcceptedAt); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
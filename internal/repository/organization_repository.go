@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type OrganizationRepository interface {
+	// Create inserts a new organization.
+	Create(ctx context.Context, tx *sql.Tx, slug, name string) (*contracts.Organization, error)
+
+	// FindByID retrieves an organization by ID, or nil if there is none.
+	FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.Organization, error)
+
+	// FindBySlug retrieves an organization by slug (case-insensitive), or nil if there is none.
+	FindBySlug(ctx context.Context, db *sql.DB, slug string) (*contracts.Organization, error)
+
+	// Update changes an organization's slug and name, returning nil if it doesn't exist.
+	Update(ctx context.Context, tx *sql.Tx, id uuid.UUID, slug, name string) (*contracts.Organization, error)
+
+	// Delete removes an organization with its memberships and invites, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+}
+
+// organizationColumns lists the columns scanOrganization expects, in order.
+const organizationColumns = `id, slug, name, created_at, updated_at`
+
+type organizationRepository struct {
+}
+
+func NewOrganizationRepository() OrganizationRepository {
+	return &organizationRepository{}
+}
+
+// Create inserts a new organization.
+func (r *organizationRepository) Create(ctx context.Context, tx *sql.Tx, slug, name string) (*contracts.Organization, error) {
+	return scanOrganization(tx.QueryRowContext(ctx,
+		`INSERT INTO organizations (slug, name) VALUES ($1, $2) RETURNING `+organizationColumns,
+		slug, name,
+	))
+}
+
+// FindByID retrieves an organization by ID, or nil if there is none.
+func (r *organizationRepository) FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.Organization, error) {
+	org, err := scanOrganization(db.QueryRowContext(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return org, err
+}
+
+// FindBySlug retrieves an organization by slug (case-insensitive), or nil if there is none.
+func (r *organizationRepository) FindBySlug(ctx context.Context, db *sql.DB, slug string) (*contracts.Organization, error) {
+	org, err := scanOrganization(db.QueryRowContext(ctx, `SELECT `+organizationColumns+` FROM organizations WHERE slug = $1`, slug))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return org, err
+}
+
+// Update changes an organization's slug and name, returning nil if it doesn't exist.
+func (r *organizationRepository) Update(ctx context.Context, tx *sql.Tx, id uuid.UUID, slug, name string) (*contracts.Organization, error) {
+	org, err := scanOrganization(tx.QueryRowContext(ctx,
+		`UPDATE organizations SET slug = $2, name = $3, updated_at = NOW() WHERE id = $1 RETURNING `+organizationColumns,
+		id, slug, name,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return org, err
+}
+
+// Delete removes an organization with its memberships and invites, reporting whether it existed.
+func (r *organizationRepository) Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM organizations WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanOrganization(row interface{ Scan(dest ...any) error }) (*contracts.Organization, error) {
+	var org contracts.Organization
+	if err := row.Scan(&org.ID, &org.Slug, &org.Name, &org.CreatedAt, &org.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
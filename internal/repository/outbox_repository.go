@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+type OutboxRepository interface {
+	// Create stores a domain event for delivery once the transaction commits.
+	Create(ctx context.Context, tx *sql.Tx, event *contracts.DomainEvent) error
+
+	// ClaimPending claims up to limit undelivered events that are due, oldest first, by pushing their
+	// next attempt back to claimedUntil so no other relay picks them up meanwhile.
+	ClaimPending(ctx context.Context, db *sql.DB, limit int, claimedUntil time.Time) ([]*contracts.OutboxEvent, error)
+
+	// MarkPublished records a successful delivery.
+	MarkPublished(ctx context.Context, db Execer, id int64) error
+
+	// MarkFailed records a failed delivery and when to try again.
+	MarkFailed(ctx context.Context, db Execer, id int64, lastError string, nextAttemptAt time.Time) error
+
+	// DeletePublished removes events delivered before the given time.
+	DeletePublished(ctx context.Context, db *sql.DB, before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+}
+
+func NewOutboxRepository() OutboxRepository {
+	return &outboxRepository{}
+}
+
+// Create stores a domain event for delivery once the transaction commits.
+func (r *outboxRepository) Create(ctx context.Context, tx *sql.Tx, event *contracts.DomainEvent) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox_events (event_id, event_type, version, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		event.ID, event.Type, event.Version, payload, event.OccurredAt,
+	)
+	return err
+}
+
+// ClaimPending claims up to limit undelivered events that are due, oldest first, by pushing their
+// next attempt back to claimedUntil so no other relay picks them up meanwhile. Rows being claimed
+// by another relay are skipped. The claim commits on its own; no lock is held while events are
+// published.
+func (r *outboxRepository) ClaimPending(ctx context.Context, db *sql.DB, limit int, claimedUntil time.Time) ([]*contracts.OutboxEvent, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox_events SET next_attempt_at = $2
+		FROM due WHERE outbox_events.id = due.id
+		RETURNING outbox_events.id, event_id, event_type, version, payload, occurred_at, attempts
+	`
+	rows, err := db.QueryContext(ctx, query, limit, claimedUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*contracts.OutboxEvent
+	for rows.Next() {
+		var e contracts.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Type, &e.Version, &e.Data, &e.OccurredAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// UPDATE ... RETURNING doesn't keep the CTE's order
+	slices.SortFunc(events, func(a, b *contracts.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return events, nil
+}
+
+// MarkPublished records a successful delivery.
+func (r *outboxRepository) MarkPublished(ctx context.Context, db Execer, id int64) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE outbox_events SET published_at = NOW(), attempts = attempts + 1, last_error = NULL WHERE id = $1`,
+		id,
+	)
+	return err
+}
+
+// MarkFailed records a failed delivery and when to try again.
+func (r *outboxRepository) MarkFailed(ctx context.Context, db Execer, id int64, lastError string, nextAttemptAt time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`,
+		id, lastError, nextAttemptAt,
+	)
+	return err
+}
+
+// DeletePublished removes events delivered before the given time.
+func (r *outboxRepository) DeletePublished(ctx context.Context, db *sql.DB, before time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM outbox_events WHERE published_at IS NOT NULL AND published_at < $1`,
+		before,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
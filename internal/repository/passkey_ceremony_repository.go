@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type PasskeyCeremonyRepository interface {
+	// Create stores an in-flight passkey registration or sign-in under the hash of its state.
+	Create(ctx context.Context, db *sql.DB, stateHash string, ceremony *contracts.PasskeyCeremony) error
+
+	// Consume deletes and returns the unexpired ceremony stored under stateHash, or nil when there is
+	// none, so a challenge can only be answered once. userID selects a registration by that user; nil
+	// selects a sign-in.
+	Consume(ctx context.Context, db *sql.DB, stateHash string, userID *uuid.UUID) (*contracts.PasskeyCeremony, error)
+
+	// DeleteExpired removes ceremonies that were never completed.
+	DeleteExpired(ctx context.Context, db *sql.DB) error
+}
+
+type passkeyCeremonyRepository struct {
+}
+
+func NewPasskeyCeremonyRepository() PasskeyCeremonyRepository {
+	return &passkeyCeremonyRepository{}
+}
+
+// Create stores an in-flight passkey registration or sign-in under the hash of its state.
+func (r *passkeyCeremonyRepository) Create(ctx context.Context, db *sql.DB, stateHash string, ceremony *contracts.PasskeyCeremony) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO passkey_ceremonies (state_hash, user_id, organization_id, session, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		stateHash, ceremony.UserID, ceremony.OrganizationID, ceremony.Session, ceremony.ExpiresAt,
+	)
+	return err
+}
+
+// Consume deletes and returns the unexpired ceremony stored under stateHash for userID, or nil when
+// there is none.
+func (r *passkeyCeremonyRepository) Consume(ctx context.Context, db *sql.DB, stateHash string, userID *uuid.UUID) (*contracts.PasskeyCeremony, error) {
+	var ceremony contracts.PasskeyCeremony
+	err := db.QueryRowContext(ctx,
+		`DELETE FROM passkey_ceremonies
+		WHERE state_hash = $1 AND user_id IS NOT DISTINCT FROM $2::uuid AND expires_at > NOW()
+		RETURNING user_id, organization_id, session, expires_at`,
+		stateHash, userID,
+	).Scan(&ceremony.UserID, &ceremony.OrganizationID, &ceremony.Session, &ceremony.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ceremony, nil
+}
+
+// DeleteExpired removes ceremonies that were never completed.
+func (r *passkeyCeremonyRepository) DeleteExpired(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM passkey_ceremonies WHERE expires_at <= NOW()`)
+	return err
+}
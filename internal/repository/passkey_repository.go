@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type PasskeyRepository interface {
+	// Create registers a passkey for its user, together with the credential it belongs to.
+	Create(ctx context.Context, tx *sql.Tx, passkey *contracts.Passkey) (*contracts.Passkey, error)
+
+	// ListByUserID retrieves every passkey of a user, oldest first.
+	ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Passkey, error)
+
+	// FindByWebAuthnID retrieves the passkey an authenticator knows by webauthnID.
+	FindByWebAuthnID(ctx context.Context, db *sql.DB, webauthnID []byte) (*contracts.Passkey, error)
+
+	// RecordUse records a sign-in with a passkey: its new signature counter and flags, and the time.
+	RecordUse(ctx context.Context, db *sql.DB, id uuid.UUID, signCount uint32, flags uint8, at time.Time) error
+}
+
+type passkeyRepository struct {
+}
+
+func NewPasskeyRepository() PasskeyRepository {
+	return &passkeyRepository{}
+}
+
+// passkeySelect selects passkeys with the user owning their credential.
+const passkeySelect = `
+	SELECT pk.credential_id, c.user_id, pk.webauthn_id, pk.user_handle, pk.public_key, pk.attestation_type,
+		pk.transports, pk.aaguid, pk.sign_count, pk.flags, pk.created_at
+	FROM passkey_credentials pk
+	JOIN credentials c ON c.id = pk.credential_id`
+
+// Create registers a passkey for its user, together with the credential it belongs to.
+func (r *passkeyRepository) Create(ctx context.Context, tx *sql.Tx, passkey *contracts.Passkey) (*contracts.Passkey, error) {
+	transports, err := encodeTransports(passkey.Transports)
+	if err != nil {
+		return nil, err
+	}
+	return scanPasskey(tx.QueryRowContext(ctx,
+		`WITH credential AS (
+			INSERT INTO credentials (user_id, type) VALUES ($1, 'passkey') RETURNING id, user_id
+		), passkey AS (
+			INSERT INTO passkey_credentials (credential_id, webauthn_id, user_handle, public_key, attestation_type, transports, aaguid, sign_count, flags)
+			SELECT id, $2, $3, $4, $5, $6, $7, $8, $9 FROM credential
+			RETURNING *
+		)
+		SELECT passkey.credential_id, credential.user_id, passkey.webauthn_id, passkey.user_handle, passkey.public_key,
+			passkey.attestation_type, passkey.transports, passkey.aaguid, passkey.sign_count, passkey.flags, passkey.created_at
+		FROM passkey JOIN credential ON credential.id = passkey.credential_id`,
+		passkey.UserID, passkey.WebAuthnID, passkey.UserHandle, passkey.PublicKey, passkey.AttestationType, transports,
+		passkey.AAGUID, int64(passkey.SignCount), int16(passkey.Flags),
+	))
+}
+
+// ListByUserID retrieves every passkey of a user, oldest first.
+func (r *passkeyRepository) ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.Passkey, error) {
+	rows, err := db.QueryContext(ctx, passkeySelect+` WHERE c.user_id = $1 ORDER BY pk.created_at, pk.credential_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []*contracts.Passkey
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+// FindByWebAuthnID retrieves the passkey an authenticator knows by webauthnID.
+func (r *passkeyRepository) FindByWebAuthnID(ctx context.Context, db *sql.DB, webauthnID []byte) (*contracts.Passkey, error) {
+	passkey, err := scanPasskey(db.QueryRowContext(ctx, passkeySelect+` WHERE pk.webauthn_id = $1`, webauthnID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return passkey, err
+}
+
+// RecordUse records a sign-in with a passkey: its new signature counter and flags, and the time.
+func (r *passkeyRepository) RecordUse(ctx context.Context, db *sql.DB, id uuid.UUID, signCount uint32, flags uint8, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`WITH passkey AS (
+			UPDATE passkey_credentials SET sign_count = $2, flags = $3 WHERE credential_id = $1 RETURNING credential_id
+		)
+		UPDATE credentials SET last_used_at = $4 WHERE id IN (SELECT credential_id FROM passkey)`,
+		id, int64(signCount), int16(flags), at,
+	)
+	return err
+}
+
+func scanPasskey(row interface{ Scan(dest ...any) error }) (*contracts.Passkey, error) {
+	var (
+		passkey    contracts.Passkey
+		transports []byte
+		signCount  int64
+		flags      int16
+	)
+	if err := row.Scan(&passkey.ID, &passkey.UserID, &passkey.WebAuthnID, &passkey.UserHandle, &passkey.PublicKey,
+		&passkey.AttestationType, &transports, &passkey.AAGUID, &signCount, &flags, &passkey.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(transports, &passkey.Transports); err != nil {
+		return nil, err
+	}
+	passkey.SignCount = uint32(signCount)
+	passkey.Flags = uint8(flags)
+	return &passkey, nil
+}
+
+// encodeTransports encodes a passkey's transports as a JSON array, never null.
+func encodeTransports(transports []string) ([]byte, error) {
+	if transports == nil {
+		transports = []string{}
+	}
+	return json.Marshal(transports)
+}
@@ -4,14 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
 	"github.com/google/uuid"
 )
 
 type PasswordCredentialRepository interface {
-	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, hash, salt string) error
+	Create(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error
 	GetByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) (*contracts.PasswordCredential, error)
+	Update(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error
+	// TouchLogin records a sign-in with the user's password.
+	TouchLogin(ctx context.Context, db *sql.DB, userID uuid.UUID, at time.Time) error
+	CountByPepper(ctx context.Context, db *sql.DB) ([]contracts.PepperUsage, error)
 }
 
 type postgresPasswordCredentialRepository struct {
@@ -21,20 +26,28 @@ func NewPasswordCredentialRepository() PasswordCredentialRepository {
 	return &postgresPasswordCredentialRepository{}
 }
 
-func (r *postgresPasswordCredentialRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, hash, salt string) error {
+// Create stores a user's password together with the credential it belongs to.
+func (r *postgresPasswordCredentialRepository) Create(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error {
 	_, err := tx.ExecContext(ctx,
-		`INSERT INTO password_credentials (user_id, password_hash, password_salt) VALUES ($1, $2, $3)`,
-		userID, hash, salt,
+		`WITH credential AS (
+			INSERT INTO credentials (user_id, type) VALUES ($1, 'password') RETURNING id
+		)
+		INSERT INTO password_credentials
+			(credential_id, user_id, password_hash, password_salt, password_algo, pepper_id,
+			 hash_memory, hash_iterations, hash_parallelism, hash_salt_length, hash_key_length)
+		SELECT credential.id, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10 FROM credential`,
+		append([]any{cred.UserID, cred.PasswordHash, cred.PasswordSalt, cred.Algorithm, cred.PepperID}, hashParamArgs(cred.Params)...)...,
 	)
 	return err
 }
 
 func (r *postgresPasswordCredentialRepository) GetByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) (*contracts.PasswordCredential, error) {
-	cred := &contracts.PasswordCredential{}
-	err := db.QueryRowContext(ctx,
-		`SELECT user_id, password_hash, password_salt FROM password_credentials WHERE user_id = $1`,
+	cred, err := scanPasswordCredential(db.QueryRowContext(ctx,
+		`SELECT user_id, password_hash, password_salt, password_algo, pepper_id,
+			hash_memory, hash_iterations, hash_parallelism, hash_salt_length, hash_key_length
+		FROM password_credentials WHERE user_id = $1`,
 		userID,
-	).Scan(&cred.UserID, &cred.PasswordHash, &cred.PasswordSalt)
+	))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -43,3 +56,85 @@ func (r *postgresPasswordCredentialRepository) GetByUserID(ctx context.Context,
 	}
 	return cred, nil
 }
+
+// Update replaces a user's password hash, e.g. after a parameter or pepper upgrade.
+func (r *postgresPasswordCredentialRepository) Update(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE password_credentials
+		SET password_hash = $2, password_salt = $3, password_algo = $4, pepper_id = $5,
+			hash_memory = $6, hash_iterations = $7, hash_parallelism = $8, hash_salt_length = $9, hash_key_length = $10
+		WHERE user_id = $1`,
+		append([]any{cred.UserID, cred.PasswordHash, cred.PasswordSalt, cred.Algorithm, cred.PepperID}, hashParamArgs(cred.Params)...)...,
+	)
+	return err
+}
+
+// TouchLogin records a sign-in with the user's password.
+func (r *postgresPasswordCredentialRepository) TouchLogin(ctx context.Context, db *sql.DB, userID uuid.UUID, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE credentials SET last_used_at = $2 WHERE user_id = $1 AND type = 'password'`,
+		userID, at,
+	)
+	return err
+}
+
+// CountByPepper reports how many credentials were hashed with each pepper version.
+func (r *postgresPasswordCredentialRepository) CountByPepper(ctx context.Context, db *sql.DB) ([]contracts.PepperUsage, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT pepper_id, COUNT(*) FROM password_credentials GROUP BY pepper_id ORDER BY pepper_id NULLS LAST`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []contracts.PepperUsage
+	for rows.Next() {
+		var pepperID sql.NullInt64
+		var u contracts.PepperUsage
+		if err := rows.Scan(&pepperID, &u.Credentials); err != nil {
+			return nil, err
+		}
+		if pepperID.Valid {
+			id := int(pepperID.Int64)
+			u.PepperID = &id
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// scanPasswordCredential scans the columns user_id, password_hash, password_salt, password_algo,
+// pepper_id and the five hash_* parameter columns, in that order.
+func scanPasswordCredential(row interface{ Scan(dest ...any) error }) (*contracts.PasswordCredential, error) {
+	cred := &contracts.PasswordCredential{}
+	var pepperID, memory, iterations, parallelism, saltLength, keyLength sql.NullInt64
+	err := row.Scan(&cred.UserID, &cred.PasswordHash, &cred.PasswordSalt, &cred.Algorithm, &pepperID,
+		&memory, &iterations, &parallelism, &saltLength, &keyLength)
+	if err != nil {
+		return nil, err
+	}
+	if pepperID.Valid {
+		id := int(pepperID.Int64)
+		cred.PepperID = &id
+	}
+	if memory.Valid && iterations.Valid && parallelism.Valid && saltLength.Valid && keyLength.Valid {
+		cred.Params = &contracts.PasswordHashParams{
+			Memory:      uint32(memory.Int64),
+			Iterations:  uint32(iterations.Int64),
+			Parallelism: uint8(parallelism.Int64),
+			SaltLength:  uint32(saltLength.Int64),
+			KeyLength:   uint32(keyLength.Int64),
+		}
+	}
+	return cred, nil
+}
+
+// hashParamArgs flattens hash parameters into query arguments; nil stores NULLs (e.g. for
+// legacy hashes, which carry their parameters inside the hash string).
+func hashParamArgs(params *contracts.PasswordHashParams) []any {
+	if params == nil {
+		return []any{nil, nil, nil, nil, nil}
+	}
+	return []any{params.Memory, params.Iterations, params.Parallelism, params.SaltLength, params.KeyLength}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+// PasswordHistoryRepository stores hashes of passwords users have replaced.
+type PasswordHistoryRepository interface {
+	Create(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error
+	ListRecent(ctx context.Context, db *sql.DB, userID uuid.UUID, limit int) ([]*contracts.PasswordCredential, error)
+	Trim(ctx context.Context, tx *sql.Tx, userID uuid.UUID, keep int) error
+}
+
+type postgresPasswordHistoryRepository struct {
+}
+
+func NewPasswordHistoryRepository() PasswordHistoryRepository {
+	return &postgresPasswordHistoryRepository{}
+}
+
+// Create records a replaced password credential.
+func (r *postgresPasswordHistoryRepository) Create(ctx context.Context, tx *sql.Tx, cred *contracts.PasswordCredential) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO password_history
+			(user_id, password_hash, password_salt, password_algo, pepper_id,
+			 hash_memory, hash_iterations, hash_parallelism, hash_salt_length, hash_key_length)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		append([]any{cred.UserID, cred.PasswordHash, cred.PasswordSalt, cred.Algorithm, cred.PepperID}, hashParamArgs(cred.Params)...)...,
+	)
+	return err
+}
+
+// ListRecent returns up to limit of the user's previous passwords, most recent first.
+func (r *postgresPasswordHistoryRepository) ListRecent(ctx context.Context, db *sql.DB, userID uuid.UUID, limit int) ([]*contracts.PasswordCredential, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT user_id, password_hash, password_salt, password_algo, pepper_id,
+			hash_memory, hash_iterations, hash_parallelism, hash_salt_length, hash_key_length
+		FROM password_history WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*contracts.PasswordCredential
+	for rows.Next() {
+		cred, err := scanPasswordCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, cred)
+	}
+	return history, rows.Err()
+}
+
+// Trim deletes all but the keep most recent entries for the user.
+func (r *postgresPasswordHistoryRepository) Trim(ctx context.Context, tx *sql.Tx, userID uuid.UUID, keep int) error {
+	_, err := tx.ExecContext(ctx,
+		`DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history WHERE user_id = $1
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		)`,
+		userID, keep,
+	)
+	return err
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+type PermissionRepository interface {
+	// Create inserts a new permission.
+	Create(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Permission, error)
+
+	// List retrieves every permission, ordered by name.
+	List(ctx context.Context, db *sql.DB) ([]*contracts.Permission, error)
+
+	// FindMissing returns the names that aren't permissions, in the order given.
+	FindMissing(ctx context.Context, tx *sql.Tx, names []string) ([]string, error)
+
+	// ListByRole retrieves the permissions a role grants, ordered by name.
+	ListByRole(ctx context.Context, db *sql.DB, role string) ([]string, error)
+
+	// Delete removes a permission, revoking it from every role, and reports whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, name string) (bool, error)
+}
+
+// permissionColumns lists the columns scanPermission expects, in order.
+const permissionColumns = `name, description, created_at`
+
+type permissionRepository struct {
+}
+
+func NewPermissionRepository() PermissionRepository {
+	return &permissionRepository{}
+}
+
+// Create inserts a new permission.
+func (r *permissionRepository) Create(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Permission, error) {
+	return scanPermission(tx.QueryRowContext(ctx,
+		`INSERT INTO permissions (name, description) VALUES ($1, $2) RETURNING `+permissionColumns,
+		name, description,
+	))
+}
+
+// List retrieves every permission, ordered by name.
+func (r *permissionRepository) List(ctx context.Context, db *sql.DB) ([]*contracts.Permission, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+permissionColumns+` FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []*contracts.Permission
+	for rows.Next() {
+		permission, err := scanPermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+// FindMissing returns the names that aren't permissions, in the order given.
+func (r *permissionRepository) FindMissing(ctx context.Context, tx *sql.Tx, names []string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT n.name FROM unnest($1::text[]) WITH ORDINALITY AS n(name, ord)
+		WHERE NOT EXISTS (SELECT 1 FROM permissions p WHERE p.name = n.name)
+		ORDER BY n.ord`,
+		names,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		missing = append(missing, name)
+	}
+	return missing, rows.Err()
+}
+
+// ListByRole retrieves the permissions a role grants, ordered by name.
+func (r *permissionRepository) ListByRole(ctx context.Context, db *sql.DB, role string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT permission_name FROM role_permissions WHERE role_name = $1 ORDER BY permission_name`,
+		role,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, name)
+	}
+	return permissions, rows.Err()
+}
+
+// Delete removes a permission, revoking it from every role, and reports whether it existed.
+func (r *permissionRepository) Delete(ctx context.Context, tx *sql.Tx, name string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM permissions WHERE name = $1`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanPermission(row interface{ Scan(dest ...any) error }) (*contracts.Permission, error) {
+	var permission contracts.Permission
+	if err := row.Scan(&permission.Name, &permission.Description, &permission.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type PersonalAccessTokenRepository interface {
+	// Create stores a new token by its hash.
+	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, name, tokenHash string, scopes []string, expiresAt time.Time) (*contracts.PersonalAccessToken, error)
+
+	// FindByHash retrieves the token with the given hash, or nil if there is none.
+	FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.PersonalAccessToken, error)
+
+	// FindByID retrieves a token by its ID, or nil if there is none.
+	FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.PersonalAccessToken, error)
+
+	// ListByUser retrieves a user's tokens, oldest first.
+	ListByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.PersonalAccessToken, error)
+
+	// TouchLastUsed records a use of a token.
+	TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error
+
+	// Delete revokes one of a user's tokens, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, userID, id uuid.UUID) (bool, error)
+}
+
+// personalAccessTokenColumns lists the columns scanPersonalAccessToken expects, in order.
+const personalAccessTokenColumns = `id, user_id, name, scopes, created_at, expires_at, last_used_at`
+
+type personalAccessTokenRepository struct {
+}
+
+func NewPersonalAccessTokenRepository() PersonalAccessTokenRepository {
+	return &personalAccessTokenRepository{}
+}
+
+// Create stores a new token by its hash.
+func (r *personalAccessTokenRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, name, tokenHash string, scopes []string, expiresAt time.Time) (*contracts.PersonalAccessToken, error) {
+	encoded, err := encodeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	return scanPersonalAccessToken(tx.QueryRowContext(ctx,
+		`INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+personalAccessTokenColumns,
+		userID, name, tokenHash, encoded, expiresAt,
+	))
+}
+
+// FindByHash retrieves the token with the given hash, or nil if there is none.
+func (r *personalAccessTokenRepository) FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.PersonalAccessToken, error) {
+	token, err := scanPersonalAccessToken(db.QueryRowContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE token_hash = $1`,
+		tokenHash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+// FindByID retrieves a token by its ID, or nil if there is none.
+func (r *personalAccessTokenRepository) FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.PersonalAccessToken, error) {
+	token, err := scanPersonalAccessToken(db.QueryRowContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE id = $1`,
+		id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+// ListByUser retrieves a user's tokens, oldest first.
+func (r *personalAccessTokenRepository) ListByUser(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.PersonalAccessToken, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+personalAccessTokenColumns+` FROM personal_access_tokens WHERE user_id = $1 ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*contracts.PersonalAccessToken
+	for rows.Next() {
+		token, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchLastUsed records a use of a token.
+func (r *personalAccessTokenRepository) TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE personal_access_tokens SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// Delete revokes one of a user's tokens, reporting whether it existed.
+func (r *personalAccessTokenRepository) Delete(ctx context.Context, tx *sql.Tx, userID, id uuid.UUID) (bool, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func scanPersonalAccessToken(row interface{ Scan(dest ...any) error }) (*contracts.PersonalAccessToken, error) {
+	var token contracts.PersonalAccessToken
+	var scopes []byte
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &token.Scopes); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// encodeScopes encodes a token's scopes as a JSON array, never null.
+func encodeScopes(scopes []string) ([]byte, error) {
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return json.Marshal(scopes)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type RateLimitRepository interface {
+	// Hit increments the counter for key in the window starting at windowStart and returns
+	// the hit counts of that window and the window immediately before it.
+	Hit(ctx context.Context, db *sql.DB, key string, windowStart, previousStart, expiresAt time.Time) (current, previous int64, err error)
+
+	// DeleteExpired removes counters that can no longer affect any decision (for cleanup).
+	DeleteExpired(ctx context.Context, db *sql.DB) error
+}
+
+type rateLimitRepository struct {
+}
+
+func NewRateLimitRepository() RateLimitRepository {
+	return &rateLimitRepository{}
+}
+
+// Hit increments the counter for key in the window starting at windowStart and returns
+// the hit counts of that window and the window immediately before it.
+func (r *rateLimitRepository) Hit(ctx context.Context, db *sql.DB, key string, windowStart, previousStart, expiresAt time.Time) (int64, int64, error) {
+	query := `
+		WITH current_window AS (
+			INSERT INTO rate_limit_counters (key, window_start, hits, expires_at)
+			VALUES ($1, $2, 1, $4)
+			ON CONFLICT (key, window_start) DO UPDATE SET hits = rate_limit_counters.hits + 1
+			RETURNING hits
+		)
+		SELECT current_window.hits,
+			COALESCE((SELECT hits FROM rate_limit_counters WHERE key = $1 AND window_start = $3), 0)
+		FROM current_window
+	`
+	var current, previous int64
+	err := db.QueryRowContext(ctx, query, key, windowStart, previousStart, expiresAt).Scan(&current, &previous)
+	if err != nil {
+		return 0, 0, err
+	}
+	return current, previous, nil
+}
+
+// DeleteExpired removes counters that can no longer affect any decision (for cleanup).
+func (r *rateLimitRepository) DeleteExpired(ctx context.Context, db *sql.DB) error {
+	query := `
+		DELETE FROM rate_limit_counters
+		WHERE expires_at < NOW()
+	`
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
@@ -11,20 +11,32 @@ import (
 )
 
 type RefreshTokenRepository interface {
-	// Create stores a new refresh token in the database.
-	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tokenHash string, issuedAt time.Time, expiresAt time.Time) (uuid.UUID, error)
+	// Create stores a new refresh token in the database as part of a session. authenticatedAt is when
+	// the user last proved who they are in the session, nil if unknown; organizationID is the
+	// organization the session acts in, nil if none.
+	Create(ctx context.Context, tx *sql.Tx, userID, sessionID uuid.UUID, tokenHash string, issuedAt time.Time, expiresAt time.Time, authenticatedAt *time.Time, organizationID *uuid.UUID) (uuid.UUID, error)
 
 	// FindByHash retrieves a refresh token by its hash.
 	FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.RefreshToken, error)
 
+	// FindActiveBySession retrieves the active refresh token of a session, or nil if it has none.
+	FindActiveBySession(ctx context.Context, db *sql.DB, sessionID uuid.UUID) (*contracts.RefreshToken, error)
+
+	// ListByUserID retrieves every refresh token stored for a user, newest first.
+	ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.RefreshToken, error)
+
 	// Revoke marks a refresh token as revoked.
-	Revoke(ctx context.Context, db *sql.DB, tokenID uuid.UUID) error
+	Revoke(ctx context.Context, tx *sql.Tx, tokenID uuid.UUID) error
+
+	// RevokeSession revokes the active refresh tokens of a session, reporting whether there were any.
+	RevokeSession(ctx context.Context, tx *sql.Tx, sessionID uuid.UUID) (bool, error)
 
 	// RevokeWithReplacement marks a token as revoked and records its replacement (for token rotation).
 	RevokeWithReplacement(ctx context.Context, tx *sql.Tx, tokenID, replacementTokenID uuid.UUID) error
 
-	// RevokeAllForUser revokes all active refresh tokens for a user (for logout all devices).
-	RevokeAllForUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error
+	// RevokeAllForUser revokes all active refresh tokens for a user (for logout all devices) and returns
+	// the IDs of the sessions they belonged to.
+	RevokeAllForUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]uuid.UUID, error)
 
 	// DeleteExpired removes expired tokens from the database (for cleanup).
 	DeleteExpired(ctx context.Context, db *sql.DB) error
@@ -37,13 +49,13 @@ func NewRefreshTokenRepository() RefreshTokenRepository {
 	return &refreshTokenRepository{}
 }
 
-// Create stores a new refresh token in the database.
-func (r *refreshTokenRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tokenHash string, issuedAt time.Time, expiresAt time.Time) (uuid.UUID, error) {
+// Create stores a new refresh token in the database as part of a session.
+func (r *refreshTokenRepository) Create(ctx context.Context, tx *sql.Tx, userID, sessionID uuid.UUID, tokenHash string, issuedAt time.Time, expiresAt time.Time, authenticatedAt *time.Time, organizationID *uuid.UUID) (uuid.UUID, error) {
 	tokenID := uuid.New()
 	_, err := tx.ExecContext(ctx,
-		`INSERT INTO refresh_tokens (token_id, user_id, token_hash, issued_at, expires_at)
-		VALUES ($1, $2, $3, $4, $5)`,
-		tokenID, userID, tokenHash, issuedAt, expiresAt)
+		`INSERT INTO refresh_tokens (token_id, user_id, session_id, token_hash, issued_at, expires_at, authenticated_at, organization_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		tokenID, userID, sessionID, tokenHash, issuedAt, expiresAt, authenticatedAt, organizationID)
 	if err != nil {
 		return uuid.Nil, err
 	}
@@ -53,7 +65,7 @@ func (r *refreshTokenRepository) Create(ctx context.Context, tx *sql.Tx, userID
 // FindByHash retrieves a refresh token by its hash.
 func (r *refreshTokenRepository) FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.RefreshToken, error) {
 	query := `
-		SELECT token_id, user_id, token_hash, issued_at, expires_at, revoked_at, replaced_by_token_id
+		SELECT token_id, user_id, session_id, token_hash, issued_at, expires_at, revoked_at, replaced_by_token_id, authenticated_at, organization_id
 		FROM refresh_tokens
 		WHERE token_hash = $1
 	`
@@ -61,11 +73,45 @@ func (r *refreshTokenRepository) FindByHash(ctx context.Context, db *sql.DB, tok
 	err := db.QueryRowContext(ctx, query, tokenHash).Scan(
 		&rt.TokenID,
 		&rt.UserID,
+		&rt.SessionID,
+		&rt.TokenHash,
+		&rt.IssuedAt,
+		&rt.ExpiresAt,
+		&rt.RevokedAt,
+		&rt.ReplacedByTokenID,
+		&rt.AuthenticatedAt,
+		&rt.OrganizationID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rt, nil
+}
+
+// FindActiveBySession retrieves the active refresh token of a session, or nil if it has none.
+func (r *refreshTokenRepository) FindActiveBySession(ctx context.Context, db *sql.DB, sessionID uuid.UUID) (*contracts.RefreshToken, error) {
+	query := `
+		SELECT token_id, user_id, session_id, token_hash, issued_at, expires_at, revoked_at, replaced_by_token_id, authenticated_at, organization_id
+		FROM refresh_tokens
+		WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY issued_at DESC
+		LIMIT 1
+	`
+	var rt contracts.RefreshToken
+	err := db.QueryRowContext(ctx, query, sessionID).Scan(
+		&rt.TokenID,
+		&rt.UserID,
+		&rt.SessionID,
 		&rt.TokenHash,
 		&rt.IssuedAt,
 		&rt.ExpiresAt,
 		&rt.RevokedAt,
 		&rt.ReplacedByTokenID,
+		&rt.AuthenticatedAt,
+		&rt.OrganizationID,
 	)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -76,17 +122,68 @@ func (r *refreshTokenRepository) FindByHash(ctx context.Context, db *sql.DB, tok
 	return &rt, nil
 }
 
+// ListByUserID retrieves every refresh token stored for a user, newest first.
+func (r *refreshTokenRepository) ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.RefreshToken, error) {
+	query := `
+		SELECT token_id, user_id, session_id, token_hash, issued_at, expires_at, revoked_at, replaced_by_token_id, authenticated_at, organization_id
+		FROM refresh_tokens
+		WHERE user_id = $1
+		ORDER BY issued_at DESC
+	`
+	rows, err := db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*contracts.RefreshToken
+	for rows.Next() {
+		var rt contracts.RefreshToken
+		if err := rows.Scan(
+			&rt.TokenID,
+			&rt.UserID,
+			&rt.SessionID,
+			&rt.TokenHash,
+			&rt.IssuedAt,
+			&rt.ExpiresAt,
+			&rt.RevokedAt,
+			&rt.ReplacedByTokenID,
+			&rt.AuthenticatedAt,
+			&rt.OrganizationID,
+		); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &rt)
+	}
+	return tokens, rows.Err()
+}
+
 // Revoke marks a refresh token as revoked.
-func (r *refreshTokenRepository) Revoke(ctx context.Context, db *sql.DB, tokenID uuid.UUID) error {
+func (r *refreshTokenRepository) Revoke(ctx context.Context, tx *sql.Tx, tokenID uuid.UUID) error {
 	query := `
 		UPDATE refresh_tokens
 		SET revoked_at = NOW()
 		WHERE token_id = $1 AND revoked_at IS NULL
 	`
-	_, err := db.ExecContext(ctx, query, tokenID)
+	_, err := tx.ExecContext(ctx, query, tokenID)
 	return err
 }
 
+// RevokeSession revokes the active refresh tokens of a session, reporting whether there were any.
+func (r *refreshTokenRepository) RevokeSession(ctx context.Context, tx *sql.Tx, sessionID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = NOW()
+		WHERE session_id = $1 AND revoked_at IS NULL
+	`
+	res, err := tx.ExecContext(ctx, query, sessionID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 // RevokeWithReplacement marks a token as revoked and records its replacement (for token rotation).
 func (r *refreshTokenRepository) RevokeWithReplacement(ctx context.Context, tx *sql.Tx, tokenID, replacementTokenID uuid.UUID) error {
 	query := `
@@ -98,15 +195,30 @@ func (r *refreshTokenRepository) RevokeWithReplacement(ctx context.Context, tx *
 	return err
 }
 
-// RevokeAllForUser revokes all active refresh tokens for a user (for logout all devices).
-func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+// RevokeAllForUser revokes all active refresh tokens for a user (for logout all devices) and returns
+// the IDs of the sessions they belonged to.
+func (r *refreshTokenRepository) RevokeAllForUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]uuid.UUID, error) {
 	query := `
 		UPDATE refresh_tokens
 		SET revoked_at = NOW()
 		WHERE user_id = $1 AND revoked_at IS NULL
+		RETURNING session_id
 	`
-	_, err := tx.ExecContext(ctx, query, userID)
-	return err
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessionIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	return sessionIDs, rows.Err()
 }
 
 // DeleteExpired removes expired tokens from the database (for cleanup).
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+type RoleRepository interface {
+	// Create inserts a new role without permissions.
+	Create(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Role, error)
+
+	// FindByName retrieves a role with its permissions, or nil if there is none.
+	FindByName(ctx context.Context, db *sql.DB, name string) (*contracts.Role, error)
+
+	// List retrieves every role with its permissions, ordered by name.
+	List(ctx context.Context, db *sql.DB) ([]*contracts.Role, error)
+
+	// Update changes a role's description, returning nil if it doesn't exist.
+	Update(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Role, error)
+
+	// SetPermissions replaces the permissions a role grants.
+	SetPermissions(ctx context.Context, tx *sql.Tx, name string, permissions []string) error
+
+	// InUse reports whether any user or service account holds a role.
+	InUse(ctx context.Context, tx *sql.Tx, name string) (bool, error)
+
+	// HeldGranting reports whether any active user holds a role granting one of permissions.
+	HeldGranting(ctx context.Context, tx *sql.Tx, permissions []string) (bool, error)
+
+	// Delete removes a role and its grants, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, name string) (bool, error)
+}
+
+// roleColumns lists the columns scanRole expects, in order.
+const roleColumns = `name, description, builtin, created_at, updated_at`
+
+type roleRepository struct {
+}
+
+func NewRoleRepository() RoleRepository {
+	return &roleRepository{}
+}
+
+// Create inserts a new role without permissions.
+func (r *roleRepository) Create(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Role, error) {
+	role, err := scanRole(tx.QueryRowContext(ctx,
+		`INSERT INTO roles (name, description) VALUES ($1, $2) RETURNING `+roleColumns,
+		name, description,
+	))
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = []string{}
+	return role, nil
+}
+
+// FindByName retrieves a role with its permissions, or nil if there is none.
+func (r *roleRepository) FindByName(ctx context.Context, db *sql.DB, name string) (*contracts.Role, error) {
+	role, err := scanRole(db.QueryRowContext(ctx, `SELECT `+roleColumns+` FROM roles WHERE name = $1`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	grants, err := listGrants(ctx, db, &name)
+	if err != nil {
+		return nil, err
+	}
+	role.Permissions = grants[name]
+	return role, nil
+}
+
+// List retrieves every role with its permissions, ordered by name.
+func (r *roleRepository) List(ctx context.Context, db *sql.DB) ([]*contracts.Role, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+roleColumns+` FROM roles ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*contracts.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	grants, err := listGrants(ctx, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles {
+		role.Permissions = grants[role.Name]
+		if role.Permissions == nil {
+			role.Permissions = []string{}
+		}
+	}
+	return roles, nil
+}
+
+// Update changes a role's description, returning nil if it doesn't exist.
+func (r *roleRepository) Update(ctx context.Context, tx *sql.Tx, name, description string) (*contracts.Role, error) {
+	role, err := scanRole(tx.QueryRowContext(ctx,
+		`UPDATE roles SET description = $2, updated_at = NOW() WHERE name = $1 RETURNING `+roleColumns,
+		name, description,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return role, err
+}
+
+// SetPermissions replaces the permissions a role grants.
+func (r *roleRepository) SetPermissions(ctx context.Context, tx *sql.Tx, name string, permissions []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_name = $1`, name); err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO role_permissions (role_name, permission_name)
+		SELECT $1, p FROM unnest($2::text[]) AS p
+		ON CONFLICT DO NOTHING`,
+		name, permissions,
+	)
+	return err
+}
+
+// InUse reports whether any user or service account holds a role.
+func (r *roleRepository) InUse(ctx context.Context, tx *sql.Tx, name string) (bool, error) {
+	var inUse bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM users WHERE "role" = $1)
+		OR EXISTS (SELECT 1 FROM service_accounts WHERE "role" = $1)`,
+		name,
+	).Scan(&inUse)
+	return inUse, err
+}
+
+// HeldGranting reports whether any active user holds a role granting one of permissions.
+func (r *roleRepository) HeldGranting(ctx context.Context, tx *sql.Tx, permissions []string) (bool, error) {
+	var held bool
+	err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM users u
+			JOIN role_permissions rp ON rp.role_name = u."role"
+			WHERE u.status = $1 AND rp.permission_name = ANY($2::text[])
+		)`,
+		contracts.UserStatusActive, permissions,
+	).Scan(&held)
+	return held, err
+}
+
+// Delete removes a role and its grants, reporting whether it existed.
+func (r *roleRepository) Delete(ctx context.Context, tx *sql.Tx, name string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE name = $1`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// listGrants returns the permissions granted to one role, or to every role when name is nil, keyed
+// by role name.
+func listGrants(ctx context.Context, db *sql.DB, name *string) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT role_name, permission_name FROM role_permissions
+		WHERE $1::text IS NULL OR role_name = $1
+		ORDER BY role_name, permission_name`,
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := make(map[string][]string)
+	if name != nil {
+		grants[*name] = []string{}
+	}
+	for rows.Next() {
+		var role, permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		grants[role] = append(grants[role], permission)
+	}
+	return grants, rows.Err()
+}
+
+func scanRole(row interface{ Scan(dest ...any) error }) (*contracts.Role, error) {
+	var role contracts.Role
+	if err := row.Scan(&role.Name, &role.Description, &role.Builtin, &role.CreatedAt, &role.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
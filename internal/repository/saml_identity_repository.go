@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type SAMLIdentityRepository interface {
+	// Create links a SAML identity to a user, together with the credential it belongs to. provisioned
+	// marks the identity the user was created for.
+	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, connection, nameID string, email *string, provisioned bool) (*contracts.SAMLIdentity, error)
+
+	// FindByConnectionNameID retrieves the identity a connection's IdP knows by nameID.
+	FindByConnectionNameID(ctx context.Context, db *sql.DB, connection, nameID string) (*contracts.SAMLIdentity, error)
+
+	// TouchLogin records a sign-in with an identity and refreshes the email the IdP asserted.
+	TouchLogin(ctx context.Context, db *sql.DB, id uuid.UUID, email *string, at time.Time) error
+}
+
+type samlIdentityRepository struct {
+}
+
+func NewSAMLIdentityRepository() SAMLIdentityRepository {
+	return &samlIdentityRepository{}
+}
+
+// Create links a SAML identity to a user, together with the credential it belongs to.
+func (r *samlIdentityRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, connection, nameID string, email *string, provisioned bool) (*contracts.SAMLIdentity, error) {
+	return scanSAMLIdentity(tx.QueryRowContext(ctx,
+		`WITH credential AS (
+			INSERT INTO credentials (user_id, type, last_used_at) VALUES ($1, 'saml', NOW()) RETURNING id, user_id
+		), identity AS (
+			INSERT INTO saml_identities (credential_id, connection, name_id, email, provisioned)
+			SELECT id, $2, $3, $4, $5 FROM credential
+			RETURNING credential_id, connection, name_id, email, provisioned, created_at
+		)
+		SELECT identity.credential_id, credential.user_id, identity.connection, identity.name_id, identity.email, identity.provisioned, identity.created_at
+		FROM identity JOIN credential ON credential.id = identity.credential_id`,
+		userID, connection, nameID, email, provisioned,
+	))
+}
+
+// FindByConnectionNameID retrieves the identity a connection's IdP knows by nameID.
+func (r *samlIdentityRepository) FindByConnectionNameID(ctx context.Context, db *sql.DB, connection, nameID string) (*contracts.SAMLIdentity, error) {
+	identity, err := scanSAMLIdentity(db.QueryRowContext(ctx,
+		`SELECT si.credential_id, c.user_id, si.connection, si.name_id, si.email, si.provisioned, si.created_at
+		FROM saml_identities si
+		JOIN credentials c ON c.id = si.credential_id
+		WHERE si.connection = $1 AND si.name_id = $2`,
+		connection, nameID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return identity, err
+}
+
+// TouchLogin records a sign-in with an identity and refreshes the email the IdP asserted.
+func (r *samlIdentityRepository) TouchLogin(ctx context.Context, db *sql.DB, id uuid.UUID, email *string, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`WITH identity AS (
+			UPDATE saml_identities SET email = COALESCE($3, email) WHERE credential_id = $1 RETURNING credential_id
+		)
+		UPDATE credentials SET last_used_at = $2 WHERE id IN (SELECT credential_id FROM identity)`,
+		id, at, email,
+	)
+	return err
+}
+
+func scanSAMLIdentity(row interface{ Scan(dest ...any) error }) (*contracts.SAMLIdentity, error) {
+	var identity contracts.SAMLIdentity
+	if err := row.Scan(&identity.ID, &identity.UserID, &identity.Connection, &identity.NameID, &identity.Email,
+		&identity.Provisioned, &identity.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+type SAMLLoginStateRepository interface {
+	// Create stores an in-flight SAML login under the hash of its RelayState.
+	Create(ctx context.Context, db *sql.DB, stateHash string, state *contracts.SAMLLoginState) error
+
+	// Consume deletes and returns the unexpired login stored under stateHash for connection, or nil
+	// when there is none, so an AuthnRequest can only be answered once.
+	Consume(ctx context.Context, db *sql.DB, stateHash, connection string) (*contracts.SAMLLoginState, error)
+
+	// DeleteExpired removes logins that were never completed.
+	DeleteExpired(ctx context.Context, db *sql.DB) error
+}
+
+type samlLoginStateRepository struct {
+}
+
+func NewSAMLLoginStateRepository() SAMLLoginStateRepository {
+	return &samlLoginStateRepository{}
+}
+
+// Create stores an in-flight SAML login under the hash of its RelayState.
+func (r *samlLoginStateRepository) Create(ctx context.Context, db *sql.DB, stateHash string, state *contracts.SAMLLoginState) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO saml_login_states (state_hash, connection, request_id, organization_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		stateHash, state.Connection, state.RequestID, state.OrganizationID, state.ExpiresAt,
+	)
+	return err
+}
+
+// Consume deletes and returns the unexpired login stored under stateHash for connection, or nil when
+// there is none, so an AuthnRequest can only be answered once.
+func (r *samlLoginStateRepository) Consume(ctx context.Context, db *sql.DB, stateHash, connection string) (*contracts.SAMLLoginState, error) {
+	var state contracts.SAMLLoginState
+	err := db.QueryRowContext(ctx,
+		`DELETE FROM saml_login_states
+		WHERE state_hash = $1 AND connection = $2 AND expires_at > NOW()
+		RETURNING connection, request_id, organization_id, expires_at`,
+		stateHash, connection,
+	).Scan(&state.Connection, &state.RequestID, &state.OrganizationID, &state.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// DeleteExpired removes logins that were never completed.
+func (r *samlLoginStateRepository) DeleteExpired(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM saml_login_states WHERE expires_at <= NOW()`)
+	return err
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type SCIMTokenRepository interface {
+	// Create stores a new token by its hash.
+	Create(ctx context.Context, tx *sql.Tx, tenant, tokenHash, description string) (*contracts.SCIMToken, error)
+
+	// FindByHash retrieves the token with the given hash, or nil if there is none.
+	FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.SCIMToken, error)
+
+	// List retrieves every token, oldest first.
+	List(ctx context.Context, db *sql.DB) ([]*contracts.SCIMToken, error)
+
+	// TouchLastUsed records a use of a token.
+	TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error
+
+	// Delete revokes a token, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+}
+
+// scimTokenColumns lists the columns scanSCIMToken expects, in order.
+const scimTokenColumns = `id, tenant, description, created_at, last_used_at`
+
+type scimTokenRepository struct {
+}
+
+func NewSCIMTokenRepository() SCIMTokenRepository {
+	return &scimTokenRepository{}
+}
+
+// Create stores a new token by its hash.
+func (r *scimTokenRepository) Create(ctx context.Context, tx *sql.Tx, tenant, tokenHash, description string) (*contracts.SCIMToken, error) {
+	return scanSCIMToken(tx.QueryRowContext(ctx,
+		`INSERT INTO scim_tokens (tenant, token_hash, description) VALUES ($1, $2, $3) RETURNING `+scimTokenColumns,
+		tenant, tokenHash, description,
+	))
+}
+
+// FindByHash retrieves the token with the given hash, or nil if there is none.
+func (r *scimTokenRepository) FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.SCIMToken, error) {
+	token, err := scanSCIMToken(db.QueryRowContext(ctx,
+		`SELECT `+scimTokenColumns+` FROM scim_tokens WHERE token_hash = $1`,
+		tokenHash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return token, err
+}
+
+// List retrieves every token, oldest first.
+func (r *scimTokenRepository) List(ctx context.Context, db *sql.DB) ([]*contracts.SCIMToken, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+scimTokenColumns+` FROM scim_tokens ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*contracts.SCIMToken
+	for rows.Next() {
+		token, err := scanSCIMToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// TouchLastUsed records a use of a token.
+func (r *scimTokenRepository) TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE scim_tokens SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// Delete revokes a token, reporting whether it existed.
+func (r *scimTokenRepository) Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM scim_tokens WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func scanSCIMToken(row interface{ Scan(dest ...any) error }) (*contracts.SCIMToken, error) {
+	var token contracts.SCIMToken
+	if err := row.Scan(&token.ID, &token.Tenant, &token.Description, &token.CreatedAt, &token.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
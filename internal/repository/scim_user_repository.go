@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/scim"
+	"github.com/google/uuid"
+)
+
+type SCIMUserRepository interface {
+	// Create records that a tenant provisioned a user, with its SCIM attributes.
+	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tenant string, externalID, givenName, familyName, displayName *string) error
+
+	// Update replaces the SCIM attributes of a provisioned user.
+	Update(ctx context.Context, tx *sql.Tx, userID uuid.UUID, externalID, givenName, familyName, displayName *string) error
+
+	// FindByID retrieves a user the tenant provisioned, or nil if it provisioned no such user.
+	FindByID(ctx context.Context, db *sql.DB, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error)
+
+	// LockByID retrieves a user the tenant provisioned and locks it until tx ends, or returns nil if
+	// it provisioned no such user.
+	LockByID(ctx context.Context, tx *sql.Tx, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error)
+
+	// List returns up to limit of the tenant's users matching filter (all when nil), oldest first,
+	// skipping the first offset.
+	List(ctx context.Context, db *sql.DB, tenant string, filter scim.Filter, offset, limit int) ([]*contracts.SCIMUser, error)
+
+	// Count returns the number of the tenant's users matching filter (all when nil).
+	Count(ctx context.Context, db *sql.DB, tenant string, filter scim.Filter) (int64, error)
+}
+
+// scimUserColumns lists the columns scanSCIMUser expects, in order: the user's, then the SCIM attributes.
+const scimUserColumns = `u.id, u.username, u.email, u.created_at, u.updated_at, u."role", u.status, u.status_reason,
+	u.status_changed_at, u.deletion_scheduled_at, s.tenant, s.external_id, s.given_name, s.family_name, s.display_name`
+
+type scimUserRepository struct {
+}
+
+func NewSCIMUserRepository() SCIMUserRepository {
+	return &scimUserRepository{}
+}
+
+// Create records that a tenant provisioned a user, with its SCIM attributes.
+func (r *scimUserRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tenant string, externalID, givenName, familyName, displayName *string) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO scim_users (user_id, tenant, external_id, given_name, family_name, display_name)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		userID, tenant, externalID, givenName, familyName, displayName,
+	)
+	return err
+}
+
+// Update replaces the SCIM attributes of a provisioned user.
+func (r *scimUserRepository) Update(ctx context.Context, tx *sql.Tx, userID uuid.UUID, externalID, givenName, familyName, displayName *string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE scim_users SET external_id = $2, given_name = $3, family_name = $4, display_name = $5 WHERE user_id = $1`,
+		userID, externalID, givenName, familyName, displayName,
+	)
+	return err
+}
+
+// FindByID retrieves a user the tenant provisioned, or nil if it provisioned no such user.
+func (r *scimUserRepository) FindByID(ctx context.Context, db *sql.DB, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error) {
+	user, err := scanSCIMUser(db.QueryRowContext(ctx,
+		`SELECT `+scimUserColumns+`
+		FROM scim_users s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.tenant = $1 AND s.user_id = $2`,
+		tenant, userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// LockByID retrieves a user the tenant provisioned and locks it until tx ends, or returns nil if it
+// provisioned no such user.
+func (r *scimUserRepository) LockByID(ctx context.Context, tx *sql.Tx, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error) {
+	user, err := scanSCIMUser(tx.QueryRowContext(ctx,
+		`SELECT `+scimUserColumns+`
+		FROM scim_users s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.tenant = $1 AND s.user_id = $2
+		FOR UPDATE`,
+		tenant, userID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// List returns up to limit of the tenant's users matching filter, oldest first, skipping the first offset.
+func (r *scimUserRepository) List(ctx context.Context, db *sql.DB, tenant string, filter scim.Filter, offset, limit int) ([]*contracts.SCIMUser, error) {
+	where, args, err := scimUserWhere(tenant, filter)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, offset, limit)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+scimUserColumns+`
+		FROM scim_users s
+		JOIN users u ON u.id = s.user_id
+		WHERE `+where+
+			fmt.Sprintf(` ORDER BY u.created_at, u.id OFFSET $%d LIMIT $%d`, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*contracts.SCIMUser
+	for rows.Next() {
+		user, err := scanSCIMUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Count returns the number of the tenant's users matching filter.
+func (r *scimUserRepository) Count(ctx context.Context, db *sql.DB, tenant string, filter scim.Filter) (int64, error) {
+	where, args, err := scimUserWhere(tenant, filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM scim_users s JOIN users u ON u.id = s.user_id WHERE `+where,
+		args...,
+	).Scan(&count)
+	return count, err
+}
+
+// scimUserWhere builds the WHERE condition and positional arguments selecting the tenant's users
+// matching filter.
+func scimUserWhere(tenant string, filter scim.Filter) (string, []any, error) {
+	args := []any{tenant}
+	if filter == nil {
+		return "s.tenant = $1", args, nil
+	}
+	condition, args, err := scimFilterSQL(filter, args)
+	if err != nil {
+		return "", nil, err
+	}
+	return "s.tenant = $1 AND " + condition, args, nil
+}
+
+// scimFilterColumn is the SQL expression a filterable SCIM attribute is read from. fold marks text
+// compared case-insensitively that isn't already CITEXT.
+type scimFilterColumn struct {
+	expr string
+	text bool
+	fold bool
+}
+
+// scimFilterColumns maps the attributes scim.ParseFilter accepts to SQL. Every user has exactly one
+// email, reported as the primary work email.
+var scimFilterColumns = map[string]scimFilterColumn{
+	"id":                {expr: `u.id::text`, text: true, fold: true},
+	"externalid":        {expr: `s.external_id`, text: true},
+	"username":          {expr: `u.username`, text: true},
+	"displayname":       {expr: `s.display_name`, text: true, fold: true},
+	"name.formatted":    {expr: `NULLIF(CONCAT_WS(' ', s.given_name, s.family_name), '')`, text: true, fold: true},
+	"name.givenname":    {expr: `s.given_name`, text: true, fold: true},
+	"name.familyname":   {expr: `s.family_name`, text: true, fold: true},
+	"emails.value":      {expr: `u.email`, text: true},
+	"emails.type":       {expr: `'work'::text`, text: true, fold: true},
+	"emails.primary":    {expr: `TRUE`},
+	"active":            {expr: `(u.status = 'active')`},
+	"meta.created":      {expr: `u.created_at`},
+	"meta.lastmodified": {expr: `u.updated_at`},
+}
+
+var scimCompareOps = map[string]string{"eq": "=", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+
+// scimFilterSQL translates a parsed filter into a condition, appending its arguments to args.
+// Conditions are never NULL, so not matches users without the attribute.
+func scimFilterSQL(filter scim.Filter, args []any) (string, []any, error) {
+	switch f := filter.(type) {
+	case *scim.LogicalExpr:
+		left, args, err := scimFilterSQL(f.Left, args)
+		if err != nil {
+			return "", nil, err
+		}
+		right, args, err := scimFilterSQL(f.Right, args)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + left + " " + strings.ToUpper(f.Op) + " " + right + ")", args, nil
+	case *scim.NotExpr:
+		inner, args, err := scimFilterSQL(f.Filter, args)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT " + inner, args, nil
+	case *scim.AttrExpr:
+		column, ok := scimFilterColumns[f.Attr]
+		if !ok {
+			return "", nil, fmt.Errorf("no column for SCIM attribute %s", f.Attr)
+		}
+		if f.Op == "pr" {
+			if column.text {
+				return "(NULLIF(" + column.expr + ", '') IS NOT NULL)", args, nil
+			}
+			return "(" + column.expr + " IS NOT NULL)", args, nil
+		}
+
+		value := f.Value
+		var pattern bool
+		if s, ok := value.(string); ok {
+			switch f.Op {
+			case "co":
+				value, pattern = "%"+likeEscaper.Replace(s)+"%", true
+			case "sw":
+				value, pattern = likeEscaper.Replace(s)+"%", true
+			case "ew":
+				value, pattern = "%"+likeEscaper.Replace(s), true
+			}
+		}
+		args = append(args, value)
+		lhs, rhs := column.expr, fmt.Sprintf("$%d", len(args))
+		if column.fold {
+			lhs, rhs = "LOWER("+lhs+")", "LOWER("+rhs+")"
+		}
+
+		switch {
+		case pattern:
+			return "COALESCE(" + lhs + " LIKE " + rhs + ", FALSE)", args, nil
+		case f.Op == "ne":
+			return "(" + lhs + " IS DISTINCT FROM " + rhs + ")", args, nil
+		default:
+			op, ok := scimCompareOps[f.Op]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown SCIM operator %s", f.Op)
+			}
+			return "COALESCE(" + lhs + " " + op + " " + rhs + ", FALSE)", args, nil
+		}
+	}
+	return "", nil, fmt.Errorf("unknown SCIM filter %T", filter)
+}
+
+// scanSCIMUser scans a row of scimUserColumns.
+func scanSCIMUser(row interface{ Scan(dest ...any) error }) (*contracts.SCIMUser, error) {
+	user := &contracts.SCIMUser{User: &contracts.User{}}
+	err := row.Scan(&user.User.ID, &user.User.Username, &user.User.Email, &user.User.CreatedAt, &user.User.UpdatedAt,
+		&user.User.Role, &user.User.Status, &user.User.StatusReason, &user.User.StatusChangedAt,
+		&user.User.DeletionScheduledAt, &user.Tenant, &user.ExternalID, &user.GivenName, &user.FamilyName,
+		&user.DisplayName)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/scim"
+)
+
+func TestSCIMUserWhere(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "no filter",
+			wantSQL:  `s.tenant = $1`,
+			wantArgs: []any{"acme"},
+		},
+		{
+			name:     "CITEXT equality",
+			filter:   `userName eq "bjensen"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(u.username = $2, FALSE)`,
+			wantArgs: []any{"acme", "bjensen"},
+		},
+		{
+			name:     "text folded",
+			filter:   `name.givenName eq "Barbara"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(LOWER(s.given_name) = LOWER($2), FALSE)`,
+			wantArgs: []any{"acme", "Barbara"},
+		},
+		{
+			name:     "not equal matches missing values",
+			filter:   `externalId ne "e-1"`,
+			wantSQL:  `s.tenant = $1 AND (s.external_id IS DISTINCT FROM $2)`,
+			wantArgs: []any{"acme", "e-1"},
+		},
+		{
+			name:     "contains escapes wildcards",
+			filter:   `emails co "100%_\\"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(u.email LIKE $2, FALSE)`,
+			wantArgs: []any{"acme", `%100\%\_\\%`},
+		},
+		{
+			name:     "starts with",
+			filter:   `displayName sw "Ba"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(LOWER(s.display_name) LIKE LOWER($2), FALSE)`,
+			wantArgs: []any{"acme", "Ba%"},
+		},
+		{
+			name:     "ends with",
+			filter:   `emails.value ew "@example.com"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(u.email LIKE $2, FALSE)`,
+			wantArgs: []any{"acme", "%@example.com"},
+		},
+		{
+			name:     "text present",
+			filter:   `displayName pr`,
+			wantSQL:  `s.tenant = $1 AND (NULLIF(s.display_name, '') IS NOT NULL)`,
+			wantArgs: []any{"acme"},
+		},
+		{
+			name:     "dateTime ordered",
+			filter:   `meta.lastModified ge "2024-01-02T03:04:05Z"`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(u.updated_at >= $2, FALSE)`,
+			wantArgs: []any{"acme", created},
+		},
+		{
+			name:     "boolean",
+			filter:   `active eq false`,
+			wantSQL:  `s.tenant = $1 AND COALESCE((u.status = 'active') = $2, FALSE)`,
+			wantArgs: []any{"acme", false},
+		},
+		{
+			name:     "email type is always work",
+			filter:   `emails[type eq "Work"]`,
+			wantSQL:  `s.tenant = $1 AND COALESCE(LOWER('work'::text) = LOWER($2), FALSE)`,
+			wantArgs: []any{"acme", "Work"},
+		},
+		{
+			name:     "logical operators and negation number arguments in order",
+			filter:   `not (userName eq "a" or userName eq "b") and active eq true`,
+			wantSQL:  `s.tenant = $1 AND (NOT (COALESCE(u.username = $2, FALSE) OR COALESCE(u.username = $3, FALSE)) AND COALESCE((u.status = 'active') = $4, FALSE))`,
+			wantArgs: []any{"acme", "a", "b", true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var filter scim.Filter
+			if tt.filter != "" {
+				var err error
+				if filter, err = scim.ParseFilter(tt.filter); err != nil {
+					t.Fatalf("ParseFilter() error = %v", err)
+				}
+			}
+			gotSQL, gotArgs, err := scimUserWhere("acme", filter)
+			if err != nil {
+				t.Fatalf("scimUserWhere() error = %v", err)
+			}
+			if gotSQL != tt.wantSQL {
+				t.Errorf("SQL = %s\nwant  %s", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// TestSCIMFilterColumns checks that every attribute the parser accepts can be translated.
+func TestSCIMFilterColumns(t *testing.T) {
+	for _, attr := range []string{"id", "externalId", "userName", "displayName", "name.formatted", "name.givenName",
+		"name.familyName", "emails.value", "emails.type", "emails.primary", "active", "meta.created", "meta.lastModified"} {
+		filter, err := scim.ParseFilter(attr + " pr")
+		if err != nil {
+			t.Fatalf("ParseFilter(%s pr) error = %v", attr, err)
+		}
+		if _, _, err := scimUserWhere("acme", filter); err != nil {
+			t.Errorf("scimUserWhere(%s pr) error = %v", attr, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type ServiceAccountKeyRepository interface {
+	// Create stores a new key by its hash.
+	Create(ctx context.Context, tx *sql.Tx, serviceAccountID uuid.UUID, prefix, keyHash string) (*contracts.ServiceAccountKey, error)
+
+	// FindByHash retrieves the key with the given hash, or nil if there is none.
+	FindByHash(ctx context.Context, db *sql.DB, keyHash string) (*contracts.ServiceAccountKey, error)
+
+	// ListByAccount retrieves a service account's keys, oldest first.
+	ListByAccount(ctx context.Context, db *sql.DB, serviceAccountID uuid.UUID) ([]*contracts.ServiceAccountKey, error)
+
+	// CountByAccount counts a service account's keys.
+	CountByAccount(ctx context.Context, tx *sql.Tx, serviceAccountID uuid.UUID) (int, error)
+
+	// TouchLastUsed records a use of a key.
+	TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error
+
+	// Delete revokes one of a service account's keys, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, serviceAccountID, id uuid.UUID) (bool, error)
+}
+
+// serviceAccountKeyColumns lists the columns scanServiceAccountKey expects, in order.
+const serviceAccountKeyColumns = `id, service_account_id, prefix, created_at, last_used_at`
+
+type serviceAccountKeyRepository struct {
+}
+
+func NewServiceAccountKeyRepository() ServiceAccountKeyRepository {
+	return &serviceAccountKeyRepository{}
+}
+
+// Create stores a new key by its hash.
+func (r *serviceAccountKeyRepository) Create(ctx context.Context, tx *sql.Tx, serviceAccountID uuid.UUID, prefix, keyHash string) (*contracts.ServiceAccountKey, error) {
+	return scanServiceAccountKey(tx.QueryRowContext(ctx,
+		`INSERT INTO service_account_keys (service_account_id, prefix, key_hash) VALUES ($1, $2, $3) RETURNING `+serviceAccountKeyColumns,
+		serviceAccountID, prefix, keyHash,
+	))
+}
+
+// FindByHash retrieves the key with the given hash, or nil if there is none.
+func (r *serviceAccountKeyRepository) FindByHash(ctx context.Context, db *sql.DB, keyHash string) (*contracts.ServiceAccountKey, error) {
+	key, err := scanServiceAccountKey(db.QueryRowContext(ctx,
+		`SELECT `+serviceAccountKeyColumns+` FROM service_account_keys WHERE key_hash = $1`,
+		keyHash,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return key, err
+}
+
+// ListByAccount retrieves a service account's keys, oldest first.
+func (r *serviceAccountKeyRepository) ListByAccount(ctx context.Context, db *sql.DB, serviceAccountID uuid.UUID) ([]*contracts.ServiceAccountKey, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+serviceAccountKeyColumns+` FROM service_account_keys WHERE service_account_id = $1 ORDER BY created_at, id`,
+		serviceAccountID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*contracts.ServiceAccountKey
+	for rows.Next() {
+		key, err := scanServiceAccountKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// CountByAccount counts a service account's keys.
+func (r *serviceAccountKeyRepository) CountByAccount(ctx context.Context, tx *sql.Tx, serviceAccountID uuid.UUID) (int, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM service_account_keys WHERE service_account_id = $1`, serviceAccountID).Scan(&n)
+	return n, err
+}
+
+// TouchLastUsed records a use of a key.
+func (r *serviceAccountKeyRepository) TouchLastUsed(ctx context.Context, db Execer, id uuid.UUID, at time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE service_account_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+// Delete revokes one of a service account's keys, reporting whether it existed.
+func (r *serviceAccountKeyRepository) Delete(ctx context.Context, tx *sql.Tx, serviceAccountID, id uuid.UUID) (bool, error) {
+	result, err := tx.ExecContext(ctx, `DELETE FROM service_account_keys WHERE id = $1 AND service_account_id = $2`, id, serviceAccountID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func scanServiceAccountKey(row interface{ Scan(dest ...any) error }) (*contracts.ServiceAccountKey, error) {
+	var key contracts.ServiceAccountKey
+	if err := row.Scan(&key.ID, &key.ServiceAccountID, &key.Prefix, &key.CreatedAt, &key.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type ServiceAccountRepository interface {
+	// Create inserts a new service account, owned by organizationID or by the admins when it is nil.
+	Create(ctx context.Context, tx *sql.Tx, name, description, role string, organizationID, createdBy *uuid.UUID) (*contracts.ServiceAccount, error)
+
+	// FindByID retrieves a service account, or nil if there is none.
+	FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.ServiceAccount, error)
+
+	// LockByID retrieves a service account, locking it until tx ends, or nil if there is none.
+	LockByID(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*contracts.ServiceAccount, error)
+
+	// List retrieves an organization's service accounts, or every service account when organizationID
+	// is nil, oldest first.
+	List(ctx context.Context, db *sql.DB, organizationID *uuid.UUID) ([]*contracts.ServiceAccount, error)
+
+	// Delete removes a service account with its keys, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+}
+
+// serviceAccountColumns lists the columns scanServiceAccount expects, in order.
+const serviceAccountColumns = `id, name, description, role, organization_id, created_by, created_at, updated_at`
+
+type serviceAccountRepository struct {
+}
+
+func NewServiceAccountRepository() ServiceAccountRepository {
+	return &serviceAccountRepository{}
+}
+
+// Create inserts a new service account, owned by organizationID or by the admins when it is nil.
+func (r *serviceAccountRepository) Create(ctx context.Context, tx *sql.Tx, name, description, role string, organizationID, createdBy *uuid.UUID) (*contracts.ServiceAccount, error) {
+	return scanServiceAccount(tx.QueryRowContext(ctx,
+		`INSERT INTO service_accounts (name, description, role, organization_id, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+serviceAccountColumns,
+		name, description, role, organizationID, createdBy,
+	))
+}
+
+// FindByID retrieves a service account, or nil if there is none.
+func (r *serviceAccountRepository) FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.ServiceAccount, error) {
+	account, err := scanServiceAccount(db.QueryRowContext(ctx, `SELECT `+serviceAccountColumns+` FROM service_accounts WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return account, err
+}
+
+// LockByID retrieves a service account, locking it until tx ends, or nil if there is none.
+func (r *serviceAccountRepository) LockByID(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*contracts.ServiceAccount, error) {
+	account, err := scanServiceAccount(tx.QueryRowContext(ctx, `SELECT `+serviceAccountColumns+` FROM service_accounts WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return account, err
+}
+
+// List retrieves an organization's service accounts, or every service account when organizationID
+// is nil, oldest first.
+func (r *serviceAccountRepository) List(ctx context.Context, db *sql.DB, organizationID *uuid.UUID) ([]*contracts.ServiceAccount, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+serviceAccountColumns+` FROM service_accounts
+		WHERE $1::uuid IS NULL OR organization_id = $1
+		ORDER BY created_at, id`,
+		organizationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []*contracts.ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// Delete removes a service account with its keys, reporting whether it existed.
+func (r *serviceAccountRepository) Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM service_accounts WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanServiceAccount(row interface{ Scan(dest ...any) error }) (*contracts.ServiceAccount, error) {
+	var account contracts.ServiceAccount
+	if err := row.Scan(&account.ID, &account.Name, &account.Description, &account.Role, &account.OrganizationID,
+		&account.CreatedBy, &account.CreatedAt, &account.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	// Create stores a new session identified by the hash of its opaque token.
+	Create(ctx context.Context, db *sql.DB, session *contracts.Session, tokenHash string) error
+
+	// FindActiveByHash retrieves a session by the hash of its token, provided its refresh session still
+	// has an active refresh token.
+	FindActiveByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.Session, error)
+
+	// Touch records that a session was used at seenAt, extending its idle timeout.
+	Touch(ctx context.Context, db *sql.DB, sessionID uuid.UUID, seenAt time.Time) error
+
+	// Delete removes a session.
+	Delete(ctx context.Context, db *sql.DB, sessionID uuid.UUID) error
+
+	// DeleteExpired removes sessions past their absolute timeout, idle since before idleBefore, or whose
+	// refresh session was revoked, and returns how many were removed.
+	DeleteExpired(ctx context.Context, db *sql.DB, idleBefore time.Time) (int64, error)
+}
+
+type sessionRepository struct {
+}
+
+func NewSessionRepository() SessionRepository {
+	return &sessionRepository{}
+}
+
+// Create stores a new session identified by the hash of its opaque token.
+func (r *sessionRepository) Create(ctx context.Context, db *sql.DB, session *contracts.Session, tokenHash string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO sessions (id, user_id, token_hash, ip, user_agent, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		session.ID, session.UserID, tokenHash, session.IP, session.UserAgent, session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+	)
+	return err
+}
+
+// FindActiveByHash retrieves a session by the hash of its token, provided its refresh session still
+// has an active refresh token.
+func (r *sessionRepository) FindActiveByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.Session, error) {
+	query := `
+		SELECT s.id, s.user_id, s.ip, s.user_agent, s.created_at, s.last_seen_at, s.expires_at
+		FROM sessions s
+		WHERE s.token_hash = $1
+		AND EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.session_id = s.id AND t.revoked_at IS NULL AND t.expires_at > NOW()
+		)
+	`
+	var s contracts.Session
+	err := db.QueryRowContext(ctx, query, tokenHash).Scan(&s.ID, &s.UserID, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Touch records that a session was used at seenAt, extending its idle timeout.
+func (r *sessionRepository) Touch(ctx context.Context, db *sql.DB, sessionID uuid.UUID, seenAt time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $2 WHERE id = $1`, sessionID, seenAt)
+	return err
+}
+
+// Delete removes a session.
+func (r *sessionRepository) Delete(ctx context.Context, db *sql.DB, sessionID uuid.UUID) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, sessionID)
+	return err
+}
+
+// DeleteExpired removes sessions past their absolute timeout, idle since before idleBefore, or whose
+// refresh session was revoked, and returns how many were removed.
+func (r *sessionRepository) DeleteExpired(ctx context.Context, db *sql.DB, idleBefore time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx,
+		`DELETE FROM sessions s
+		WHERE s.expires_at <= NOW()
+		OR s.last_seen_at < $1
+		OR NOT EXISTS (
+			SELECT 1 FROM refresh_tokens t
+			WHERE t.session_id = s.id AND t.revoked_at IS NULL AND t.expires_at > NOW()
+		)`,
+		idleBefore,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type UserIdentityRepository interface {
+	// Create links an external identity to a user.
+	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, provider, subject string, email *string) (*contracts.UserIdentity, error)
+
+	// FindByProviderSubject retrieves the identity a provider knows by subject.
+	FindByProviderSubject(ctx context.Context, db *sql.DB, provider, subject string) (*contracts.UserIdentity, error)
+
+	// ListByUserID retrieves every identity linked to a user, oldest first.
+	ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.UserIdentity, error)
+
+	// TouchLogin records a sign-in with an identity and refreshes the email the provider reported.
+	TouchLogin(ctx context.Context, db *sql.DB, id uuid.UUID, email *string, at time.Time) error
+}
+
+type userIdentityRepository struct {
+}
+
+func NewUserIdentityRepository() UserIdentityRepository {
+	return &userIdentityRepository{}
+}
+
+// Create links an external identity to a user, together with the credential it belongs to.
+func (r *userIdentityRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, provider, subject string, email *string) (*contracts.UserIdentity, error) {
+	return scanUserIdentity(tx.QueryRowContext(ctx,
+		`WITH credential AS (
+			INSERT INTO credentials (user_id, type, last_used_at) VALUES ($1, 'oidc', NOW()) RETURNING id
+		)
+		INSERT INTO user_identities (id, credential_id, user_id, provider, subject, email)
+		SELECT credential.id, credential.id, $1, $2, $3, $4 FROM credential
+		RETURNING id, user_id, provider, subject, email, created_at`,
+		userID, provider, subject, email,
+	))
+}
+
+// FindByProviderSubject retrieves the identity a provider knows by subject.
+func (r *userIdentityRepository) FindByProviderSubject(ctx context.Context, db *sql.DB, provider, subject string) (*contracts.UserIdentity, error) {
+	identity, err := scanUserIdentity(db.QueryRowContext(ctx,
+		`SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities WHERE provider = $1 AND subject = $2`,
+		provider, subject,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return identity, err
+}
+
+// ListByUserID retrieves every identity linked to a user, oldest first.
+func (r *userIdentityRepository) ListByUserID(ctx context.Context, db *sql.DB, userID uuid.UUID) ([]*contracts.UserIdentity, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities WHERE user_id = $1 ORDER BY created_at, id`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identities []*contracts.UserIdentity
+	for rows.Next() {
+		identity, err := scanUserIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// TouchLogin records a sign-in with an identity and refreshes the email the provider reported.
+func (r *userIdentityRepository) TouchLogin(ctx context.Context, db *sql.DB, id uuid.UUID, email *string, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`WITH identity AS (
+			UPDATE user_identities SET email = COALESCE($3, email) WHERE id = $1 RETURNING credential_id
+		)
+		UPDATE credentials SET last_used_at = $2 WHERE id IN (SELECT credential_id FROM identity)`,
+		id, at, email,
+	)
+	return err
+}
+
+func scanUserIdentity(row interface{ Scan(dest ...any) error }) (*contracts.UserIdentity, error) {
+	var identity contracts.UserIdentity
+	if err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email,
+		&identity.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type UserInviteRepository interface {
+	// Create stores a new invite for a user.
+	Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tokenHash string, expiresAt time.Time) error
+
+	// FindByHash retrieves an invite by its token hash.
+	FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.UserInvite, error)
+
+	// MarkAccepted marks an invite as used, reporting false if it was already accepted.
+	MarkAccepted(ctx context.Context, tx *sql.Tx, tokenHash string) (bool, error)
+}
+
+type userInviteRepository struct {
+}
+
+func NewUserInviteRepository() UserInviteRepository {
+	return &userInviteRepository{}
+}
+
+// Create stores a new invite for a user.
+func (r *userInviteRepository) Create(ctx context.Context, tx *sql.Tx, userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO user_invites (token_hash, user_id, expires_at) VALUES ($1, $2, $3)`,
+		tokenHash, userID, expiresAt,
+	)
+	return err
+}
+
+// FindByHash retrieves an invite by its token hash.
+func (r *userInviteRepository) FindByHash(ctx context.Context, db *sql.DB, tokenHash string) (*contracts.UserInvite, error) {
+	var invite contracts.UserInvite
+	err := db.QueryRowContext(ctx,
+		`SELECT token_hash, user_id, created_at, expires_at, accepted_at FROM user_invites WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&invite.TokenHash, &invite.UserID, &invite.CreatedAt, &invite.ExpiresAt, &invite.AcceptedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// MarkAccepted marks an invite as used, reporting false if it was already accepted.
+func (r *userInviteRepository) MarkAccepted(ctx context.Context, tx *sql.Tx, tokenHash string) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE user_invites SET accepted_at = NOW() WHERE token_hash = $1 AND accepted_at IS NULL`,
+		tokenHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
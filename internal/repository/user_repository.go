@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // UserRepository defines operations for user data access.
@@ -15,8 +19,19 @@ type UserRepository interface {
 	FindByUsername(ctx context.Context, db *sql.DB, username string) (*contracts.User, error)
 	FindByID(ctx context.Context, db *sql.DB, userID uuid.UUID) (*contracts.User, error)
 	FindByEmail(ctx context.Context, db *sql.DB, email string) (*contracts.User, error)
+	UpdateStatus(ctx context.Context, tx *sql.Tx, userID uuid.UUID, status string, reason *string) (*contracts.User, error)
+	ScheduleDeletion(ctx context.Context, tx *sql.Tx, userID uuid.UUID, reason string, at time.Time) (*contracts.User, error)
+	DeleteScheduled(ctx context.Context, tx *sql.Tx, now time.Time) ([]uuid.UUID, error)
+	List(ctx context.Context, db *sql.DB, filter contracts.UserFilter, after *contracts.UserCursor, limit int) ([]*contracts.User, error)
+	Count(ctx context.Context, db *sql.DB, filter contracts.UserFilter) (int64, error)
+	Update(ctx context.Context, tx *sql.Tx, userID uuid.UUID, username, email string) (*contracts.User, error)
+	UpdateRole(ctx context.Context, tx *sql.Tx, userID uuid.UUID, role string) (*contracts.User, error)
+	Delete(ctx context.Context, tx *sql.Tx, userID uuid.UUID) (bool, error)
 }
 
+// userColumns lists the columns scanUser expects, in order.
+const userColumns = `id, username, email, created_at, updated_at, "role", status, status_reason, status_changed_at, deletion_scheduled_at`
+
 // postgresUserRepository implements UserRepository using PostgreSQL.
 type postgresUserRepository struct {
 }
@@ -28,21 +43,28 @@ func NewUserRepository() UserRepository {
 
 // Create inserts a new user and returns the generated ID.
 func (r *postgresUserRepository) Create(ctx context.Context, tx *sql.Tx, username, email, role string) (*contracts.User, error) {
-	user := &contracts.User{}
-	err := tx.QueryRowContext(ctx,
-		`INSERT INTO users (username, email, role) VALUES ($1, $2, $3) RETURNING id, username, email, created_at, updated_at, "role"`,
+	return scanUser(tx.QueryRowContext(ctx,
+		`INSERT INTO users (username, email, role) VALUES ($1, $2, $3) RETURNING `+userColumns,
 		username, email, role,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Role)
-	return user, err
+	))
+}
+
+// uniqueViolation is the SQLSTATE Postgres reports when a write breaks a unique constraint.
+const uniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err is a unique constraint violation, such as Create or Update
+// hitting a username or email another user holds.
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
 }
 
 // FindByUsername retrieves a user by username.
 func (r *postgresUserRepository) FindByUsername(ctx context.Context, db *sql.DB, username string) (*contracts.User, error) {
-	user := &contracts.User{}
-	err := db.QueryRowContext(ctx,
-		`SELECT id, username, email, created_at, updated_at, role FROM users WHERE username = $1`,
+	user, err := scanUser(db.QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE username = $1`,
 		username,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Role)
+	))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -55,11 +77,10 @@ func (r *postgresUserRepository) FindByUsername(ctx context.Context, db *sql.DB,
 
 // FindByID retrieves a user by ID.
 func (r *postgresUserRepository) FindByID(ctx context.Context, db *sql.DB, userID uuid.UUID) (*contracts.User, error) {
-	user := &contracts.User{}
-	err := db.QueryRowContext(ctx,
-		`SELECT id, username, email, created_at, updated_at, role FROM users WHERE id = $1`,
+	user, err := scanUser(db.QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE id = $1`,
 		userID,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Role)
+	))
 
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
@@ -72,11 +93,121 @@ func (r *postgresUserRepository) FindByID(ctx context.Context, db *sql.DB, userI
 
 // FindByEmail retrieves a user by email
 func (r *postgresUserRepository) FindByEmail(ctx context.Context, db *sql.DB, email string) (*contracts.User, error) {
-	user := &contracts.User{}
-	err := db.QueryRowContext(ctx,
-		`SELECT id, username, email, created_at, updated_at, role FROM users WHERE email = $1`,
+	user, err := scanUser(db.QueryRowContext(ctx,
+		`SELECT `+userColumns+` FROM users WHERE email = $1`,
 		email,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Role)
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateStatus sets a user's status and reason, stamping the time of the change. Any scheduled
+// deletion is cancelled.
+func (r *postgresUserRepository) UpdateStatus(ctx context.Context, tx *sql.Tx, userID uuid.UUID, status string, reason *string) (*contracts.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET status = $2, status_reason = $3, status_changed_at = NOW(), deletion_scheduled_at = NULL
+		WHERE id = $1
+		RETURNING `+userColumns,
+		userID, status, reason,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ScheduleDeletion marks a user pending_deletion, to be purged at the given time.
+func (r *postgresUserRepository) ScheduleDeletion(ctx context.Context, tx *sql.Tx, userID uuid.UUID, reason string, at time.Time) (*contracts.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET status = 'pending_deletion', status_reason = $2, status_changed_at = NOW(), deletion_scheduled_at = $3
+		WHERE id = $1
+		RETURNING `+userColumns,
+		userID, reason, at,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteScheduled hard-deletes users whose scheduled deletion time has passed and returns their IDs;
+// credentials and refresh tokens go with them through ON DELETE CASCADE.
+func (r *postgresUserRepository) DeleteScheduled(ctx context.Context, tx *sql.Tx, now time.Time) ([]uuid.UUID, error) {
+	rows, err := tx.QueryContext(ctx,
+		`DELETE FROM users WHERE status = 'pending_deletion' AND deletion_scheduled_at <= $1 RETURNING id`,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
+
+// List returns up to limit users matching filter, newest first, starting after the given cursor.
+func (r *postgresUserRepository) List(ctx context.Context, db *sql.DB, filter contracts.UserFilter, after *contracts.UserCursor, limit int) ([]*contracts.User, error) {
+	where, args := userFilterClause(filter)
+	if after != nil {
+		args = append(args, after.CreatedAt, after.ID)
+		where = append(where, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+userColumns+` FROM users`+whereSQL(where)+
+			fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*contracts.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// Count returns the number of users matching filter.
+func (r *postgresUserRepository) Count(ctx context.Context, db *sql.DB, filter contracts.UserFilter) (int64, error) {
+	where, args := userFilterClause(filter)
+	var count int64
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`+whereSQL(where), args...).Scan(&count)
+	return count, err
+}
+
+// Update changes a user's username and email.
+func (r *postgresUserRepository) Update(ctx context.Context, tx *sql.Tx, userID uuid.UUID, username, email string) (*contracts.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET username = $2, email = $3 WHERE id = $1 RETURNING `+userColumns,
+		userID, username, email,
+	))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
@@ -85,3 +216,76 @@ func (r *postgresUserRepository) FindByEmail(ctx context.Context, db *sql.DB, em
 	}
 	return user, nil
 }
+
+// UpdateRole changes a user's role.
+func (r *postgresUserRepository) UpdateRole(ctx context.Context, tx *sql.Tx, userID uuid.UUID, role string) (*contracts.User, error) {
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET "role" = $2 WHERE id = $1 RETURNING `+userColumns,
+		userID, role,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Delete hard-deletes a user, reporting whether it existed.
+func (r *postgresUserRepository) Delete(ctx context.Context, tx *sql.Tx, userID uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// userFilterClause builds the WHERE conditions and positional arguments for filter.
+func userFilterClause(filter contracts.UserFilter) ([]string, []any) {
+	var where []string
+	var args []any
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		where = append(where, fmt.Sprintf(`"role" = $%d`, len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.CreatedAfter != nil {
+		args = append(args, *filter.CreatedAfter)
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.CreatedBefore != nil {
+		args = append(args, *filter.CreatedBefore)
+		where = append(where, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if filter.Search != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Search)+"%")
+		where = append(where, fmt.Sprintf("(username ILIKE $%d OR email ILIKE $%d)", len(args), len(args)))
+	}
+	return where, args
+}
+
+// likeEscaper escapes LIKE wildcards so search terms match literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func whereSQL(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// scanUser scans a row of userColumns.
+func scanUser(row interface{ Scan(dest ...any) error }) (*contracts.User, error) {
+	user := &contracts.User{}
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.Role,
+		&user.Status, &user.StatusReason, &user.StatusChangedAt, &user.DeletionScheduledAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
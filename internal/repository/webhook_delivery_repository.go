@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type WebhookDeliveryRepository interface {
+	// Enqueue schedules an event for delivery to a subscription. Enqueueing the same event twice is a no-op.
+	Enqueue(ctx context.Context, db Execer, subscriptionID, eventID uuid.UUID, eventType string, payload []byte) error
+
+	// ClaimDue claims up to limit deliveries that are due, oldest first, by pushing their next attempt
+	// back to claimedUntil so no other dispatcher picks them up meanwhile. Deliveries to inactive
+	// subscriptions are skipped.
+	ClaimDue(ctx context.Context, db *sql.DB, limit int, claimedUntil time.Time) ([]*contracts.WebhookDelivery, error)
+
+	// Delete removes a delivery once it has been delivered.
+	Delete(ctx context.Context, db Execer, id int64) error
+
+	// MarkFailed records a failed attempt and when to try again. status is nil when no response was received.
+	MarkFailed(ctx context.Context, db Execer, id int64, status *int, lastError string, nextAttemptAt time.Time) error
+
+	// MoveToDeadLetter records a final failed attempt and moves the delivery to the dead-letter table.
+	MoveToDeadLetter(ctx context.Context, db Execer, id int64, status *int, lastError string) error
+
+	// ListDeadLetters returns up to limit dead letters, newest first, with IDs below beforeID when it is
+	// non-zero, optionally for a single subscription.
+	ListDeadLetters(ctx context.Context, db *sql.DB, subscriptionID *uuid.UUID, beforeID int64, limit int) ([]*contracts.WebhookDeadLetter, error)
+
+	// Replay moves a dead letter back to the delivery queue with its attempts reset, reporting whether it
+	// was moved. A dead letter whose event is already queued for its subscription is left in place.
+	Replay(ctx context.Context, tx *sql.Tx, id int64) (bool, error)
+
+	// DeadLetterExists reports whether a dead letter exists.
+	DeadLetterExists(ctx context.Context, tx *sql.Tx, id int64) (bool, error)
+}
+
+type webhookDeliveryRepository struct {
+}
+
+func NewWebhookDeliveryRepository() WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{}
+}
+
+// Enqueue schedules an event for delivery to a subscription. Enqueueing the same event twice is a no-op.
+func (r *webhookDeliveryRepository) Enqueue(ctx context.Context, db Execer, subscriptionID, eventID uuid.UUID, eventType string, payload []byte) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		subscriptionID, eventID, eventType, payload,
+	)
+	return err
+}
+
+// ClaimDue claims up to limit deliveries that are due, oldest first, by pushing their next attempt
+// back to claimedUntil so no other dispatcher picks them up meanwhile. Deliveries to inactive
+// subscriptions and rows being claimed by another dispatcher are skipped. The claim commits on its
+// own; no lock is held while deliveries are sent.
+func (r *webhookDeliveryRepository) ClaimDue(ctx context.Context, db *sql.DB, limit int, claimedUntil time.Time) ([]*contracts.WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT d.id
+			FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE s.active AND d.next_attempt_at <= NOW()
+			ORDER BY d.next_attempt_at, d.id
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = $2
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING d.id, d.subscription_id, s.url, s.secret, d.event_id, d.event_type, d.payload, d.attempts
+	`
+	rows, err := db.QueryContext(ctx, query, limit, claimedUntil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*contracts.WebhookDelivery
+	for rows.Next() {
+		var d contracts.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.URL, &d.Secret, &d.EventID, &d.EventType, &d.Payload, &d.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// UPDATE ... RETURNING doesn't keep the CTE's order
+	slices.SortFunc(deliveries, func(a, b *contracts.WebhookDelivery) int { return cmp.Compare(a.ID, b.ID) })
+	return deliveries, nil
+}
+
+// Delete removes a delivery once it has been delivered.
+func (r *webhookDeliveryRepository) Delete(ctx context.Context, db Execer, id int64) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE id = $1`, id)
+	return err
+}
+
+// MarkFailed records a failed attempt and when to try again. status is nil when no response was received.
+func (r *webhookDeliveryRepository) MarkFailed(ctx context.Context, db Execer, id int64, status *int, lastError string, nextAttemptAt time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET attempts = attempts + 1, last_status = $2, last_error = $3, next_attempt_at = $4
+		WHERE id = $1`,
+		id, status, lastError, nextAttemptAt,
+	)
+	return err
+}
+
+// MoveToDeadLetter records a final failed attempt and moves the delivery to the dead-letter table.
+func (r *webhookDeliveryRepository) MoveToDeadLetter(ctx context.Context, db Execer, id int64, status *int, lastError string) error {
+	_, err := db.ExecContext(ctx,
+		`WITH moved AS (
+			DELETE FROM webhook_deliveries WHERE id = $1
+			RETURNING subscription_id, event_id, event_type, payload, attempts, created_at
+		)
+		INSERT INTO webhook_dead_letters (subscription_id, event_id, event_type, payload, attempts, last_status, last_error, created_at)
+		SELECT subscription_id, event_id, event_type, payload, attempts + 1, $2, $3, created_at FROM moved`,
+		id, status, lastError,
+	)
+	return err
+}
+
+// ListDeadLetters returns up to limit dead letters, newest first, with IDs below beforeID when it is
+// non-zero, optionally for a single subscription.
+func (r *webhookDeliveryRepository) ListDeadLetters(ctx context.Context, db *sql.DB, subscriptionID *uuid.UUID, beforeID int64, limit int) ([]*contracts.WebhookDeadLetter, error) {
+	var where []string
+	var args []any
+	if subscriptionID != nil {
+		args = append(args, *subscriptionID)
+		where = append(where, fmt.Sprintf("subscription_id = $%d", len(args)))
+	}
+	if beforeID > 0 {
+		args = append(args, beforeID)
+		where = append(where, fmt.Sprintf("id < $%d", len(args)))
+	}
+	args = append(args, limit)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, subscription_id, event_id, event_type, payload, attempts, last_status, last_error, created_at, failed_at
+		FROM webhook_dead_letters`+whereSQL(where)+fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []*contracts.WebhookDeadLetter
+	for rows.Next() {
+		var l contracts.WebhookDeadLetter
+		if err := rows.Scan(&l.ID, &l.SubscriptionID, &l.EventID, &l.EventType, &l.Payload, &l.Attempts,
+			&l.LastStatus, &l.LastError, &l.CreatedAt, &l.FailedAt); err != nil {
+			return nil, err
+		}
+		letters = append(letters, &l)
+	}
+	return letters, rows.Err()
+}
+
+// Replay moves a dead letter back to the delivery queue with its attempts reset, reporting whether it
+// was moved. A dead letter whose event is already queued for its subscription is left in place.
+func (r *webhookDeliveryRepository) Replay(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	res, err := tx.ExecContext(ctx,
+		`WITH replayed AS (
+			DELETE FROM webhook_dead_letters l
+			WHERE l.id = $1 AND NOT EXISTS (
+				SELECT 1 FROM webhook_deliveries d WHERE d.subscription_id = l.subscription_id AND d.event_id = l.event_id
+			)
+			RETURNING subscription_id, event_id, event_type, payload
+		)
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT subscription_id, event_id, event_type, payload FROM replayed
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeadLetterExists reports whether a dead letter exists.
+func (r *webhookDeliveryRepository) DeadLetterExists(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_dead_letters WHERE id = $1)`, id).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+type WebhookSubscriptionRepository interface {
+	// Create stores a new subscription.
+	Create(ctx context.Context, tx *sql.Tx, url, secret string, eventTypes []string, description string) (*contracts.WebhookSubscription, error)
+
+	// FindByID retrieves a subscription, or nil if it doesn't exist.
+	FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.WebhookSubscription, error)
+
+	// List retrieves every subscription, oldest first.
+	List(ctx context.Context, db *sql.DB) ([]*contracts.WebhookSubscription, error)
+
+	// ListActiveForEvent retrieves the active subscriptions that receive the given event type.
+	ListActiveForEvent(ctx context.Context, db *sql.DB, eventType string) ([]*contracts.WebhookSubscription, error)
+
+	// Update changes a subscription's settings, returning nil if it doesn't exist.
+	Update(ctx context.Context, tx *sql.Tx, id uuid.UUID, url string, eventTypes []string, description string, active bool) (*contracts.WebhookSubscription, error)
+
+	// UpdateSecret replaces a subscription's signing secret, returning nil if it doesn't exist.
+	UpdateSecret(ctx context.Context, tx *sql.Tx, id uuid.UUID, secret string) (*contracts.WebhookSubscription, error)
+
+	// Delete removes a subscription with its pending deliveries and dead letters, reporting whether it existed.
+	Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error)
+}
+
+// webhookSubscriptionColumns lists the columns scanWebhookSubscription expects, in order.
+const webhookSubscriptionColumns = `id, url, secret, event_types, description, active, created_at, updated_at`
+
+type webhookSubscriptionRepository struct {
+}
+
+func NewWebhookSubscriptionRepository() WebhookSubscriptionRepository {
+	return &webhookSubscriptionRepository{}
+}
+
+// Create stores a new subscription.
+func (r *webhookSubscriptionRepository) Create(ctx context.Context, tx *sql.Tx, url, secret string, eventTypes []string, description string) (*contracts.WebhookSubscription, error) {
+	types, err := encodeEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookSubscription(tx.QueryRowContext(ctx,
+		`INSERT INTO webhook_subscriptions (url, secret, event_types, description)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+webhookSubscriptionColumns,
+		url, secret, types, description,
+	))
+}
+
+// FindByID retrieves a subscription, or nil if it doesn't exist.
+func (r *webhookSubscriptionRepository) FindByID(ctx context.Context, db *sql.DB, id uuid.UUID) (*contracts.WebhookSubscription, error) {
+	return scanWebhookSubscription(db.QueryRowContext(ctx,
+		`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`,
+		id,
+	))
+}
+
+// List retrieves every subscription, oldest first.
+func (r *webhookSubscriptionRepository) List(ctx context.Context, db *sql.DB) ([]*contracts.WebhookSubscription, error) {
+	return queryWebhookSubscriptions(ctx, db,
+		`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions ORDER BY created_at, id`,
+	)
+}
+
+// ListActiveForEvent retrieves the active subscriptions that receive the given event type.
+func (r *webhookSubscriptionRepository) ListActiveForEvent(ctx context.Context, db *sql.DB, eventType string) ([]*contracts.WebhookSubscription, error) {
+	return queryWebhookSubscriptions(ctx, db,
+		`SELECT `+webhookSubscriptionColumns+`
+		FROM webhook_subscriptions
+		WHERE active AND (event_types = '[]'::jsonb OR event_types ? $1)`,
+		eventType,
+	)
+}
+
+// Update changes a subscription's settings, returning nil if it doesn't exist.
+func (r *webhookSubscriptionRepository) Update(ctx context.Context, tx *sql.Tx, id uuid.UUID, url string, eventTypes []string, description string, active bool) (*contracts.WebhookSubscription, error) {
+	types, err := encodeEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+	return scanWebhookSubscription(tx.QueryRowContext(ctx,
+		`UPDATE webhook_subscriptions
+		SET url = $2, event_types = $3, description = $4, active = $5, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+webhookSubscriptionColumns,
+		id, url, types, description, active,
+	))
+}
+
+// UpdateSecret replaces a subscription's signing secret, returning nil if it doesn't exist.
+func (r *webhookSubscriptionRepository) UpdateSecret(ctx context.Context, tx *sql.Tx, id uuid.UUID, secret string) (*contracts.WebhookSubscription, error) {
+	return scanWebhookSubscription(tx.QueryRowContext(ctx,
+		`UPDATE webhook_subscriptions SET secret = $2, updated_at = NOW() WHERE id = $1 RETURNING `+webhookSubscriptionColumns,
+		id, secret,
+	))
+}
+
+// Delete removes a subscription with its pending deliveries and dead letters, reporting whether it existed.
+func (r *webhookSubscriptionRepository) Delete(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func queryWebhookSubscriptions(ctx context.Context, db *sql.DB, query string, args ...any) ([]*contracts.WebhookSubscription, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*contracts.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// scanWebhookSubscription scans a row of webhookSubscriptionColumns, returning nil if there is none.
+func scanWebhookSubscription(row interface{ Scan(dest ...any) error }) (*contracts.WebhookSubscription, error) {
+	var sub contracts.WebhookSubscription
+	var types []byte
+	err := row.Scan(&sub.ID, &sub.URL, &sub.Secret, &types, &sub.Description, &sub.Active, &sub.CreatedAt, &sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(types, &sub.EventTypes); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// encodeEventTypes encodes an event type filter as a JSON array, never null.
+func encodeEventTypes(eventTypes []string) ([]byte, error) {
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	return json.Marshal(eventTypes)
+}
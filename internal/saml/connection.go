@@ -0,0 +1,271 @@
+// Package saml signs users in through enterprise SAML 2.0 identity providers, acting as a service
+// provider with the HTTP-Redirect binding for requests and the HTTP-POST binding for responses.
+package saml
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	crewsaml "github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// nameIDFormats maps configured NameID formats to their SAML URNs.
+var nameIDFormats = map[string]crewsaml.NameIDFormat{
+	config.SAMLNameIDPersistent:  crewsaml.PersistentNameIDFormat,
+	config.SAMLNameIDEmail:       crewsaml.EmailAddressNameIDFormat,
+	config.SAMLNameIDUnspecified: crewsaml.UnspecifiedNameIDFormat,
+}
+
+// Connection talks to one identity provider. Metadata configured by URL is fetched on first use
+// rather than at startup, so an unreachable IdP doesn't stop the service; failed fetches are retried
+// on the next login.
+type Connection struct {
+	cfg         config.SAMLConnectionConfig
+	sp          crewsaml.ServiceProvider
+	metadataURL *url.URL
+	client      *http.Client
+
+	mu  sync.Mutex
+	idp *crewsaml.EntityDescriptor
+}
+
+// NewConnection creates a connection for cfg. The service provider is published under baseURL, and
+// signs its requests with key, whose certificate is cert. IdP metadata configured as a file is read
+// immediately; requests fetching it by URL give up after timeout.
+func NewConnection(cfg config.SAMLConnectionConfig, baseURL string, key *rsa.PrivateKey, cert *x509.Certificate, timeout time.Duration) (*Connection, error) {
+	root, err := url.Parse(baseURL + "/auth/saml/" + cfg.Name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAML base URL: %w", err)
+	}
+	metadataURL := *root.JoinPath("metadata")
+	acsURL := *root.JoinPath("acs")
+
+	c := &Connection{
+		cfg: cfg,
+		sp: crewsaml.ServiceProvider{
+			EntityID:          metadataURL.String(),
+			Key:               key,
+			Certificate:       cert,
+			MetadataURL:       metadataURL,
+			AcsURL:            acsURL,
+			AuthnNameIDFormat: nameIDFormats[cfg.NameIDFormat],
+			SignatureMethod:   dsig.RSASHA256SignatureMethod,
+		},
+		client: &http.Client{Timeout: timeout},
+	}
+
+	if cfg.IDPMetadataFile != "" {
+		data, err := os.ReadFile(cfg.IDPMetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("read %s IdP metadata: %w", cfg.Name, err)
+		}
+		idp, err := samlsp.ParseMetadata(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s IdP metadata: %w", cfg.Name, err)
+		}
+		c.idp = idp
+	} else {
+		c.metadataURL, err = url.Parse(cfg.IDPMetadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s IdP metadata URL: %w", cfg.Name, err)
+		}
+	}
+	return c, nil
+}
+
+// LoadKeyPair reads the service provider's PEM certificate and RSA private key.
+func LoadKeyPair(certFile, keyFile string) (*rsa.PrivateKey, *x509.Certificate, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errors.New("SAML service provider key must be an RSA key")
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// Metadata returns the service provider's metadata document for the IdP administrator to import.
+func (c *Connection) Metadata() ([]byte, error) {
+	md := c.sp.Metadata()
+	// Responses are only accepted through the HTTP-POST binding; artifact resolution isn't supported
+	for i := range md.SPSSODescriptors {
+		var acs []crewsaml.IndexedEndpoint
+		for _, endpoint := range md.SPSSODescriptors[i].AssertionConsumerServices {
+			if endpoint.Binding == crewsaml.HTTPPostBinding {
+				acs = append(acs, endpoint)
+			}
+		}
+		md.SPSSODescriptors[i].AssertionConsumerServices = acs
+	}
+
+	data, err := xml.MarshalIndent(md, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// AuthnRequestURL returns the IdP URL carrying a signed AuthnRequest and relayState, and the
+// request's ID, which the response must answer.
+func (c *Connection) AuthnRequestURL(ctx context.Context, relayState string) (string, string, error) {
+	sp, err := c.serviceProvider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	location := sp.GetSSOBindingLocation(crewsaml.HTTPRedirectBinding)
+	if location == "" {
+		return "", "", fmt.Errorf("%s IdP has no HTTP-Redirect single sign-on endpoint", c.cfg.Name)
+	}
+
+	req, err := sp.MakeAuthenticationRequest(location, crewsaml.HTTPRedirectBinding, crewsaml.HTTPPostBinding)
+	if err != nil {
+		return "", "", fmt.Errorf("create %s AuthnRequest: %w", c.cfg.Name, err)
+	}
+	redirectURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", "", fmt.Errorf("sign %s AuthnRequest: %w", c.cfg.Name, err)
+	}
+	return redirectURL.String(), req.ID, nil
+}
+
+// ParseResponse validates a base64-encoded SAMLResponse answering requestID, and returns the identity
+// it asserts. The response or its assertion must be signed by the IdP, be addressed to this service
+// provider and be within its validity window.
+func (c *Connection) ParseResponse(ctx context.Context, samlResponse, requestID string) (*contracts.SAMLAssertion, error) {
+	sp, err := c.serviceProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s response: %w", c.cfg.Name, err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(raw, []string{requestID})
+	if err != nil {
+		// The library hides the reason behind a generic message; keep it for the logs
+		var invalid *crewsaml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			err = invalid.PrivateErr
+		}
+		return nil, fmt.Errorf("validate %s response: %w", c.cfg.Name, err)
+	}
+	return c.mapAssertion(assertion)
+}
+
+// mapAssertion maps the assertion's subject and attributes as configured for the connection.
+func (c *Connection) mapAssertion(assertion *crewsaml.Assertion) (*contracts.SAMLAssertion, error) {
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || strings.TrimSpace(assertion.Subject.NameID.Value) == "" {
+		return nil, fmt.Errorf("%s assertion has no NameID", c.cfg.Name)
+	}
+	nameID := assertion.Subject.NameID
+	if nameID.Format == string(crewsaml.TransientNameIDFormat) {
+		return nil, fmt.Errorf("%s asserted a transient NameID, which can't identify returning users", c.cfg.Name)
+	}
+
+	attributes := make(map[string][]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			for _, v := range attr.Values {
+				attributes[attr.Name] = append(attributes[attr.Name], v.Value)
+				if attr.FriendlyName != "" {
+					attributes[attr.FriendlyName] = append(attributes[attr.FriendlyName], v.Value)
+				}
+			}
+		}
+	}
+
+	result := &contracts.SAMLAssertion{
+		Connection:   c.cfg.Name,
+		NameID:       nameID.Value,
+		Email:        first(attributes[c.cfg.EmailAttribute]),
+		EmailTrusted: c.cfg.TrustEmail,
+		Role:         c.cfg.DefaultRole,
+		RoleManaged:  c.cfg.RoleAttribute != "",
+	}
+	if result.Email == "" && nameID.Format == string(crewsaml.EmailAddressNameIDFormat) {
+		result.Email = nameID.Value
+	}
+	result.EmailAllowed = c.emailAllowed(result.Email)
+	if c.cfg.UsernameAttribute != "" {
+		result.Username = first(attributes[c.cfg.UsernameAttribute])
+	}
+	if result.RoleManaged {
+		values := attributes[c.cfg.RoleAttribute]
+	mappings:
+		for _, mapping := range c.cfg.RoleMap {
+			for _, v := range values {
+				if v == mapping.Value {
+					result.Role = mapping.Role
+					break mappings
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// emailAllowed reports whether email is in one of the connection's email domains, matched exactly,
+// or the connection restricts none.
+func (c *Connection) emailAllowed(email string) bool {
+	if len(c.cfg.EmailDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	return slices.Contains(c.cfg.EmailDomains, strings.ToLower(email[at+1:]))
+}
+
+// serviceProvider returns the service provider configured with the IdP's metadata, fetching it if it
+// hasn't been fetched yet.
+func (c *Connection) serviceProvider(ctx context.Context) (*crewsaml.ServiceProvider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.idp == nil {
+		idp, err := samlsp.FetchMetadata(ctx, c.client, *c.metadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("fetch %s IdP metadata: %w", c.cfg.Name, err)
+		}
+		c.idp = idp
+	}
+
+	sp := c.sp
+	sp.IDPMetadata = c.idp
+	return &sp, nil
+}
+
+// first returns the first non-blank value, or "".
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,165 @@
+package saml
+
+import (
+	"testing"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	crewsaml "github.com/crewjam/saml"
+)
+
+// testAssertion returns an assertion for nameID in format, with the given attributes.
+func testAssertion(nameID string, format crewsaml.NameIDFormat, attributes ...crewsaml.Attribute) *crewsaml.Assertion {
+	return &crewsaml.Assertion{
+		Subject:             &crewsaml.Subject{NameID: &crewsaml.NameID{Value: nameID, Format: string(format)}},
+		AttributeStatements: []crewsaml.AttributeStatement{{Attributes: attributes}},
+	}
+}
+
+// attribute returns an attribute with a name, an optional friendly name and values.
+func attribute(name, friendlyName string, values ...string) crewsaml.Attribute {
+	attr := crewsaml.Attribute{Name: name, FriendlyName: friendlyName}
+	for _, v := range values {
+		attr.Values = append(attr.Values, crewsaml.AttributeValue{Value: v})
+	}
+	return attr
+}
+
+func TestMapAssertion(t *testing.T) {
+	acme := config.SAMLConnectionConfig{
+		Name:              "acme",
+		EmailAttribute:    "email",
+		UsernameAttribute: "uid",
+		RoleAttribute:     "groups",
+		RoleMap: []config.SAMLRoleMapping{
+			{Value: "Bid Managers", Role: "manager"},
+			{Value: "Bidders", Role: "bidder"},
+		},
+		DefaultRole:  "user",
+		TrustEmail:   true,
+		EmailDomains: []string{"acme.example"},
+	}
+	unmapped := config.SAMLConnectionConfig{Name: "plain", EmailAttribute: "email", DefaultRole: "user"}
+
+	tests := []struct {
+		name      string
+		cfg       config.SAMLConnectionConfig
+		assertion *crewsaml.Assertion
+		wantErr   bool
+		want      mappedFields
+	}{
+		{
+			name: "attributes mapped",
+			cfg:  acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat,
+				attribute("email", "", " ada@acme.example "),
+				attribute("uid", "", "ada"),
+				attribute("groups", "", "Everyone", "Bidders"),
+			),
+			want: mappedFields{email: "ada@acme.example", username: "ada", role: "bidder", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name: "first listed mapping wins",
+			cfg:  acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat,
+				attribute("email", "", "ada@acme.example"),
+				attribute("groups", "", "Bidders", "Bid Managers"),
+			),
+			want: mappedFields{email: "ada@acme.example", role: "manager", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name:      "default role when nothing maps",
+			cfg:       acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("email", "", "ada@acme.example"), attribute("groups", "", "Everyone")),
+			want:      mappedFields{email: "ada@acme.example", role: "user", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name:      "attribute matched by friendly name",
+			cfg:       acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("urn:oid:0.9.2342.19200300.100.1.3", "email", "ada@acme.example")),
+			want:      mappedFields{email: "ada@acme.example", role: "user", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name:      "email from an emailAddress NameID",
+			cfg:       acme,
+			assertion: testAssertion("ada@acme.example", crewsaml.EmailAddressNameIDFormat),
+			want:      mappedFields{email: "ada@acme.example", role: "user", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name:      "email domain matched case-insensitively",
+			cfg:       acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("email", "", "Ada@ACME.example")),
+			want:      mappedFields{email: "Ada@ACME.example", role: "user", roleManaged: true, trusted: true, allowed: true},
+		},
+		{
+			name:      "email outside the domains",
+			cfg:       acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("email", "", "ada@globex.example")),
+			want:      mappedFields{email: "ada@globex.example", role: "user", roleManaged: true, trusted: true},
+		},
+		{
+			name:      "subdomains aren't in the domain",
+			cfg:       acme,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("email", "", "ada@evil.acme.example")),
+			want:      mappedFields{email: "ada@evil.acme.example", role: "user", roleManaged: true, trusted: true},
+		},
+		{
+			name:      "any email without domains",
+			cfg:       unmapped,
+			assertion: testAssertion("u-1", crewsaml.PersistentNameIDFormat, attribute("email", "", "ada@globex.example"), attribute("groups", "", "Bid Managers")),
+			want:      mappedFields{email: "ada@globex.example", role: "user", allowed: true},
+		},
+		{
+			name:      "no NameID",
+			cfg:       acme,
+			assertion: &crewsaml.Assertion{Subject: &crewsaml.Subject{}},
+			wantErr:   true,
+		},
+		{
+			name:      "blank NameID",
+			cfg:       acme,
+			assertion: testAssertion("  ", crewsaml.PersistentNameIDFormat),
+			wantErr:   true,
+		},
+		{
+			name:      "transient NameID",
+			cfg:       acme,
+			assertion: testAssertion("_3f7b", crewsaml.TransientNameIDFormat, attribute("email", "", "ada@acme.example")),
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connection{cfg: tt.cfg}
+			got, err := c.mapAssertion(tt.assertion)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("mapAssertion() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mapAssertion() error = %v", err)
+			}
+			if got.Connection != tt.cfg.Name || got.NameID != tt.assertion.Subject.NameID.Value {
+				t.Errorf("identity = %s/%s", got.Connection, got.NameID)
+			}
+			mapped := mappedFields{
+				email:       got.Email,
+				username:    got.Username,
+				role:        got.Role,
+				roleManaged: got.RoleManaged,
+				trusted:     got.EmailTrusted,
+				allowed:     got.EmailAllowed,
+			}
+			if mapped != tt.want {
+				t.Errorf("mapped %+v, want %+v", mapped, tt.want)
+			}
+		})
+	}
+}
+
+// mappedFields are the mapped fields of a contracts.SAMLAssertion compared by TestMapAssertion.
+type mappedFields struct {
+	email, username, role         string
+	roleManaged, trusted, allowed bool
+}
@@ -0,0 +1,188 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ServiceProviderConfig describes the SCIM features this service supports. Users are listed at most
+// maxResults at a time.
+func ServiceProviderConfig(baseURL string, maxResults int) map[string]any {
+	return map[string]any{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]any{"supported": true},
+		"bulk":             map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]any{"supported": true, "maxResults": maxResults},
+		"changePassword":   map[string]any{"supported": false},
+		"sort":             map[string]any{"supported": false},
+		"etag":             map[string]any{"supported": false},
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "A SCIM token issued to the tenant, sent as an Authorization: Bearer header",
+			"primary":     true,
+		}},
+		"meta": map[string]any{
+			"resourceType": "ServiceProviderConfig",
+			"location":     baseURL + "/ServiceProviderConfig",
+		},
+	}
+}
+
+// UserResourceType describes the User resource type.
+func UserResourceType(baseURL string) map[string]any {
+	return map[string]any{
+		"schemas":     []string{SchemaResourceType},
+		"id":          "User",
+		"name":        "User",
+		"endpoint":    "/Users",
+		"description": "User account",
+		"schema":      SchemaUser,
+		"meta": map[string]any{
+			"resourceType": "ResourceType",
+			"location":     baseURL + "/ResourceTypes/User",
+		},
+	}
+}
+
+// UserSchema describes the User attributes this service stores.
+func UserSchema(baseURL string) map[string]any {
+	return map[string]any{
+		"schemas":     []string{SchemaSchema},
+		"id":          SchemaUser,
+		"name":        "User",
+		"description": "User account",
+		"attributes": []map[string]any{
+			attribute("userName", typeString, false, true, "readWrite", "default", "server"),
+			withSubAttributes(attribute("name", "complex", false, false, "readWrite", "default", "none"),
+				attribute("formatted", typeString, false, false, "readWrite", "default", "none"),
+				attribute("familyName", typeString, false, false, "readWrite", "default", "none"),
+				attribute("givenName", typeString, false, false, "readWrite", "default", "none"),
+			),
+			attribute("displayName", typeString, false, false, "readWrite", "default", "none"),
+			withSubAttributes(attribute("emails", "complex", true, true, "readWrite", "default", "server"),
+				attribute("value", typeString, false, true, "readWrite", "default", "server"),
+				attribute("type", typeString, false, false, "readWrite", "default", "none"),
+				attribute("primary", typeBoolean, false, false, "readWrite", "default", "none"),
+			),
+			attribute("active", typeBoolean, false, false, "readWrite", "default", "none"),
+			attribute("password", typeString, false, false, "writeOnly", "never", "none"),
+		},
+		"meta": map[string]any{
+			"resourceType": "Schema",
+			"location":     baseURL + "/Schemas/" + SchemaUser,
+		},
+	}
+}
+
+func attribute(name, attrType string, multiValued, required bool, mutability, returned, uniqueness string) map[string]any {
+	return map[string]any{
+		"name":        name,
+		"type":        attrType,
+		"multiValued": multiValued,
+		"required":    required,
+		"caseExact":   false,
+		"mutability":  mutability,
+		"returned":    returned,
+		"uniqueness":  uniqueness,
+	}
+}
+
+func withSubAttributes(attr map[string]any, subAttributes ...map[string]any) map[string]any {
+	attr["subAttributes"] = subAttributes
+	return attr
+}
+
+// Project returns resource with only the requested attributes, or without the excluded ones, as
+// given in the attributes and excludedAttributes query parameters. Both hold comma-separated
+// attribute paths, optionally with a sub-attribute; id and schemas are always returned.
+func Project(resource any, attributes, excludedAttributes string) (any, error) {
+	if attributes == "" && excludedAttributes == "" {
+		return resource, nil
+	}
+	encoded, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return nil, err
+	}
+
+	if attributes != "" {
+		keep := parseAttributeList(attributes)
+		for key, value := range fields {
+			if key == "id" || key == "schemas" {
+				continue
+			}
+			subs, ok := keep[strings.ToLower(key)]
+			if !ok {
+				delete(fields, key)
+				continue
+			}
+			if len(subs) > 0 {
+				filterSubAttributes(value, func(sub string) bool { return subs[sub] })
+			}
+		}
+		return fields, nil
+	}
+
+	for attr, subs := range parseAttributeList(excludedAttributes) {
+		for key, value := range fields {
+			if strings.ToLower(key) != attr || key == "id" || key == "schemas" {
+				continue
+			}
+			if len(subs) == 0 {
+				delete(fields, key)
+				continue
+			}
+			filterSubAttributes(value, func(sub string) bool { return !subs[sub] })
+		}
+	}
+	return fields, nil
+}
+
+// parseAttributeList maps each lowercased attribute in a comma-separated list to the sub-attributes
+// named with it; an attribute named on its own maps to nil, covering all of its sub-attributes.
+func parseAttributeList(list string) map[string]map[string]bool {
+	attrs := make(map[string]map[string]bool)
+	whole := make(map[string]bool)
+	for _, item := range strings.Split(list, ",") {
+		item = normaliseAttr(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		attr, sub, hasSub := strings.Cut(item, ".")
+		if !hasSub {
+			whole[attr] = true
+			attrs[attr] = nil
+			continue
+		}
+		if whole[attr] {
+			continue
+		}
+		if attrs[attr] == nil {
+			attrs[attr] = make(map[string]bool)
+		}
+		attrs[attr][sub] = true
+	}
+	return attrs
+}
+
+// filterSubAttributes keeps the sub-attributes of a complex or multi-valued complex value for
+// which keep returns true.
+func filterSubAttributes(value any, keep func(sub string) bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key := range v {
+			if !keep(strings.ToLower(key)) {
+				delete(v, key)
+			}
+		}
+	case []any:
+		for _, item := range v {
+			filterSubAttributes(item, keep)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProject(t *testing.T) {
+	active := true
+	user := &User{
+		Schemas:     []string{SchemaUser},
+		ID:          "2819c223-7f76-453a-919d-413861904646",
+		UserName:    "bjensen",
+		DisplayName: "Babs",
+		Name:        &Name{GivenName: "Barbara", FamilyName: "Jensen"},
+		Emails:      []Email{{Value: "bjensen@example.com", Type: "work", Primary: true}},
+		Active:      &active,
+		Meta:        &Meta{ResourceType: "User", Location: "https://auth.example.com/scim/v2/Users/2819c223-7f76-453a-919d-413861904646"},
+	}
+
+	tests := []struct {
+		name               string
+		attributes         string
+		excludedAttributes string
+		want               string
+	}{
+		{
+			name: "everything by default",
+			want: `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","userName":"bjensen","name":{"familyName":"Jensen","givenName":"Barbara"},"displayName":"Babs","emails":[{"value":"bjensen@example.com","type":"work","primary":true}],"active":true,"meta":{"resourceType":"User","location":"https://auth.example.com/scim/v2/Users/2819c223-7f76-453a-919d-413861904646"}}`,
+		},
+		{
+			name:       "attributes keep id and schemas",
+			attributes: "userName, ACTIVE",
+			want:       `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","userName":"bjensen","active":true}`,
+		},
+		{
+			name:       "sub-attributes",
+			attributes: "name.givenName,emails.value",
+			want:       `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","name":{"givenName":"Barbara"},"emails":[{"value":"bjensen@example.com"}]}`,
+		},
+		{
+			name:       "whole attribute wins over its sub-attributes",
+			attributes: "name,name.givenName",
+			want:       `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","name":{"familyName":"Jensen","givenName":"Barbara"}}`,
+		},
+		{
+			name:       "schema URN stripped",
+			attributes: "urn:ietf:params:scim:schemas:core:2.0:User:userName",
+			want:       `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","userName":"bjensen"}`,
+		},
+		{
+			name:               "excluded attributes",
+			excludedAttributes: "meta,emails.type,name.familyName,id",
+			want:               `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","userName":"bjensen","name":{"givenName":"Barbara"},"displayName":"Babs","emails":[{"value":"bjensen@example.com","primary":true}],"active":true}`,
+		},
+		{
+			name:               "attributes take precedence over excluded attributes",
+			attributes:         "userName",
+			excludedAttributes: "userName",
+			want:               `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"id":"2819c223-7f76-453a-919d-413861904646","userName":"bjensen"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projected, err := Project(user, tt.attributes, tt.excludedAttributes)
+			if err != nil {
+				t.Fatalf("Project() error = %v", err)
+			}
+			got, err := json.Marshal(projected)
+			if err != nil {
+				t.Fatalf("encode projection: %v", err)
+			}
+			// Compared decoded, since projections are maps whose keys encode sorted
+			if !equalJSON(t, got, []byte(tt.want)) {
+				t.Errorf("Project() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// equalJSON reports whether two JSON documents hold the same value.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb any
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("decode %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("decode %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
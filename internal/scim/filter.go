@@ -0,0 +1,364 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed filter expression: an *AttrExpr, *LogicalExpr or *NotExpr.
+type Filter interface {
+	filter()
+}
+
+// AttrExpr compares an attribute with a value. Attr is the attribute path lowercased, without the
+// User schema URN and fully qualified, so emails[type eq "work"] yields emails.type.
+type AttrExpr struct {
+	Attr  string
+	Op    string // eq, ne, co, sw, ew, gt, ge, lt, le or pr
+	Value any    // string, bool or time.Time; nil for pr
+}
+
+// LogicalExpr joins two filters with and or or.
+type LogicalExpr struct {
+	Op          string
+	Left, Right Filter
+}
+
+// NotExpr negates a filter.
+type NotExpr struct {
+	Filter Filter
+}
+
+func (*AttrExpr) filter()    {}
+func (*LogicalExpr) filter() {}
+func (*NotExpr) filter()     {}
+
+// Attribute types.
+const (
+	typeString   = "string"
+	typeBoolean  = "boolean"
+	typeDateTime = "dateTime"
+)
+
+// filterAttributes lists the User attributes that can be filtered on, with their types. A
+// multi-valued attribute filtered without a sub-attribute compares its value.
+var filterAttributes = map[string]string{
+	"id":                typeString,
+	"externalid":        typeString,
+	"username":          typeString,
+	"displayname":       typeString,
+	"name.formatted":    typeString,
+	"name.givenname":    typeString,
+	"name.familyname":   typeString,
+	"emails.value":      typeString,
+	"emails.type":       typeString,
+	"emails.primary":    typeBoolean,
+	"active":            typeBoolean,
+	"meta.created":      typeDateTime,
+	"meta.lastmodified": typeDateTime,
+}
+
+// multiValuedAttributes lists the multi-valued User attributes that may hold a value filter.
+var multiValuedAttributes = map[string]bool{"emails": true}
+
+var compareOps = map[string]bool{"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "gt": true, "ge": true, "lt": true, "le": true}
+
+// ParseFilter parses a User filter such as `userName eq "bjensen" and emails[type eq "work"]`.
+// Attribute names and operators are case-insensitive.
+func ParseFilter(s string) (Filter, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	f, err := p.parseOr("")
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(ErrorInvalidFilter, "unexpected %q in filter", t.text)
+	}
+	return f, nil
+}
+
+// Path is a parsed PATCH operation path: an attribute, optionally narrowed by a value filter and
+// followed by a sub-attribute, as in emails[type eq "work"].value.
+type Path struct {
+	Attr   string // lowercased, without the User schema URN, e.g. "name.givenname" or "emails"
+	Filter Filter // nil when the path has no value filter
+	Sub    string // lowercased sub-attribute after the value filter, if any
+}
+
+// ParsePath parses a PATCH operation path.
+func ParsePath(s string) (*Path, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, &Error{Type: ErrorInvalidPath, Detail: err.Error()}
+	}
+	p := &parser{tokens: tokens}
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, errorf(ErrorInvalidPath, "invalid path %q", s)
+	}
+	path := &Path{Attr: normaliseAttr(t.text)}
+	if p.peek().kind == tokenLBracket {
+		if !multiValuedAttributes[path.Attr] {
+			return nil, errorf(ErrorInvalidPath, "%s is not a multi-valued attribute", t.text)
+		}
+		p.next()
+		if path.Filter, err = p.parseOr(path.Attr); err != nil {
+			return nil, &Error{Type: ErrorInvalidPath, Detail: err.Error()}
+		}
+		if p.next().kind != tokenRBracket {
+			return nil, errorf(ErrorInvalidPath, "unterminated value filter in %q", s)
+		}
+		if t := p.peek(); t.kind == tokenWord && strings.HasPrefix(t.text, ".") {
+			p.next()
+			path.Sub = strings.ToLower(strings.TrimPrefix(t.text, "."))
+		}
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorf(ErrorInvalidPath, "unexpected %q in path", t.text)
+	}
+	return path, nil
+}
+
+// normaliseAttr lowercases an attribute path and strips the User schema URN.
+func normaliseAttr(attr string) string {
+	attr = strings.ToLower(attr)
+	return strings.TrimPrefix(attr, strings.ToLower(SchemaUser)+":")
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so.
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	if t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// parseOr parses filters joined by or. Attributes are relative to prefix inside a value filter.
+func (p *parser) parseOr(prefix string) (Filter, error) {
+	left, err := p.parseAnd(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseAnd parses filters joined by and, which binds tighter than or.
+func (p *parser) parseAnd(prefix string) (Filter, error) {
+	left, err := p.parseUnary(prefix)
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseUnary(prefix)
+		if err != nil {
+			return nil, err
+		}
+		left = &LogicalExpr{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// parseUnary parses a negation, a parenthesised filter, a value filter or an attribute expression.
+func (p *parser) parseUnary(prefix string) (Filter, error) {
+	if p.peek().kind == tokenWord && strings.EqualFold(p.peek().text, "not") && p.tokens[p.pos+1].kind == tokenLParen {
+		p.next()
+		inner, err := p.parseGroup(prefix)
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{Filter: inner}, nil
+	}
+	if p.peek().kind == tokenLParen {
+		return p.parseGroup(prefix)
+	}
+
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, errorf(ErrorInvalidFilter, "expected an attribute, got %q", t.text)
+	}
+	attr := normaliseAttr(t.text)
+	if prefix != "" {
+		attr = prefix + "." + attr
+	}
+
+	if p.peek().kind == tokenLBracket {
+		if prefix != "" || !multiValuedAttributes[attr] {
+			return nil, errorf(ErrorInvalidFilter, "%s can't hold a value filter", t.text)
+		}
+		p.next()
+		inner, err := p.parseOr(attr)
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenRBracket {
+			return nil, errorf(ErrorInvalidFilter, "unterminated value filter on %s", t.text)
+		}
+		return inner, nil
+	}
+
+	if multiValuedAttributes[attr] {
+		attr += ".value"
+	}
+	attrType, ok := filterAttributes[attr]
+	if !ok {
+		return nil, errorf(ErrorInvalidFilter, "can't filter on %s", t.text)
+	}
+
+	opToken := p.next()
+	op := strings.ToLower(opToken.text)
+	if opToken.kind != tokenWord || (op != "pr" && !compareOps[op]) {
+		return nil, errorf(ErrorInvalidFilter, "expected an operator after %s, got %q", t.text, opToken.text)
+	}
+	if op == "pr" {
+		return &AttrExpr{Attr: attr, Op: op}, nil
+	}
+	value, err := p.parseValue(t.text, attrType, op)
+	if err != nil {
+		return nil, err
+	}
+	return &AttrExpr{Attr: attr, Op: op, Value: value}, nil
+}
+
+// parseGroup parses a parenthesised filter.
+func (p *parser) parseGroup(prefix string) (Filter, error) {
+	if p.next().kind != tokenLParen {
+		return nil, errorf(ErrorInvalidFilter, "expected (")
+	}
+	inner, err := p.parseOr(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if p.next().kind != tokenRParen {
+		return nil, errorf(ErrorInvalidFilter, "missing )")
+	}
+	return inner, nil
+}
+
+// parseValue parses the comparison value for an attribute of attrType.
+func (p *parser) parseValue(attr, attrType, op string) (any, error) {
+	t := p.next()
+	switch attrType {
+	case typeBoolean:
+		if op != "eq" && op != "ne" {
+			return nil, errorf(ErrorInvalidFilter, "%s can only be compared with eq or ne", attr)
+		}
+		if t.kind == tokenWord && strings.EqualFold(t.text, "true") {
+			return true, nil
+		}
+		if t.kind == tokenWord && strings.EqualFold(t.text, "false") {
+			return false, nil
+		}
+		return nil, errorf(ErrorInvalidFilter, "%s must be compared with true or false", attr)
+	case typeDateTime:
+		if op == "co" || op == "sw" || op == "ew" {
+			return nil, errorf(ErrorInvalidFilter, "%s can't be compared with %s", attr, op)
+		}
+		if t.kind == tokenString {
+			if v, err := time.Parse(time.RFC3339Nano, t.text); err == nil {
+				return v, nil
+			}
+		}
+		return nil, errorf(ErrorInvalidFilter, "%s must be compared with a dateTime", attr)
+	default:
+		if t.kind != tokenString {
+			return nil, errorf(ErrorInvalidFilter, "%s must be compared with a string", attr)
+		}
+		return t.text, nil
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string // a word, or a string literal's decoded value
+}
+
+// tokenize splits a filter or path into words, string literals and brackets, ending with tokenEOF.
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		case c == '[':
+			tokens = append(tokens, token{kind: tokenLBracket, text: "["})
+			i++
+		case c == ']':
+			tokens = append(tokens, token{kind: tokenRBracket, text: "]"})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, errorf(ErrorInvalidFilter, "unterminated string in %q", s)
+			}
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, errorf(ErrorInvalidFilter, "invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:end]})
+			i = end
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
@@ -0,0 +1,185 @@
+package scim
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		filter   string
+		want     Filter
+		wantType string // scimType of the error; empty when the filter is valid
+	}{
+		{
+			name:   "string comparison",
+			filter: `userName eq "bjensen"`,
+			want:   &AttrExpr{Attr: "username", Op: "eq", Value: "bjensen"},
+		},
+		{
+			name:   "case-insensitive attribute and operator",
+			filter: `USERNAME Sw "bj"`,
+			want:   &AttrExpr{Attr: "username", Op: "sw", Value: "bj"},
+		},
+		{
+			name:   "schema URN stripped",
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bjensen"`,
+			want:   &AttrExpr{Attr: "username", Op: "eq", Value: "bjensen"},
+		},
+		{
+			name:   "escaped string",
+			filter: `displayName eq "Barbara \"Babs\" Jensen"`,
+			want:   &AttrExpr{Attr: "displayname", Op: "eq", Value: `Barbara "Babs" Jensen`},
+		},
+		{
+			name:   "present",
+			filter: `externalId pr`,
+			want:   &AttrExpr{Attr: "externalid", Op: "pr"},
+		},
+		{
+			name:   "boolean",
+			filter: `active eq False`,
+			want:   &AttrExpr{Attr: "active", Op: "eq", Value: false},
+		},
+		{
+			name:   "dateTime",
+			filter: `meta.created gt "2024-01-02T03:04:05Z"`,
+			want:   &AttrExpr{Attr: "meta.created", Op: "gt", Value: created},
+		},
+		{
+			name:   "multi-valued attribute compares its value",
+			filter: `emails co "@example.com"`,
+			want:   &AttrExpr{Attr: "emails.value", Op: "co", Value: "@example.com"},
+		},
+		{
+			name:   "value filter",
+			filter: `emails[type eq "work" and value ew "@example.com"]`,
+			want: &LogicalExpr{
+				Op:    "and",
+				Left:  &AttrExpr{Attr: "emails.type", Op: "eq", Value: "work"},
+				Right: &AttrExpr{Attr: "emails.value", Op: "ew", Value: "@example.com"},
+			},
+		},
+		{
+			name:   "and binds tighter than or",
+			filter: `userName eq "a" or userName eq "b" and active eq true`,
+			want: &LogicalExpr{
+				Op:   "or",
+				Left: &AttrExpr{Attr: "username", Op: "eq", Value: "a"},
+				Right: &LogicalExpr{
+					Op:    "and",
+					Left:  &AttrExpr{Attr: "username", Op: "eq", Value: "b"},
+					Right: &AttrExpr{Attr: "active", Op: "eq", Value: true},
+				},
+			},
+		},
+		{
+			name:   "grouping and negation",
+			filter: `not (userName eq "a" or userName eq "b")`,
+			want: &NotExpr{Filter: &LogicalExpr{
+				Op:    "or",
+				Left:  &AttrExpr{Attr: "username", Op: "eq", Value: "a"},
+				Right: &AttrExpr{Attr: "username", Op: "eq", Value: "b"},
+			}},
+		},
+		{name: "unknown attribute", filter: `nickName eq "babs"`, wantType: ErrorInvalidFilter},
+		{name: "unknown operator", filter: `userName like "b%"`, wantType: ErrorInvalidFilter},
+		{name: "boolean compared with a string", filter: `active eq "true"`, wantType: ErrorInvalidFilter},
+		{name: "boolean ordered", filter: `active gt false`, wantType: ErrorInvalidFilter},
+		{name: "dateTime with a substring operator", filter: `meta.created co "2024"`, wantType: ErrorInvalidFilter},
+		{name: "invalid dateTime", filter: `meta.created gt "yesterday"`, wantType: ErrorInvalidFilter},
+		{name: "string compared with a word", filter: `userName eq bjensen`, wantType: ErrorInvalidFilter},
+		{name: "unterminated string", filter: `userName eq "bjensen`, wantType: ErrorInvalidFilter},
+		{name: "missing parenthesis", filter: `(userName eq "a"`, wantType: ErrorInvalidFilter},
+		{name: "unterminated value filter", filter: `emails[type eq "work"`, wantType: ErrorInvalidFilter},
+		{name: "value filter on a single-valued attribute", filter: `name[givenName eq "b"]`, wantType: ErrorInvalidFilter},
+		{name: "trailing tokens", filter: `userName eq "a" "b"`, wantType: ErrorInvalidFilter},
+		{name: "empty", filter: ``, wantType: ErrorInvalidFilter},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFilter(tt.filter)
+			if tt.wantType != "" {
+				var scimErr *Error
+				if !errors.As(err, &scimErr) || scimErr.Type != tt.wantType {
+					t.Fatalf("ParseFilter() = %#v, %v; want a %s error", got, err, tt.wantType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFilter() = %s, want %s", describeFilter(got), describeFilter(tt.want))
+			}
+		})
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		want    *Path
+		wantErr bool
+	}{
+		{name: "attribute", path: "displayName", want: &Path{Attr: "displayname"}},
+		{name: "sub-attribute", path: "name.givenName", want: &Path{Attr: "name.givenname"}},
+		{name: "schema URN stripped", path: "urn:ietf:params:scim:schemas:core:2.0:User:active", want: &Path{Attr: "active"}},
+		{
+			name: "value filter and sub-attribute",
+			path: `emails[type eq "work"].value`,
+			want: &Path{Attr: "emails", Filter: &AttrExpr{Attr: "emails.type", Op: "eq", Value: "work"}, Sub: "value"},
+		},
+		{
+			name: "value filter",
+			path: `emails[primary eq true]`,
+			want: &Path{Attr: "emails", Filter: &AttrExpr{Attr: "emails.primary", Op: "eq", Value: true}},
+		},
+		{name: "value filter on a single-valued attribute", path: `name[givenName eq "b"]`, wantErr: true},
+		{name: "unterminated value filter", path: `emails[type eq "work"`, wantErr: true},
+		{name: "trailing tokens", path: `displayName extra`, wantErr: true},
+		{name: "empty", path: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			if tt.wantErr {
+				var scimErr *Error
+				if !errors.As(err, &scimErr) || scimErr.Type != ErrorInvalidPath {
+					t.Fatalf("ParsePath() = %+v, %v; want an %s error", got, err, ErrorInvalidPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePath() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePath() = %+v (filter %s), want %+v (filter %s)", got, describeFilter(got.Filter), tt.want, describeFilter(tt.want.Filter))
+			}
+		})
+	}
+}
+
+// describeFilter renders a filter for failure messages, since its nodes are pointers.
+func describeFilter(f Filter) string {
+	switch f := f.(type) {
+	case *AttrExpr:
+		if f.Op == "pr" {
+			return f.Attr + " pr"
+		}
+		return fmt.Sprintf("%s %s %v", f.Attr, f.Op, f.Value)
+	case *LogicalExpr:
+		return "(" + describeFilter(f.Left) + " " + f.Op + " " + describeFilter(f.Right) + ")"
+	case *NotExpr:
+		return "not (" + describeFilter(f.Filter) + ")"
+	case nil:
+		return "<nil>"
+	}
+	return "?"
+}
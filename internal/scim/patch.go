@@ -0,0 +1,395 @@
+package scim
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// PatchOperation is one operation of a PATCH request.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies the request's operations to u in order. Operations names are case-insensitive, and
+// booleans may be given as the strings "true" and "false", as some clients send them. Enterprise
+// extension attributes are ignored; the password can't be changed.
+func (r *PatchRequest) Apply(u *User) error {
+	if len(r.Operations) == 0 {
+		return errorf(ErrorInvalidSyntax, "no operations given")
+	}
+	for _, op := range r.Operations {
+		if err := applyOperation(u, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOperation(u *User, op PatchOperation) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return errorf(ErrorInvalidSyntax, "unknown operation %q", op.Op)
+	}
+
+	if op.Path == "" {
+		if kind == "remove" {
+			return errorf(ErrorNoTarget, "remove requires a path")
+		}
+		// Without a path the value holds the attributes to add or replace, keyed by path
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &values); err != nil {
+			return errorf(ErrorInvalidValue, "value must be an object when no path is given")
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		// Sorted so an object and its sub-attributes, e.g. name and name.givenName, apply in a fixed order
+		slices.Sort(keys)
+		for _, key := range keys {
+			if strings.EqualFold(key, SchemaEnterpriseUser) {
+				continue
+			}
+			path, err := ParsePath(key)
+			if err != nil {
+				return err
+			}
+			if err := setAttr(u, kind, path, values[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	path, err := ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if kind == "remove" {
+		return removeAttr(u, path)
+	}
+	return setAttr(u, kind, path, op.Value)
+}
+
+// setAttr adds or replaces the attribute at path.
+func setAttr(u *User, kind string, path *Path, value json.RawMessage) error {
+	if strings.HasPrefix(path.Attr, strings.ToLower(SchemaEnterpriseUser)) {
+		return nil
+	}
+	switch path.Attr {
+	case "username":
+		s, err := decodeString(path.Attr, value)
+		if err != nil {
+			return err
+		}
+		u.UserName = s
+	case "externalid":
+		s, err := decodeString(path.Attr, value)
+		if err != nil {
+			return err
+		}
+		u.ExternalID = s
+	case "displayname":
+		s, err := decodeString(path.Attr, value)
+		if err != nil {
+			return err
+		}
+		u.DisplayName = s
+	case "active":
+		b, err := decodeBool(path.Attr, value)
+		if err != nil {
+			return err
+		}
+		u.Active = &b
+	case "name":
+		var parts map[string]json.RawMessage
+		if err := json.Unmarshal(value, &parts); err != nil {
+			return errorf(ErrorInvalidValue, "name must be an object")
+		}
+		for key, part := range parts {
+			if err := setAttr(u, kind, &Path{Attr: "name." + strings.ToLower(key)}, part); err != nil {
+				return err
+			}
+		}
+	case "name.givenname", "name.familyname", "name.formatted":
+		s, err := decodeString(path.Attr, value)
+		if err != nil {
+			return err
+		}
+		if u.Name == nil {
+			u.Name = &Name{}
+		}
+		switch path.Attr {
+		case "name.givenname":
+			u.Name.GivenName = s
+		case "name.familyname":
+			u.Name.FamilyName = s
+		default:
+			u.Name.Formatted = s
+		}
+	case "emails":
+		return setEmails(u, kind, path, value)
+	case "emails.value", "emails.type", "emails.primary":
+		return setEmails(u, kind, &Path{Attr: "emails", Sub: strings.TrimPrefix(path.Attr, "emails.")}, value)
+	case "password":
+		return errorf(ErrorMutability, "password can only be set when a user is created")
+	case "id", "meta", "schemas":
+		return errorf(ErrorMutability, "%s is read-only", path.Attr)
+	default:
+		return errorf(ErrorInvalidPath, "unknown attribute %s", path.Attr)
+	}
+	return nil
+}
+
+// setEmails adds or replaces emails, or the sub-attribute path.Sub of the emails matching
+// path.Filter. When no email matches, one is added.
+func setEmails(u *User, kind string, path *Path, value json.RawMessage) error {
+	if path.Filter == nil && path.Sub == "" {
+		var emails []Email
+		if err := json.Unmarshal(value, &emails); err != nil {
+			var email Email
+			if err := json.Unmarshal(value, &email); err != nil {
+				return errorf(ErrorInvalidValue, "emails must be an array of emails")
+			}
+			emails = []Email{email}
+		}
+		if kind == "replace" {
+			u.Emails = nil
+		}
+		for _, email := range emails {
+			addEmail(u, email)
+		}
+		return nil
+	}
+
+	var matched []int
+	for i, e := range u.Emails {
+		if path.Filter == nil || matchEmail(path.Filter, e) {
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) == 0 {
+		// Some clients add an email by setting e.g. emails[type eq "work"].value
+		email := Email{Type: emailTypeFrom(path.Filter)}
+		if err := setEmailPart(&email, path.Sub, value); err != nil {
+			return err
+		}
+		addEmail(u, email)
+		return nil
+	}
+	for _, i := range matched {
+		if err := setEmailPart(&u.Emails[i], path.Sub, value); err != nil {
+			return err
+		}
+		if u.Emails[i].Primary {
+			clearOtherPrimaries(u, i)
+		}
+	}
+	return nil
+}
+
+// setEmailPart sets a sub-attribute of email, or the whole email when sub is empty.
+func setEmailPart(email *Email, sub string, value json.RawMessage) error {
+	switch sub {
+	case "":
+		if err := json.Unmarshal(value, email); err != nil {
+			return errorf(ErrorInvalidValue, "invalid email")
+		}
+	case "value":
+		s, err := decodeString("emails.value", value)
+		if err != nil {
+			return err
+		}
+		email.Value = s
+	case "type":
+		s, err := decodeString("emails.type", value)
+		if err != nil {
+			return err
+		}
+		email.Type = s
+	case "primary":
+		b, err := decodeBool("emails.primary", value)
+		if err != nil {
+			return err
+		}
+		email.Primary = b
+	default:
+		return errorf(ErrorInvalidPath, "unknown attribute emails.%s", sub)
+	}
+	return nil
+}
+
+// addEmail appends email, which becomes the only primary email if it is primary.
+func addEmail(u *User, email Email) {
+	u.Emails = append(u.Emails, email)
+	if email.Primary {
+		clearOtherPrimaries(u, len(u.Emails)-1)
+	}
+}
+
+func clearOtherPrimaries(u *User, keep int) {
+	for i := range u.Emails {
+		if i != keep {
+			u.Emails[i].Primary = false
+		}
+	}
+}
+
+// removeAttr removes the attribute at path.
+func removeAttr(u *User, path *Path) error {
+	if strings.HasPrefix(path.Attr, strings.ToLower(SchemaEnterpriseUser)) {
+		return nil
+	}
+	switch path.Attr {
+	case "username":
+		return errorf(ErrorInvalidValue, "userName is required")
+	case "externalid":
+		u.ExternalID = ""
+	case "displayname":
+		u.DisplayName = ""
+	case "active":
+		u.Active = nil
+	case "name":
+		u.Name = nil
+	case "name.givenname", "name.familyname", "name.formatted":
+		if u.Name == nil {
+			return nil
+		}
+		switch path.Attr {
+		case "name.givenname":
+			u.Name.GivenName = ""
+		case "name.familyname":
+			u.Name.FamilyName = ""
+		default:
+			u.Name.Formatted = ""
+		}
+	case "emails":
+		if path.Filter == nil {
+			u.Emails = nil
+			return nil
+		}
+		kept := u.Emails[:0]
+		for _, e := range u.Emails {
+			if !matchEmail(path.Filter, e) {
+				kept = append(kept, e)
+				continue
+			}
+			switch path.Sub {
+			case "", "value":
+				// removing the address removes the email
+			case "type":
+				e.Type = ""
+				kept = append(kept, e)
+			case "primary":
+				e.Primary = false
+				kept = append(kept, e)
+			default:
+				return errorf(ErrorInvalidPath, "unknown attribute emails.%s", path.Sub)
+			}
+		}
+		u.Emails = kept
+	case "id", "meta", "schemas", "password":
+		return errorf(ErrorMutability, "%s can't be removed", path.Attr)
+	default:
+		return errorf(ErrorInvalidPath, "unknown attribute %s", path.Attr)
+	}
+	return nil
+}
+
+// matchEmail reports whether email satisfies a value filter on emails.
+func matchEmail(f Filter, email Email) bool {
+	switch f := f.(type) {
+	case *LogicalExpr:
+		if f.Op == "and" {
+			return matchEmail(f.Left, email) && matchEmail(f.Right, email)
+		}
+		return matchEmail(f.Left, email) || matchEmail(f.Right, email)
+	case *NotExpr:
+		return !matchEmail(f.Filter, email)
+	case *AttrExpr:
+		if f.Attr == "emails.primary" {
+			switch f.Op {
+			case "pr":
+				return email.Primary
+			case "ne":
+				return email.Primary != f.Value
+			default:
+				return email.Primary == f.Value
+			}
+		}
+		actual := email.Value
+		if f.Attr == "emails.type" {
+			actual = email.Type
+		}
+		if f.Op == "pr" {
+			return actual != ""
+		}
+		return compareStrings(strings.ToLower(actual), f.Op, strings.ToLower(f.Value.(string)))
+	}
+	return false
+}
+
+func compareStrings(actual, op, expected string) bool {
+	switch op {
+	case "eq":
+		return actual == expected
+	case "ne":
+		return actual != expected
+	case "co":
+		return strings.Contains(actual, expected)
+	case "sw":
+		return strings.HasPrefix(actual, expected)
+	case "ew":
+		return strings.HasSuffix(actual, expected)
+	case "gt":
+		return actual > expected
+	case "ge":
+		return actual >= expected
+	case "lt":
+		return actual < expected
+	case "le":
+		return actual <= expected
+	}
+	return false
+}
+
+// emailTypeFrom returns the type a value filter such as type eq "work" requires, if any.
+func emailTypeFrom(f Filter) string {
+	if expr, ok := f.(*AttrExpr); ok && expr.Attr == "emails.type" && expr.Op == "eq" {
+		return expr.Value.(string)
+	}
+	return ""
+}
+
+// decodeString decodes a string value; null clears the attribute.
+func decodeString(attr string, value json.RawMessage) (string, error) {
+	var s *string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", errorf(ErrorInvalidValue, "%s must be a string", attr)
+	}
+	if s == nil {
+		return "", nil
+	}
+	return *s, nil
+}
+
+// decodeBool decodes a boolean value, given either as a boolean or as the string "true" or "false".
+func decodeBool(attr string, value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, errorf(ErrorInvalidValue, "%s must be a boolean", attr)
+}
@@ -0,0 +1,144 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testUser returns the user PATCH operations are applied to in TestPatchApply.
+func testUser() *User {
+	active := true
+	return &User{
+		Schemas:     []string{SchemaUser},
+		ID:          "2819c223-7f76-453a-919d-413861904646",
+		UserName:    "bjensen",
+		DisplayName: "Babs",
+		Name:        &Name{GivenName: "Barbara", FamilyName: "Jensen"},
+		Emails:      []Email{{Value: "bjensen@example.com", Type: "work", Primary: true}},
+		Active:      &active,
+	}
+}
+
+func TestPatchApply(t *testing.T) {
+	inactive := false
+	tests := []struct {
+		name       string
+		operations string
+		want       func(u *User) // edits testUser into the expected result
+		wantType   string        // scimType of the error; empty when the patch applies
+	}{
+		{
+			name:       "replace attribute",
+			operations: `[{"op": "replace", "path": "displayName", "value": "Barbara"}]`,
+			want:       func(u *User) { u.DisplayName = "Barbara" },
+		},
+		{
+			name:       "operation names are case-insensitive",
+			operations: `[{"op": "Replace", "path": "userName", "value": "barbara"}]`,
+			want:       func(u *User) { u.UserName = "barbara" },
+		},
+		{
+			name:       "deactivate with a string boolean",
+			operations: `[{"op": "replace", "path": "active", "value": "False"}]`,
+			want:       func(u *User) { u.Active = &inactive },
+		},
+		{
+			name:       "replace without a path",
+			operations: `[{"op": "replace", "value": {"active": false, "name": {"givenName": "Babs"}, "name.familyName": "Jensen-Smith"}}]`,
+			want: func(u *User) {
+				u.Active = &inactive
+				u.Name = &Name{GivenName: "Babs", FamilyName: "Jensen-Smith"}
+			},
+		},
+		{
+			name:       "enterprise extension ignored",
+			operations: `[{"op": "add", "value": {"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User": {"department": "Bids"}}}, {"op": "replace", "path": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager", "value": "x"}]`,
+			want:       func(u *User) {},
+		},
+		{
+			name:       "replace email value through a value filter",
+			operations: `[{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "babs@example.com"}]`,
+			want:       func(u *User) { u.Emails[0].Value = "babs@example.com" },
+		},
+		{
+			name:       "value filter matching nothing adds an email",
+			operations: `[{"op": "add", "path": "emails[type eq \"home\"].value", "value": "babs@home.example"}]`,
+			want: func(u *User) {
+				u.Emails = append(u.Emails, Email{Value: "babs@home.example", Type: "home"})
+			},
+		},
+		{
+			name:       "added primary email demotes the others",
+			operations: `[{"op": "add", "path": "emails", "value": [{"value": "babs@example.org", "type": "work", "primary": true}]}]`,
+			want: func(u *User) {
+				u.Emails = []Email{{Value: "bjensen@example.com", Type: "work"}, {Value: "babs@example.org", Type: "work", Primary: true}}
+			},
+		},
+		{
+			name:       "replace emails",
+			operations: `[{"op": "replace", "path": "emails", "value": {"value": "babs@example.org", "primary": true}}]`,
+			want:       func(u *User) { u.Emails = []Email{{Value: "babs@example.org", Primary: true}} },
+		},
+		{
+			name:       "remove attribute",
+			operations: `[{"op": "remove", "path": "name.givenName"}, {"op": "remove", "path": "displayName"}]`,
+			want: func(u *User) {
+				u.Name.GivenName = ""
+				u.DisplayName = ""
+			},
+		},
+		{
+			name:       "remove emails through a value filter",
+			operations: `[{"op": "remove", "path": "emails[value ew \"@example.com\"]"}]`,
+			want:       func(u *User) { u.Emails = []Email{} },
+		},
+		{
+			name:       "remove active",
+			operations: `[{"op": "remove", "path": "active"}]`,
+			want:       func(u *User) { u.Active = nil },
+		},
+		{
+			name:       "operations apply in order",
+			operations: `[{"op": "replace", "path": "displayName", "value": "A"}, {"op": "replace", "path": "displayName", "value": "B"}]`,
+			want:       func(u *User) { u.DisplayName = "B" },
+		},
+		{name: "no operations", operations: `[]`, wantType: ErrorInvalidSyntax},
+		{name: "unknown operation", operations: `[{"op": "move", "path": "displayName"}]`, wantType: ErrorInvalidSyntax},
+		{name: "remove without a path", operations: `[{"op": "remove"}]`, wantType: ErrorNoTarget},
+		{name: "no path and no object", operations: `[{"op": "replace", "value": "x"}]`, wantType: ErrorInvalidValue},
+		{name: "remove userName", operations: `[{"op": "remove", "path": "userName"}]`, wantType: ErrorInvalidValue},
+		{name: "set password", operations: `[{"op": "replace", "path": "password", "value": "secret"}]`, wantType: ErrorMutability},
+		{name: "set id", operations: `[{"op": "replace", "path": "id", "value": "x"}]`, wantType: ErrorMutability},
+		{name: "unknown attribute", operations: `[{"op": "replace", "path": "nickName", "value": "Babs"}]`, wantType: ErrorInvalidPath},
+		{name: "wrong value type", operations: `[{"op": "replace", "path": "active", "value": "yes"}]`, wantType: ErrorInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patch PatchRequest
+			if err := json.Unmarshal([]byte(`{"schemas": ["`+SchemaPatchOp+`"], "Operations": `+tt.operations+`}`), &patch); err != nil {
+				t.Fatalf("decode operations: %v", err)
+			}
+			got := testUser()
+			err := patch.Apply(got)
+			if tt.wantType != "" {
+				var scimErr *Error
+				if !errors.As(err, &scimErr) || scimErr.Type != tt.wantType {
+					t.Fatalf("Apply() error = %v, want a %s error", err, tt.wantType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			want := testUser()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(want)
+				t.Errorf("Apply() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrDeletionNotPending = errors.New("account is not pending deletion")
+
+// exportAuthEventLimit caps the audit events included in a data export to the most recent ones.
+const exportAuthEventLimit = 1000
+
+// AccountService handles self-service account lifecycle: deletion and personal data export.
+type AccountService interface {
+	RequestDeletion(ctx context.Context, userID uuid.UUID, proof Reauthentication) (*contracts.UserDTO, error)
+	CancelDeletion(ctx context.Context, email, password string) (*contracts.UserDTO, error)
+	Export(ctx context.Context, userID uuid.UUID) (*contracts.AccountExportDTO, error)
+	PurgeDeleted(ctx context.Context) (int64, error)
+	RunPurge(ctx context.Context, interval time.Duration)
+}
+
+// accountService implements AccountService.
+type accountService struct {
+	pool             *sql.DB
+	userRepo         repository.UserRepository
+	credRepo         repository.PasswordCredentialRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	hasher           PasswordHasher
+	gracePeriod      time.Duration
+	reauthMaxAge     time.Duration
+}
+
+// NewAccountService creates a new account service. Deletions requested through it are purged once
+// gracePeriod has passed; users without a password can request one from a session authenticated
+// within reauthMaxAge.
+func NewAccountService(pool *sql.DB, userRepo repository.UserRepository, credRepo repository.PasswordCredentialRepository, refreshTokenRepo repository.RefreshTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, hasher PasswordHasher, gracePeriod, reauthMaxAge time.Duration) AccountService {
+	return &accountService{
+		pool:             pool,
+		userRepo:         userRepo,
+		credRepo:         credRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		hasher:           hasher,
+		gracePeriod:      gracePeriod,
+		reauthMaxAge:     reauthMaxAge,
+	}
+}
+
+// RequestDeletion re-authenticates the user with proof, marks the account pending_deletion for the
+// grace period and revokes every session.
+func (s *accountService) RequestDeletion(ctx context.Context, userID uuid.UUID, proof Reauthentication) (*contracts.UserDTO, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	if err := checkReauthentication(ctx, s.pool, s.credRepo, s.hasher, s.reauthMaxAge, user.ID, proof); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err = s.userRepo.ScheduleDeletion(ctx, tx, user.ID, "deletion requested by user", time.Now().Add(s.gracePeriod))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, user.ID, contracts.SessionRevokedDeletionRequest); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventAccountDeletionRequested, user.ID, map[string]any{
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+	})); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
+
+// CancelDeletion reactivates an account pending deletion. The user can't hold a session while
+// pending deletion, so they authenticate with their email and password instead. Users without a
+// password restore their account by signing in with their identity provider.
+func (s *accountService) CancelDeletion(ctx context.Context, email, password string) (*contracts.UserDTO, error) {
+	user, err := s.userRepo.FindByEmail(ctx, s.pool, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.verifyPassword(ctx, user.ID, password); err != nil {
+		return nil, err
+	}
+	if user.Status != contracts.UserStatusPendingDeletion {
+		return nil, ErrDeletionNotPending
+	}
+
+	user, err = cancelDeletion(ctx, s.pool, s.userRepo, s.auditRepo, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
+
+// Export collects the personal data held about a user.
+func (s *accountService) Export(ctx context.Context, userID uuid.UUID) (*contracts.AccountExportDTO, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	tokens, err := s.refreshTokenRepo.ListByUserID(ctx, s.pool, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]contracts.SessionDTO, 0, len(tokens))
+	for _, t := range tokens {
+		sessions = append(sessions, contracts.SessionDTO{
+			ID:        t.TokenID,
+			IssuedAt:  t.IssuedAt,
+			ExpiresAt: t.ExpiresAt,
+			RevokedAt: t.RevokedAt,
+		})
+	}
+
+	events, err := s.auditRepo.List(ctx, s.pool, contracts.AuthEventFilter{UserID: &user.ID}, 0, exportAuthEventLimit)
+	if err != nil {
+		return nil, err
+	}
+	activity := make([]contracts.AuthEventDTO, 0, len(events))
+	for _, e := range events {
+		activity = append(activity, e.ToDTO())
+	}
+
+	return &contracts.AccountExportDTO{
+		ExportedAt: time.Now().UTC(),
+		Profile:    *user.ToDTO(),
+		Sessions:   sessions,
+		Activity:   activity,
+	}, nil
+}
+
+// PurgeDeleted hard-deletes accounts whose deletion grace period has passed and publishes
+// user.deleted for each of them.
+func (s *accountService) PurgeDeleted(ctx context.Context) (int64, error) {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	userIDs, err := s.userRepo.DeleteScheduled(ctx, tx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range userIDs {
+		if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserDeleted, contracts.UserDeletedEventData{UserID: id}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(userIDs)), nil
+}
+
+// RunPurge calls PurgeDeleted every interval until ctx is cancelled.
+func (s *accountService) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.PurgeDeleted(ctx)
+			if err != nil {
+				log.Printf("couldn't purge deleted accounts: %v\n", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purged %d deleted accounts\n", n)
+			}
+		}
+	}
+}
+
+// cancelDeletion reactivates the account userID, which is pending deletion, and records who cancelled
+// the deletion with meta.
+func cancelDeletion(ctx context.Context, db *sql.DB, userRepo repository.UserRepository, auditRepo repository.AuthEventRepository, userID uuid.UUID, meta map[string]any) (*contracts.User, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := userRepo.UpdateStatus(ctx, tx, userID, contracts.UserStatusActive, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventAccountDeletionCancelled, user.ID, meta)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verifyPassword checks password against the user's stored credential.
+func (s *accountService) verifyPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	creds, err := s.credRepo.GetByUserID(ctx, s.pool, userID)
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return ErrInvalidCredentials
+	}
+	ok, err := s.hasher.Verify(ctx, password, creds)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
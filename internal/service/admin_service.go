@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var ErrCannotModifySelf = errors.New("admins cannot perform this action on their own account")
+
+// AdminService handles administrative reporting and account management.
+type AdminService interface {
+	PepperReport(ctx context.Context) ([]PepperReportEntry, error)
+	ListUsers(ctx context.Context, filter contracts.UserFilter, after *contracts.UserCursor, limit int) (*UserPage, error)
+	GetUser(ctx context.Context, userID uuid.UUID) (*contracts.UserDTO, error)
+	CreateUser(ctx context.Context, username, email, role, password string, invite bool) (*contracts.UserDTO, bool, error)
+	UpdateUser(ctx context.Context, userID uuid.UUID, username, email string) (*contracts.UserDTO, error)
+	ChangeRole(ctx context.Context, actorID, userID uuid.UUID, role string) (*contracts.UserDTO, error)
+	SuspendUser(ctx context.Context, actorID, userID uuid.UUID, reason string) (*contracts.UserDTO, error)
+	RestoreUser(ctx context.Context, actorID, userID uuid.UUID) (*contracts.UserDTO, error)
+	ForceLogout(ctx context.Context, userID uuid.UUID) error
+	DeleteUser(ctx context.Context, actorID, userID uuid.UUID) error
+	ListAuthEvents(ctx context.Context, filter contracts.AuthEventFilter, beforeID int64, limit int) (*AuthEventPage, error)
+}
+
+// PepperReportEntry counts the credentials remaining on a pepper version. PepperID is nil for
+// unpeppered (imported legacy) hashes.
+type PepperReportEntry struct {
+	PepperID    *int
+	Credentials int64
+	Current     bool
+	Configured  bool
+}
+
+// adminService implements AdminService.
+type adminService struct {
+	pool             *sql.DB
+	userRepo         repository.UserRepository
+	roleRepo         repository.RoleRepository
+	credRepo         repository.PasswordCredentialRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	inviteRepo       repository.UserInviteRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	hasher           PasswordHasher
+	breached         BreachChecker // nil when no breached-password corpus is configured
+	policy           config.PasswordPolicy
+	mailer           Mailer
+	inviteURL        string
+	inviteTTL        time.Duration
+}
+
+// NewAdminService creates a new admin service. Invitations link to inviteURL with the token appended
+// and expire after inviteTTL.
+func NewAdminService(pool *sql.DB, userRepo repository.UserRepository, roleRepo repository.RoleRepository, credRepo repository.PasswordCredentialRepository, refreshTokenRepo repository.RefreshTokenRepository, inviteRepo repository.UserInviteRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, hasher PasswordHasher, breached BreachChecker, policy config.PasswordPolicy, mailer Mailer, inviteURL string, inviteTTL time.Duration) AdminService {
+	return &adminService{
+		pool:             pool,
+		userRepo:         userRepo,
+		roleRepo:         roleRepo,
+		credRepo:         credRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		inviteRepo:       inviteRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		hasher:           hasher,
+		breached:         breached,
+		policy:           policy,
+		mailer:           mailer,
+		inviteURL:        inviteURL,
+		inviteTTL:        inviteTTL,
+	}
+}
+
+// PepperReport lists every configured pepper version, plus any unconfigured version still in use,
+// with the number of credentials hashed with it. Credentials move to the current version on login.
+func (s *adminService) PepperReport(ctx context.Context) ([]PepperReportEntry, error) {
+	usage, err := s.credRepo.CountByPepper(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int64, len(usage))
+	var unpeppered int64
+	for _, u := range usage {
+		if u.PepperID == nil {
+			unpeppered = u.Credentials
+			continue
+		}
+		counts[*u.PepperID] = u.Credentials
+	}
+
+	var report []PepperReportEntry
+	for _, id := range s.hasher.PepperIDs() {
+		report = append(report, PepperReportEntry{
+			PepperID:    &id,
+			Credentials: counts[id],
+			Current:     id == s.hasher.CurrentPepperID(),
+			Configured:  true,
+		})
+		delete(counts, id)
+	}
+	// Versions still referenced by credentials but no longer configured cannot be verified
+	for _, u := range usage {
+		if u.PepperID == nil {
+			continue
+		}
+		if n, ok := counts[*u.PepperID]; ok {
+			report = append(report, PepperReportEntry{PepperID: u.PepperID, Credentials: n})
+		}
+	}
+	if unpeppered > 0 {
+		report = append(report, PepperReportEntry{Credentials: unpeppered})
+	}
+	return report, nil
+}
+
+// SuspendUser blocks a user from logging in or refreshing tokens and revokes their sessions.
+func (s *adminService) SuspendUser(ctx context.Context, actorID, userID uuid.UUID, reason string) (*contracts.UserDTO, error) {
+	return s.setUserStatus(ctx, actorID, userID, contracts.UserStatusSuspended, &reason)
+}
+
+// RestoreUser reactivates a suspended, deactivated or pending-deletion user.
+func (s *adminService) RestoreUser(ctx context.Context, actorID, userID uuid.UUID) (*contracts.UserDTO, error) {
+	return s.setUserStatus(ctx, actorID, userID, contracts.UserStatusActive, nil)
+}
+
+// setUserStatus changes a user's status and revokes all of their refresh tokens in one transaction.
+func (s *adminService) setUserStatus(ctx context.Context, actorID, userID uuid.UUID, status string, reason *string) (*contracts.UserDTO, error) {
+	if actorID == userID {
+		return nil, ErrCannotModifySelf
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.UpdateStatus(ctx, tx, userID, status, reason)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, userID, contracts.SessionRevokedStatusChanged); err != nil {
+		return nil, err
+	}
+	metadata := map[string]any{"status": status}
+	if reason != nil {
+		metadata["reason"] = *reason
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventStatusChanged, &userID, metadata)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPasswordOrInviteRequired = errors.New("a password or an invite is required")
+	ErrInvalidInvite            = errors.New("invite is invalid, expired or already used")
+)
+
+// Mailer sends transactional email.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// UserPage is one page of a user listing.
+type UserPage struct {
+	Users      []*contracts.UserDTO
+	Total      int64                 // users matching the filter across all pages
+	NextCursor *contracts.UserCursor // nil on the last page
+}
+
+// ListUsers returns up to limit users matching filter, newest first, starting after the cursor.
+func (s *adminService) ListUsers(ctx context.Context, filter contracts.UserFilter, after *contracts.UserCursor, limit int) (*UserPage, error) {
+	// Fetch one extra row to learn whether another page follows
+	users, err := s.userRepo.List(ctx, s.pool, filter, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.userRepo.Count(ctx, s.pool, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &UserPage{Total: total}
+	if len(users) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		page.NextCursor = &contracts.UserCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	page.Users = make([]*contracts.UserDTO, 0, len(users))
+	for _, u := range users {
+		page.Users = append(page.Users, u.ToDTO())
+	}
+	return page, nil
+}
+
+// GetUser returns a single user.
+func (s *adminService) GetUser(ctx context.Context, userID uuid.UUID) (*contracts.UserDTO, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user.ToDTO(), nil
+}
+
+// CreateUser creates a user with a password, an emailed invitation to choose one, or both. It
+// reports whether the invitation email was sent; a failed send doesn't undo the creation.
+func (s *adminService) CreateUser(ctx context.Context, username, email, role, password string, invite bool) (*contracts.UserDTO, bool, error) {
+	if password == "" && !invite {
+		return nil, false, ErrPasswordOrInviteRequired
+	}
+
+	// Check uniqueness
+	existingUsername, _ := s.userRepo.FindByUsername(ctx, s.pool, username)
+	if existingUsername != nil {
+		return nil, false, ErrUserExists
+	}
+	existingEmail, _ := s.userRepo.FindByEmail(ctx, s.pool, email)
+	if existingEmail != nil {
+		return nil, false, ErrUserExists
+	}
+	if err := requireRoleExists(ctx, s.roleRepo, s.pool, role); err != nil {
+		return nil, false, err
+	}
+
+	var cred *contracts.PasswordCredential
+	if password != "" {
+		if err := screenNewPassword(s.policy, s.breached, password, username, email); err != nil {
+			return nil, false, err
+		}
+		var err error
+		if cred, err = s.hasher.Hash(ctx, password); err != nil {
+			return nil, false, err
+		}
+	}
+	var inviteToken string
+	if invite {
+		var err error
+		if inviteToken, err = generateInviteToken(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Create(ctx, tx, username, email, role)
+	if err != nil {
+		return nil, false, ErrUserExists
+	}
+	if cred != nil {
+		cred.UserID = user.ID
+		if err := s.credRepo.Create(ctx, tx, cred); err != nil {
+			return nil, false, err
+		}
+	}
+	if invite {
+		if err := s.inviteRepo.Create(ctx, tx, user.ID, hashInviteToken(inviteToken), time.Now().Add(s.inviteTTL)); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserCreated, &user.ID, map[string]any{
+		"role":   role,
+		"invite": invite,
+	})); err != nil {
+		return nil, false, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	if !invite {
+		return user.ToDTO(), false, nil
+	}
+	if err := s.sendInvite(ctx, user, inviteToken); err != nil {
+		log.Printf("couldn't send invite to user %s: %v\n", user.ID, err)
+		return user.ToDTO(), false, nil
+	}
+	return user.ToDTO(), true, nil
+}
+
+// UpdateUser changes a user's username and email.
+func (s *adminService) UpdateUser(ctx context.Context, userID uuid.UUID, username, email string) (*contracts.UserDTO, error) {
+	// Check uniqueness against other users
+	existingUsername, _ := s.userRepo.FindByUsername(ctx, s.pool, username)
+	if existingUsername != nil && existingUsername.ID != userID {
+		return nil, ErrUserExists
+	}
+	existingEmail, _ := s.userRepo.FindByEmail(ctx, s.pool, email)
+	if existingEmail != nil && existingEmail.ID != userID {
+		return nil, ErrUserExists
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Update(ctx, tx, userID, username, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserUpdated, &userID, nil)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
+
+// ChangeRole sets a user's role and revokes their sessions so new tokens carry the new role.
+func (s *adminService) ChangeRole(ctx context.Context, actorID, userID uuid.UUID, role string) (*contracts.UserDTO, error) {
+	if actorID == userID {
+		return nil, ErrCannotModifySelf
+	}
+	if err := requireRoleExists(ctx, s.roleRepo, s.pool, role); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.UpdateRole(ctx, tx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, userID, contracts.SessionRevokedRoleChanged); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRoleChanged, &userID, map[string]any{"role": role})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRoleChanged, contracts.UserRoleChangedEventData{
+		UserID: userID,
+		Role:   user.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
+
+// ForceLogout revokes every refresh token of a user.
+func (s *adminService) ForceLogout(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, userID, contracts.SessionRevokedAdmin); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventSessionsRevoked, &userID, nil)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteUser hard-deletes a user immediately, bypassing the self-service grace period.
+func (s *adminService) DeleteUser(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.userRepo.Delete(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+	// The event names no subject: the user's ID is personal data and goes with the rest of the user
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserDeleted, nil, nil)); err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserDeleted, contracts.UserDeletedEventData{UserID: userID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sendInvite emails the user a link, or the bare token when no INVITE_URL is configured.
+func (s *adminService) sendInvite(ctx context.Context, user *contracts.User, token string) error {
+	action := "Use this invitation code to choose your password: " + token
+	if s.inviteURL != "" {
+		action = "Choose your password here: " + s.inviteURL + token
+	}
+	body := fmt.Sprintf("Hello %s,\n\nAn account has been created for you.\n%s\n\nThis invitation expires on %s.\n",
+		user.Username, action, time.Now().Add(s.inviteTTL).UTC().Format(time.RFC1123))
+	return s.mailer.Send(ctx, user.Email, "You've been invited", body)
+}
+
+// generateInviteToken creates a cryptographically secure random token (32 bytes, base64url-encoded).
+func generateInviteToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashInviteToken computes the SHA-256 hash under which an invite token is stored.
+func hashInviteToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
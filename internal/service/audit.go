@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/audit"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+// authEvent builds an audit event about subjectID, attributed to the authenticated caller (if any)
+// and tagged with the request details stored in ctx.
+func authEvent(ctx context.Context, eventType string, subjectID *uuid.UUID, metadata map[string]any) *contracts.AuthEvent {
+	req := audit.RequestFrom(ctx)
+	return &contracts.AuthEvent{
+		Type:      eventType,
+		ActorID:   req.ActorID,
+		SubjectID: subjectID,
+		IP:        req.IP,
+		UserAgent: req.UserAgent,
+		RequestID: req.RequestID,
+		Metadata:  metadata,
+	}
+}
+
+// selfEvent builds an audit event for an action a user performs on their own account; without an
+// authenticated caller (e.g. login or refresh) the user is recorded as the actor.
+func selfEvent(ctx context.Context, eventType string, userID uuid.UUID, metadata map[string]any) *contracts.AuthEvent {
+	event := authEvent(ctx, eventType, &userID, metadata)
+	if event.ActorID == nil {
+		event.ActorID = &userID
+	}
+	return event
+}
+
+// recordStandalone writes an event that isn't part of a transaction. Failures are logged rather
+// than failing the request the event describes.
+func recordStandalone(ctx context.Context, repo repository.AuthEventRepository, db repository.Execer, event *contracts.AuthEvent) {
+	if err := repo.Create(ctx, db, event); err != nil {
+		log.Printf("couldn't record %s audit event: %v\n", event.Type, err)
+	}
+}
+
+// AuthEventPage is one page of the audit log.
+type AuthEventPage struct {
+	Events     []*contracts.AuthEvent
+	NextCursor int64 // ID to pass as beforeID for the next page; 0 on the last page
+}
+
+// ListAuthEvents returns up to limit audit events matching filter, newest first, with IDs below beforeID.
+func (s *adminService) ListAuthEvents(ctx context.Context, filter contracts.AuthEventFilter, beforeID int64, limit int) (*AuthEventPage, error) {
+	// Fetch one extra row to learn whether another page follows
+	events, err := s.auditRepo.List(ctx, s.pool, filter, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &AuthEventPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = page.Events[limit-1].ID
+	}
+	if page.Events == nil {
+		page.Events = []*contracts.AuthEvent{}
+	}
+	return page, nil
+}
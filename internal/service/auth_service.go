@@ -4,22 +4,37 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"slices"
+	"time"
 
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
 	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
 	"github.com/LittleAksMax/bids-auth-service/internal/repository"
-	"github.com/LittleAksMax/bids-util/passwords"
+	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrUserExists         = errors.New("username or email already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrBreachedPassword   = errors.New("password appears in a known data breach")
+	ErrAccountInactive    = errors.New("account is not active")
+	ErrRegistrationRole   = errors.New("role can't be chosen when registering")
 )
 
 // AuthService handles authentication business logic.
 type AuthService interface {
 	Register(ctx context.Context, username, email, password, role string) (*contracts.UserDTO, error)
 	Login(ctx context.Context, email, password string) (*contracts.UserDTO, error)
+	ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) (*contracts.UserDTO, error)
+	AcceptInvite(ctx context.Context, token, password string) (*contracts.UserDTO, error)
+}
+
+// BreachChecker screens passwords against a corpus of breached passwords.
+type BreachChecker interface {
+	IsBreached(password string) bool
 }
 
 // authService implements AuthService.
@@ -27,22 +42,43 @@ type authService struct {
 	pool         *sql.DB
 	tokenService TokenService // NOTE: this breaks the
 	userRepo     repository.UserRepository
+	roleRepo     repository.RoleRepository
 	credRepo     repository.PasswordCredentialRepository
-	pepper       string
+	historyRepo  repository.PasswordHistoryRepository
+	inviteRepo   repository.UserInviteRepository
+	auditRepo    repository.AuthEventRepository
+	outboxRepo   repository.OutboxRepository
+	hasher       PasswordHasher
+	breached     BreachChecker // nil when no breached-password corpus is configured
+	policy       config.PasswordPolicy
+	roles        []string // roles users may pick when registering
 }
 
 // NewAuthService creates a new authentication service.
-func NewAuthService(pool *sql.DB, userRepo repository.UserRepository, credRepo repository.PasswordCredentialRepository, pepper string) AuthService {
+func NewAuthService(pool *sql.DB, userRepo repository.UserRepository, roleRepo repository.RoleRepository, credRepo repository.PasswordCredentialRepository, historyRepo repository.PasswordHistoryRepository, inviteRepo repository.UserInviteRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, hasher PasswordHasher, breached BreachChecker, policy config.PasswordPolicy, registrationRoles []string) AuthService {
 	return &authService{
-		pool:     pool,
-		userRepo: userRepo,
-		credRepo: credRepo,
-		pepper:   pepper,
+		pool:        pool,
+		userRepo:    userRepo,
+		roleRepo:    roleRepo,
+		credRepo:    credRepo,
+		historyRepo: historyRepo,
+		inviteRepo:  inviteRepo,
+		auditRepo:   auditRepo,
+		outboxRepo:  outboxRepo,
+		hasher:      hasher,
+		breached:    breached,
+		policy:      policy,
+		roles:       registrationRoles,
 	}
 }
 
-// Register creates a new user account and returns tokens for immediate use.
+// Register creates a new user account and returns tokens for immediate use. The role must be one of
+// the registration roles.
 func (s *authService) Register(ctx context.Context, username, email, password, role string) (*contracts.UserDTO, error) {
+	if !slices.Contains(s.roles, role) {
+		return nil, ErrRegistrationRole
+	}
+
 	// Check uniqueness
 	existingUsername, _ := s.userRepo.FindByUsername(ctx, s.pool, username)
 	if existingUsername != nil {
@@ -53,6 +89,19 @@ func (s *authService) Register(ctx context.Context, username, email, password, r
 		return nil, ErrUserExists
 	}
 
+	if err := requireRoleExists(ctx, s.roleRepo, s.pool, role); err != nil {
+		return nil, err
+	}
+	if err := s.screenPassword(password, username, email); err != nil {
+		return nil, err
+	}
+
+	// Hash before opening the transaction so a saturated hasher doesn't hold a connection
+	cred, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create transaction used for registration process to maintain consistency
 	tx, err := s.pool.BeginTx(ctx, nil)
 	if err != nil {
@@ -76,12 +125,21 @@ func (s *authService) Register(ctx context.Context, username, email, password, r
 	}
 
 	// Store password credential
-	salt, hash, err := passwords.HashPassword(password, s.pepper, passwords.DefaultParams)
+	cred.UserID = user.ID
+	err = s.credRepo.Create(ctx, tx, cred)
 	if err != nil {
 		return nil, err
 	}
-	err = s.credRepo.Create(ctx, tx, user.ID, hash, salt)
-	if err != nil {
+
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventRegister, user.ID, map[string]any{"role": role})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
 		return nil, err
 	}
 
@@ -99,6 +157,7 @@ func (s *authService) Login(ctx context.Context, email, password string) (*contr
 		return nil, err
 	}
 	if user == nil {
+		s.recordLoginFailure(ctx, nil, "unknown_user")
 		return nil, ErrInvalidCredentials
 	}
 
@@ -108,17 +167,255 @@ func (s *authService) Login(ctx context.Context, email, password string) (*contr
 		return nil, err
 	}
 	if creds == nil {
+		s.recordLoginFailure(ctx, &user.ID, "no_password")
 		return nil, ErrInvalidCredentials
 	}
 
 	// Verify password
-	ok, err := passwords.VerifyPassword(password, s.pepper, creds.PasswordSalt, creds.PasswordHash, passwords.DefaultParams)
+	ok, err := s.hasher.Verify(ctx, password, creds)
 	if err != nil {
 		return nil, err
 	}
 	if !ok {
+		s.recordLoginFailure(ctx, &user.ID, "invalid_password")
 		return nil, ErrInvalidCredentials
 	}
+	// Checked after the password so the account status isn't disclosed to anyone without it
+	if !user.IsActive() {
+		s.recordLoginFailure(ctx, &user.ID, "account_"+user.Status)
+		return nil, ErrAccountInactive
+	}
+
+	// Transparently upgrade hashes produced with outdated parameters or peppers; failure must not block login
+	if s.hasher.NeedsRehash(creds) {
+		if err := s.setPassword(ctx, user.ID, password, nil); err != nil {
+			log.Printf("couldn't upgrade password hash for user %s: %v\n", user.ID, err)
+		}
+	}
 
+	if err := s.credRepo.TouchLogin(ctx, s.pool, user.ID, time.Now().UTC()); err != nil {
+		log.Printf("couldn't record password use for user %s: %v\n", user.ID, err)
+	}
+	recordStandalone(ctx, s.auditRepo, s.pool, selfEvent(ctx, contracts.AuthEventLoginSucceeded, user.ID, nil))
 	return user.ToDTO(), nil
 }
+
+// recordLoginFailure records a failed login; subjectID is nil when no user matched.
+func (s *authService) recordLoginFailure(ctx context.Context, subjectID *uuid.UUID, reason string) {
+	recordStandalone(ctx, s.auditRepo, s.pool, authEvent(ctx, contracts.AuthEventLoginFailed, subjectID, map[string]any{"reason": reason}))
+}
+
+// ChangePassword verifies the current password and replaces it with a new one.
+func (s *authService) ChangePassword(ctx context.Context, userID uuid.UUID, currentPassword, newPassword string) (*contracts.UserDTO, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+
+	creds, err := s.credRepo.GetByUserID(ctx, s.pool, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if creds == nil {
+		return nil, ErrInvalidCredentials
+	}
+	ok, err := s.hasher.Verify(ctx, currentPassword, creds)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.screenPassword(newPassword, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+	reused, err := s.isRecentPassword(ctx, newPassword, creds)
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, &PasswordPolicyError{Violations: []PasswordPolicyViolation{{
+			Rule:    PasswordRuleReused,
+			Message: fmt.Sprintf("must not be one of your last %d passwords", s.policy.HistoryDepth),
+		}}}
+	}
+	if err := s.setPassword(ctx, user.ID, newPassword, creds); err != nil {
+		return nil, err
+	}
+
+	return user.ToDTO(), nil
+}
+
+// AcceptInvite redeems an invitation by setting the invited user's password.
+func (s *authService) AcceptInvite(ctx context.Context, token, password string) (*contracts.UserDTO, error) {
+	tokenHash := hashInviteToken(token)
+	invite, err := s.inviteRepo.FindByHash(ctx, s.pool, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInvalidInvite
+	}
+
+	user, err := s.userRepo.FindByID(ctx, s.pool, invite.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidInvite
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+
+	if err := s.screenPassword(password, user.Username, user.Email); err != nil {
+		return nil, err
+	}
+	existing, err := s.credRepo.GetByUserID(ctx, s.pool, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return nil, err
+	}
+	cred.UserID = user.ID
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	// Marking the invite first makes concurrent redemptions of the same token fail
+	accepted, err := s.inviteRepo.MarkAccepted(ctx, tx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidInvite
+	}
+	if existing == nil {
+		err = s.credRepo.Create(ctx, tx, cred)
+	} else {
+		err = s.credRepo.Update(ctx, tx, cred)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventInviteAccepted, user.ID, nil)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user.ToDTO(), nil
+}
+
+// screenPassword applies the password policy's static rules and the breached-password corpus.
+func (s *authService) screenPassword(password, username, email string) error {
+	return screenNewPassword(s.policy, s.breached, password, username, email)
+}
+
+// screenNewPassword applies policy's static rules and, when breached is set, the breached-password corpus.
+func screenNewPassword(policy config.PasswordPolicy, breached BreachChecker, password, username, email string) error {
+	if violations := CheckPasswordPolicy(policy, password, username, email); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	if breached != nil && breached.IsBreached(password) {
+		return ErrBreachedPassword
+	}
+	return nil
+}
+
+// isRecentPassword reports whether password matches the current credential or one of the stored
+// previous ones, within the policy's history depth. Entries whose pepper has been retired or whose
+// algorithm is unsupported can no longer be checked and are skipped.
+func (s *authService) isRecentPassword(ctx context.Context, password string, current *contracts.PasswordCredential) (bool, error) {
+	if s.policy.HistoryDepth == 0 {
+		return false, nil
+	}
+	recent := []*contracts.PasswordCredential{current}
+	if s.policy.HistoryDepth > 1 {
+		history, err := s.historyRepo.ListRecent(ctx, s.pool, current.UserID, s.policy.HistoryDepth-1)
+		if err != nil {
+			return false, err
+		}
+		recent = append(recent, history...)
+	}
+
+	for _, cred := range recent {
+		ok, err := s.hasher.Verify(ctx, password, cred)
+		if errors.Is(err, ErrUnknownPepper) || errors.Is(err, ErrUnsupportedPasswordHash) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setPassword hashes password with the current parameters and pepper and stores it as the user's
+// credential. When previous is set, this is a password change: the replaced credential is kept in the
+// password history and the change is audited. Otherwise it is a re-hash of the same password.
+func (s *authService) setPassword(ctx context.Context, userID uuid.UUID, password string, previous *contracts.PasswordCredential) error {
+	cred, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	cred.UserID = userID
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := s.credRepo.Update(ctx, tx, cred); err != nil {
+		return err
+	}
+	if previous != nil {
+		// The current credential covers one slot of the history depth
+		keep := max(s.policy.HistoryDepth-1, 0)
+		if keep > 0 {
+			if err := s.historyRepo.Create(ctx, tx, previous); err != nil {
+				return err
+			}
+		}
+		if err := s.historyRepo.Trim(ctx, tx, userID, keep); err != nil {
+			return err
+		}
+		if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventPasswordChanged, userID, nil)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+// BackchannelLogoutSender delivers a logout token to a relying client's back-channel logout URI and
+// returns the response status, or 0 with an error when no response was received.
+type BackchannelLogoutSender interface {
+	Send(ctx context.Context, uri, logoutToken string) (int, error)
+}
+
+// BackchannelLogoutService notifies relying clients when sessions end (OpenID Connect Back-Channel
+// Logout). It is an EventSink: session.revoked queues a notification per client and session, and
+// user.deleted one per client covering every session of the user.
+type BackchannelLogoutService interface {
+	Publish(ctx context.Context, event contracts.DomainEventDTO) error
+	DeliverDue(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// backchannelLogoutService implements BackchannelLogoutService.
+type backchannelLogoutService struct {
+	pool         *sql.DB
+	deliveryRepo repository.BackchannelLogoutRepository
+	signer       LogoutTokenSigner
+	sender       BackchannelLogoutSender
+	logoutURIs   map[string]string // client ID -> back-channel logout URI
+	maxAttempts  int
+	batchSize    int
+}
+
+// NewBackchannelLogoutService creates a new back-channel logout service notifying the clients in
+// logoutURIs with logout tokens signed by signer, which is nil when there are none. Notifications are
+// attempted up to maxAttempts times before being abandoned, and up to batchSize are sent per pass.
+func NewBackchannelLogoutService(pool *sql.DB, deliveryRepo repository.BackchannelLogoutRepository, signer LogoutTokenSigner, sender BackchannelLogoutSender, logoutURIs map[string]string, maxAttempts, batchSize int) BackchannelLogoutService {
+	return &backchannelLogoutService{
+		pool:         pool,
+		deliveryRepo: deliveryRepo,
+		signer:       signer,
+		sender:       sender,
+		logoutURIs:   logoutURIs,
+		maxAttempts:  maxAttempts,
+		batchSize:    batchSize,
+	}
+}
+
+// Publish queues logout notifications for the sessions ended by event. Queueing is idempotent, so an
+// event redelivered by the outbox relay is only sent once per client and session.
+func (s *backchannelLogoutService) Publish(ctx context.Context, event contracts.DomainEventDTO) error {
+	if len(s.logoutURIs) == 0 {
+		return nil
+	}
+
+	var userID uuid.UUID
+	var sessionIDs []*uuid.UUID
+	switch event.Type {
+	case contracts.DomainEventSessionRevoked:
+		var data contracts.SessionRevokedEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return err
+		}
+		userID = data.UserID
+		for i := range data.SessionIDs {
+			sessionIDs = append(sessionIDs, &data.SessionIDs[i])
+		}
+	case contracts.DomainEventUserDeleted:
+		var data contracts.UserDeletedEventData
+		if err := json.Unmarshal(event.Data, &data); err != nil {
+			return err
+		}
+		userID = data.UserID
+		sessionIDs = []*uuid.UUID{nil}
+	default:
+		return nil
+	}
+
+	for clientID := range s.logoutURIs {
+		for _, sessionID := range sessionIDs {
+			if err := s.deliveryRepo.Enqueue(ctx, s.pool, clientID, event.ID, userID, sessionID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeliverDue sends one batch of due notifications and returns how many succeeded. Failures are retried
+// with exponential backoff; after maxAttempts they are abandoned.
+func (s *backchannelLogoutService) DeliverDue(ctx context.Context) (int, error) {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deliveries, err := s.deliveryRepo.ClaimDue(ctx, tx, s.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range deliveries {
+		err := s.send(ctx, d)
+		if err == nil {
+			if err := s.deliveryRepo.Delete(ctx, tx, d.ID); err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		if d.Attempts+1 >= s.maxAttempts {
+			log.Printf("back-channel logout %d to client %s for event %s abandoned: %v\n", d.ID, d.ClientID, d.EventID, err)
+			if err := s.deliveryRepo.Delete(ctx, tx, d.ID); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := s.deliveryRepo.MarkFailed(ctx, tx, d.ID, err.Error(), time.Now().Add(retryBackoff(d.Attempts))); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, tx.Commit()
+}
+
+// send signs a fresh logout token for d and delivers it to the client's logout URI.
+func (s *backchannelLogoutService) send(ctx context.Context, d *contracts.BackchannelLogoutDelivery) error {
+	uri, ok := s.logoutURIs[d.ClientID]
+	if !ok {
+		return fmt.Errorf("no back-channel logout uri configured for client %s", d.ClientID)
+	}
+	logoutToken, err := s.signer.Sign(d.ClientID, d.UserID, d.SessionID)
+	if err != nil {
+		return err
+	}
+	status, err := s.sender.Send(ctx, uri, logoutToken)
+	if err != nil {
+		return err
+	}
+	if status < 200 || status > 299 {
+		return fmt.Errorf("logout uri responded with status %d", status)
+	}
+	return nil
+}
+
+// Run delivers due notifications every interval until ctx is cancelled.
+func (s *backchannelLogoutService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DeliverDue(ctx); err != nil {
+				log.Printf("couldn't deliver back-channel logouts: %v\n", err)
+			}
+		}
+	}
+}
@@ -1,50 +1,241 @@
 package service
 
-import "net/http"
+import (
+	"net/http"
 
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+)
+
+// loginStateCookieName names the cookie binding an in-flight social login to the browser that began it.
+const loginStateCookieName = "oidc_state"
+
+// loginStateCookiePath scopes the login state cookie to the social login endpoints.
+const loginStateCookiePath = "/auth/oidc"
+
+// samlStateCookieName names the cookie binding an in-flight SAML login to the browser that began it.
+const samlStateCookieName = "saml_state"
+
+// samlStateCookiePath scopes the SAML state cookie to the SAML endpoints.
+const samlStateCookiePath = "/auth/saml"
+
+// hostCookiePrefix makes browsers enforce that a cookie is Secure, host-only and scoped to "/".
+const hostCookiePrefix = "__Host-"
+
+// CookieService builds the cookies carrying the refresh token for browser clients. Alongside the
+// HttpOnly refresh cookie it sets a CSRF cookie readable by scripts, which clients echo in the
+// X-CSRF-Token header when they refresh or log out with the refresh cookie (double-submit). In
+// backend-for-frontend mode it builds the opaque session cookie instead.
 type CookieService interface {
-	CreateSetAuthCookie(refreshToken string) *http.Cookie
-	CreateClearAuthCookie() *http.Cookie
+	CreateSetAuthCookies(refreshToken, csrfToken string) []*http.Cookie
+	CreateClearAuthCookies() []*http.Cookie
+	CreateSetSessionCookie(sessionToken string) *http.Cookie
+	CreateClearSessionCookie() *http.Cookie
+	CreateSetLoginStateCookie(state string, maxAge int) *http.Cookie
+	CreateClearLoginStateCookie() *http.Cookie
+	CreateSetSAMLStateCookie(state string, maxAge int) *http.Cookie
+	CreateClearSAMLStateCookie() *http.Cookie
+	RefreshCookieName() string
+	CSRFCookieName() string
+	SessionCookieName() string
+	LoginStateCookieName() string
+	SAMLStateCookieName() string
+}
+
+// CookieProfiles selects the cookie settings for a client, so that e.g. embedded widgets on another
+// site can use partitioned SameSite=None cookies while the main web app keeps strict ones.
+type CookieProfiles interface {
+	// For returns the cookie service for clientID, or the default one for unknown or empty IDs.
+	For(clientID string) CookieService
 }
 
 type cookieService struct {
-	refreshPath string
-	cookieName  string
-	refreshTTL  int
-	mode        http.SameSite
-	secureMode  bool
+	profile           config.CookieProfile
+	legacyRefreshPath string
+	refreshTTL        int
+	sessionTTL        int
 }
 
-func (cs *cookieService) CreateSetAuthCookie(refreshToken string) *http.Cookie {
-	return &http.Cookie{
-		Name:     cs.cookieName,
-		Value:    refreshToken,
-		Path:     cs.refreshPath,
-		MaxAge:   cs.refreshTTL,
-		HttpOnly: true,
-		Secure:   cs.secureMode, // TODO: set based on environment
-		SameSite: cs.mode,
+// CreateSetAuthCookies returns the refresh and CSRF cookies for a new token pair, and expires a
+// refresh cookie left at the legacy path so browsers stop sending a rotated token.
+func (cs *cookieService) CreateSetAuthCookies(refreshToken, csrfToken string) []*http.Cookie {
+	cookies := []*http.Cookie{
+		cs.cookie(cs.RefreshCookieName(), refreshToken, cs.refreshPath(), cs.refreshTTL, true),
+		cs.cookie(cs.CSRFCookieName(), csrfToken, "/", cs.refreshTTL, false), // read by scripts to fill X-CSRF-Token
+	}
+	return append(cookies, cs.clearLegacyCookies()...)
+}
+
+// CreateClearAuthCookies returns cookies expiring the refresh and CSRF cookies.
+func (cs *cookieService) CreateClearAuthCookies() []*http.Cookie {
+	cookies := []*http.Cookie{
+		cs.cookie(cs.RefreshCookieName(), "", cs.refreshPath(), -1, true),
+		cs.cookie(cs.CSRFCookieName(), "", "/", -1, false),
 	}
+	return append(cookies, cs.clearLegacyCookies()...)
+}
+
+// CreateSetSessionCookie returns the session cookie for a new backend-for-frontend session. It is sent
+// on every path so a proxy in front of any API can exchange it for an access token.
+func (cs *cookieService) CreateSetSessionCookie(sessionToken string) *http.Cookie {
+	return cs.cookie(cs.SessionCookieName(), sessionToken, "/", cs.sessionTTL, true)
+}
+
+// CreateClearSessionCookie returns a cookie expiring the session cookie.
+func (cs *cookieService) CreateClearSessionCookie() *http.Cookie {
+	return cs.cookie(cs.SessionCookieName(), "", "/", -1, true)
+}
+
+// CreateSetLoginStateCookie returns the cookie holding a social login's state until the provider
+// redirects back. It is at most SameSite=Lax, as a strict cookie isn't sent on that redirect.
+func (cs *cookieService) CreateSetLoginStateCookie(state string, maxAge int) *http.Cookie {
+	return cs.loginStateCookie(state, maxAge)
+}
+
+// CreateClearLoginStateCookie returns a cookie expiring the login state cookie.
+func (cs *cookieService) CreateClearLoginStateCookie() *http.Cookie {
+	return cs.loginStateCookie("", -1)
+}
+
+// CreateSetSAMLStateCookie returns the cookie holding a SAML login's RelayState until the IdP posts
+// its response. The response is a cross-site POST, so over HTTPS the cookie is SameSite=None.
+func (cs *cookieService) CreateSetSAMLStateCookie(state string, maxAge int) *http.Cookie {
+	return cs.samlStateCookie(state, maxAge)
+}
+
+// CreateClearSAMLStateCookie returns a cookie expiring the SAML state cookie.
+func (cs *cookieService) CreateClearSAMLStateCookie() *http.Cookie {
+	return cs.samlStateCookie("", -1)
+}
+
+// RefreshCookieName returns the name of the refresh token cookie.
+func (cs *cookieService) RefreshCookieName() string {
+	return cs.name(cs.profile.RefreshName)
+}
+
+// CSRFCookieName returns the name of the CSRF cookie.
+func (cs *cookieService) CSRFCookieName() string {
+	return cs.name(cs.profile.CSRFName)
+}
+
+// SessionCookieName returns the name of the session cookie.
+func (cs *cookieService) SessionCookieName() string {
+	return cs.name(cs.profile.SessionName)
 }
 
-func (cs *cookieService) CreateClearAuthCookie() *http.Cookie {
+// LoginStateCookieName returns the name of the social login state cookie.
+func (cs *cookieService) LoginStateCookieName() string {
+	return cs.name(loginStateCookieName)
+}
+
+// SAMLStateCookieName returns the name of the SAML login state cookie.
+func (cs *cookieService) SAMLStateCookieName() string {
+	return cs.name(samlStateCookieName)
+}
+
+// cookie builds a cookie with the profile's attributes. A negative maxAge expires it.
+func (cs *cookieService) cookie(name, value, path string, maxAge int, httpOnly bool) *http.Cookie {
 	return &http.Cookie{
-		Name:     cs.cookieName,
-		Value:    "",
-		Path:     cs.refreshPath,
-		MaxAge:   0,
+		Name:        name,
+		Value:       value,
+		Domain:      cs.profile.Domain,
+		Path:        path,
+		MaxAge:      maxAge,
+		HttpOnly:    httpOnly,
+		Secure:      cs.profile.Secure,
+		SameSite:    cs.profile.SameSite,
+		Partitioned: cs.profile.Partitioned,
+	}
+}
+
+func (cs *cookieService) loginStateCookie(state string, maxAge int) *http.Cookie {
+	path := loginStateCookiePath
+	if cs.profile.HostPrefix {
+		path = "/"
+	}
+	c := cs.cookie(cs.LoginStateCookieName(), state, path, maxAge, true)
+	if c.SameSite == http.SameSiteStrictMode {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	return c
+}
+
+// samlStateCookie builds the SAML state cookie. Browsers only accept SameSite=None on Secure cookies;
+// insecure profiles (local development) fall back to Lax, which works while the IdP is same-site.
+func (cs *cookieService) samlStateCookie(state string, maxAge int) *http.Cookie {
+	path := samlStateCookiePath
+	if cs.profile.HostPrefix {
+		path = "/"
+	}
+	c := cs.cookie(cs.SAMLStateCookieName(), state, path, maxAge, true)
+	c.SameSite = http.SameSiteLaxMode
+	if c.Secure {
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+// clearLegacyCookies expires the host-only, unprefixed refresh cookie once scoped to legacyRefreshPath.
+func (cs *cookieService) clearLegacyCookies() []*http.Cookie {
+	if cs.legacyRefreshPath == "" || cs.legacyRefreshPath == cs.refreshPath() {
+		return nil
+	}
+	return []*http.Cookie{{
+		Name:     cs.profile.RefreshName,
+		Path:     cs.legacyRefreshPath,
+		MaxAge:   -1,
 		HttpOnly: true,
-		Secure:   cs.secureMode,
-		SameSite: cs.mode,
+		Secure:   cs.profile.Secure,
+		SameSite: cs.profile.SameSite,
+	}}
+}
+
+// refreshPath returns the refresh cookie path; __Host- cookies must be scoped to "/".
+func (cs *cookieService) refreshPath() string {
+	if cs.profile.HostPrefix {
+		return "/"
+	}
+	return cs.profile.Path
+}
+
+func (cs *cookieService) name(base string) string {
+	if cs.profile.HostPrefix {
+		return hostCookiePrefix + base
 	}
+	return base
 }
 
-func NewCookieService(refreshPath, cookieName string, refreshTTL int, sameSiteMode http.SameSite, secureMode bool) CookieService {
+// NewCookieService creates a cookie service for a cookie profile. legacyRefreshPath, when set, is a
+// path the refresh cookie used to be scoped to; cookies left there are expired whenever new ones are set.
+func NewCookieService(profile config.CookieProfile, legacyRefreshPath string, refreshTTL, sessionTTL int) CookieService {
 	return &cookieService{
-		refreshPath: refreshPath,
-		cookieName:  cookieName,
-		refreshTTL:  refreshTTL,
-		mode:        sameSiteMode,
-		secureMode:  secureMode,
+		profile:           profile,
+		legacyRefreshPath: legacyRefreshPath,
+		refreshTTL:        refreshTTL,
+		sessionTTL:        sessionTTL,
+	}
+}
+
+type cookieProfiles struct {
+	defaultService CookieService
+	byClient       map[string]CookieService
+}
+
+// For returns the cookie service for clientID, or the default one for unknown or empty IDs.
+func (p *cookieProfiles) For(clientID string) CookieService {
+	if cs, ok := p.byClient[clientID]; ok {
+		return cs
+	}
+	return p.defaultService
+}
+
+// NewCookieProfiles creates cookie services for the default profile and each client's profile.
+func NewCookieProfiles(defaultProfile config.CookieProfile, clientProfiles map[string]config.CookieProfile, legacyRefreshPath string, refreshTTL, sessionTTL int) CookieProfiles {
+	byClient := make(map[string]CookieService, len(clientProfiles))
+	for clientID, profile := range clientProfiles {
+		byClient[clientID] = NewCookieService(profile, legacyRefreshPath, refreshTTL, sessionTTL)
+	}
+	return &cookieProfiles{
+		defaultService: NewCookieService(defaultProfile, legacyRefreshPath, refreshTTL, sessionTTL),
+		byClient:       byClient,
 	}
 }
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReauthenticationRequired = errors.New("re-authentication required")
+	ErrCredentialNotFound       = errors.New("credential not found")
+	ErrLastCredential           = errors.New("cannot remove the only way to sign in")
+	ErrPasswordAlreadySet       = errors.New("a password is already set")
+	ErrAccountsDontShareEmail   = errors.New("the accounts share no email address")
+	ErrInvalidMergeCredentials  = errors.New("invalid credentials for the account to merge")
+)
+
+// Reauthentication is what a user offers to prove they are present before changing how they sign in
+// or deleting their account:
+// their current password, or a session they authenticated in recently.
+type Reauthentication struct {
+	Password string
+	AuthTime time.Time // when the caller's session was authenticated; zero if unknown
+}
+
+// CredentialService manages the ways a user can sign in: a password and identities at external
+// providers. Changes require re-authentication, and a user can never remove their last
+// credential.
+type CredentialService interface {
+	List(ctx context.Context, userID uuid.UUID) ([]*contracts.Credential, error)
+	AddPassword(ctx context.Context, userID uuid.UUID, proof Reauthentication, password string) error
+	LinkIdentity(ctx context.Context, userID uuid.UUID, proof Reauthentication, provider string, allowMerge bool) (string, string, error)
+	Remove(ctx context.Context, userID uuid.UUID, proof Reauthentication, credentialID uuid.UUID) error
+	MergeAccount(ctx context.Context, userID uuid.UUID, proof Reauthentication, username, password string) (uuid.UUID, error)
+}
+
+// credentialService implements CredentialService.
+type credentialService struct {
+	pool             *sql.DB
+	userRepo         repository.UserRepository
+	credentialRepo   repository.CredentialRepository
+	credRepo         repository.PasswordCredentialRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	socialLogin      SocialLoginService
+	hasher           PasswordHasher
+	breached         BreachChecker
+	policy           config.PasswordPolicy
+	reauthMaxAge     time.Duration
+}
+
+// NewCredentialService creates a new credential service. Sessions authenticated within reauthMaxAge
+// count as re-authenticated. socialLogin is nil when no identity providers are configured.
+func NewCredentialService(pool *sql.DB, userRepo repository.UserRepository, credentialRepo repository.CredentialRepository, credRepo repository.PasswordCredentialRepository, refreshTokenRepo repository.RefreshTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, socialLogin SocialLoginService, hasher PasswordHasher, breached BreachChecker, policy config.PasswordPolicy, reauthMaxAge time.Duration) CredentialService {
+	return &credentialService{
+		pool:             pool,
+		userRepo:         userRepo,
+		credentialRepo:   credentialRepo,
+		credRepo:         credRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		socialLogin:      socialLogin,
+		hasher:           hasher,
+		breached:         breached,
+		policy:           policy,
+		reauthMaxAge:     reauthMaxAge,
+	}
+}
+
+// List returns the user's credentials, oldest first.
+func (s *credentialService) List(ctx context.Context, userID uuid.UUID) ([]*contracts.Credential, error) {
+	return s.credentialRepo.ListByUserID(ctx, s.pool, userID)
+}
+
+// AddPassword sets a password for a user who signs in some other way. Users who already have one
+// change it through AuthService.ChangePassword.
+func (s *credentialService) AddPassword(ctx context.Context, userID uuid.UUID, proof Reauthentication, password string) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.reauthenticate(ctx, user.ID, proof); err != nil {
+		return err
+	}
+	if err := screenNewPassword(s.policy, s.breached, password, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	// Hash before opening the transaction so a saturated hasher doesn't hold a connection
+	cred, err := s.hasher.Hash(ctx, password)
+	if err != nil {
+		return err
+	}
+	cred.UserID = user.ID
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	credentials, err := s.credentialRepo.LockByUserID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, c := range credentials {
+		if c.Type == contracts.CredentialTypePassword {
+			return ErrPasswordAlreadySet
+		}
+	}
+	if err := s.credRepo.Create(ctx, tx, cred); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventCredentialAdded, user.ID, map[string]any{"type": contracts.CredentialTypePassword})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LinkIdentity starts a login with provider that links the identity to the user, and returns the
+// provider URL and state as SocialLoginService.BeginLink does.
+func (s *credentialService) LinkIdentity(ctx context.Context, userID uuid.UUID, proof Reauthentication, provider string, allowMerge bool) (string, string, error) {
+	if s.socialLogin == nil {
+		return "", "", ErrUnknownProvider
+	}
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.reauthenticate(ctx, user.ID, proof); err != nil {
+		return "", "", err
+	}
+	return s.socialLogin.BeginLink(ctx, provider, user.ID, allowMerge)
+}
+
+// Remove unlinks one of the user's credentials, unless it is their last.
+func (s *credentialService) Remove(ctx context.Context, userID uuid.UUID, proof Reauthentication, credentialID uuid.UUID) error {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.reauthenticate(ctx, user.ID, proof); err != nil {
+		return err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	// Locked so two concurrent removals can't each see the other's credential and remove both
+	credentials, err := s.credentialRepo.LockByUserID(ctx, tx, user.ID)
+	if err != nil {
+		return err
+	}
+	var target *contracts.Credential
+	for _, c := range credentials {
+		if c.ID == credentialID {
+			target = c
+		}
+	}
+	if target == nil {
+		return ErrCredentialNotFound
+	}
+	if len(credentials) == 1 {
+		return ErrLastCredential
+	}
+
+	if _, err := s.credentialRepo.Delete(ctx, tx, target.ID); err != nil {
+		return err
+	}
+	metadata := map[string]any{"type": target.Type, "credential_id": target.ID}
+	if target.Provider != nil {
+		metadata["provider"] = *target.Provider
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventCredentialRemoved, user.ID, metadata)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeAccount merges another account of the user's into theirs: the one signed in with username
+// and password, which must share an email with the user's account. Its identities move over and it is
+// deleted, as when linking an identity with merging allowed. It returns the ID of the merged account.
+func (s *credentialService) MergeAccount(ctx context.Context, userID uuid.UUID, proof Reauthentication, username, password string) (uuid.UUID, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if err := s.reauthenticate(ctx, user.ID, proof); err != nil {
+		return uuid.Nil, err
+	}
+
+	// Control of the other account is proven by its password, checked before anything about it is revealed
+	source, err := s.userRepo.FindByUsername(ctx, s.pool, username)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if source == nil || source.ID == user.ID {
+		return uuid.Nil, ErrInvalidMergeCredentials
+	}
+	cred, err := s.credRepo.GetByUserID(ctx, s.pool, source.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if cred == nil {
+		return uuid.Nil, ErrInvalidMergeCredentials
+	}
+	ok, err := s.hasher.Verify(ctx, password, cred)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !ok {
+		return uuid.Nil, ErrInvalidMergeCredentials
+	}
+
+	shares, err := s.credentialRepo.SharesEmail(ctx, s.pool, user.ID, source.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !shares {
+		return uuid.Nil, ErrAccountsDontShareEmail
+	}
+	if !source.IsActive() {
+		return uuid.Nil, ErrMergeSourceInactive
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := mergeAccounts(ctx, tx, s.credentialRepo, s.refreshTokenRepo, s.userRepo, s.auditRepo, s.outboxRepo, user, source, map[string]any{
+		"merged_user_id": source.ID,
+		"reason":         "shared_email",
+	}); err != nil {
+		return uuid.Nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return uuid.Nil, err
+	}
+	return source.ID, nil
+}
+
+// reauthenticate checks proof: the user's current password when given, else a session authenticated
+// within reauthMaxAge.
+func (s *credentialService) reauthenticate(ctx context.Context, userID uuid.UUID, proof Reauthentication) error {
+	return checkReauthentication(ctx, s.pool, s.credRepo, s.hasher, s.reauthMaxAge, userID, proof)
+}
+
+// checkReauthentication checks proof against the user's password credential, or that the session
+// was authenticated within maxAge when no password is given.
+func checkReauthentication(ctx context.Context, db *sql.DB, credRepo repository.PasswordCredentialRepository, hasher PasswordHasher, maxAge time.Duration, userID uuid.UUID, proof Reauthentication) error {
+	if proof.Password == "" {
+		if proof.AuthTime.IsZero() || time.Since(proof.AuthTime) > maxAge {
+			return ErrReauthenticationRequired
+		}
+		return nil
+	}
+
+	cred, err := credRepo.GetByUserID(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	if cred == nil {
+		return ErrInvalidCredentials
+	}
+	ok, err := hasher.Verify(ctx, proof.Password, cred)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// activeUser returns the user, who must exist and be active.
+func (s *credentialService) activeUser(ctx context.Context, userID uuid.UUID) (*contracts.User, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	return user, nil
+}
+
+// mergeAccounts moves the linked identities of source into target and deletes source, within tx,
+// recording the merge with metadata. The source's password is dropped with it, and target keeps its
+// role, so merging never grants access target didn't have.
+func mergeAccounts(ctx context.Context, tx *sql.Tx, credentialRepo repository.CredentialRepository, refreshTokenRepo repository.RefreshTokenRepository, userRepo repository.UserRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, target, source *contracts.User, metadata map[string]any) error {
+	if _, err := credentialRepo.ReassignLinked(ctx, tx, source.ID, target.ID); err != nil {
+		return err
+	}
+	if err := revokeSessions(ctx, refreshTokenRepo, outboxRepo, tx, source.ID, contracts.SessionRevokedMerged); err != nil {
+		return err
+	}
+	deleted, err := userRepo.Delete(ctx, tx, source.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+	if err := auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventAccountsMerged, target.ID, metadata)); err != nil {
+		return err
+	}
+	return publishEvent(ctx, outboxRepo, tx, contracts.DomainEventUserMerged, contracts.UserMergedEventData{
+		UserID:       target.ID,
+		MergedUserID: source.ID,
+	})
+}
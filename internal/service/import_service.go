@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+// ImportedUser is a user migrated from another system along with their legacy password hash.
+type ImportedUser struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	PasswordAlgo string `json:"password_algo"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// UserImportService loads users with legacy password hashes. Their hashes are migrated to
+// Argon2id by AuthService.Login on first successful login.
+type UserImportService interface {
+	Import(ctx context.Context, u ImportedUser) (*contracts.UserDTO, error)
+}
+
+type userImportService struct {
+	pool       *sql.DB
+	userRepo   repository.UserRepository
+	credRepo   repository.PasswordCredentialRepository
+	auditRepo  repository.AuthEventRepository
+	outboxRepo repository.OutboxRepository
+}
+
+// NewUserImportService creates a new user import service.
+func NewUserImportService(pool *sql.DB, userRepo repository.UserRepository, credRepo repository.PasswordCredentialRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository) UserImportService {
+	return &userImportService{
+		pool:       pool,
+		userRepo:   userRepo,
+		credRepo:   credRepo,
+		auditRepo:  auditRepo,
+		outboxRepo: outboxRepo,
+	}
+}
+
+// Import creates the user and their legacy password credential in one transaction, recording it
+// and publishing user.registered.
+func (s *userImportService) Import(ctx context.Context, u ImportedUser) (*contracts.UserDTO, error) {
+	if !IsLegacyPasswordAlgo(u.PasswordAlgo) {
+		return nil, ErrUnsupportedPasswordHash
+	}
+	if err := ValidateLegacyHash(u.PasswordAlgo, u.PasswordHash); err != nil {
+		return nil, err
+	}
+	if u.Role == "" {
+		u.Role = "user"
+	}
+
+	existingUsername, _ := s.userRepo.FindByUsername(ctx, s.pool, u.Username)
+	if existingUsername != nil {
+		return nil, ErrUserExists
+	}
+	existingEmail, _ := s.userRepo.FindByEmail(ctx, s.pool, u.Email)
+	if existingEmail != nil {
+		return nil, ErrUserExists
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Create(ctx, tx, u.Username, u.Email, u.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	// Legacy hashes embed their own salt and parameters and are not peppered
+	cred := &contracts.PasswordCredential{
+		UserID:       user.ID,
+		PasswordHash: u.PasswordHash,
+		Algorithm:    u.PasswordAlgo,
+	}
+	if err := s.credRepo.Create(ctx, tx, cred); err != nil {
+		return nil, err
+	}
+
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserImported, &user.ID, map[string]any{
+		"role":          user.Role,
+		"password_algo": u.PasswordAlgo,
+	})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return user.ToDTO(), nil
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+var ErrUnsupportedPasswordHash = errors.New("unsupported password hash")
+
+// Legacy hashes are imported verbatim and carry their own salt and parameters:
+//   - bcrypt:        modular crypt format, e.g. "$2b$12$<salt+hash>"
+//   - pbkdf2-sha256: "pbkdf2_sha256$<iterations>$<salt>$<base64 hash>" (Django format)
+//   - scrypt:        "scrypt$<N>$<r>$<p>$<base64 salt>$<base64 hash>"
+//
+// None of them are peppered. They are replaced with Argon2id on the first successful login.
+
+// IsLegacyPasswordAlgo reports whether algo is an importable legacy algorithm.
+func IsLegacyPasswordAlgo(algo string) bool {
+	switch algo {
+	case contracts.PasswordAlgoBcrypt, contracts.PasswordAlgoPBKDF2SHA256, contracts.PasswordAlgoScrypt:
+		return true
+	}
+	return false
+}
+
+// ValidateLegacyHash checks that hash is well-formed for algo without verifying any password.
+func ValidateLegacyHash(algo, hash string) error {
+	switch algo {
+	case contracts.PasswordAlgoBcrypt:
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedPasswordHash, err)
+		}
+		return nil
+	case contracts.PasswordAlgoPBKDF2SHA256:
+		_, _, _, err := parsePBKDF2Hash(hash)
+		return err
+	case contracts.PasswordAlgoScrypt:
+		_, err := parseScryptHash(hash)
+		return err
+	}
+	return fmt.Errorf("%w: algorithm %q", ErrUnsupportedPasswordHash, algo)
+}
+
+// verifyLegacyPassword checks password against a hash imported from another system.
+func verifyLegacyPassword(algo, password, hash string) (bool, error) {
+	switch algo {
+	case contracts.PasswordAlgoBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+
+	case contracts.PasswordAlgoPBKDF2SHA256:
+		iterations, salt, expected, err := parsePBKDF2Hash(hash)
+		if err != nil {
+			return false, err
+		}
+		derived, err := pbkdf2.Key(sha256.New, password, []byte(salt), iterations, len(expected))
+		if err != nil {
+			return false, err
+		}
+		return subtle.ConstantTimeCompare(derived, expected) == 1, nil
+
+	case contracts.PasswordAlgoScrypt:
+		p, err := parseScryptHash(hash)
+		if err != nil {
+			return false, err
+		}
+		derived, err := scrypt.Key([]byte(password), p.salt, p.n, p.r, p.p, len(p.key))
+		if err != nil {
+			return false, err
+		}
+		return subtle.ConstantTimeCompare(derived, p.key) == 1, nil
+	}
+	return false, fmt.Errorf("%w: algorithm %q", ErrUnsupportedPasswordHash, algo)
+}
+
+func parsePBKDF2Hash(hash string) (int, string, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2_sha256" {
+		return 0, "", nil, fmt.Errorf("%w: malformed pbkdf2_sha256 hash", ErrUnsupportedPasswordHash)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return 0, "", nil, fmt.Errorf("%w: invalid pbkdf2 iterations", ErrUnsupportedPasswordHash)
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return 0, "", nil, fmt.Errorf("%w: invalid pbkdf2 hash encoding", ErrUnsupportedPasswordHash)
+	}
+	return iterations, parts[2], key, nil
+}
+
+type scryptHash struct {
+	n, r, p int
+	salt    []byte
+	key     []byte
+}
+
+func parseScryptHash(hash string) (*scryptHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "scrypt" {
+		return nil, fmt.Errorf("%w: malformed scrypt hash", ErrUnsupportedPasswordHash)
+	}
+	var params [3]int
+	for i, raw := range parts[1:4] {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("%w: invalid scrypt parameter %q", ErrUnsupportedPasswordHash, raw)
+		}
+		params[i] = v
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid scrypt salt encoding", ErrUnsupportedPasswordHash)
+	}
+	key, err := base64.StdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("%w: invalid scrypt hash encoding", ErrUnsupportedPasswordHash)
+	}
+	return &scryptHash{n: params[0], r: params[1], p: params[2], salt: salt, key: key}, nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+)
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		algo     string
+		password string
+		hash     string
+		want     bool
+	}{
+		{
+			name:     "bcrypt",
+			algo:     contracts.PasswordAlgoBcrypt,
+			password: "U*U",
+			hash:     "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+			want:     true,
+		},
+		{
+			name:     "bcrypt wrong password",
+			algo:     contracts.PasswordAlgoBcrypt,
+			password: "U*V",
+			hash:     "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		},
+		{
+			// Django's own test vector
+			name:     "pbkdf2-sha256",
+			algo:     contracts.PasswordAlgoPBKDF2SHA256,
+			password: "lètmein",
+			hash:     "pbkdf2_sha256$30000$seasalt$VrX+V8drCGo68wlvy6rfu8i1d1pfkdeXA4LJkRGJodY=",
+			want:     true,
+		},
+		{
+			// RFC 7914 section 11, with a 64-byte key
+			name:     "pbkdf2-sha256 RFC 7914",
+			algo:     contracts.PasswordAlgoPBKDF2SHA256,
+			password: "passwd",
+			hash:     "pbkdf2_sha256$1$salt$VawEblbjCJ/sFpHCJUS2BflBhSFt3gRl5oudV8INrLxJypzM8Xm2RZkWZLOdd+8xfHG4RbHjC9UJESBB06GXgw==",
+			want:     true,
+		},
+		{
+			name:     "pbkdf2-sha256 wrong password",
+			algo:     contracts.PasswordAlgoPBKDF2SHA256,
+			password: "letmein",
+			hash:     "pbkdf2_sha256$30000$seasalt$VrX+V8drCGo68wlvy6rfu8i1d1pfkdeXA4LJkRGJodY=",
+		},
+		{
+			// RFC 7914 section 12
+			name:     "scrypt",
+			algo:     contracts.PasswordAlgoScrypt,
+			password: "password",
+			hash:     "scrypt$1024$8$16$TmFDbA==$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA==",
+			want:     true,
+		},
+		{
+			name:     "scrypt wrong password",
+			algo:     contracts.PasswordAlgoScrypt,
+			password: "Password",
+			hash:     "scrypt$1024$8$16$TmFDbA==$/bq+HJ00cgB4VucZDQHp/nxq18vII3gw53N2Y0s3MWIurzDZLiKjiG/xCSedmDDaxyevuUqD7m2DYMvfoswGQA==",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateLegacyHash(tt.algo, tt.hash); err != nil {
+				t.Fatalf("ValidateLegacyHash() error = %v", err)
+			}
+			got, err := verifyLegacyPassword(tt.algo, tt.password, tt.hash)
+			if err != nil {
+				t.Fatalf("verifyLegacyPassword() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("verifyLegacyPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMalformedLegacyHashes(t *testing.T) {
+	tests := []struct {
+		name string
+		algo string
+		hash string
+	}{
+		{name: "bcrypt truncated", algo: contracts.PasswordAlgoBcrypt, hash: "$2a$05$CCCC"},
+		{name: "pbkdf2 wrong prefix", algo: contracts.PasswordAlgoPBKDF2SHA256, hash: "pbkdf2_sha1$1$salt$c2FsdA=="},
+		{name: "pbkdf2 zero iterations", algo: contracts.PasswordAlgoPBKDF2SHA256, hash: "pbkdf2_sha256$0$salt$c2FsdA=="},
+		{name: "pbkdf2 bad encoding", algo: contracts.PasswordAlgoPBKDF2SHA256, hash: "pbkdf2_sha256$1$salt$!!!"},
+		{name: "pbkdf2 missing field", algo: contracts.PasswordAlgoPBKDF2SHA256, hash: "pbkdf2_sha256$1$c2FsdA=="},
+		{name: "scrypt missing field", algo: contracts.PasswordAlgoScrypt, hash: "scrypt$1024$8$16$TmFDbA=="},
+		{name: "scrypt negative parameter", algo: contracts.PasswordAlgoScrypt, hash: "scrypt$1024$-8$16$TmFDbA==$c2FsdA=="},
+		{name: "scrypt empty key", algo: contracts.PasswordAlgoScrypt, hash: "scrypt$1024$8$16$TmFDbA==$"},
+		{name: "unknown algorithm", algo: "md5", hash: "5f4dcc3b5aa765d61d8327deb882cf99"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateLegacyHash(tt.algo, tt.hash); err == nil {
+				t.Error("ValidateLegacyHash() accepted a malformed hash")
+			}
+			if _, err := verifyLegacyPassword(tt.algo, "password", tt.hash); err == nil {
+				t.Error("verifyLegacyPassword() accepted a malformed hash")
+			} else if tt.algo != contracts.PasswordAlgoBcrypt && !errors.Is(err, ErrUnsupportedPasswordHash) {
+				t.Errorf("verifyLegacyPassword() error = %v, want ErrUnsupportedPasswordHash", err)
+			}
+		})
+	}
+}
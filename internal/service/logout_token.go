@@ -0,0 +1,128 @@
+package service
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// backchannelLogoutEvent identifies a logout token in its events claim (OpenID Connect Back-Channel Logout 1.0).
+const backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenTTL bounds how long a logout token is accepted; a fresh one is signed for every delivery attempt.
+const logoutTokenTTL = 2 * time.Minute
+
+// logoutTokenClaims are the claims of a back-channel logout token.
+type logoutTokenClaims struct {
+	Events    map[string]struct{} `json:"events"`
+	SessionID string              `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// LogoutTokenSigner signs back-channel logout tokens with an RSA key of their own. Relying clients
+// verify them against the public key, published as a JSON Web Key Set, so they never hold a secret
+// that could mint access tokens.
+type LogoutTokenSigner interface {
+	Sign(clientID string, userID uuid.UUID, sessionID *uuid.UUID) (string, error)
+	PublicKey() *rsa.PublicKey
+	KeyID() string
+}
+
+// logoutTokenSigner implements LogoutTokenSigner.
+type logoutTokenSigner struct {
+	key    *rsa.PrivateKey
+	keyID  string
+	issuer string
+}
+
+// NewLogoutTokenSigner creates a signer issuing logout tokens as issuer. The key ID is the key's
+// JWK thumbprint (RFC 7638), so it changes whenever the key is replaced.
+func NewLogoutTokenSigner(key *rsa.PrivateKey, issuer string) (LogoutTokenSigner, error) {
+	thumbprint, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		Kty: "RSA",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(thumbprint)
+	return &logoutTokenSigner{
+		key:    key,
+		keyID:  base64.RawURLEncoding.EncodeToString(sum[:]),
+		issuer: issuer,
+	}, nil
+}
+
+// LoadLogoutSigningKey reads a PEM RSA private key, in PKCS #1 or PKCS #8 form, for signing logout tokens.
+func LoadLogoutSigningKey(file string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("logout token signing key must be an RSA key")
+	}
+	return key, nil
+}
+
+// Sign creates a back-channel logout token for a relying client. Without a session ID it ends every
+// session of the user.
+func (s *logoutTokenSigner) Sign(clientID string, userID uuid.UUID, sessionID *uuid.UUID) (string, error) {
+	now := time.Now()
+	claims := logoutTokenClaims{
+		Events: map[string]struct{}{backchannelLogoutEvent: {}},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			Issuer:    s.issuer,
+			Audience:  jwt.ClaimStrings{clientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(logoutTokenTTL)),
+			ID:        uuid.New().String(),
+		},
+	}
+	if sessionID != nil {
+		claims.SessionID = sessionID.String()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["typ"] = "logout+jwt"
+	token.Header["kid"] = s.keyID
+	return token.SignedString(s.key)
+}
+
+// PublicKey returns the key relying clients verify logout tokens with.
+func (s *logoutTokenSigner) PublicKey() *rsa.PublicKey {
+	return &s.key.PublicKey
+}
+
+// KeyID returns the kid header of the logout tokens signed.
+func (s *logoutTokenSigner) KeyID() string {
+	return s.keyID
+}
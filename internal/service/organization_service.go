@@ -0,0 +1,656 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+var (
+	ErrOrganizationNotFound         = errors.New("organization not found")
+	ErrOrganizationExists           = errors.New("an organization with this slug already exists")
+	ErrInvalidOrganizationSlug      = errors.New("slug must be 1-63 lowercase letters, digits or dashes, not starting or ending with a dash")
+	ErrInvalidOrganizationName      = errors.New("organization name is required")
+	ErrInvalidMembershipRole        = errors.New("role must be owner, admin, member or viewer")
+	ErrNotOrganizationMember        = errors.New("not a member of the organization")
+	ErrInsufficientOrganizationRole = errors.New("insufficient permissions in the organization")
+	ErrMemberNotFound               = errors.New("member not found")
+	ErrAlreadyMember                = errors.New("already a member of the organization")
+	ErrLastOwner                    = errors.New("an organization must keep at least one owner")
+	ErrOrganizationInviteNotFound   = errors.New("organization invite not found")
+	ErrInvalidOrganizationInvite    = errors.New("invite is invalid, expired, already used or addressed to another email")
+)
+
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// membershipRoleRanks orders membership roles by privilege. Admins manage members and invites; only
+// owners manage owners and delete the organization.
+var membershipRoleRanks = map[string]int{
+	contracts.MembershipRoleViewer: 1,
+	contracts.MembershipRoleMember: 2,
+	contracts.MembershipRoleAdmin:  3,
+	contracts.MembershipRoleOwner:  4,
+}
+
+// OrganizationService manages organizations, their members and invitations. Every method acts on
+// behalf of an authenticated user and checks their role in the organization; organizations they
+// don't belong to are reported as not found.
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, userID uuid.UUID, slug, name string) (*contracts.Organization, error)
+	ListMemberships(ctx context.Context, userID uuid.UUID) ([]*contracts.Membership, error)
+	GetOrganization(ctx context.Context, userID, organizationID uuid.UUID) (*contracts.Organization, *contracts.Membership, error)
+	UpdateOrganization(ctx context.Context, userID, organizationID uuid.UUID, slug, name string) (*contracts.Organization, error)
+	DeleteOrganization(ctx context.Context, userID, organizationID uuid.UUID) error
+	ListMembers(ctx context.Context, userID, organizationID uuid.UUID) ([]*contracts.Membership, error)
+	ChangeMemberRole(ctx context.Context, userID, organizationID, memberID uuid.UUID, role string) (*contracts.Membership, error)
+	RemoveMember(ctx context.Context, userID, organizationID, memberID uuid.UUID) error
+	InviteMember(ctx context.Context, userID, organizationID uuid.UUID, email, role string) (*contracts.OrganizationInvite, bool, error)
+	ListInvites(ctx context.Context, userID, organizationID uuid.UUID) ([]*contracts.OrganizationInvite, error)
+	RevokeInvite(ctx context.Context, userID, organizationID, inviteID uuid.UUID) error
+	AcceptInvite(ctx context.Context, userID uuid.UUID, token string) (*contracts.Membership, error)
+}
+
+// organizationService implements OrganizationService.
+type organizationService struct {
+	pool           *sql.DB
+	userRepo       repository.UserRepository
+	orgRepo        repository.OrganizationRepository
+	membershipRepo repository.MembershipRepository
+	inviteRepo     repository.OrganizationInviteRepository
+	auditRepo      repository.AuthEventRepository
+	mailer         Mailer
+	inviteURL      string
+	inviteTTL      time.Duration
+}
+
+// NewOrganizationService creates a new organization service. Invitations are emailed with a link to
+// inviteURL, the token appended, and expire after inviteTTL.
+func NewOrganizationService(pool *sql.DB, userRepo repository.UserRepository, orgRepo repository.OrganizationRepository, membershipRepo repository.MembershipRepository, inviteRepo repository.OrganizationInviteRepository, auditRepo repository.AuthEventRepository, mailer Mailer, inviteURL string, inviteTTL time.Duration) OrganizationService {
+	return &organizationService{
+		pool:           pool,
+		userRepo:       userRepo,
+		orgRepo:        orgRepo,
+		membershipRepo: membershipRepo,
+		inviteRepo:     inviteRepo,
+		auditRepo:      auditRepo,
+		mailer:         mailer,
+		inviteURL:      inviteURL,
+		inviteTTL:      inviteTTL,
+	}
+}
+
+// CreateOrganization creates an organization with the user as its owner.
+func (s *organizationService) CreateOrganization(ctx context.Context, userID uuid.UUID, slug, name string) (*contracts.Organization, error) {
+	slug, name, err := normaliseOrganization(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.orgRepo.FindBySlug(ctx, s.pool, slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrOrganizationExists
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	org, err := s.orgRepo.Create(ctx, tx, slug, name)
+	if err != nil {
+		// The slug was taken since it was checked
+		return nil, ErrOrganizationExists
+	}
+	if _, err := s.membershipRepo.Create(ctx, tx, org.ID, userID, contracts.MembershipRoleOwner); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventOrganizationCreated, userID, map[string]any{
+		"organization_id": org.ID,
+		"slug":            org.Slug,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListMemberships returns the organizations the user belongs to, with their role in each.
+func (s *organizationService) ListMemberships(ctx context.Context, userID uuid.UUID) ([]*contracts.Membership, error) {
+	memberships, err := s.membershipRepo.ListByUser(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if memberships == nil {
+		memberships = []*contracts.Membership{}
+	}
+	return memberships, nil
+}
+
+// GetOrganization returns an organization the user belongs to, with their membership.
+func (s *organizationService) GetOrganization(ctx context.Context, userID, organizationID uuid.UUID) (*contracts.Organization, *contracts.Membership, error) {
+	membership, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleViewer)
+	if err != nil {
+		return nil, nil, err
+	}
+	org, err := s.orgRepo.FindByID(ctx, s.pool, organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org == nil {
+		return nil, nil, ErrOrganizationNotFound
+	}
+	return org, membership, nil
+}
+
+// UpdateOrganization changes an organization's slug and name. Admins and owners may update it.
+func (s *organizationService) UpdateOrganization(ctx context.Context, userID, organizationID uuid.UUID, slug, name string) (*contracts.Organization, error) {
+	if _, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleAdmin); err != nil {
+		return nil, err
+	}
+	slug, name, err := normaliseOrganization(slug, name)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.orgRepo.FindBySlug(ctx, s.pool, slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ID != organizationID {
+		return nil, ErrOrganizationExists
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	org, err := s.orgRepo.Update(ctx, tx, organizationID, slug, name)
+	if err != nil {
+		return nil, ErrOrganizationExists
+	}
+	if org == nil {
+		return nil, ErrOrganizationNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventOrganizationUpdated, userID, map[string]any{
+		"organization_id": org.ID,
+		"slug":            org.Slug,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// DeleteOrganization deletes an organization with its memberships and invites. Only owners may
+// delete it; sessions acting in it carry on without an organization.
+func (s *organizationService) DeleteOrganization(ctx context.Context, userID, organizationID uuid.UUID) error {
+	if _, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleOwner); err != nil {
+		return err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.orgRepo.Delete(ctx, tx, organizationID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrOrganizationNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventOrganizationDeleted, userID, map[string]any{
+		"organization_id": organizationID,
+	})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListMembers returns an organization's members. Any member may list them.
+func (s *organizationService) ListMembers(ctx context.Context, userID, organizationID uuid.UUID) ([]*contracts.Membership, error) {
+	if _, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleViewer); err != nil {
+		return nil, err
+	}
+	members, err := s.membershipRepo.ListByOrganization(ctx, s.pool, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if members == nil {
+		members = []*contracts.Membership{}
+	}
+	return members, nil
+}
+
+// ChangeMemberRole changes a member's role. Admins may change the roles of non-owners to anything
+// but owner; only owners may make or unmake owners, and the last owner can't be demoted.
+func (s *organizationService) ChangeMemberRole(ctx context.Context, userID, organizationID, memberID uuid.UUID, role string) (*contracts.Membership, error) {
+	if _, ok := membershipRoleRanks[role]; !ok {
+		return nil, ErrInvalidMembershipRole
+	}
+	actor, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	member, err := s.membershipRepo.Find(ctx, s.pool, organizationID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+	if (member.Role == contracts.MembershipRoleOwner || role == contracts.MembershipRoleOwner) && actor.Role != contracts.MembershipRoleOwner {
+		return nil, ErrInsufficientOrganizationRole
+	}
+	if member.Role == role {
+		return member, nil
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if member.Role == contracts.MembershipRoleOwner {
+		if err := s.keepAnOwner(ctx, tx, organizationID, memberID); err != nil {
+			return nil, err
+		}
+	}
+	updated, err := s.membershipRepo.UpdateRole(ctx, tx, organizationID, memberID, role)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, ErrMemberNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventMemberRoleChanged, &memberID, map[string]any{
+		"organization_id": organizationID,
+		"old_role":        member.Role,
+		"new_role":        role,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// RemoveMember removes a user from an organization. Members may leave by removing themselves; admins
+// may remove non-owners and owners anyone, except the last owner.
+func (s *organizationService) RemoveMember(ctx context.Context, userID, organizationID, memberID uuid.UUID) error {
+	minimum := contracts.MembershipRoleAdmin
+	if userID == memberID {
+		minimum = contracts.MembershipRoleViewer
+	}
+	actor, err := s.requireRole(ctx, userID, organizationID, minimum)
+	if err != nil {
+		return err
+	}
+	member, err := s.membershipRepo.Find(ctx, s.pool, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrMemberNotFound
+	}
+	if member.Role == contracts.MembershipRoleOwner && actor.Role != contracts.MembershipRoleOwner {
+		return ErrInsufficientOrganizationRole
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if member.Role == contracts.MembershipRoleOwner {
+		if err := s.keepAnOwner(ctx, tx, organizationID, memberID); err != nil {
+			return err
+		}
+	}
+	removed, err := s.membershipRepo.Delete(ctx, tx, organizationID, memberID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrMemberNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventMemberRemoved, &memberID, map[string]any{
+		"organization_id": organizationID,
+		"role":            member.Role,
+	})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InviteMember invites whoever holds email to join an organization with role, emailing them a
+// single-use token. Admins may invite with any role but owner, which only owners may grant. It reports
+// whether the email was sent; a failed send doesn't undo the invite.
+func (s *organizationService) InviteMember(ctx context.Context, userID, organizationID uuid.UUID, email, role string) (*contracts.OrganizationInvite, bool, error) {
+	if _, ok := membershipRoleRanks[role]; !ok {
+		return nil, false, ErrInvalidMembershipRole
+	}
+	actor, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleAdmin)
+	if err != nil {
+		return nil, false, err
+	}
+	if role == contracts.MembershipRoleOwner && actor.Role != contracts.MembershipRoleOwner {
+		return nil, false, ErrInsufficientOrganizationRole
+	}
+	org, err := s.orgRepo.FindByID(ctx, s.pool, organizationID)
+	if err != nil {
+		return nil, false, err
+	}
+	if org == nil {
+		return nil, false, ErrOrganizationNotFound
+	}
+
+	// Inviting someone who is already a member would only be refused when they accept
+	email = strings.TrimSpace(email)
+	invitee, err := s.userRepo.FindByEmail(ctx, s.pool, email)
+	if err != nil {
+		return nil, false, err
+	}
+	if invitee != nil {
+		member, err := s.membershipRepo.Find(ctx, s.pool, organizationID, invitee.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		if member != nil {
+			return nil, false, ErrAlreadyMember
+		}
+	}
+
+	token, err := generateInviteToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	invite, err := s.inviteRepo.Create(ctx, tx, organizationID, email, role, hashInviteToken(token), &userID, time.Now().Add(s.inviteTTL))
+	if err != nil {
+		return nil, false, err
+	}
+	var subjectID *uuid.UUID
+	if invitee != nil {
+		subjectID = &invitee.ID
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventMemberInvited, subjectID, map[string]any{
+		"organization_id": organizationID,
+		"invite_id":       invite.ID,
+		"role":            role,
+	})); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	if err := s.sendInvite(ctx, org, invite, token); err != nil {
+		log.Printf("couldn't send invite %s to organization %s: %v\n", invite.ID, organizationID, err)
+		return invite, false, nil
+	}
+	return invite, true, nil
+}
+
+// ListInvites returns an organization's pending invites. Admins and owners may list them.
+func (s *organizationService) ListInvites(ctx context.Context, userID, organizationID uuid.UUID) ([]*contracts.OrganizationInvite, error) {
+	if _, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleAdmin); err != nil {
+		return nil, err
+	}
+	invites, err := s.inviteRepo.ListPending(ctx, s.pool, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if invites == nil {
+		invites = []*contracts.OrganizationInvite{}
+	}
+	return invites, nil
+}
+
+// RevokeInvite revokes one of an organization's invites. Admins and owners may revoke them.
+func (s *organizationService) RevokeInvite(ctx context.Context, userID, organizationID, inviteID uuid.UUID) error {
+	if _, err := s.requireRole(ctx, userID, organizationID, contracts.MembershipRoleAdmin); err != nil {
+		return err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.inviteRepo.Delete(ctx, tx, organizationID, inviteID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrOrganizationInviteNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventMemberInviteRevoked, userID, map[string]any{
+		"organization_id": organizationID,
+		"invite_id":       inviteID,
+	})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AcceptInvite redeems an invite, making the user a member with the invited role. The invite must be
+// addressed to the user's email.
+func (s *organizationService) AcceptInvite(ctx context.Context, userID uuid.UUID, token string) (*contracts.Membership, error) {
+	invite, err := s.inviteRepo.FindByHash(ctx, s.pool, hashInviteToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil || invite.AcceptedAt != nil || time.Now().After(invite.ExpiresAt) {
+		return nil, ErrInvalidOrganizationInvite
+	}
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	if !strings.EqualFold(user.Email, invite.Email) {
+		return nil, ErrInvalidOrganizationInvite
+	}
+	existing, err := s.membershipRepo.Find(ctx, s.pool, invite.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrAlreadyMember
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	// Marking the invite first makes concurrent redemptions of the same token fail
+	accepted, err := s.inviteRepo.MarkAccepted(ctx, tx, invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !accepted {
+		return nil, ErrInvalidOrganizationInvite
+	}
+	membership, err := s.membershipRepo.Create(ctx, tx, invite.OrganizationID, userID, invite.Role)
+	if repository.IsUniqueViolation(err) {
+		// The user joined since it was checked
+		return nil, ErrAlreadyMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventMemberJoined, userID, map[string]any{
+		"organization_id": invite.OrganizationID,
+		"invite_id":       invite.ID,
+		"role":            invite.Role,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// requireRole returns the user's membership of an organization if their role is at least minimum.
+// Non-members get ErrOrganizationNotFound so organizations aren't disclosed to outsiders.
+func (s *organizationService) requireRole(ctx context.Context, userID, organizationID uuid.UUID, minimum string) (*contracts.Membership, error) {
+	return requireMembershipRole(ctx, s.membershipRepo, s.pool, userID, organizationID, minimum)
+}
+
+// requireMembershipRole is organizationService.requireRole for services that act within
+// organizations.
+func requireMembershipRole(ctx context.Context, membershipRepo repository.MembershipRepository, db *sql.DB, userID, organizationID uuid.UUID, minimum string) (*contracts.Membership, error) {
+	membership, err := membershipRepo.Find(ctx, db, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrOrganizationNotFound
+	}
+	if membershipRoleRanks[membership.Role] < membershipRoleRanks[minimum] {
+		return nil, ErrInsufficientOrganizationRole
+	}
+	return membership, nil
+}
+
+// keepAnOwner fails with ErrLastOwner if ownerID is the organization's only owner, locking the owners
+// until tx ends so concurrent demotions can't both pass.
+func (s *organizationService) keepAnOwner(ctx context.Context, tx *sql.Tx, organizationID, ownerID uuid.UUID) error {
+	owners, err := s.membershipRepo.LockOwners(ctx, tx, organizationID)
+	if err != nil {
+		return err
+	}
+	for _, id := range owners {
+		if id != ownerID {
+			return nil
+		}
+	}
+	return ErrLastOwner
+}
+
+// sendInvite emails the invitee a link, or the bare token when no ORGANIZATION_INVITE_URL is configured.
+func (s *organizationService) sendInvite(ctx context.Context, org *contracts.Organization, invite *contracts.OrganizationInvite, token string) error {
+	action := "Sign in and use this invitation code to join: " + token
+	if s.inviteURL != "" {
+		action = "Sign in and join here: " + s.inviteURL + token
+	}
+	body := fmt.Sprintf("Hello,\n\nYou've been invited to join %s with the %s role.\n%s\n\nThis invitation expires on %s.\n",
+		org.Name, invite.Role, action, invite.ExpiresAt.UTC().Format(time.RFC1123))
+	return s.mailer.Send(ctx, invite.Email, "You've been invited to join "+org.Name, body)
+}
+
+// normaliseOrganization lowercases and validates a slug and trims a name.
+func normaliseOrganization(slug, name string) (string, string, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !organizationSlugPattern.MatchString(slug) {
+		return "", "", ErrInvalidOrganizationSlug
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", "", ErrInvalidOrganizationName
+	}
+	return slug, name, nil
+}
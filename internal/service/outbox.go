@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+// EventSource identifies this service in published domain events.
+const EventSource = "bids-auth-service"
+
+// domainEventVersions holds the current schema version of each domain event type.
+var domainEventVersions = map[string]int{
+	contracts.DomainEventUserRegistered:  1,
+	contracts.DomainEventUserRoleChanged: 1,
+	contracts.DomainEventUserDeleted:     1,
+	contracts.DomainEventSessionRevoked:  1,
+	contracts.DomainEventUserMerged:      1,
+}
+
+// maxRetryBackoff caps the delay between delivery attempts of a failing event or webhook.
+const maxRetryBackoff = time.Hour
+
+// EventSink delivers domain events to other services. Publish must only return nil once the event
+// has been accepted; the relay retries it otherwise.
+type EventSink interface {
+	Publish(ctx context.Context, event contracts.DomainEventDTO) error
+}
+
+// publishEvent stores a domain event in the outbox as part of tx.
+func publishEvent(ctx context.Context, outboxRepo repository.OutboxRepository, tx *sql.Tx, eventType string, data any) error {
+	return outboxRepo.Create(ctx, tx, &contracts.DomainEvent{
+		ID:         uuid.New(),
+		Type:       eventType,
+		Version:    domainEventVersions[eventType],
+		Data:       data,
+		OccurredAt: time.Now().UTC(),
+	})
+}
+
+// revokeSessions revokes every refresh token of a user and, if any were active, publishes
+// session.revoked as part of tx.
+func revokeSessions(ctx context.Context, refreshTokenRepo repository.RefreshTokenRepository, outboxRepo repository.OutboxRepository, tx *sql.Tx, userID uuid.UUID, reason string) error {
+	sessionIDs, err := refreshTokenRepo.RevokeAllForUser(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	return publishEvent(ctx, outboxRepo, tx, contracts.DomainEventSessionRevoked, contracts.SessionRevokedEventData{
+		UserID:     userID,
+		SessionIDs: sessionIDs,
+		Reason:     reason,
+	})
+}
+
+// OutboxRelay delivers outbox events to an EventSink, at least once.
+type OutboxRelay interface {
+	RelayPending(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// outboxRelay implements OutboxRelay.
+type outboxRelay struct {
+	pool       *sql.DB
+	outboxRepo repository.OutboxRepository
+	sink       EventSink
+	batchSize  int
+	lease      time.Duration
+	retention  time.Duration
+}
+
+// NewOutboxRelay creates a relay that delivers up to batchSize events per pass and deletes delivered
+// events after retention. Claimed events are left to other relays for lease, which should cover
+// publishing a whole batch; events still unmarked after it, e.g. because the relay died, are
+// delivered again.
+func NewOutboxRelay(pool *sql.DB, outboxRepo repository.OutboxRepository, sink EventSink, batchSize int, lease, retention time.Duration) OutboxRelay {
+	return &outboxRelay{
+		pool:       pool,
+		outboxRepo: outboxRepo,
+		sink:       sink,
+		batchSize:  batchSize,
+		lease:      lease,
+		retention:  retention,
+	}
+}
+
+// RelayPending delivers one batch of due events and returns how many were delivered. Failed events
+// are retried with exponential backoff. Events are claimed, published and then marked in separate
+// statements, so no transaction or row lock is held while the sink is called; several relays can
+// run concurrently, each claiming distinct rows.
+func (r *outboxRelay) RelayPending(ctx context.Context) (int, error) {
+	events, err := r.outboxRepo.ClaimPending(ctx, r.pool, r.batchSize, time.Now().Add(r.lease))
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, e := range events {
+		err := r.sink.Publish(ctx, contracts.DomainEventDTO{
+			ID:         e.EventID,
+			Type:       e.Type,
+			Version:    e.Version,
+			Source:     EventSource,
+			OccurredAt: e.OccurredAt,
+			Data:       e.Data,
+		})
+		if err != nil {
+			log.Printf("couldn't publish %s event %s: %v\n", e.Type, e.EventID, err)
+			if err := r.outboxRepo.MarkFailed(ctx, r.pool, e.ID, err.Error(), time.Now().Add(retryBackoff(e.Attempts))); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := r.outboxRepo.MarkPublished(ctx, r.pool, e.ID); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+	return delivered, nil
+}
+
+// Run relays pending events every interval, and prunes delivered ones, until ctx is cancelled.
+func (r *outboxRelay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.RelayPending(ctx); err != nil {
+				log.Printf("couldn't relay outbox events: %v\n", err)
+			}
+			if _, err := r.outboxRepo.DeletePublished(ctx, r.pool, time.Now().Add(-r.retention)); err != nil {
+				log.Printf("couldn't prune outbox events: %v\n", err)
+			}
+		}
+	}
+}
+
+// retryBackoff returns the delay before retrying a delivery that has failed attempts times before.
+func retryBackoff(attempts int) time.Duration {
+	if attempts >= 12 {
+		return maxRetryBackoff
+	}
+	return min(time.Second<<attempts, maxRetryBackoff)
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPasskeyVerification = errors.New("passkey verification failed")
+	ErrPasskeyCloned       = errors.New("the passkey's signature counter didn't increase; the authenticator may have been cloned")
+	ErrPasskeyExists       = errors.New("passkey is already registered")
+)
+
+// PasskeyRelyingParty runs WebAuthn ceremonies. Options are sent to the browser as JSON, and the
+// session data is kept server-side until the authenticator's response arrives.
+type PasskeyRelyingParty interface {
+	// BeginRegistration returns the options for creating a passkey for user, excluding the passkeys they have, and the session data.
+	BeginRegistration(user *contracts.User, passkeys []*contracts.Passkey) ([]byte, []byte, error)
+	// FinishRegistration verifies the authenticator's response to a registration and returns the passkey it created.
+	FinishRegistration(user *contracts.User, passkeys []*contracts.Passkey, session, response []byte) (*contracts.Passkey, error)
+	// BeginLogin returns the options for signing in with any passkey, and the session data.
+	BeginLogin() ([]byte, []byte, error)
+	// FinishLogin verifies an assertion against the passkey lookup finds by its credential ID, and returns that passkey with the counter and flags the authenticator reported.
+	FinishLogin(session, response []byte, lookup func(webauthnID []byte) (*contracts.Passkey, error)) (*contracts.Passkey, error)
+}
+
+// PasskeyLoginResult is the outcome of a completed passkey sign-in.
+type PasskeyLoginResult struct {
+	User           *contracts.UserDTO
+	OrganizationID *uuid.UUID // the organization selected when the sign-in started, if any
+}
+
+// PasskeyService registers passkeys as credentials and signs users in with them. Registering one
+// requires re-authentication like any other change to how a user signs in; signing in with one needs
+// nothing else, since the authenticator verifies the user.
+type PasskeyService interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID, proof Reauthentication) ([]byte, string, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, state string, response []byte) (*contracts.Passkey, error)
+	BeginLogin(ctx context.Context, organizationID *uuid.UUID) ([]byte, string, error)
+	FinishLogin(ctx context.Context, state string, response []byte) (*PasskeyLoginResult, error)
+}
+
+// passkeyService implements PasskeyService.
+type passkeyService struct {
+	pool         *sql.DB
+	rp           PasskeyRelyingParty
+	userRepo     repository.UserRepository
+	passkeyRepo  repository.PasskeyRepository
+	ceremonyRepo repository.PasskeyCeremonyRepository
+	credRepo     repository.PasswordCredentialRepository
+	auditRepo    repository.AuthEventRepository
+	hasher       PasswordHasher
+	ceremonyTTL  time.Duration
+	reauthMaxAge time.Duration
+}
+
+// NewPasskeyService creates a new passkey service. Ceremonies must complete within ceremonyTTL, and
+// sessions authenticated within reauthMaxAge count as re-authenticated.
+func NewPasskeyService(pool *sql.DB, rp PasskeyRelyingParty, userRepo repository.UserRepository, passkeyRepo repository.PasskeyRepository, ceremonyRepo repository.PasskeyCeremonyRepository, credRepo repository.PasswordCredentialRepository, auditRepo repository.AuthEventRepository, hasher PasswordHasher, ceremonyTTL, reauthMaxAge time.Duration) PasskeyService {
+	return &passkeyService{
+		pool:         pool,
+		rp:           rp,
+		userRepo:     userRepo,
+		passkeyRepo:  passkeyRepo,
+		ceremonyRepo: ceremonyRepo,
+		credRepo:     credRepo,
+		auditRepo:    auditRepo,
+		hasher:       hasher,
+		ceremonyTTL:  ceremonyTTL,
+		reauthMaxAge: reauthMaxAge,
+	}
+}
+
+// BeginRegistration starts registering a passkey for the user once they have re-authenticated, and
+// returns the options for the browser and the state identifying the ceremony.
+func (s *passkeyService) BeginRegistration(ctx context.Context, userID uuid.UUID, proof Reauthentication) ([]byte, string, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkReauthentication(ctx, s.pool, s.credRepo, s.hasher, s.reauthMaxAge, user.ID, proof); err != nil {
+		return nil, "", err
+	}
+
+	passkeys, err := s.passkeyRepo.ListByUserID(ctx, s.pool, user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	options, session, err := s.rp.BeginRegistration(user, passkeys)
+	if err != nil {
+		return nil, "", err
+	}
+	state, err := s.storeCeremony(ctx, &contracts.PasskeyCeremony{UserID: &user.ID, Session: session})
+	if err != nil {
+		return nil, "", err
+	}
+	return options, state, nil
+}
+
+// FinishRegistration verifies the authenticator's response to the registration started with state and
+// adds the passkey it created to the user's credentials.
+func (s *passkeyService) FinishRegistration(ctx context.Context, userID uuid.UUID, state string, response []byte) (*contracts.Passkey, error) {
+	user, err := s.activeUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	ceremony, err := s.ceremonyRepo.Consume(ctx, s.pool, hashLoginSecret(state), &user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	passkeys, err := s.passkeyRepo.ListByUserID(ctx, s.pool, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	passkey, err := s.rp.FinishRegistration(user, passkeys, ceremony.Session, response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	passkey, err = s.passkeyRepo.Create(ctx, tx, passkey)
+	if repository.IsUniqueViolation(err) {
+		return nil, ErrPasskeyExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventCredentialAdded, user.ID, map[string]any{
+		"type":          contracts.CredentialTypePasskey,
+		"credential_id": passkey.ID,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return passkey, nil
+}
+
+// BeginLogin starts a passkey sign-in, optionally selecting the organization the session acts in, and
+// returns the options for the browser and the state identifying the ceremony.
+func (s *passkeyService) BeginLogin(ctx context.Context, organizationID *uuid.UUID) ([]byte, string, error) {
+	options, session, err := s.rp.BeginLogin()
+	if err != nil {
+		return nil, "", err
+	}
+	state, err := s.storeCeremony(ctx, &contracts.PasskeyCeremony{OrganizationID: organizationID, Session: session})
+	if err != nil {
+		return nil, "", err
+	}
+	return options, state, nil
+}
+
+// FinishLogin verifies the assertion answering the sign-in started with state and returns the user
+// whose passkey made it. An authenticator whose signature counter didn't increase is refused as
+// possibly cloned. Like a fresh IdP login, signing in restores an account pending deletion.
+func (s *passkeyService) FinishLogin(ctx context.Context, state string, response []byte) (*PasskeyLoginResult, error) {
+	ceremony, err := s.ceremonyRepo.Consume(ctx, s.pool, hashLoginSecret(state), nil)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	var stored *contracts.Passkey
+	asserted, err := s.rp.FinishLogin(ceremony.Session, response, func(webauthnID []byte) (*contracts.Passkey, error) {
+		passkey, err := s.passkeyRepo.FindByWebAuthnID(ctx, s.pool, webauthnID)
+		stored = passkey
+		return passkey, err
+	})
+	if err != nil {
+		var subjectID *uuid.UUID
+		if stored != nil {
+			subjectID = &stored.UserID
+		}
+		s.recordLoginFailure(ctx, subjectID, "invalid_assertion")
+		return nil, fmt.Errorf("%w: %v", ErrPasskeyVerification, err)
+	}
+	if (asserted.SignCount != 0 || stored.SignCount != 0) && asserted.SignCount <= stored.SignCount {
+		s.recordLoginFailure(ctx, &stored.UserID, "clone_warning")
+		return nil, ErrPasskeyCloned
+	}
+
+	user, err := s.userRepo.FindByID(ctx, s.pool, asserted.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Status == contracts.UserStatusPendingDeletion {
+		if user, err = cancelDeletion(ctx, s.pool, s.userRepo, s.auditRepo, user.ID, map[string]any{"passkey": asserted.ID}); err != nil {
+			return nil, err
+		}
+	}
+	if !user.IsActive() {
+		s.recordLoginFailure(ctx, &user.ID, "account_"+user.Status)
+		return nil, ErrAccountInactive
+	}
+
+	if err := s.passkeyRepo.RecordUse(ctx, s.pool, asserted.ID, asserted.SignCount, asserted.Flags, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	recordStandalone(ctx, s.auditRepo, s.pool, selfEvent(ctx, contracts.AuthEventLoginSucceeded, user.ID, map[string]any{"passkey": asserted.ID}))
+	return &PasskeyLoginResult{User: user.ToDTO(), OrganizationID: ceremony.OrganizationID}, nil
+}
+
+// storeCeremony keeps ceremony until the ceremony TTL passes and returns the state identifying it;
+// only the state's hash is stored.
+func (s *passkeyService) storeCeremony(ctx context.Context, ceremony *contracts.PasskeyCeremony) (string, error) {
+	state, err := generateLoginSecret()
+	if err != nil {
+		return "", err
+	}
+	// Abandoned ceremonies are swept here rather than by a worker; failing to do so mustn't block the next
+	if err := s.ceremonyRepo.DeleteExpired(ctx, s.pool); err != nil {
+		log.Printf("couldn't delete expired passkey ceremonies: %v\n", err)
+	}
+	ceremony.ExpiresAt = time.Now().UTC().Add(s.ceremonyTTL)
+	if err := s.ceremonyRepo.Create(ctx, s.pool, hashLoginSecret(state), ceremony); err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// activeUser returns the user, who must exist and be active.
+func (s *passkeyService) activeUser(ctx context.Context, userID uuid.UUID) (*contracts.User, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	return user, nil
+}
+
+// recordLoginFailure records a failed passkey sign-in; subjectID is nil when no passkey matched.
+func (s *passkeyService) recordLoginFailure(ctx context.Context, subjectID *uuid.UUID, reason string) {
+	recordStandalone(ctx, s.auditRepo, s.pool, authEvent(ctx, contracts.AuthEventLoginFailed, subjectID, map[string]any{"passkey": true, "reason": reason}))
+}
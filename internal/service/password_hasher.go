@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-util/passwords"
+)
+
+var (
+	ErrHasherSaturated = errors.New("password hashing capacity exhausted")
+	ErrUnknownPepper   = errors.New("password pepper version not configured")
+)
+
+// PasswordHasher hashes and verifies passwords with bounded concurrency, so bursts of
+// logins cannot pin every CPU and starve cheaper endpoints.
+type PasswordHasher interface {
+	// Hash hashes password with the current parameters and pepper. The returned credential has no UserID.
+	Hash(ctx context.Context, password string) (*contracts.PasswordCredential, error)
+	// Verify checks password against a stored credential using the parameters and pepper it was hashed with.
+	Verify(ctx context.Context, password string, cred *contracts.PasswordCredential) (bool, error)
+	// NeedsRehash reports whether cred was produced with anything other than the current parameters and pepper.
+	NeedsRehash(cred *contracts.PasswordCredential) bool
+	// CurrentPepperID returns the pepper version new hashes are produced with.
+	CurrentPepperID() int
+	// PepperIDs returns every configured pepper version.
+	PepperIDs() []int
+	Stats() HasherStats
+}
+
+// HasherStats is a snapshot of the hasher's queue and timing metrics.
+type HasherStats struct {
+	Workers           int     `json:"workers"`
+	QueueDepth        int     `json:"queue_depth"`
+	InFlight          int     `json:"in_flight"`
+	Queued            int     `json:"queued"`
+	Completed         int64   `json:"completed"`
+	Rejected          int64   `json:"rejected"`
+	QueueWaitAvgMs    float64 `json:"queue_wait_avg_ms"`
+	QueueWaitMaxMs    float64 `json:"queue_wait_max_ms"`
+	HashDurationAvgMs float64 `json:"hash_duration_avg_ms"`
+	HashDurationMaxMs float64 `json:"hash_duration_max_ms"`
+}
+
+type passwordHasher struct {
+	peppers         map[int]string
+	currentPepperID int
+	params          passwords.Params
+	slots           chan struct{}
+	queueDepth      int
+	queueTimeout    time.Duration
+
+	mu           sync.Mutex
+	queued       int
+	completed    int64
+	rejected     int64
+	queueWaitSum time.Duration
+	queueWaitMax time.Duration
+	hashTimeSum  time.Duration
+	hashTimeMax  time.Duration
+}
+
+// NewPasswordHasher creates a hasher running at most workers hashes at once, with at most
+// queueDepth callers waiting up to queueTimeout for a free worker. peppers maps pepper
+// versions to values; new hashes use currentPepperID, older versions are kept for verification.
+func NewPasswordHasher(peppers map[int]string, currentPepperID int, params passwords.Params, workers, queueDepth int, queueTimeout time.Duration) PasswordHasher {
+	return &passwordHasher{
+		peppers:         peppers,
+		currentPepperID: currentPepperID,
+		params:          params,
+		slots:           make(chan struct{}, workers),
+		queueDepth:      queueDepth,
+		queueTimeout:    queueTimeout,
+	}
+}
+
+func (h *passwordHasher) Hash(ctx context.Context, password string) (*contracts.PasswordCredential, error) {
+	params := hashParamsFromPasswords(h.params)
+	pepperID := h.currentPepperID
+	cred := &contracts.PasswordCredential{
+		Algorithm: contracts.PasswordAlgoArgon2id,
+		Params:    &params,
+		PepperID:  &pepperID,
+	}
+	err := h.run(ctx, func() error {
+		var err error
+		cred.PasswordSalt, cred.PasswordHash, err = passwords.HashPassword(password, h.peppers[pepperID], h.params)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, nil
+}
+
+func (h *passwordHasher) Verify(ctx context.Context, password string, cred *contracts.PasswordCredential) (bool, error) {
+	var ok bool
+	if IsLegacyPasswordAlgo(cred.Algorithm) {
+		err := h.run(ctx, func() error {
+			var err error
+			ok, err = verifyLegacyPassword(cred.Algorithm, password, cred.PasswordHash)
+			return err
+		})
+		return ok, err
+	}
+	if cred.Algorithm != contracts.PasswordAlgoArgon2id {
+		return false, fmt.Errorf("%w: algorithm %q", ErrUnsupportedPasswordHash, cred.Algorithm)
+	}
+	// Migration 0006 backfilled the parameters of hashes that predate tracking; fall back to the
+	// current defaults for any written without them since
+	params := h.params
+	if cred.Params != nil {
+		params = passwordsParams(*cred.Params)
+	}
+	if cred.PepperID == nil {
+		return false, ErrUnknownPepper
+	}
+	pepper, found := h.peppers[*cred.PepperID]
+	if !found {
+		return false, fmt.Errorf("%w: version %d", ErrUnknownPepper, *cred.PepperID)
+	}
+
+	err := h.run(ctx, func() error {
+		var err error
+		ok, err = passwords.VerifyPassword(password, pepper, cred.PasswordSalt, cred.PasswordHash, params)
+		return err
+	})
+	return ok, err
+}
+
+func (h *passwordHasher) NeedsRehash(cred *contracts.PasswordCredential) bool {
+	return cred.Algorithm != contracts.PasswordAlgoArgon2id ||
+		cred.Params == nil ||
+		*cred.Params != hashParamsFromPasswords(h.params) ||
+		cred.PepperID == nil ||
+		*cred.PepperID != h.currentPepperID
+}
+
+func (h *passwordHasher) CurrentPepperID() int {
+	return h.currentPepperID
+}
+
+func (h *passwordHasher) PepperIDs() []int {
+	ids := make([]int, 0, len(h.peppers))
+	for id := range h.peppers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// run waits for a free worker slot, rejecting the call if the queue is full or the wait
+// exceeds the queue timeout, then executes fn and records timings.
+func (h *passwordHasher) run(ctx context.Context, fn func() error) error {
+	h.mu.Lock()
+	if h.queued >= h.queueDepth {
+		h.rejected++
+		h.mu.Unlock()
+		return ErrHasherSaturated
+	}
+	h.queued++
+	h.mu.Unlock()
+
+	enqueuedAt := time.Now()
+	timer := time.NewTimer(h.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-timer.C:
+		h.dequeue(true)
+		return ErrHasherSaturated
+	case <-ctx.Done():
+		h.dequeue(false)
+		return ctx.Err()
+	}
+	defer func() { <-h.slots }()
+
+	wait := time.Since(enqueuedAt)
+	h.dequeue(false)
+
+	startedAt := time.Now()
+	err := fn()
+	h.record(wait, time.Since(startedAt))
+	return err
+}
+
+func (h *passwordHasher) dequeue(rejected bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.queued--
+	if rejected {
+		h.rejected++
+	}
+}
+
+func (h *passwordHasher) record(wait, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.completed++
+	h.queueWaitSum += wait
+	h.queueWaitMax = max(h.queueWaitMax, wait)
+	h.hashTimeSum += duration
+	h.hashTimeMax = max(h.hashTimeMax, duration)
+}
+
+func (h *passwordHasher) Stats() HasherStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := HasherStats{
+		Workers:           cap(h.slots),
+		QueueDepth:        h.queueDepth,
+		InFlight:          len(h.slots),
+		Queued:            h.queued,
+		Completed:         h.completed,
+		Rejected:          h.rejected,
+		QueueWaitMaxMs:    millis(h.queueWaitMax),
+		HashDurationMaxMs: millis(h.hashTimeMax),
+	}
+	if h.completed > 0 {
+		stats.QueueWaitAvgMs = millis(h.queueWaitSum) / float64(h.completed)
+		stats.HashDurationAvgMs = millis(h.hashTimeSum) / float64(h.completed)
+	}
+	return stats
+}
+
+// hashParamsFromPasswords converts library parameters to their stored representation.
+func hashParamsFromPasswords(p passwords.Params) contracts.PasswordHashParams {
+	return contracts.PasswordHashParams{
+		Memory:      p.Memory,
+		Iterations:  p.Iterations,
+		Parallelism: p.Parallelism,
+		SaltLength:  p.SaltLength,
+		KeyLength:   p.KeyLength,
+	}
+}
+
+// passwordsParams converts stored parameters back to library parameters.
+func passwordsParams(p contracts.PasswordHashParams) passwords.Params {
+	return passwords.Params{
+		Memory:      p.Memory,
+		Iterations:  p.Iterations,
+		Parallelism: p.Parallelism,
+		SaltLength:  p.SaltLength,
+		KeyLength:   p.KeyLength,
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+)
+
+// Password policy rules reported in PasswordPolicyViolation.Rule.
+const (
+	PasswordRuleMinLength      = "min_length"
+	PasswordRuleMaxLength      = "max_length"
+	PasswordRuleCharacterClass = "character_class"
+	PasswordRuleStrength       = "strength"
+	PasswordRuleSimilar        = "similar_to_identity"
+	PasswordRuleReused         = "reused"
+	PasswordRuleBreached       = "breached"
+)
+
+// minIdentityTokenLength is the shortest username or email fragment the similarity rule looks for,
+// so that short fragments such as "jo" don't reject unrelated passwords.
+const minIdentityTokenLength = 3
+
+// PasswordPolicyViolation describes one rule a password failed.
+type PasswordPolicyViolation struct {
+	Rule    string
+	Message string
+}
+
+// PasswordPolicyError reports every rule a new password failed.
+type PasswordPolicyError struct {
+	Violations []PasswordPolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return "password violates policy: " + strings.Join(rules, ", ")
+}
+
+// CheckPasswordPolicy evaluates password against the static rules of policy (everything except
+// history, which needs the user's stored hashes) and returns every violation.
+func CheckPasswordPolicy(policy config.PasswordPolicy, password, username, email string) []PasswordPolicyViolation {
+	var violations []PasswordPolicyViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		violations = append(violations, PasswordPolicyViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters", policy.MinLength),
+		})
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		// Don't run the remaining checks (notably zxcvbn) over arbitrarily long input
+		return append(violations, PasswordPolicyViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters", policy.MaxLength),
+		})
+	}
+
+	for _, class := range policy.RequiredClasses {
+		if !containsClass(password, class) {
+			violations = append(violations, PasswordPolicyViolation{
+				Rule:    PasswordRuleCharacterClass,
+				Message: "must contain " + classDescription(class),
+			})
+		}
+	}
+
+	identity := identityTokens(username, email)
+	if policy.RejectSimilar {
+		lower := strings.ToLower(password)
+		for _, token := range identity {
+			if strings.Contains(lower, token) {
+				violations = append(violations, PasswordPolicyViolation{
+					Rule:    PasswordRuleSimilar,
+					Message: "must not contain your username or email",
+				})
+				break
+			}
+		}
+	}
+
+	if policy.MinStrength > 0 {
+		if score := zxcvbn.PasswordStrength(password, identity).Score; score < policy.MinStrength {
+			violations = append(violations, PasswordPolicyViolation{
+				Rule:    PasswordRuleStrength,
+				Message: fmt.Sprintf("is too easy to guess (strength %d of 4, at least %d required)", score, policy.MinStrength),
+			})
+		}
+	}
+
+	return violations
+}
+
+// containsClass reports whether password has at least one character of the given class.
+func containsClass(password, class string) bool {
+	for _, r := range password {
+		switch class {
+		case config.PasswordClassLower:
+			if unicode.IsLower(r) {
+				return true
+			}
+		case config.PasswordClassUpper:
+			if unicode.IsUpper(r) {
+				return true
+			}
+		case config.PasswordClassDigit:
+			if unicode.IsDigit(r) {
+				return true
+			}
+		case config.PasswordClassSymbol:
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func classDescription(class string) string {
+	switch class {
+	case config.PasswordClassLower:
+		return "a lower case letter"
+	case config.PasswordClassUpper:
+		return "an upper case letter"
+	case config.PasswordClassDigit:
+		return "a digit"
+	case config.PasswordClassSymbol:
+		return "a symbol"
+	default:
+		return class
+	}
+}
+
+// identityTokens returns the lower-cased username, email and email local part, plus the alphanumeric
+// fragments of the username and local part, ignoring anything shorter than minIdentityTokenLength.
+// The email domain is not fragmented; parts like "com" or "gmail" say nothing about the user.
+func identityTokens(username, email string) []string {
+	local, _, _ := strings.Cut(email, "@")
+	var tokens []string
+	add := func(token string) {
+		token = strings.ToLower(token)
+		if utf8.RuneCountInString(token) < minIdentityTokenLength {
+			return
+		}
+		if !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	add(email)
+	for _, source := range []string{username, local} {
+		add(source)
+		for _, fragment := range strings.FieldsFunc(source, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			add(fragment)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/LittleAksMax/bids-util/requests"
+)
+
+var (
+	ErrInvalidAccessToken   = errors.New("invalid personal access token")
+	ErrAccessTokenNotFound  = errors.New("personal access token not found")
+	ErrInvalidTokenName     = errors.New("name must be 1-100 characters")
+	ErrInvalidTokenScopes   = errors.New("scopes must be one or more <resource>:<action> permissions")
+	ErrScopeNotGranted      = errors.New("scope not granted by your role")
+	ErrInvalidTokenLifetime = errors.New("expiry must be in the future and within the maximum lifetime")
+)
+
+// maxTokenNameLength bounds the name a user gives a personal access token.
+const maxTokenNameLength = 100
+
+// PersonalAccessTokenService manages the long-lived tokens users script against the bids APIs with.
+// A token acts for its user with at most the permissions in its scopes, and only those the user's
+// role still grants.
+type PersonalAccessTokenService interface {
+	ListTokens(ctx context.Context, userID uuid.UUID) ([]*contracts.PersonalAccessToken, error)
+	CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*contracts.PersonalAccessToken, string, error)
+	RevokeToken(ctx context.Context, userID, id uuid.UUID) error
+	Authenticate(ctx context.Context, token string) (*AccessTokenClaims, error)
+}
+
+// personalAccessTokenService implements PersonalAccessTokenService.
+type personalAccessTokenService struct {
+	pool           *sql.DB
+	userRepo       repository.UserRepository
+	permissionRepo repository.PermissionRepository
+	tokenRepo      repository.PersonalAccessTokenRepository
+	auditRepo      repository.AuthEventRepository
+	secret         []byte
+	defaultTTL     time.Duration
+	maxTTL         time.Duration
+}
+
+// NewPersonalAccessTokenService creates a new personal access token service. Tokens are hashed with
+// secret, the API key secret, and expire after defaultTTL unless the user picks an expiry
+// within maxTTL.
+func NewPersonalAccessTokenService(pool *sql.DB, userRepo repository.UserRepository, permissionRepo repository.PermissionRepository, tokenRepo repository.PersonalAccessTokenRepository, auditRepo repository.AuthEventRepository, secret string, defaultTTL, maxTTL time.Duration) PersonalAccessTokenService {
+	return &personalAccessTokenService{
+		pool:           pool,
+		userRepo:       userRepo,
+		permissionRepo: permissionRepo,
+		tokenRepo:      tokenRepo,
+		auditRepo:      auditRepo,
+		secret:         []byte(secret),
+		defaultTTL:     defaultTTL,
+		maxTTL:         maxTTL,
+	}
+}
+
+// ListTokens returns a user's tokens, without the tokens themselves.
+func (s *personalAccessTokenService) ListTokens(ctx context.Context, userID uuid.UUID) ([]*contracts.PersonalAccessToken, error) {
+	tokens, err := s.tokenRepo.ListByUser(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*contracts.PersonalAccessToken{}
+	}
+	return tokens, nil
+}
+
+// CreateToken creates a token for the user limited to scopes, each of which their role must grant,
+// and returns it with its record; only its hash is stored. Without expiresAt the token expires after
+// the default lifetime.
+func (s *personalAccessTokenService) CreateToken(ctx context.Context, userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*contracts.PersonalAccessToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxTokenNameLength {
+		return nil, "", ErrInvalidTokenName
+	}
+	scopes = normalisePermissions(scopes)
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidTokenScopes
+	}
+	for _, scope := range scopes {
+		if !isValidPermission(scope) {
+			return nil, "", ErrInvalidTokenScopes
+		}
+	}
+
+	now := time.Now()
+	expiry := now.Add(s.defaultTTL)
+	if expiresAt != nil {
+		if !expiresAt.After(now) || expiresAt.Sub(now) > s.maxTTL {
+			return nil, "", ErrInvalidTokenLifetime
+		}
+		expiry = *expiresAt
+	}
+
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user == nil {
+		return nil, "", ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, "", ErrAccountInactive
+	}
+	granted, err := s.permissionRepo.ListByRole(ctx, s.pool, user.Role)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, scope := range scopes {
+		resource, action, _ := strings.Cut(scope, ":")
+		if _, ok := grantingPermission(granted, resource, action); !ok {
+			return nil, "", fmt.Errorf("%w: %s", ErrScopeNotGranted, scope)
+		}
+	}
+
+	secret, err := generatePersonalAccessToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	token, err := s.tokenRepo.Create(ctx, tx, userID, name, hmacToken(s.secret, secret), scopes, expiry)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventAccessTokenCreated, userID, map[string]any{
+		"token_id":   token.ID,
+		"name":       token.Name,
+		"scopes":     token.Scopes,
+		"expires_at": token.ExpiresAt,
+	})); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// RevokeToken deletes one of the user's tokens; requests using it are rejected from then on.
+func (s *personalAccessTokenService) RevokeToken(ctx context.Context, userID, id uuid.UUID) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.tokenRepo.Delete(ctx, tx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAccessTokenNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventAccessTokenRevoked, userID, map[string]any{"token_id": id})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Authenticate returns the claims a presented token carries, recording its use. They have no
+// session ID or role, as the token only acts with its scope: the token's scopes the user's role still
+// grants. A token left with none, of an inactive user, or past its expiry is rejected.
+func (s *personalAccessTokenService) Authenticate(ctx context.Context, token string) (*AccessTokenClaims, error) {
+	if !strings.HasPrefix(token, PersonalAccessTokenPrefix) {
+		return nil, ErrInvalidAccessToken
+	}
+	record, err := s.tokenRepo.FindByHash(ctx, s.pool, hmacToken(s.secret, token))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if record == nil || !record.ExpiresAt.After(now) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, s.pool, record.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return nil, ErrInvalidAccessToken
+	}
+	granted, err := s.permissionRepo.ListByRole(ctx, s.pool, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	scopes := grantedScopes(granted, record.Scopes)
+	if len(scopes) == 0 {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if err := s.tokenRepo.TouchLastUsed(ctx, s.pool, record.ID, now); err != nil {
+		log.Printf("couldn't record use of personal access token %s: %v\n", record.ID, err)
+	}
+
+	return &AccessTokenClaims{
+		Claims: requests.Claims{
+			Name: user.Username,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   user.ID.String(),
+				IssuedAt:  jwt.NewNumericDate(record.CreatedAt),
+				ExpiresAt: jwt.NewNumericDate(record.ExpiresAt),
+				ID:        record.ID.String(),
+			},
+		},
+		Scope:       strings.Join(scopes, " "),
+		SubjectType: SubjectTypePersonalAccessToken,
+	}, nil
+}
+
+// grantedScopes returns the scopes the granted permissions still allow, in order.
+func grantedScopes(granted, scopes []string) []string {
+	var allowed []string
+	for _, scope := range scopes {
+		resource, action, _ := strings.Cut(scope, ":")
+		if _, ok := grantingPermission(granted, resource, action); ok {
+			allowed = append(allowed, scope)
+		}
+	}
+	return allowed
+}
+
+// generatePersonalAccessToken creates a random, prefixed personal access token.
+func generatePersonalAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,534 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleExists         = errors.New("a role with this name already exists")
+	ErrInvalidRoleName    = errors.New("role name must be 1-64 lowercase letters, digits, dashes or underscores, starting with a letter")
+	ErrBuiltinRole        = errors.New("built-in roles cannot be deleted")
+	ErrRoleInUse          = errors.New("role is held by users or service accounts")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrUnknownPermission  = errors.New("unknown permission")
+	ErrPermissionExists   = errors.New("permission already exists")
+	ErrInvalidPermission  = errors.New("permission must be <resource>:<action>, each lowercase letters, digits, dots, dashes or underscores, or *")
+	ErrPrivilegedRole     = errors.New("role administers the auth service")
+	ErrLastAdminRole      = errors.New("no active user would be left holding a role granting auth:admin")
+	ErrProvisioningRole   = errors.New("role is given to users or service accounts by configuration and must not grant auth:admin")
+)
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+	permissionPartPattern = regexp.MustCompile(`^(?:\*|[a-z0-9][a-z0-9_.-]*)$`)
+)
+
+// maxPermissionLength matches the width of permissions.name.
+const maxPermissionLength = 128
+
+// RBACService manages global roles and the permissions they grant, and answers which permissions a
+// user holds through their role.
+type RBACService interface {
+	ListRoles(ctx context.Context) ([]*contracts.Role, error)
+	GetRole(ctx context.Context, name string) (*contracts.Role, error)
+	CreateRole(ctx context.Context, name, description string, permissions []string) (*contracts.Role, error)
+	UpdateRole(ctx context.Context, name, description string, permissions []string) (*contracts.Role, error)
+	DeleteRole(ctx context.Context, name string) error
+	ListPermissions(ctx context.Context) ([]*contracts.Permission, error)
+	CreatePermission(ctx context.Context, name, description string) (*contracts.Permission, error)
+	DeletePermission(ctx context.Context, name string) error
+	UserPermissions(ctx context.Context, userID uuid.UUID) (*EffectivePermissions, error)
+	Check(ctx context.Context, subjectID uuid.UUID, resource, action string) (*AuthzDecision, error)
+}
+
+// EffectivePermissions are the permissions a user holds through their role.
+type EffectivePermissions struct {
+	UserID      uuid.UUID
+	Role        string
+	Permissions []string
+}
+
+// AuthzDecision answers whether a user or service account may perform an action on a resource.
+// Permission is the granted permission that allowed it, empty when denied.
+type AuthzDecision struct {
+	Allowed    bool
+	Role       string
+	Permission string
+}
+
+// rbacService implements RBACService.
+type rbacService struct {
+	pool               *sql.DB
+	userRepo           repository.UserRepository
+	serviceAccountRepo repository.ServiceAccountRepository
+	roleRepo           repository.RoleRepository
+	permissionRepo     repository.PermissionRepository
+	auditRepo          repository.AuthEventRepository
+	provisioningRoles  []string
+}
+
+// NewRBACService creates a new role and permission service. provisioningRoles are the roles
+// configuration gives users or service accounts, which must never grant auth:admin.
+func NewRBACService(pool *sql.DB, userRepo repository.UserRepository, serviceAccountRepo repository.ServiceAccountRepository, roleRepo repository.RoleRepository, permissionRepo repository.PermissionRepository, auditRepo repository.AuthEventRepository, provisioningRoles []string) RBACService {
+	return &rbacService{
+		pool:               pool,
+		userRepo:           userRepo,
+		serviceAccountRepo: serviceAccountRepo,
+		roleRepo:           roleRepo,
+		permissionRepo:     permissionRepo,
+		auditRepo:          auditRepo,
+		provisioningRoles:  provisioningRoles,
+	}
+}
+
+// ListRoles returns every role with its permissions.
+func (s *rbacService) ListRoles(ctx context.Context) ([]*contracts.Role, error) {
+	roles, err := s.roleRepo.List(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []*contracts.Role{}
+	}
+	return roles, nil
+}
+
+// GetRole returns a single role with its permissions.
+func (s *rbacService) GetRole(ctx context.Context, name string) (*contracts.Role, error) {
+	role, err := s.roleRepo.FindByName(ctx, s.pool, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// CreateRole creates a role granting the given existing permissions.
+func (s *rbacService) CreateRole(ctx context.Context, name, description string, permissions []string) (*contracts.Role, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !roleNamePattern.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+	permissions = normalisePermissions(permissions)
+	existing, err := s.roleRepo.FindByName(ctx, s.pool, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrRoleExists
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := s.requirePermissions(ctx, tx, permissions); err != nil {
+		return nil, err
+	}
+	role, err := s.roleRepo.Create(ctx, tx, name, strings.TrimSpace(description))
+	if err != nil {
+		// The name was taken since it was checked
+		return nil, ErrRoleExists
+	}
+	if err := s.roleRepo.SetPermissions(ctx, tx, name, permissions); err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRoleCreated, nil, map[string]any{
+		"role":        name,
+		"permissions": permissions,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole changes a role's description and replaces the permissions it grants. Users holding it
+// get the new permissions in their access tokens when they next refresh. A role configuration gives
+// users or service accounts can't be made to grant auth:admin, and the last role granting it to an
+// active user can't stop doing so.
+func (s *rbacService) UpdateRole(ctx context.Context, name, description string, permissions []string) (*contracts.Role, error) {
+	permissions = normalisePermissions(permissions)
+	if _, ok := grantsAdmin(permissions); ok && slices.Contains(s.provisioningRoles, name) {
+		return nil, ErrProvisioningRole
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	role, err := s.roleRepo.Update(ctx, tx, name, strings.TrimSpace(description))
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	if err := s.requirePermissions(ctx, tx, permissions); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.SetPermissions(ctx, tx, name, permissions); err != nil {
+		return nil, err
+	}
+	if err := s.requireAdminHeld(ctx, tx); err != nil {
+		return nil, err
+	}
+	role.Permissions = permissions
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRoleUpdated, nil, map[string]any{
+		"role":        name,
+		"permissions": permissions,
+	})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteRole removes a role that is neither built in nor held by any user.
+func (s *rbacService) DeleteRole(ctx context.Context, name string) error {
+	role, err := s.roleRepo.FindByName(ctx, s.pool, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return ErrRoleNotFound
+	}
+	if role.Builtin {
+		return ErrBuiltinRole
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	inUse, err := s.roleRepo.InUse(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrRoleInUse
+	}
+	deleted, err := s.roleRepo.Delete(ctx, tx, name)
+	if err != nil {
+		// A user was given the role since it was checked
+		return ErrRoleInUse
+	}
+	if !deleted {
+		return ErrRoleNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRoleDeleted, nil, map[string]any{"role": name})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListPermissions returns every permission.
+func (s *rbacService) ListPermissions(ctx context.Context) ([]*contracts.Permission, error) {
+	permissions, err := s.permissionRepo.List(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []*contracts.Permission{}
+	}
+	return permissions, nil
+}
+
+// CreatePermission defines a permission roles can then grant.
+func (s *rbacService) CreatePermission(ctx context.Context, name, description string) (*contracts.Permission, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !isValidPermission(name) {
+		return nil, ErrInvalidPermission
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	missing, err := s.permissionRepo.FindMissing(ctx, tx, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) == 0 {
+		return nil, ErrPermissionExists
+	}
+	permission, err := s.permissionRepo.Create(ctx, tx, name, strings.TrimSpace(description))
+	if err != nil {
+		// The name was taken since it was checked
+		return nil, ErrPermissionExists
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventPermissionCreated, nil, map[string]any{"permission": name})); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+// DeletePermission removes a permission and revokes it from every role granting it, unless that
+// leaves no active user holding a role granting auth:admin.
+func (s *rbacService) DeletePermission(ctx context.Context, name string) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.permissionRepo.Delete(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPermissionNotFound
+	}
+	if err := s.requireAdminHeld(ctx, tx); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventPermissionDeleted, nil, map[string]any{"permission": name})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UserPermissions returns the permissions a user holds through their role.
+func (s *rbacService) UserPermissions(ctx context.Context, userID uuid.UUID) (*EffectivePermissions, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	permissions, err := s.permissionRepo.ListByRole(ctx, s.pool, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &EffectivePermissions{UserID: user.ID, Role: user.Role, Permissions: permissions}, nil
+}
+
+// Check reports whether a user or service account may perform action on resource, i.e. whether their
+// role grants <resource>:<action> directly or through a wildcard. Users who aren't active are always
+// denied. Subjects that are neither get ErrUserNotFound.
+func (s *rbacService) Check(ctx context.Context, subjectID uuid.UUID, resource, action string) (*AuthzDecision, error) {
+	resource = strings.ToLower(strings.TrimSpace(resource))
+	action = strings.ToLower(strings.TrimSpace(action))
+	if !isValidPermission(resource + ":" + action) {
+		return nil, ErrInvalidPermission
+	}
+
+	role, active, err := s.subjectRole(ctx, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	decision := &AuthzDecision{Role: role}
+	if !active {
+		return decision, nil
+	}
+	permissions, err := s.permissionRepo.ListByRole(ctx, s.pool, role)
+	if err != nil {
+		return nil, err
+	}
+	if granted, ok := grantingPermission(permissions, resource, action); ok {
+		decision.Allowed = true
+		decision.Permission = granted
+	}
+	return decision, nil
+}
+
+// subjectRole returns the role of the user or service account with subjectID, and whether it may act.
+// Service accounts have no status, so can always act until they're deleted.
+func (s *rbacService) subjectRole(ctx context.Context, subjectID uuid.UUID) (string, bool, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, subjectID)
+	if err != nil {
+		return "", false, err
+	}
+	if user != nil {
+		return user.Role, user.IsActive(), nil
+	}
+	account, err := s.serviceAccountRepo.FindByID(ctx, s.pool, subjectID)
+	if err != nil {
+		return "", false, err
+	}
+	if account == nil {
+		return "", false, ErrUserNotFound
+	}
+	return account.Role, true, nil
+}
+
+// requirePermissions returns ErrInvalidPermission or ErrUnknownPermission, naming the offending
+// permissions, unless every one of them exists.
+func (s *rbacService) requirePermissions(ctx context.Context, tx *sql.Tx, permissions []string) error {
+	for _, permission := range permissions {
+		if !isValidPermission(permission) {
+			return fmt.Errorf("%w: %s", ErrInvalidPermission, permission)
+		}
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	missing, err := s.permissionRepo.FindMissing(ctx, tx, permissions)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownPermission, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// requireRoleExists returns ErrRoleNotFound unless a role with the given name exists.
+func requireRoleExists(ctx context.Context, roleRepo repository.RoleRepository, db *sql.DB, name string) error {
+	role, err := roleRepo.FindByName(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+// CheckProvisioningRole returns an error unless the role exists and doesn't grant
+// contracts.PermissionAuthAdmin, so that configuration mapping an external system's users to roles
+// can't make them admins.
+func CheckProvisioningRole(ctx context.Context, roleRepo repository.RoleRepository, db *sql.DB, name string) error {
+	role, err := roleRepo.FindByName(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return fmt.Errorf("%w: %s", ErrRoleNotFound, name)
+	}
+	if granted, ok := grantsAdmin(role.Permissions); ok {
+		return fmt.Errorf("%w: %s grants %s", ErrPrivilegedRole, name, granted)
+	}
+	return nil
+}
+
+// requireAdminHeld returns ErrLastAdminRole unless, with tx's changes, an active user still holds a
+// role granting contracts.PermissionAuthAdmin, so admins can't lock everyone out of the admin API.
+func (s *rbacService) requireAdminHeld(ctx context.Context, tx *sql.Tx) error {
+	resource, action, _ := strings.Cut(contracts.PermissionAuthAdmin, ":")
+	wildcard := contracts.PermissionWildcard
+	held, err := s.roleRepo.HeldGranting(ctx, tx, []string{
+		contracts.PermissionAuthAdmin,
+		resource + ":" + wildcard,
+		wildcard + ":" + action,
+		wildcard + ":" + wildcard,
+	})
+	if err != nil {
+		return err
+	}
+	if !held {
+		return ErrLastAdminRole
+	}
+	return nil
+}
+
+// grantsAdmin returns the first of the granted permissions that allows contracts.PermissionAuthAdmin.
+func grantsAdmin(granted []string) (string, bool) {
+	resource, action, _ := strings.Cut(contracts.PermissionAuthAdmin, ":")
+	return grantingPermission(granted, resource, action)
+}
+
+// normalisePermissions lowercases and trims permission names and drops duplicates, keeping them
+// sorted.
+func normalisePermissions(permissions []string) []string {
+	normalised := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		normalised = append(normalised, strings.ToLower(strings.TrimSpace(permission)))
+	}
+	slices.Sort(normalised)
+	return slices.Compact(normalised)
+}
+
+// isValidPermission reports whether name is a well-formed <resource>:<action> permission.
+func isValidPermission(name string) bool {
+	resource, action, ok := strings.Cut(name, ":")
+	return ok && len(name) <= maxPermissionLength &&
+		permissionPartPattern.MatchString(resource) && permissionPartPattern.MatchString(action)
+}
+
+// grantingPermission returns the first of the granted permissions that allows action on resource,
+// either exactly or through a wildcard resource or action.
+func grantingPermission(granted []string, resource, action string) (string, bool) {
+	for _, permission := range granted {
+		r, a, ok := strings.Cut(permission, ":")
+		if !ok {
+			continue
+		}
+		if (r == contracts.PermissionWildcard || r == resource) && (a == contracts.PermissionWildcard || a == action) {
+			return permission, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+func TestUpdateRole(t *testing.T) {
+	tests := []struct {
+		name          string
+		role          string
+		permissions   []string
+		activeHolders []string // roles held by active users
+		wantErr       error
+	}{
+		{name: "changes permissions", role: "user", permissions: []string{"bids:read", "bids:write"}, activeHolders: []string{"admin", "user"}},
+		{name: "removes an admin grant another held role repeats", role: "admin", permissions: []string{"bids:read"}, activeHolders: []string{"admin", "operator"}},
+		{name: "removes the last held admin grant", role: "admin", permissions: []string{"bids:read"}, activeHolders: []string{"admin", "user"}, wantErr: ErrLastAdminRole},
+		{name: "grants admin to a provisioning role", role: "user", permissions: []string{contracts.PermissionAuthAdmin}, activeHolders: []string{"admin"}, wantErr: ErrProvisioningRole},
+		{name: "grants admin to a provisioning role through a wildcard", role: "user", permissions: []string{"auth:*"}, activeHolders: []string{"admin"}, wantErr: ErrProvisioningRole},
+		{name: "grants admin to another role", role: "auditor", permissions: []string{"*:admin"}, activeHolders: []string{"admin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles := &fakeRoleRepository{
+				grants: map[string][]string{
+					"admin":    {"*:*"},
+					"operator": {contracts.PermissionAuthAdmin},
+					"user":     {},
+					"auditor":  {},
+				},
+				activeHolders: tt.activeHolders,
+			}
+			svc := NewRBACService(newTxOnlyDB(t), nil, nil, roles, &fakePermissionRepository{roles: roles}, fakeAuthEventRepository{}, []string{"user"})
+
+			_, err := svc.UpdateRole(context.Background(), tt.role, "", tt.permissions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateRole() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeletePermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission string
+		wantErr    error
+	}{
+		{name: "other permission", permission: "bids:read"},
+		{name: "admin grant of a role no active user holds", permission: contracts.PermissionAuthAdmin},
+		{name: "last held admin grant", permission: "*:*", wantErr: ErrLastAdminRole},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roles := &fakeRoleRepository{
+				grants: map[string][]string{
+					"admin":    {"*:*", "bids:read"},
+					"operator": {contracts.PermissionAuthAdmin},
+				},
+				activeHolders: []string{"admin"},
+			}
+			svc := NewRBACService(newTxOnlyDB(t), nil, nil, roles, &fakePermissionRepository{roles: roles}, fakeAuthEventRepository{}, nil)
+
+			err := svc.DeletePermission(context.Background(), tt.permission)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeletePermission() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeRoleRepository keeps role grants in memory. activeHolders are the roles active users hold.
+type fakeRoleRepository struct {
+	repository.RoleRepository
+	grants        map[string][]string
+	activeHolders []string
+}
+
+func (r *fakeRoleRepository) Update(_ context.Context, _ *sql.Tx, name, description string) (*contracts.Role, error) {
+	if _, ok := r.grants[name]; !ok {
+		return nil, nil
+	}
+	return &contracts.Role{Name: name, Description: description}, nil
+}
+
+func (r *fakeRoleRepository) SetPermissions(_ context.Context, _ *sql.Tx, name string, permissions []string) error {
+	r.grants[name] = permissions
+	return nil
+}
+
+func (r *fakeRoleRepository) HeldGranting(_ context.Context, _ *sql.Tx, permissions []string) (bool, error) {
+	for _, role := range r.activeHolders {
+		for _, granted := range r.grants[role] {
+			if slices.Contains(permissions, granted) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// fakePermissionRepository treats every permission as defined, revoking deleted ones from the roles.
+type fakePermissionRepository struct {
+	repository.PermissionRepository
+	roles *fakeRoleRepository
+}
+
+func (r *fakePermissionRepository) FindMissing(context.Context, *sql.Tx, []string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakePermissionRepository) Delete(_ context.Context, _ *sql.Tx, name string) (bool, error) {
+	for role, granted := range r.roles.grants {
+		r.roles.grants[role] = slices.DeleteFunc(granted, func(p string) bool { return p == name })
+	}
+	return true, nil
+}
+
+type fakeAuthEventRepository struct {
+	repository.AuthEventRepository
+}
+
+func (fakeAuthEventRepository) Create(context.Context, repository.Execer, *contracts.AuthEvent) error {
+	return nil
+}
+
+// newTxOnlyDB returns a database that can only begin, commit and roll back transactions, for
+// services whose repositories are faked.
+func newTxOnlyDB(t *testing.T) *sql.DB {
+	db := sql.OpenDB(txOnlyConnector{})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type txOnlyConnector struct{}
+
+func (txOnlyConnector) Connect(context.Context) (driver.Conn, error) { return txOnlyConn{}, nil }
+func (txOnlyConnector) Driver() driver.Driver                        { return nil }
+
+type txOnlyConn struct{}
+
+func (txOnlyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("txOnlyConn: queries are not supported")
+}
+func (txOnlyConn) Close() error              { return nil }
+func (txOnlyConn) Begin() (driver.Tx, error) { return txOnlyConn{}, nil }
+func (txOnlyConn) Commit() error             { return nil }
+func (txOnlyConn) Rollback() error           { return nil }
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownSAMLConnection = errors.New("unknown SAML connection")
+	ErrSAMLLogin             = errors.New("SAML login failed")
+	ErrSAMLEmailInUse        = errors.New("an account with this email already exists and the identity provider isn't trusted to sign in to it")
+	ErrSAMLEmailDomain       = errors.New("the identity provider isn't allowed to sign in users with this email domain")
+)
+
+// SAMLConnection is an enterprise SAML identity provider.
+type SAMLConnection interface {
+	// Metadata returns the service provider's metadata document for the IdP.
+	Metadata() ([]byte, error)
+	// AuthnRequestURL returns the URL to send the user to, carrying relayState, and the ID of the AuthnRequest in it.
+	AuthnRequestURL(ctx context.Context, relayState string) (string, string, error)
+	// ParseResponse validates a base64-encoded SAMLResponse answering requestID and returns the identity it asserts.
+	ParseResponse(ctx context.Context, samlResponse, requestID string) (*contracts.SAMLAssertion, error)
+}
+
+// SAMLLoginService signs users in through enterprise SAML identity providers. First-time users are
+// provisioned with the username, email and role mapped from their assertion, or linked to the local
+// account with the same email when the connection is trusted to assert emails. Either way the email
+// must be in one of the connection's email domains, if it restricts them. Connections that map
+// roles keep the role of the users they provisioned in sync on every login.
+type SAMLLoginService interface {
+	Connections() []string
+	Metadata(connection string) ([]byte, error)
+	Begin(ctx context.Context, connection string, organizationID *uuid.UUID) (string, string, error)
+	Complete(ctx context.Context, connection, relayState, samlResponse string) (*SAMLLoginResult, error)
+}
+
+// SAMLLoginResult is the outcome of a completed SAML login.
+type SAMLLoginResult struct {
+	User           *contracts.UserDTO
+	OrganizationID *uuid.UUID // the organization selected when the login started, if any
+}
+
+// samlLoginService implements SAMLLoginService.
+type samlLoginService struct {
+	pool             *sql.DB
+	connections      map[string]SAMLConnection
+	names            []string
+	userRepo         repository.UserRepository
+	identityRepo     repository.SAMLIdentityRepository
+	stateRepo        repository.SAMLLoginStateRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	stateTTL         time.Duration
+}
+
+// NewSAMLLoginService creates a new SAML login service. names lists the configured connections in
+// display order, and logins must complete within stateTTL.
+func NewSAMLLoginService(pool *sql.DB, connections map[string]SAMLConnection, names []string, userRepo repository.UserRepository, identityRepo repository.SAMLIdentityRepository, stateRepo repository.SAMLLoginStateRepository, refreshTokenRepo repository.RefreshTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, stateTTL time.Duration) SAMLLoginService {
+	return &samlLoginService{
+		pool:             pool,
+		connections:      connections,
+		names:            names,
+		userRepo:         userRepo,
+		identityRepo:     identityRepo,
+		stateRepo:        stateRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		stateTTL:         stateTTL,
+	}
+}
+
+// Connections returns the names of the configured connections.
+func (s *samlLoginService) Connections() []string {
+	return s.names
+}
+
+// Metadata returns the service provider metadata to register with connection's IdP.
+func (s *samlLoginService) Metadata(connection string) ([]byte, error) {
+	c, ok := s.connections[connection]
+	if !ok {
+		return nil, ErrUnknownSAMLConnection
+	}
+	return c.Metadata()
+}
+
+// Begin starts a login with connection and returns the IdP URL to redirect the user to, and the
+// RelayState the response must carry. The RelayState is bound to the browser by the caller. The session
+// the login ends in is to act in organizationID, if given.
+func (s *samlLoginService) Begin(ctx context.Context, connection string, organizationID *uuid.UUID) (string, string, error) {
+	c, ok := s.connections[connection]
+	if !ok {
+		return "", "", ErrUnknownSAMLConnection
+	}
+
+	relayState, err := generateLoginSecret()
+	if err != nil {
+		return "", "", err
+	}
+	authURL, requestID, err := c.AuthnRequestURL(ctx, relayState)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrSAMLLogin, err)
+	}
+
+	// Abandoned logins are swept here rather than by a worker; failing to do so mustn't block the login
+	if err := s.stateRepo.DeleteExpired(ctx, s.pool); err != nil {
+		log.Printf("couldn't delete expired SAML login states: %v\n", err)
+	}
+	if err := s.stateRepo.Create(ctx, s.pool, hashLoginSecret(relayState), &contracts.SAMLLoginState{
+		Connection:     connection,
+		RequestID:      requestID,
+		OrganizationID: organizationID,
+		ExpiresAt:      time.Now().UTC().Add(s.stateTTL),
+	}); err != nil {
+		return "", "", err
+	}
+	return authURL, relayState, nil
+}
+
+// Complete validates the IdP's response to a login and returns the signed-in user. IdP-initiated
+// logins aren't accepted: every response must answer an AuthnRequest from Begin.
+func (s *samlLoginService) Complete(ctx context.Context, connection, relayState, samlResponse string) (*SAMLLoginResult, error) {
+	c, ok := s.connections[connection]
+	if !ok {
+		return nil, ErrUnknownSAMLConnection
+	}
+	if relayState == "" || samlResponse == "" {
+		return nil, ErrInvalidLoginState
+	}
+
+	login, err := s.stateRepo.Consume(ctx, s.pool, hashLoginSecret(relayState), connection)
+	if err != nil {
+		return nil, err
+	}
+	if login == nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	assertion, err := c.ParseResponse(ctx, samlResponse, login.RequestID)
+	if err != nil {
+		s.recordLoginFailure(ctx, nil, connection, "invalid_response")
+		return nil, fmt.Errorf("%w: %v", ErrSAMLLogin, err)
+	}
+
+	user, provisioned, err := s.resolveUser(ctx, assertion)
+	if err != nil {
+		return nil, err
+	}
+	// A fresh IdP login proves presence as well as a password would, so it restores an account
+	// pending deletion
+	if user.Status == contracts.UserStatusPendingDeletion {
+		if user, err = cancelDeletion(ctx, s.pool, s.userRepo, s.auditRepo, user.ID, map[string]any{"saml_connection": connection}); err != nil {
+			return nil, err
+		}
+	}
+	if !user.IsActive() {
+		s.recordLoginFailure(ctx, &user.ID, connection, "account_"+user.Status)
+		return nil, ErrAccountInactive
+	}
+	// The IdP only manages the role of users it provisioned; a local account it was linked to keeps the
+	// role its admins gave it
+	if assertion.RoleManaged && provisioned && user.Role != assertion.Role {
+		if user, err = s.syncRole(ctx, user, assertion); err != nil {
+			return nil, err
+		}
+	}
+
+	recordStandalone(ctx, s.auditRepo, s.pool, selfEvent(ctx, contracts.AuthEventLoginSucceeded, user.ID, map[string]any{"saml_connection": connection}))
+	return &SAMLLoginResult{User: user.ToDTO(), OrganizationID: login.OrganizationID}, nil
+}
+
+// resolveUser finds the user linked to the asserted identity, linking or provisioning one on first
+// sign-in, and reports whether the identity provisioned the user.
+func (s *samlLoginService) resolveUser(ctx context.Context, assertion *contracts.SAMLAssertion) (*contracts.User, bool, error) {
+	identity, err := s.identityRepo.FindByConnectionNameID(ctx, s.pool, assertion.Connection, assertion.NameID)
+	if err != nil {
+		return nil, false, err
+	}
+	if identity != nil {
+		user, err := s.userRepo.FindByID(ctx, s.pool, identity.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+		if user == nil {
+			return nil, false, ErrUserNotFound
+		}
+		if err := s.identityRepo.TouchLogin(ctx, s.pool, identity.ID, optionalAssertedEmail(assertion), time.Now().UTC()); err != nil {
+			return nil, false, err
+		}
+		return user, identity.Provisioned, nil
+	}
+
+	if assertion.Email == "" {
+		return nil, false, ErrIdentityEmailRequired
+	}
+	// Checked before the email is looked up, so one customer's IdP can't probe or claim another's users
+	if !assertion.EmailAllowed {
+		s.recordLoginFailure(ctx, nil, assertion.Connection, "email_domain")
+		return nil, false, ErrSAMLEmailDomain
+	}
+	existing, err := s.userRepo.FindByEmail(ctx, s.pool, assertion.Email)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		// Linking on email would let any connection's IdP claim accounts by asserting their address
+		if !assertion.EmailTrusted {
+			s.recordLoginFailure(ctx, &existing.ID, assertion.Connection, "email_untrusted")
+			return nil, false, ErrSAMLEmailInUse
+		}
+		if !existing.IsActive() {
+			return existing, false, nil
+		}
+		if err := s.link(ctx, existing, assertion); err != nil {
+			return nil, false, err
+		}
+		return existing, false, nil
+	}
+	user, err := s.provision(ctx, assertion)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// link attaches the asserted identity to an existing user.
+func (s *samlLoginService) link(ctx context.Context, user *contracts.User, assertion *contracts.SAMLAssertion) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if _, err := s.identityRepo.Create(ctx, tx, user.ID, assertion.Connection, assertion.NameID, optionalAssertedEmail(assertion), false); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventIdentityLinked, user.ID, map[string]any{"saml_connection": assertion.Connection})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// provision creates a passwordless user for the asserted identity, with its mapped role.
+func (s *samlLoginService) provision(ctx context.Context, assertion *contracts.SAMLAssertion) (*contracts.User, error) {
+	username, err := availableUsername(ctx, s.userRepo, s.pool, assertion.Username, assertion.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Create(ctx, tx, username, assertion.Email, assertion.Role)
+	if repository.IsUniqueViolation(err) {
+		// The username or email was taken since they were checked
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.identityRepo.Create(ctx, tx, user.ID, assertion.Connection, assertion.NameID, optionalAssertedEmail(assertion), true); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventRegister, user.ID, map[string]any{"role": user.Role, "saml_connection": assertion.Connection})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// syncRole gives a user the connection provisioned the role mapped from their assertion, revoking
+// their sessions as an admin role change does.
+func (s *samlLoginService) syncRole(ctx context.Context, user *contracts.User, assertion *contracts.SAMLAssertion) (*contracts.User, error) {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	updated, err := s.userRepo.UpdateRole(ctx, tx, user.ID, assertion.Role)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, user.ID, contracts.SessionRevokedRoleChanged); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRoleChanged, &user.ID, map[string]any{"role": updated.Role, "saml_connection": assertion.Connection})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRoleChanged, contracts.UserRoleChangedEventData{
+		UserID: updated.ID,
+		Role:   updated.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// recordLoginFailure records a failed SAML login; subjectID is nil when no user matched.
+func (s *samlLoginService) recordLoginFailure(ctx context.Context, subjectID *uuid.UUID, connection, reason string) {
+	recordStandalone(ctx, s.auditRepo, s.pool, authEvent(ctx, contracts.AuthEventLoginFailed, subjectID, map[string]any{"saml_connection": connection, "reason": reason}))
+}
+
+// optionalAssertedEmail returns the asserted email for storage, or nil if the IdP didn't supply one.
+func optionalAssertedEmail(assertion *contracts.SAMLAssertion) *string {
+	if assertion.Email == "" {
+		return nil
+	}
+	return &assertion.Email
+}
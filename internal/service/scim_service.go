@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/config"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/LittleAksMax/bids-auth-service/internal/scim"
+)
+
+var (
+	ErrInvalidSCIMToken  = errors.New("invalid SCIM token")
+	ErrSCIMTokenNotFound = errors.New("SCIM token not found")
+	ErrInvalidSCIMTenant = errors.New("tenant must be 1-64 letters, digits, dots, dashes or underscores")
+)
+
+var scimTenantPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// SCIMService provisions users on behalf of SCIM tenants, such as a customer's directory, and
+// manages the bearer tokens tenants authenticate with. A tenant only sees the users it provisioned.
+type SCIMService interface {
+	ListTokens(ctx context.Context) ([]*contracts.SCIMToken, error)
+	IssueToken(ctx context.Context, tenant, description string) (*contracts.SCIMToken, string, error)
+	RevokeToken(ctx context.Context, id uuid.UUID) error
+	Authenticate(ctx context.Context, token string) (*contracts.SCIMToken, error)
+	ListUsers(ctx context.Context, tenant string, filter scim.Filter, offset, limit int) (*SCIMUserPage, error)
+	GetUser(ctx context.Context, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error)
+	CreateUser(ctx context.Context, tenant string, attrs contracts.SCIMUserAttributes, password string) (*contracts.SCIMUser, error)
+	ReplaceUser(ctx context.Context, tenant string, userID uuid.UUID, attrs contracts.SCIMUserAttributes) (*contracts.SCIMUser, error)
+	PatchUser(ctx context.Context, tenant string, userID uuid.UUID, patch func(current *contracts.SCIMUser) (contracts.SCIMUserAttributes, error)) (*contracts.SCIMUser, error)
+	DeleteUser(ctx context.Context, tenant string, userID uuid.UUID) error
+}
+
+// SCIMUserPage is one page of a tenant's users.
+type SCIMUserPage struct {
+	Users []*contracts.SCIMUser
+	Total int64 // users matching the filter across all pages
+}
+
+// scimService implements SCIMService.
+type scimService struct {
+	pool             *sql.DB
+	userRepo         repository.UserRepository
+	credRepo         repository.PasswordCredentialRepository
+	tokenRepo        repository.SCIMTokenRepository
+	scimUserRepo     repository.SCIMUserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	hasher           PasswordHasher
+	breached         BreachChecker // nil when no breached-password corpus is configured
+	policy           config.PasswordPolicy
+	defaultRole      string
+}
+
+// NewSCIMService creates a new SCIM service. Provisioned users get defaultRole.
+func NewSCIMService(pool *sql.DB, userRepo repository.UserRepository, credRepo repository.PasswordCredentialRepository, tokenRepo repository.SCIMTokenRepository, scimUserRepo repository.SCIMUserRepository, refreshTokenRepo repository.RefreshTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, hasher PasswordHasher, breached BreachChecker, policy config.PasswordPolicy, defaultRole string) SCIMService {
+	return &scimService{
+		pool:             pool,
+		userRepo:         userRepo,
+		credRepo:         credRepo,
+		tokenRepo:        tokenRepo,
+		scimUserRepo:     scimUserRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		hasher:           hasher,
+		breached:         breached,
+		policy:           policy,
+		defaultRole:      defaultRole,
+	}
+}
+
+// ListTokens returns every issued token, without the tokens themselves.
+func (s *scimService) ListTokens(ctx context.Context) ([]*contracts.SCIMToken, error) {
+	tokens, err := s.tokenRepo.List(ctx, s.pool)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = []*contracts.SCIMToken{}
+	}
+	return tokens, nil
+}
+
+// IssueToken creates a token for tenant and returns it with its record; only its hash is stored.
+func (s *scimService) IssueToken(ctx context.Context, tenant, description string) (*contracts.SCIMToken, string, error) {
+	if !scimTenantPattern.MatchString(tenant) {
+		return nil, "", ErrInvalidSCIMTenant
+	}
+	secret, err := generateSCIMToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	token, err := s.tokenRepo.Create(ctx, tx, tenant, hashSCIMToken(secret), description)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return token, secret, nil
+}
+
+// RevokeToken deletes a token; requests using it are rejected from then on.
+func (s *scimService) RevokeToken(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.tokenRepo.Delete(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSCIMTokenNotFound
+	}
+	return tx.Commit()
+}
+
+// Authenticate returns the record of a presented token, recording its use.
+func (s *scimService) Authenticate(ctx context.Context, token string) (*contracts.SCIMToken, error) {
+	record, err := s.tokenRepo.FindByHash(ctx, s.pool, hashSCIMToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrInvalidSCIMToken
+	}
+	if err := s.tokenRepo.TouchLastUsed(ctx, s.pool, record.ID, time.Now()); err != nil {
+		log.Printf("couldn't record use of SCIM token %s: %v\n", record.ID, err)
+	}
+	return record, nil
+}
+
+// ListUsers returns up to limit of the tenant's users matching filter (all when nil), oldest first,
+// skipping the first offset.
+func (s *scimService) ListUsers(ctx context.Context, tenant string, filter scim.Filter, offset, limit int) (*SCIMUserPage, error) {
+	users, err := s.scimUserRepo.List(ctx, s.pool, tenant, filter, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	total, err := s.scimUserRepo.Count(ctx, s.pool, tenant, filter)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*contracts.SCIMUser{}
+	}
+	return &SCIMUserPage{Users: users, Total: total}, nil
+}
+
+// GetUser returns one of the tenant's users.
+func (s *scimService) GetUser(ctx context.Context, tenant string, userID uuid.UUID) (*contracts.SCIMUser, error) {
+	user, err := s.scimUserRepo.FindByID(ctx, s.pool, tenant, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// CreateUser provisions a user for the tenant, active unless attrs.Active is false. A password is
+// optional; users without one sign in another way, e.g. through the tenant's SAML connection.
+func (s *scimService) CreateUser(ctx context.Context, tenant string, attrs contracts.SCIMUserAttributes, password string) (*contracts.SCIMUser, error) {
+	// Check uniqueness before hashing, so a conflict doesn't cost a hash
+	existingUsername, err := s.userRepo.FindByUsername(ctx, s.pool, attrs.Username)
+	if err != nil {
+		return nil, err
+	}
+	if existingUsername != nil {
+		return nil, ErrUserExists
+	}
+	existingEmail, err := s.userRepo.FindByEmail(ctx, s.pool, attrs.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existingEmail != nil {
+		return nil, ErrUserExists
+	}
+
+	var cred *contracts.PasswordCredential
+	if password != "" {
+		if err := screenNewPassword(s.policy, s.breached, password, attrs.Username, attrs.Email); err != nil {
+			return nil, err
+		}
+		if cred, err = s.hasher.Hash(ctx, password); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Create(ctx, tx, attrs.Username, attrs.Email, s.defaultRole)
+	if repository.IsUniqueViolation(err) {
+		// The username or email was taken since they were checked
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if attrs.Active != nil && !*attrs.Active {
+		if user, err = s.userRepo.UpdateStatus(ctx, tx, user.ID, contracts.UserStatusDeactivated, scimDeprovisionedReason(tenant)); err != nil {
+			return nil, err
+		}
+	}
+	if cred != nil {
+		cred.UserID = user.ID
+		if err := s.credRepo.Create(ctx, tx, cred); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.scimUserRepo.Create(ctx, tx, user.ID, tenant, attrs.ExternalID, attrs.GivenName, attrs.FamilyName, attrs.DisplayName); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserCreated, &user.ID, map[string]any{
+		"role":        user.Role,
+		"scim_tenant": tenant,
+	})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &contracts.SCIMUser{
+		User:        user,
+		Tenant:      tenant,
+		ExternalID:  attrs.ExternalID,
+		GivenName:   attrs.GivenName,
+		FamilyName:  attrs.FamilyName,
+		DisplayName: attrs.DisplayName,
+	}, nil
+}
+
+// ReplaceUser replaces one of the tenant's users' attributes. Deactivating an active user revokes
+// their sessions; activating only reactivates a user the tenant deactivated, so it can't lift a
+// suspension or a pending deletion. A nil attrs.Active keeps the user's status.
+func (s *scimService) ReplaceUser(ctx context.Context, tenant string, userID uuid.UUID, attrs contracts.SCIMUserAttributes) (*contracts.SCIMUser, error) {
+	return s.PatchUser(ctx, tenant, userID, func(*contracts.SCIMUser) (contracts.SCIMUserAttributes, error) {
+		return attrs, nil
+	})
+}
+
+// PatchUser replaces one of the tenant's users' attributes, as ReplaceUser does, with those patch
+// derives from the user's current ones. The user is locked while patch runs, so concurrent PATCH
+// requests apply one after the other rather than overwriting each other. Errors from patch are
+// returned as they are.
+func (s *scimService) PatchUser(ctx context.Context, tenant string, userID uuid.UUID, patch func(current *contracts.SCIMUser) (contracts.SCIMUserAttributes, error)) (*contracts.SCIMUser, error) {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	current, err := s.scimUserRepo.LockByID(ctx, tx, tenant, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, ErrUserNotFound
+	}
+	attrs, err := patch(current)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uniqueness against other users is left to the constraints, which see concurrent changes too
+	user, err := s.userRepo.Update(ctx, tx, userID, attrs.Username, attrs.Email)
+	if repository.IsUniqueViolation(err) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.scimUserRepo.Update(ctx, tx, userID, attrs.ExternalID, attrs.GivenName, attrs.FamilyName, attrs.DisplayName); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserUpdated, &userID, map[string]any{"scim_tenant": tenant})); err != nil {
+		return nil, err
+	}
+
+	var status string
+	var reason *string
+	switch {
+	case attrs.Active == nil:
+		// Not given; the status stays as it is
+	case !*attrs.Active && user.Status == contracts.UserStatusActive:
+		status, reason = contracts.UserStatusDeactivated, scimDeprovisionedReason(tenant)
+	case *attrs.Active && user.Status == contracts.UserStatusDeactivated:
+		status = contracts.UserStatusActive
+	}
+	if status != "" {
+		if user, err = s.userRepo.UpdateStatus(ctx, tx, userID, status, reason); err != nil {
+			return nil, err
+		}
+		if status == contracts.UserStatusDeactivated {
+			if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, userID, contracts.SessionRevokedStatusChanged); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventStatusChanged, &userID, map[string]any{
+			"status":      status,
+			"scim_tenant": tenant,
+		})); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &contracts.SCIMUser{
+		User:        user,
+		Tenant:      tenant,
+		ExternalID:  attrs.ExternalID,
+		GivenName:   attrs.GivenName,
+		FamilyName:  attrs.FamilyName,
+		DisplayName: attrs.DisplayName,
+	}, nil
+}
+
+// DeleteUser hard-deletes one of the tenant's users, as an admin deletion does.
+func (s *scimService) DeleteUser(ctx context.Context, tenant string, userID uuid.UUID) error {
+	existing, err := s.scimUserRepo.FindByID(ctx, s.pool, tenant, userID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrUserNotFound
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.userRepo.Delete(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+	// The event names no subject: the user's ID is personal data and goes with the rest of the user
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventUserDeleted, nil, map[string]any{"scim_tenant": tenant})); err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserDeleted, contracts.UserDeletedEventData{UserID: userID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// scimDeprovisionedReason is the status reason of users a tenant deactivates.
+func scimDeprovisionedReason(tenant string) *string {
+	reason := "deprovisioned by SCIM tenant " + tenant
+	return &reason
+}
+
+// generateSCIMToken creates a prefixed cryptographically secure random token (32 bytes, base64url-encoded).
+func generateSCIMToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return scimTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSCIMToken computes the SHA-256 hash under which a SCIM token is stored.
+func hashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,10 @@
+package service
+
+// Prefixes of the opaque secrets the service issues. A prefix tells a secret's kind apart from JWTs
+// and the other kinds, and lets secret scanners recognise it when leaked.
+const (
+	webhookSecretPrefix       = "whsec_"
+	scimTokenPrefix           = "scim_"
+	PersonalAccessTokenPrefix = "pat_"
+	serviceAccountKeyPrefix   = "sa_"
+)
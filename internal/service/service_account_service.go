@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+var (
+	ErrServiceAccountNotFound    = errors.New("service account not found")
+	ErrServiceAccountKeyNotFound = errors.New("service account key not found")
+	ErrInvalidServiceAccountName = errors.New("name must be 1-100 characters")
+	ErrServiceAccountRole        = errors.New("role is not allowed for service accounts")
+	ErrTooManyServiceAccountKeys = errors.New("service account already has two keys; revoke one first")
+	ErrInvalidAPIKey             = errors.New("invalid API key")
+)
+
+// maxServiceAccountKeys allows a second key to be rolled out before the first is revoked.
+const maxServiceAccountKeys = 2
+
+// maxServiceAccountNameLength bounds a service account's name.
+const maxServiceAccountNameLength = 100
+
+// ServiceAccountOwner is who a service account operation acts for: an admin, who manages every
+// service account and creates ones the admins own, or, with OrganizationID, an organization admin
+// managing the organization's service accounts.
+type ServiceAccountOwner struct {
+	UserID         uuid.UUID
+	OrganizationID *uuid.UUID
+}
+
+// ServiceAccountService manages service accounts, the non-human identities scheduled jobs and
+// integrations act as, and the API keys they exchange for access tokens. Service accounts only hold
+// the roles allowed for them.
+type ServiceAccountService interface {
+	ListAccounts(ctx context.Context, owner ServiceAccountOwner) ([]*contracts.ServiceAccount, error)
+	CreateAccount(ctx context.Context, owner ServiceAccountOwner, name, description, role string) (*contracts.ServiceAccount, error)
+	GetAccount(ctx context.Context, owner ServiceAccountOwner, id uuid.UUID) (*contracts.ServiceAccount, error)
+	DeleteAccount(ctx context.Context, owner ServiceAccountOwner, id uuid.UUID) error
+	CreateKey(ctx context.Context, owner ServiceAccountOwner, accountID uuid.UUID) (*contracts.ServiceAccountKey, string, error)
+	RevokeKey(ctx context.Context, owner ServiceAccountOwner, accountID, keyID uuid.UUID) error
+	IssueAccessToken(ctx context.Context, apiKey string) (*AccessToken, error)
+}
+
+// serviceAccountService implements ServiceAccountService.
+type serviceAccountService struct {
+	pool           *sql.DB
+	accountRepo    repository.ServiceAccountRepository
+	keyRepo        repository.ServiceAccountKeyRepository
+	roleRepo       repository.RoleRepository
+	membershipRepo repository.MembershipRepository
+	auditRepo      repository.AuthEventRepository
+	tokenService   TokenService
+	secret         []byte
+	allowedRoles   []string
+	orgRoles       []string
+}
+
+// NewServiceAccountService creates a new service account service. Keys are hashed with secret, the
+// API key secret. Accounts the admins own may hold allowedRoles and those an organization owns orgRoles,
+// the first of each being the default.
+func NewServiceAccountService(pool *sql.DB, accountRepo repository.ServiceAccountRepository, keyRepo repository.ServiceAccountKeyRepository, roleRepo repository.RoleRepository, membershipRepo repository.MembershipRepository, auditRepo repository.AuthEventRepository, tokenService TokenService, secret string, allowedRoles, orgRoles []string) ServiceAccountService {
+	return &serviceAccountService{
+		pool:           pool,
+		accountRepo:    accountRepo,
+		keyRepo:        keyRepo,
+		roleRepo:       roleRepo,
+		membershipRepo: membershipRepo,
+		auditRepo:      auditRepo,
+		tokenService:   tokenService,
+		secret:         []byte(secret),
+		allowedRoles:   allowedRoles,
+		orgRoles:       orgRoles,
+	}
+}
+
+// ListAccounts returns the service accounts the owner manages, oldest first.
+func (s *serviceAccountService) ListAccounts(ctx context.Context, owner ServiceAccountOwner) ([]*contracts.ServiceAccount, error) {
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	accounts, err := s.accountRepo.List(ctx, s.pool, owner.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []*contracts.ServiceAccount{}
+	}
+	return accounts, nil
+}
+
+// CreateAccount creates a service account owned by the owner's organization, or by the admins. Without
+// a role it gets the default one of those the owner may grant.
+func (s *serviceAccountService) CreateAccount(ctx context.Context, owner ServiceAccountOwner, name, description, role string) (*contracts.ServiceAccount, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxServiceAccountNameLength {
+		return nil, ErrInvalidServiceAccountName
+	}
+	roles := s.allowedRoles
+	if owner.OrganizationID != nil {
+		roles = s.orgRoles
+	}
+	if role == "" {
+		role = roles[0]
+	}
+	if !slices.Contains(roles, role) {
+		return nil, ErrServiceAccountRole
+	}
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	if err := requireRoleExists(ctx, s.roleRepo, s.pool, role); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	account, err := s.accountRepo.Create(ctx, tx, name, strings.TrimSpace(description), role, owner.OrganizationID, &owner.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventServiceAccountCreated, nil, map[string]any{
+		"service_account_id": account.ID,
+		"organization_id":    account.OrganizationID,
+		"name":               account.Name,
+		"role":               account.Role,
+	})); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	account.Keys = []*contracts.ServiceAccountKey{}
+	return account, nil
+}
+
+// GetAccount returns one of the owner's service accounts with its keys.
+func (s *serviceAccountService) GetAccount(ctx context.Context, owner ServiceAccountOwner, id uuid.UUID) (*contracts.ServiceAccount, error) {
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return nil, err
+	}
+	account, err := s.accountRepo.FindByID(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	if !owns(owner, account) {
+		return nil, ErrServiceAccountNotFound
+	}
+	keys, err := s.keyRepo.ListByAccount(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		keys = []*contracts.ServiceAccountKey{}
+	}
+	account.Keys = keys
+	return account, nil
+}
+
+// DeleteAccount deletes one of the owner's service accounts with its keys. Access tokens already
+// issued to it stop being accepted by introspection and forward-auth at once, but APIs verifying them
+// themselves accept them until they expire.
+func (s *serviceAccountService) DeleteAccount(ctx context.Context, owner ServiceAccountOwner, id uuid.UUID) error {
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	account, err := s.accountRepo.LockByID(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !owns(owner, account) {
+		return ErrServiceAccountNotFound
+	}
+	if _, err := s.accountRepo.Delete(ctx, tx, id); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventServiceAccountDeleted, nil, map[string]any{
+		"service_account_id": account.ID,
+		"organization_id":    account.OrganizationID,
+	})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateKey creates an API key for one of the owner's service accounts and returns it with its
+// record; only its hash is stored. An account has at most two keys, so one can be rotated while the
+// other is in use.
+func (s *serviceAccountService) CreateKey(ctx context.Context, owner ServiceAccountOwner, accountID uuid.UUID) (*contracts.ServiceAccountKey, string, error) {
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return nil, "", err
+	}
+	prefix, apiKey, err := generateServiceAccountKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	// Locking the account serialises key creation, so concurrent requests can't exceed the limit
+	account, err := s.accountRepo.LockByID(ctx, tx, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !owns(owner, account) {
+		return nil, "", ErrServiceAccountNotFound
+	}
+	count, err := s.keyRepo.CountByAccount(ctx, tx, accountID)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxServiceAccountKeys {
+		return nil, "", ErrTooManyServiceAccountKeys
+	}
+
+	key, err := s.keyRepo.Create(ctx, tx, accountID, prefix, hmacToken(s.secret, apiKey))
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventServiceAccountKeyCreated, nil, map[string]any{
+		"service_account_id": accountID,
+		"key_id":             key.ID,
+		"prefix":             key.Prefix,
+	})); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return key, apiKey, nil
+}
+
+// RevokeKey deletes one of a service account's keys; it can't be exchanged from then on.
+func (s *serviceAccountService) RevokeKey(ctx context.Context, owner ServiceAccountOwner, accountID, keyID uuid.UUID) error {
+	if err := s.requireOwner(ctx, owner); err != nil {
+		return err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	account, err := s.accountRepo.LockByID(ctx, tx, accountID)
+	if err != nil {
+		return err
+	}
+	if !owns(owner, account) {
+		return ErrServiceAccountNotFound
+	}
+	deleted, err := s.keyRepo.Delete(ctx, tx, accountID, keyID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrServiceAccountKeyNotFound
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventServiceAccountKeyRevoked, nil, map[string]any{
+		"service_account_id": accountID,
+		"key_id":             keyID,
+	})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IssueAccessToken exchanges an API key for an access token for its service account, recording
+// the key's use.
+func (s *serviceAccountService) IssueAccessToken(ctx context.Context, apiKey string) (*AccessToken, error) {
+	if !strings.HasPrefix(apiKey, serviceAccountKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.keyRepo.FindByHash(ctx, s.pool, hmacToken(s.secret, apiKey))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	account, err := s.accountRepo.FindByID(ctx, s.pool, key.ServiceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.keyRepo.TouchLastUsed(ctx, s.pool, key.ID, time.Now()); err != nil {
+		log.Printf("couldn't record use of service account key %s: %v\n", key.ID, err)
+	}
+	return s.tokenService.IssueServiceAccountToken(ctx, account)
+}
+
+// requireOwner checks that an organization owner is an admin of the organization. Admin owners are
+// checked by the caller.
+func (s *serviceAccountService) requireOwner(ctx context.Context, owner ServiceAccountOwner) error {
+	if owner.OrganizationID == nil {
+		return nil
+	}
+	_, err := requireMembershipRole(ctx, s.membershipRepo, s.pool, owner.UserID, *owner.OrganizationID, contracts.MembershipRoleAdmin)
+	return err
+}
+
+// owns reports whether account exists and is managed by owner: admins manage every service account,
+// organizations their own.
+func owns(owner ServiceAccountOwner, account *contracts.ServiceAccount) bool {
+	if account == nil {
+		return false
+	}
+	if owner.OrganizationID == nil {
+		return true
+	}
+	return account.OrganizationID != nil && *account.OrganizationID == *owner.OrganizationID
+}
+
+// generateServiceAccountKey creates an API key, sa_<prefix>_<secret>, returning its prefix and the key.
+func generateServiceAccountKey() (string, string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix := hex.EncodeToString(id)
+	return prefix, serviceAccountKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
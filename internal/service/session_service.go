@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/audit"
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+var ErrSessionNotFound = errors.New("session is invalid or expired")
+
+// SessionService manages server-side browser sessions for backend-for-frontend mode. The browser only
+// holds an opaque session token; access tokens are minted per request by exchanging it. Each session
+// is backed by a refresh session, so every path revoking refresh tokens also ends it.
+type SessionService interface {
+	Start(ctx context.Context, user *contracts.UserDTO, organizationID *uuid.UUID) (string, *contracts.Session, error)
+	Exchange(ctx context.Context, token string) (*AccessToken, error)
+	End(ctx context.Context, token string) error
+	PurgeExpired(ctx context.Context) (int64, error)
+	RunPurge(ctx context.Context, interval time.Duration)
+}
+
+// sessionService implements SessionService.
+type sessionService struct {
+	pool            *sql.DB
+	sessionRepo     repository.SessionRepository
+	tokenService    TokenService
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
+}
+
+// NewSessionService creates a new session service. Sessions end after idleTimeout without use, and
+// absoluteTimeout after they started regardless of use.
+func NewSessionService(pool *sql.DB, sessionRepo repository.SessionRepository, tokenService TokenService, idleTimeout, absoluteTimeout time.Duration) SessionService {
+	return &sessionService{
+		pool:            pool,
+		sessionRepo:     sessionRepo,
+		tokenService:    tokenService,
+		idleTimeout:     idleTimeout,
+		absoluteTimeout: absoluteTimeout,
+	}
+}
+
+// Start begins a session for an authenticated user, acting in organizationID if given, and returns its
+// opaque token. Like any login it revokes the user's other sessions. The refresh token minted with it
+// never leaves the server.
+func (s *sessionService) Start(ctx context.Context, user *contracts.UserDTO, organizationID *uuid.UUID) (string, *contracts.Session, error) {
+	tokenPair, err := s.tokenService.CreateNewTokenPair(ctx, user.ID, user.Username, user.Role, organizationID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := generateSessionToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	req := audit.RequestFrom(ctx)
+	now := time.Now().UTC()
+	session := &contracts.Session{
+		ID:         tokenPair.SessionID,
+		UserID:     user.ID,
+		IP:         req.IP,
+		UserAgent:  req.UserAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.absoluteTimeout),
+	}
+	if err := s.sessionRepo.Create(ctx, s.pool, session, hashSessionToken(token)); err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// Exchange validates a session token and mints a short-lived access token for the session, extending
+// its idle timeout.
+func (s *sessionService) Exchange(ctx context.Context, token string) (*AccessToken, error) {
+	session, err := s.find(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.tokenService.IssueAccessToken(ctx, session.UserID, session.ID, session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.Touch(ctx, s.pool, session.ID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return accessToken, nil
+}
+
+// End revokes the session's refresh session and removes it. Ending an unknown or expired session
+// succeeds.
+func (s *sessionService) End(ctx context.Context, token string) error {
+	session, err := s.find(ctx, token)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokenService.RevokeSession(ctx, session.UserID, session.ID); err != nil {
+		return err
+	}
+	return s.sessionRepo.Delete(ctx, s.pool, session.ID)
+}
+
+// find returns the active session for token, checking its idle and absolute timeouts.
+func (s *sessionService) find(ctx context.Context, token string) (*contracts.Session, error) {
+	if token == "" {
+		return nil, ErrSessionNotFound
+	}
+	session, err := s.sessionRepo.FindActiveByHash(ctx, s.pool, hashSessionToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrSessionNotFound
+	}
+	now := time.Now()
+	if !now.Before(session.ExpiresAt) || now.Sub(session.LastSeenAt) >= s.idleTimeout {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// PurgeExpired removes sessions that timed out or whose refresh session was revoked, and returns how
+// many were removed.
+func (s *sessionService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.sessionRepo.DeleteExpired(ctx, s.pool, time.Now().Add(-s.idleTimeout))
+}
+
+// RunPurge calls PurgeExpired every interval until ctx is cancelled.
+func (s *sessionService) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.PurgeExpired(ctx)
+			if err != nil {
+				log.Printf("couldn't purge expired sessions: %v\n", err)
+				continue
+			}
+			if n > 0 {
+				log.Printf("purged %d expired sessions\n", n)
+			}
+		}
+	}
+}
+
+// generateSessionToken creates a cryptographically secure random session token (32 bytes, base64url-encoded).
+func generateSessionToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSessionToken computes the SHA-256 hash under which a session token is stored.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownProvider         = errors.New("unknown identity provider")
+	ErrInvalidLoginState       = errors.New("login state is invalid or expired")
+	ErrProviderLogin           = errors.New("identity provider login failed")
+	ErrIdentityEmailRequired   = errors.New("identity provider did not return an email address")
+	ErrIdentityEmailUnverified = errors.New("identity provider has not verified the email address")
+	ErrIdentityEmailInUse      = errors.New("an account with this email already exists; sign in with it to link the provider")
+	ErrIdentityLinkedElsewhere = errors.New("identity is linked to another account")
+	ErrMergeSourceInactive     = errors.New("the account linked to this identity is not active and can't be merged")
+)
+
+// OIDCProvider is an external OpenID Connect identity provider.
+type OIDCProvider interface {
+	// AuthCodeURL returns the URL to send the user to, carrying state, nonce and the PKCE challenge for codeVerifier.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange redeems an authorization code and returns the identity from the verified ID token.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*contracts.ExternalIdentity, error)
+}
+
+// SocialLoginResult is the outcome of a completed social login.
+type SocialLoginResult struct {
+	User           *contracts.UserDTO
+	Linked         bool       // the identity was linked to an already signed-in user instead of signing in
+	MergedUserID   *uuid.UUID // the account merged into User because it held the identity, if any
+	OrganizationID *uuid.UUID // the organization selected when the login started, if any
+}
+
+// SocialLoginService signs users in through external identity providers. First-time users are
+// provisioned without a password; an identity is never linked to an existing account by its email
+// alone, but signed-in users can link further identities to their account.
+type SocialLoginService interface {
+	Providers() []string
+	Begin(ctx context.Context, provider string, organizationID *uuid.UUID) (string, string, error)
+	BeginLink(ctx context.Context, provider string, userID uuid.UUID, allowMerge bool) (string, string, error)
+	Complete(ctx context.Context, provider, state, code string) (*SocialLoginResult, error)
+}
+
+// socialLoginService implements SocialLoginService.
+type socialLoginService struct {
+	pool             *sql.DB
+	providers        map[string]OIDCProvider
+	names            []string
+	userRepo         repository.UserRepository
+	identityRepo     repository.UserIdentityRepository
+	credentialRepo   repository.CredentialRepository
+	stateRepo        repository.OIDCLoginStateRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	auditRepo        repository.AuthEventRepository
+	outboxRepo       repository.OutboxRepository
+	stateTTL         time.Duration
+	defaultRole      string
+}
+
+// NewSocialLoginService creates a new social login service. names lists the configured providers in
+// display order. Logins must complete within stateTTL, and provisioned users get defaultRole.
+func NewSocialLoginService(pool *sql.DB, providers map[string]OIDCProvider, names []string, userRepo repository.UserRepository, identityRepo repository.UserIdentityRepository, credentialRepo repository.CredentialRepository, stateRepo repository.OIDCLoginStateRepository, refreshTokenRepo repository.RefreshTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, stateTTL time.Duration, defaultRole string) SocialLoginService {
+	return &socialLoginService{
+		pool:             pool,
+		providers:        providers,
+		names:            names,
+		userRepo:         userRepo,
+		identityRepo:     identityRepo,
+		credentialRepo:   credentialRepo,
+		stateRepo:        stateRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		auditRepo:        auditRepo,
+		outboxRepo:       outboxRepo,
+		stateTTL:         stateTTL,
+		defaultRole:      defaultRole,
+	}
+}
+
+// Providers returns the names of the configured providers.
+func (s *socialLoginService) Providers() []string {
+	return s.names
+}
+
+// Begin starts a login with provider and returns the provider URL to redirect the user to, and the
+// state the callback must carry. The state is bound to the browser by the caller. The session the login
+// ends in is to act in organizationID, if given.
+func (s *socialLoginService) Begin(ctx context.Context, provider string, organizationID *uuid.UUID) (string, string, error) {
+	return s.begin(ctx, provider, nil, false, organizationID)
+}
+
+// BeginLink starts a login with provider that links the identity to userID when completed, like Begin.
+// The caller must have re-authenticated the user. With allowMerge, an identity already linked to
+// another account merges that account into the user's.
+func (s *socialLoginService) BeginLink(ctx context.Context, provider string, userID uuid.UUID, allowMerge bool) (string, string, error) {
+	return s.begin(ctx, provider, &userID, allowMerge, nil)
+}
+
+func (s *socialLoginService) begin(ctx context.Context, provider string, linkUserID *uuid.UUID, allowMerge bool, organizationID *uuid.UUID) (string, string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := generateLoginSecret()
+		if err != nil {
+			return "", "", err
+		}
+		secrets[i] = secret
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrProviderLogin, err)
+	}
+
+	// Abandoned logins are swept here rather than by a worker; failing to do so mustn't block the login
+	if err := s.stateRepo.DeleteExpired(ctx, s.pool); err != nil {
+		log.Printf("couldn't delete expired login states: %v\n", err)
+	}
+	if err := s.stateRepo.Create(ctx, s.pool, hashLoginSecret(state), &contracts.OIDCLoginState{
+		Provider:       provider,
+		Nonce:          nonce,
+		CodeVerifier:   verifier,
+		LinkUserID:     linkUserID,
+		AllowMerge:     allowMerge,
+		OrganizationID: organizationID,
+		ExpiresAt:      time.Now().UTC().Add(s.stateTTL),
+	}); err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Complete finishes a login from the provider's callback and returns the signed-in user, or the user
+// the identity was linked to.
+func (s *socialLoginService) Complete(ctx context.Context, provider, state, code string) (*SocialLoginResult, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidLoginState
+	}
+
+	login, err := s.stateRepo.Consume(ctx, s.pool, hashLoginSecret(state), provider)
+	if err != nil {
+		return nil, err
+	}
+	if login == nil {
+		return nil, ErrInvalidLoginState
+	}
+
+	ext, err := p.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		s.recordLoginFailure(ctx, nil, provider, "provider_error")
+		return nil, fmt.Errorf("%w: %v", ErrProviderLogin, err)
+	}
+	if login.LinkUserID != nil {
+		return s.completeLink(ctx, *login.LinkUserID, ext, login.AllowMerge)
+	}
+
+	user, err := s.resolveUser(ctx, ext)
+	if err != nil {
+		return nil, err
+	}
+	// A fresh provider login proves presence as well as a password would, so it restores an account
+	// pending deletion
+	if user.Status == contracts.UserStatusPendingDeletion {
+		if user, err = cancelDeletion(ctx, s.pool, s.userRepo, s.auditRepo, user.ID, map[string]any{"provider": provider}); err != nil {
+			return nil, err
+		}
+	}
+	if !user.IsActive() {
+		s.recordLoginFailure(ctx, &user.ID, provider, "account_"+user.Status)
+		return nil, ErrAccountInactive
+	}
+
+	recordStandalone(ctx, s.auditRepo, s.pool, selfEvent(ctx, contracts.AuthEventLoginSucceeded, user.ID, map[string]any{"provider": provider}))
+	return &SocialLoginResult{User: user.ToDTO(), OrganizationID: login.OrganizationID}, nil
+}
+
+// completeLink links ext to the user who started the login. An identity already linked to another
+// account is refused unless merging was allowed, in which case that account is merged in: completing
+// the provider login proves control of it.
+func (s *socialLoginService) completeLink(ctx context.Context, userID uuid.UUID, ext *contracts.ExternalIdentity, allowMerge bool) (*SocialLoginResult, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+
+	identity, err := s.identityRepo.FindByProviderSubject(ctx, s.pool, ext.Provider, ext.Subject)
+	if err != nil {
+		return nil, err
+	}
+	result := &SocialLoginResult{User: user.ToDTO(), Linked: true}
+	switch {
+	case identity == nil:
+		if err := s.link(ctx, user, ext); err != nil {
+			return nil, err
+		}
+	case identity.UserID == user.ID:
+		// Already linked; nothing to do
+	case !allowMerge:
+		return nil, ErrIdentityLinkedElsewhere
+	default:
+		if err := s.merge(ctx, user, identity.UserID, ext.Provider); err != nil {
+			return nil, err
+		}
+		result.MergedUserID = &identity.UserID
+	}
+	return result, nil
+}
+
+// merge moves the identities of the user sourceID into target and deletes sourceID, as mergeAccounts
+// does. Accounts that aren't active can't be merged, so that merging can't lift a suspension.
+func (s *socialLoginService) merge(ctx context.Context, target *contracts.User, sourceID uuid.UUID, provider string) error {
+	source, err := s.userRepo.FindByID(ctx, s.pool, sourceID)
+	if err != nil {
+		return err
+	}
+	if source == nil {
+		return ErrUserNotFound
+	}
+	if !source.IsActive() {
+		return ErrMergeSourceInactive
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := mergeAccounts(ctx, tx, s.credentialRepo, s.refreshTokenRepo, s.userRepo, s.auditRepo, s.outboxRepo, target, source, map[string]any{
+		"merged_user_id": source.ID,
+		"provider":       provider,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// resolveUser finds the user linked to ext, linking or provisioning one on first sign-in.
+func (s *socialLoginService) resolveUser(ctx context.Context, ext *contracts.ExternalIdentity) (*contracts.User, error) {
+	identity, err := s.identityRepo.FindByProviderSubject(ctx, s.pool, ext.Provider, ext.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		user, err := s.userRepo.FindByID(ctx, s.pool, identity.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		if err := s.identityRepo.TouchLogin(ctx, s.pool, identity.ID, optionalEmail(ext), time.Now().UTC()); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if ext.Email == "" {
+		return nil, ErrIdentityEmailRequired
+	}
+	existing, err := s.userRepo.FindByEmail(ctx, s.pool, ext.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// Local emails aren't verified, so whoever registered the address first may not own it;
+		// linking on it would hand them the provider user's sign-ins. The owner links from the
+		// signed-in account instead.
+		s.recordLoginFailure(ctx, &existing.ID, ext.Provider, "email_in_use")
+		return nil, ErrIdentityEmailInUse
+	}
+	// Provisioning on an unverified email would keep its real owner from registering it
+	if !ext.EmailVerified {
+		s.recordLoginFailure(ctx, nil, ext.Provider, "email_unverified")
+		return nil, ErrIdentityEmailUnverified
+	}
+	return s.provision(ctx, ext)
+}
+
+// link attaches ext to an existing user.
+func (s *socialLoginService) link(ctx context.Context, user *contracts.User, ext *contracts.ExternalIdentity) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if _, err := s.identityRepo.Create(ctx, tx, user.ID, ext.Provider, ext.Subject, optionalEmail(ext)); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventIdentityLinked, user.ID, map[string]any{"provider": ext.Provider})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// provision creates a passwordless user for ext.
+func (s *socialLoginService) provision(ctx context.Context, ext *contracts.ExternalIdentity) (*contracts.User, error) {
+	username, err := availableUsername(ctx, s.userRepo, s.pool, ext.Username, ext.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure transaction is rolled back if any step fails (safe to do if commit has already happened)
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	user, err := s.userRepo.Create(ctx, tx, username, ext.Email, s.defaultRole)
+	if repository.IsUniqueViolation(err) {
+		// The username or email was taken since they were checked
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.identityRepo.Create(ctx, tx, user.ID, ext.Provider, ext.Subject, optionalEmail(ext)); err != nil {
+		return nil, err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventRegister, user.ID, map[string]any{"role": user.Role, "provider": ext.Provider})); err != nil {
+		return nil, err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventUserRegistered, contracts.UserRegisteredEventData{
+		UserID:   user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		Role:     user.Role,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// availableUsername derives a free username for a user provisioned from an external identity: the
+// username the identity provider preferred, or the email's local part, adding a random suffix if it
+// is taken.
+func availableUsername(ctx context.Context, userRepo repository.UserRepository, db *sql.DB, preferred, email string) (string, error) {
+	base := strings.TrimSpace(preferred)
+	if base == "" {
+		base, _, _ = strings.Cut(email, "@")
+	}
+
+	candidate := base
+	for range 5 {
+		existing, err := userRepo.FindByUsername(ctx, db, candidate)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(10000))
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%04d", base, n.Int64())
+	}
+	return "", ErrUserExists
+}
+
+// recordLoginFailure records a failed social login; subjectID is nil when no user matched.
+func (s *socialLoginService) recordLoginFailure(ctx context.Context, subjectID *uuid.UUID, provider, reason string) {
+	recordStandalone(ctx, s.auditRepo, s.pool, authEvent(ctx, contracts.AuthEventLoginFailed, subjectID, map[string]any{"provider": provider, "reason": reason}))
+}
+
+// optionalEmail returns the identity's email for storage, or nil if the provider didn't supply one.
+func optionalEmail(ext *contracts.ExternalIdentity) *string {
+	if ext.Email == "" {
+		return nil
+	}
+	return &ext.Email
+}
+
+// generateLoginSecret creates a cryptographically secure random state, nonce or PKCE verifier (32 bytes, base64url-encoded).
+func generateLoginSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashLoginSecret computes the SHA-256 hash under which a login state is stored.
+func hashLoginSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
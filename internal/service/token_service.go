@@ -9,68 +9,138 @@ import (
 	"encoding/base64"
 	"errors"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
 	"github.com/LittleAksMax/bids-auth-service/internal/repository"
 	"github.com/LittleAksMax/bids-util/requests"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
+var ErrRefreshTokenReused = errors.New("refresh token reused; all sessions have been revoked")
+
 // TokenPair represents an access and refresh token pair.
 type TokenPair struct {
 	AccessToken  string
 	RefreshToken string
+	SessionID    uuid.UUID
+}
+
+// AccessToken is an access token minted for an existing session.
+type AccessToken struct {
+	Token     string
+	ExpiresAt time.Time
 }
 
 type TokenService interface {
-	CreateNewTokenPair(ctx context.Context, userID uuid.UUID, username, role string) (*TokenPair, error)
-	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	CreateNewTokenPair(ctx context.Context, userID uuid.UUID, username, role string, organizationID *uuid.UUID) (*TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string, organizationID *uuid.UUID) (*TokenPair, error)
 	Logout(ctx context.Context, refreshToken string) error
+	IssueAccessToken(ctx context.Context, userID, sessionID uuid.UUID, authenticatedAt time.Time) (*AccessToken, error)
+	IssueScopedAccessToken(claims *AccessTokenClaims) (*AccessToken, error)
+	IssueServiceAccountToken(ctx context.Context, account *contracts.ServiceAccount) (*AccessToken, error)
+	RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error
+	ParseAccessToken(accessToken string) (*AccessTokenClaims, error)
+	CheckAccessToken(ctx context.Context, claims *AccessTokenClaims) (*AccessTokenClaims, error)
 }
 
+// AccessTokenClaims adds the session ID, the time the user last authenticated in the session
+// (auth_time, omitted if unknown), the organization the session acts in with the user's role there
+// (omitted if none is selected), the space-separated permissions of the user's role (omitted
+// unless enabled) and, for service accounts and personal access tokens, the subject type to the
+// claims shared with other bids services.
+type AccessTokenClaims struct {
+	requests.Claims
+	SessionID        string           `json:"sid"`
+	AuthTime         *jwt.NumericDate `json:"auth_time,omitempty"`
+	OrganizationID   string           `json:"org_id,omitempty"`
+	OrganizationRole string           `json:"org_role,omitempty"`
+	Scope            string           `json:"scope,omitempty"`
+	SubjectType      string           `json:"sub_type,omitempty"`
+}
+
+// Subject types mark access tokens that weren't issued to a user's session.
+const (
+	// SubjectTypeServiceAccount marks access tokens issued to service accounts rather than users.
+	SubjectTypeServiceAccount = "service_account"
+	// SubjectTypePersonalAccessToken marks access tokens exchanged for a personal access token, which
+	// act with the token's scope rather than the user's role.
+	SubjectTypePersonalAccessToken = "personal_access_token"
+)
+
 type tokenService struct {
-	pool             *sql.DB
-	refreshTokenRepo repository.RefreshTokenRepository
-	userRepo         repository.UserRepository
-	accessSecret     []byte
-	refreshSecret    []byte
-	accessTTL        time.Duration
-	refreshTTL       time.Duration
-	issuer           string
-	audience         string
-}
-
-func NewTokenService(pool *sql.DB, refreshTokenRepo repository.RefreshTokenRepository, userRepo repository.UserRepository, accessSecret, refreshSecret string, accessTTL, refreshTTL time.Duration, issuer, audience string) TokenService {
+	pool               *sql.DB
+	refreshTokenRepo   repository.RefreshTokenRepository
+	userRepo           repository.UserRepository
+	membershipRepo     repository.MembershipRepository
+	serviceAccountRepo repository.ServiceAccountRepository
+	permissionRepo     repository.PermissionRepository
+	patRepo            repository.PersonalAccessTokenRepository
+	auditRepo          repository.AuthEventRepository
+	outboxRepo         repository.OutboxRepository
+	includeScope       bool
+	accessSecret       []byte
+	refreshSecret      []byte
+	accessTTL          time.Duration
+	refreshTTL         time.Duration
+	issuer             string
+	audience           string
+}
+
+// NewTokenService creates a new token service. Access tokens carry the permissions of the user's role
+// in their scope claim when includeScope is set.
+func NewTokenService(pool *sql.DB, refreshTokenRepo repository.RefreshTokenRepository, userRepo repository.UserRepository, membershipRepo repository.MembershipRepository, serviceAccountRepo repository.ServiceAccountRepository, permissionRepo repository.PermissionRepository, patRepo repository.PersonalAccessTokenRepository, auditRepo repository.AuthEventRepository, outboxRepo repository.OutboxRepository, includeScope bool, accessSecret, refreshSecret string, accessTTL, refreshTTL time.Duration, issuer, audience string) TokenService {
 	return &tokenService{
-		pool:             pool,
-		refreshTokenRepo: refreshTokenRepo,
-		userRepo:         userRepo,
-		accessSecret:     []byte(accessSecret),
-		refreshSecret:    []byte(refreshSecret),
-		accessTTL:        accessTTL,
-		refreshTTL:       refreshTTL,
-		issuer:           issuer,
-		audience:         audience,
+		pool:               pool,
+		refreshTokenRepo:   refreshTokenRepo,
+		userRepo:           userRepo,
+		membershipRepo:     membershipRepo,
+		serviceAccountRepo: serviceAccountRepo,
+		permissionRepo:     permissionRepo,
+		patRepo:            patRepo,
+		auditRepo:          auditRepo,
+		outboxRepo:         outboxRepo,
+		includeScope:       includeScope,
+		accessSecret:       []byte(accessSecret),
+		refreshSecret:      []byte(refreshSecret),
+		accessTTL:          accessTTL,
+		refreshTTL:         refreshTTL,
+		issuer:             issuer,
+		audience:           audience,
 	}
 }
 
-// GenerateAccessToken creates a signed JWT with the specified claims.
-func (s *tokenService) generateAccessToken(userID uuid.UUID, username, role string) (string, error) {
+// GenerateAccessToken creates a signed JWT with the specified claims. membership is the user's
+// membership of the organization the session acts in, nil if none, and scope the claim built by
+// s.scope.
+func (s *tokenService) generateAccessToken(userID, sessionID uuid.UUID, username, role string, authenticatedAt *time.Time, membership *contracts.Membership, scope string) (string, error) {
 	now := time.Now()
 	jti := uuid.New().String()
 
-	claims := requests.Claims{
-		Role: role,
-		Name: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			Subject:   userID.String(),
-			Issuer:    s.issuer,
-			Audience:  jwt.ClaimStrings{s.audience},
-			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
-			ID:        jti,
+	claims := AccessTokenClaims{
+		Claims: requests.Claims{
+			Role: role,
+			Name: username,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				Issuer:    s.issuer,
+				Audience:  jwt.ClaimStrings{s.audience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+				ID:        jti,
+			},
 		},
+		SessionID: sessionID.String(),
+		Scope:     scope,
+	}
+	if authenticatedAt != nil {
+		claims.AuthTime = jwt.NewNumericDate(*authenticatedAt)
+	}
+	if membership != nil {
+		claims.OrganizationID = membership.OrganizationID.String()
+		claims.OrganizationRole = membership.Role
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -86,7 +156,21 @@ func (s *tokenService) generateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-func (s *tokenService) CreateNewTokenPair(ctx context.Context, userID uuid.UUID, username, role string) (*TokenPair, error) { // Parse userID string to UUID
+// CreateNewTokenPair starts a new session for a user, acting in organizationID if given, which the
+// user must be a member of.
+func (s *tokenService) CreateNewTokenPair(ctx context.Context, userID uuid.UUID, username, role string, organizationID *uuid.UUID) (*TokenPair, error) {
+	membership, err := s.findMembership(ctx, userID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if organizationID != nil && membership == nil {
+		return nil, ErrNotOrganizationMember
+	}
+	scope, err := s.scope(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+
 	// Transaction for atomic revocation + creation of tokens
 	tx, err := s.pool.BeginTx(ctx, nil)
 	if err != nil {
@@ -103,11 +187,14 @@ func (s *tokenService) CreateNewTokenPair(ctx context.Context, userID uuid.UUID,
 	}()
 
 	// Revoke all existing refresh tokens for this user to avoid multiple active sessions
-	if err := s.refreshTokenRepo.RevokeAllForUser(ctx, tx, userID); err != nil {
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, userID, contracts.SessionRevokedNewLogin); err != nil {
 		return nil, err
 	}
 
-	accessToken, err := s.generateAccessToken(userID, username, role)
+	// Every login starts a new session, authenticated now
+	sessionID := uuid.New()
+	issuedAt := time.Now().UTC()
+	accessToken, err := s.generateAccessToken(userID, sessionID, username, role, &issuedAt, membership, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -118,10 +205,9 @@ func (s *tokenService) CreateNewTokenPair(ctx context.Context, userID uuid.UUID,
 
 	// Hash and store refresh token in database
 	tokenHash := s.hashRefreshToken(refreshToken)
-	issuedAt := time.Now().UTC()
 	expiresAt := issuedAt.Add(s.refreshTTL)
 
-	_, err = s.refreshTokenRepo.Create(ctx, tx, userID, tokenHash, issuedAt, expiresAt)
+	_, err = s.refreshTokenRepo.Create(ctx, tx, userID, sessionID, tokenHash, issuedAt, expiresAt, &issuedAt, membershipOrganization(membership))
 	if err != nil {
 		return nil, err
 	}
@@ -130,11 +216,13 @@ func (s *tokenService) CreateNewTokenPair(ctx context.Context, userID uuid.UUID,
 		log.Printf("couldn't commit transaction: %v\n", err)
 	}
 
-	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, SessionID: sessionID}, nil
 }
 
-// Refresh validates a refresh token, rotates it, and returns a new access+refresh token pair.
-func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+// Refresh validates a refresh token, rotates it, and returns a new access+refresh token pair. Given
+// an organizationID, which the user must be a member of, the session switches to acting in it;
+// otherwise it keeps its organization for as long as the user remains a member.
+func (s *tokenService) Refresh(ctx context.Context, refreshToken string, organizationID *uuid.UUID) (*TokenPair, error) {
 	if refreshToken == "" {
 		return nil, errors.New("missing refresh token")
 	}
@@ -148,8 +236,15 @@ func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*Token
 	if existing == nil {
 		return nil, errors.New("invalid refresh token")
 	}
-	// Validate not revoked and not expired
+	// Validate not revoked and not expired. A token that was already rotated being presented again
+	// means it leaked, so every session of the user is revoked.
 	if existing.RevokedAt != nil {
+		if existing.ReplacedByTokenID != nil {
+			if err := s.revokeReusedToken(ctx, existing); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
 		return nil, errors.New("refresh token revoked")
 	}
 	if time.Now().After(existing.ExpiresAt) {
@@ -164,6 +259,20 @@ func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*Token
 	if user == nil {
 		return nil, errors.New("user not found for refresh token")
 	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+	selected := existing.OrganizationID
+	if organizationID != nil {
+		selected = organizationID
+	}
+	membership, err := s.findMembership(ctx, user.ID, selected)
+	if err != nil {
+		return nil, err
+	}
+	if organizationID != nil && membership == nil {
+		return nil, ErrNotOrganizationMember
+	}
 
 	// Begin rotation transaction
 	tx, err := s.pool.BeginTx(ctx, nil)
@@ -188,7 +297,7 @@ func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*Token
 	newHash := s.hashRefreshToken(newRefresh)
 	issuedAt := time.Now().UTC()
 	expiresAt := issuedAt.Add(s.refreshTTL)
-	newTokenID, err := s.refreshTokenRepo.Create(ctx, tx, existing.UserID, newHash, issuedAt, expiresAt)
+	newTokenID, err := s.refreshTokenRepo.Create(ctx, tx, existing.UserID, existing.SessionID, newHash, issuedAt, expiresAt, existing.AuthenticatedAt, membershipOrganization(membership))
 	if err != nil {
 		return nil, err
 	}
@@ -197,9 +306,23 @@ func (s *tokenService) Refresh(ctx context.Context, refreshToken string) (*Token
 	if err := s.refreshTokenRepo.RevokeWithReplacement(ctx, tx, existing.TokenID, newTokenID); err != nil {
 		return nil, err
 	}
+	metadata := map[string]any{
+		"token_id":     existing.TokenID,
+		"new_token_id": newTokenID,
+	}
+	if organizationID != nil {
+		metadata["organization_id"] = *organizationID
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventTokenRefreshed, user.ID, metadata)); err != nil {
+		return nil, err
+	}
 
 	// Generate new access token for user
-	accessToken, err := s.generateAccessToken(user.ID, user.Username, user.Role)
+	scope, err := s.scope(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := s.generateAccessToken(user.ID, existing.SessionID, user.Username, user.Role, existing.AuthenticatedAt, membership, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -228,13 +351,338 @@ func (s *tokenService) Logout(ctx context.Context, refreshToken string) error {
 		return nil // already revoked: success
 	}
 
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
 	// Revoke single token (no replacement)
-	return s.refreshTokenRepo.Revoke(ctx, s.pool, existing.TokenID)
+	if err := s.refreshTokenRepo.Revoke(ctx, tx, existing.TokenID); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventLogout, existing.UserID, map[string]any{"token_id": existing.TokenID})); err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventSessionRevoked, contracts.SessionRevokedEventData{
+		UserID:     existing.UserID,
+		SessionIDs: []uuid.UUID{existing.SessionID},
+		Reason:     contracts.SessionRevokedLogout,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// IssueAccessToken mints an access token for an existing session without rotating its refresh token,
+// acting in the session's organization while the user remains a member. The caller must have checked
+// that the session is still active, and supplies when it was authenticated.
+func (s *tokenService) IssueAccessToken(ctx context.Context, userID, sessionID uuid.UUID, authenticatedAt time.Time) (*AccessToken, error) {
+	user, err := s.userRepo.FindByID(ctx, s.pool, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsActive() {
+		return nil, ErrAccountInactive
+	}
+
+	var membership *contracts.Membership
+	current, err := s.refreshTokenRepo.FindActiveBySession(ctx, s.pool, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if membership, err = s.findMembership(ctx, user.ID, current.OrganizationID); err != nil {
+			return nil, err
+		}
+	}
+
+	scope, err := s.scope(ctx, user.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(s.accessTTL)
+	accessToken, err := s.generateAccessToken(user.ID, sessionID, user.Username, user.Role, &authenticatedAt, membership, scope)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessToken{Token: accessToken, ExpiresAt: expiresAt}, nil
+}
+
+// IssueScopedAccessToken mints a session-less access token for the claims of a personal access token,
+// carrying its subject and scope but not the user's role, that expires with them if sooner than usual.
+// It is marked as a personal access token's so it isn't mistaken for a session's, and its ID is the
+// personal access token's, so it stops being accepted once that is revoked.
+func (s *tokenService) IssueScopedAccessToken(claims *AccessTokenClaims) (*AccessToken, error) {
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(s.accessTTL)
+	if claims.ExpiresAt != nil && claims.ExpiresAt.Before(expiresAt) {
+		expiresAt = claims.ExpiresAt.Time
+	}
+
+	minted := AccessTokenClaims{
+		Claims: requests.Claims{
+			Name: claims.Name,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   userID.String(),
+				Issuer:    s.issuer,
+				Audience:  jwt.ClaimStrings{s.audience},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				ID:        tokenID.String(),
+			},
+		},
+		Scope:       claims.Scope,
+		SubjectType: SubjectTypePersonalAccessToken,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, minted).SignedString(s.accessSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// IssueServiceAccountToken mints a session-less access token for a service account, acting in the
+// organization that owns it, if any.
+func (s *tokenService) IssueServiceAccountToken(ctx context.Context, account *contracts.ServiceAccount) (*AccessToken, error) {
+	scope, err := s.scope(ctx, account.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.accessTTL)
+	claims := AccessTokenClaims{
+		Claims: requests.Claims{
+			Role: account.Role,
+			Name: account.Name,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   account.ID.String(),
+				Issuer:    s.issuer,
+				Audience:  jwt.ClaimStrings{s.audience},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+				ID:        uuid.New().String(),
+			},
+		},
+		Scope:       scope,
+		SubjectType: SubjectTypeServiceAccount,
+	}
+	if account.OrganizationID != nil {
+		claims.OrganizationID = account.OrganizationID.String()
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.accessSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &AccessToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// RevokeSession revokes every refresh token of a session (idempotent), as Logout does for a single token.
+func (s *tokenService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	revoked, err := s.refreshTokenRepo.RevokeSession(ctx, tx, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return nil // already revoked: success
+	}
+	if err := s.auditRepo.Create(ctx, tx, selfEvent(ctx, contracts.AuthEventLogout, userID, map[string]any{"session_id": sessionID})); err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, s.outboxRepo, tx, contracts.DomainEventSessionRevoked, contracts.SessionRevokedEventData{
+		UserID:     userID,
+		SessionIDs: []uuid.UUID{sessionID},
+		Reason:     contracts.SessionRevokedLogout,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// revokeReusedToken revokes every refresh token of the owner of a reused token and records the reuse.
+func (s *tokenService) revokeReusedToken(ctx context.Context, reused *contracts.RefreshToken) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	if err := revokeSessions(ctx, s.refreshTokenRepo, s.outboxRepo, tx, reused.UserID, contracts.SessionRevokedTokenReuse); err != nil {
+		return err
+	}
+	if err := s.auditRepo.Create(ctx, tx, authEvent(ctx, contracts.AuthEventRefreshTokenReused, &reused.UserID, map[string]any{"token_id": reused.TokenID})); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ParseAccessToken verifies an access token issued by this service and returns its claims.
+func (s *tokenService) ParseAccessToken(accessToken string) (*AccessTokenClaims, error) {
+	claims := &AccessTokenClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.accessSecret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CheckAccessToken returns the claims a verified access token can still act with, or nil if what it
+// was issued to can no longer act: a service account must still exist, and a user must still be
+// active, and for a session token still be signed in to the session. A personal access token's must
+// still exist unexpired, and acts with only the scopes the user's role still grants. Otherwise the
+// token is only valid until it expires.
+func (s *tokenService) CheckAccessToken(ctx context.Context, claims *AccessTokenClaims) (*AccessTokenClaims, error) {
+	subjectID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, nil
+	}
+	if claims.SubjectType == SubjectTypeServiceAccount {
+		account, err := s.serviceAccountRepo.FindByID(ctx, s.pool, subjectID)
+		if err != nil || account == nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+
+	user, err := s.userRepo.FindByID(ctx, s.pool, subjectID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive() {
+		return nil, nil
+	}
+	if claims.SubjectType == SubjectTypePersonalAccessToken {
+		return s.checkPersonalAccessToken(ctx, claims, user)
+	}
+	sessionID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, nil
+	}
+	session, err := s.refreshTokenRepo.FindActiveBySession(ctx, s.pool, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != subjectID {
+		return nil, nil
+	}
+	return claims, nil
+}
+
+// checkPersonalAccessToken returns the claims of an access token exchanged for one of user's personal
+// access tokens, with its scope narrowed to what their role still grants, or nil if the personal
+// access token was revoked or expired or none of its scope is still granted.
+func (s *tokenService) checkPersonalAccessToken(ctx context.Context, claims *AccessTokenClaims, user *contracts.User) (*AccessTokenClaims, error) {
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, nil
+	}
+	record, err := s.patRepo.FindByID(ctx, s.pool, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.UserID != user.ID || !record.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	granted, err := s.permissionRepo.ListByRole(ctx, s.pool, user.Role)
+	if err != nil {
+		return nil, err
+	}
+	scopes := grantedScopes(granted, strings.Fields(claims.Scope))
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+	checked := *claims
+	checked.Scope = strings.Join(scopes, " ")
+	return &checked, nil
+}
+
+// findMembership returns the user's membership of an organization, or nil if organizationID is nil
+// or they aren't a member.
+func (s *tokenService) findMembership(ctx context.Context, userID uuid.UUID, organizationID *uuid.UUID) (*contracts.Membership, error) {
+	if organizationID == nil {
+		return nil, nil
+	}
+	return s.membershipRepo.Find(ctx, s.pool, *organizationID, userID)
+}
+
+// membershipOrganization returns the organization of a membership, or nil for none.
+func membershipOrganization(membership *contracts.Membership) *uuid.UUID {
+	if membership == nil {
+		return nil
+	}
+	return &membership.OrganizationID
+}
+
+// scope returns the permissions role grants as a space-separated scope claim, or "" when access
+// tokens don't carry permissions.
+func (s *tokenService) scope(ctx context.Context, role string) (string, error) {
+	if !s.includeScope {
+		return "", nil
+	}
+	permissions, err := s.permissionRepo.ListByRole(ctx, s.pool, role)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(permissions, " "), nil
 }
 
 // hashRefreshToken computes HMAC-SHA256 hash of the refresh token using the refresh secret.
 func (s *tokenService) hashRefreshToken(token string) string {
-	h := hmac.New(sha256.New, s.refreshSecret)
+	return hmacToken(s.refreshSecret, token)
+}
+
+// hmacToken computes the HMAC-SHA256 hash under which an opaque token is stored.
+func hmacToken(secret []byte, token string) string {
+	h := hmac.New(sha256.New, secret)
 	h.Write([]byte(token))
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrInvalidWebhookURL       = errors.New("webhook url must be an absolute http or https url")
+	ErrInvalidWebhookEventType = errors.New("unknown event type")
+	ErrDeadLetterNotFound      = errors.New("dead letter not found")
+	ErrDeadLetterQueued        = errors.New("the dead letter's event is already queued for delivery")
+)
+
+// WebhookSender delivers a signed payload to a partner URL and returns the response status, or 0 with
+// an error when no response was received.
+type WebhookSender interface {
+	Send(ctx context.Context, url, secret string, payload []byte, eventID uuid.UUID, eventType string) (int, error)
+}
+
+// WebhookService manages partner webhook subscriptions and delivers domain events to them. It is an
+// EventSink: publishing an event queues a delivery for every matching subscription.
+type WebhookService interface {
+	ListSubscriptions(ctx context.Context) ([]*contracts.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id uuid.UUID) (*contracts.WebhookSubscription, error)
+	CreateSubscription(ctx context.Context, url string, eventTypes []string, description string) (*contracts.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id uuid.UUID, url string, eventTypes []string, description string, active bool) (*contracts.WebhookSubscription, error)
+	RotateSecret(ctx context.Context, id uuid.UUID) (*contracts.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+	ListDeadLetters(ctx context.Context, subscriptionID *uuid.UUID, beforeID int64, limit int) (*WebhookDeadLetterPage, error)
+	ReplayDeadLetter(ctx context.Context, id int64) error
+	Publish(ctx context.Context, event contracts.DomainEventDTO) error
+	DeliverDue(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+// WebhookDeadLetterPage is one page of the webhook dead-letter queue.
+type WebhookDeadLetterPage struct {
+	DeadLetters []*contracts.WebhookDeadLetter
+	NextCursor  int64 // ID to pass as beforeID for the next page; 0 on the last page
+}
+
+// webhookService implements WebhookService.
+type webhookService struct {
+	pool             *sql.DB
+	subscriptionRepo repository.WebhookSubscriptionRepository
+	deliveryRepo     repository.WebhookDeliveryRepository
+	sender           WebhookSender
+	maxAttempts      int
+	batchSize        int
+	lease            time.Duration
+}
+
+// NewWebhookService creates a new webhook service. Deliveries are attempted up to maxAttempts times
+// before being dead-lettered, and up to batchSize are sent per pass. Claimed deliveries are left to
+// other dispatchers for lease, which should cover sending a whole batch.
+func NewWebhookService(pool *sql.DB, subscriptionRepo repository.WebhookSubscriptionRepository, deliveryRepo repository.WebhookDeliveryRepository, sender WebhookSender, maxAttempts, batchSize int, lease time.Duration) WebhookService {
+	return &webhookService{
+		pool:             pool,
+		subscriptionRepo: subscriptionRepo,
+		deliveryRepo:     deliveryRepo,
+		sender:           sender,
+		maxAttempts:      maxAttempts,
+		batchSize:        batchSize,
+		lease:            lease,
+	}
+}
+
+// ListSubscriptions returns every subscription.
+func (s *webhookService) ListSubscriptions(ctx context.Context) ([]*contracts.WebhookSubscription, error) {
+	return s.subscriptionRepo.List(ctx, s.pool)
+}
+
+// GetSubscription returns a single subscription.
+func (s *webhookService) GetSubscription(ctx context.Context, id uuid.UUID) (*contracts.WebhookSubscription, error) {
+	sub, err := s.subscriptionRepo.FindByID(ctx, s.pool, id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return sub, nil
+}
+
+// CreateSubscription registers a URL for the given event types (all when empty) with a new secret.
+func (s *webhookService) CreateSubscription(ctx context.Context, url string, eventTypes []string, description string) (*contracts.WebhookSubscription, error) {
+	if err := validateWebhook(url, eventTypes); err != nil {
+		return nil, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	sub, err := s.subscriptionRepo.Create(ctx, tx, url, secret, eventTypes, description)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// UpdateSubscription changes a subscription's URL, event types, description and whether it is active.
+// Deliveries to an inactive subscription are held until it is reactivated.
+func (s *webhookService) UpdateSubscription(ctx context.Context, id uuid.UUID, url string, eventTypes []string, description string, active bool) (*contracts.WebhookSubscription, error) {
+	if err := validateWebhook(url, eventTypes); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	sub, err := s.subscriptionRepo.Update(ctx, tx, id, url, eventTypes, description, active)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// RotateSecret replaces a subscription's signing secret. Pending deliveries are signed with the new one.
+func (s *webhookService) RotateSecret(ctx context.Context, id uuid.UUID) (*contracts.WebhookSubscription, error) {
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	sub, err := s.subscriptionRepo.UpdateSecret(ctx, tx, id, secret)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// DeleteSubscription removes a subscription together with its pending deliveries and dead letters.
+func (s *webhookService) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	deleted, err := s.subscriptionRepo.Delete(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return tx.Commit()
+}
+
+// ListDeadLetters returns up to limit dead letters, newest first, with IDs below beforeID.
+func (s *webhookService) ListDeadLetters(ctx context.Context, subscriptionID *uuid.UUID, beforeID int64, limit int) (*WebhookDeadLetterPage, error) {
+	// Fetch one extra row to learn whether another page follows
+	letters, err := s.deliveryRepo.ListDeadLetters(ctx, s.pool, subscriptionID, beforeID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &WebhookDeadLetterPage{DeadLetters: letters}
+	if len(letters) > limit {
+		page.DeadLetters = letters[:limit]
+		page.NextCursor = page.DeadLetters[limit-1].ID
+	}
+	if page.DeadLetters == nil {
+		page.DeadLetters = []*contracts.WebhookDeadLetter{}
+	}
+	return page, nil
+}
+
+// ReplayDeadLetter queues a dead letter for delivery again with a fresh set of attempts. A dead
+// letter whose event is already queued for its subscription is kept and ErrDeadLetterQueued returned.
+func (s *webhookService) ReplayDeadLetter(ctx context.Context, id int64) error {
+	tx, err := s.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(); err != nil {
+			if errors.Is(err, sql.ErrTxDone) {
+				// already committed or rolled back; no-op
+				return
+			}
+			log.Printf("couldn't rollback transaction: %v\n", err)
+		}
+	}()
+
+	replayed, err := s.deliveryRepo.Replay(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if !replayed {
+		exists, err := s.deliveryRepo.DeadLetterExists(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrDeadLetterQueued
+		}
+		return ErrDeadLetterNotFound
+	}
+	return tx.Commit()
+}
+
+// Publish queues event for every active subscription that receives its type. Queueing is idempotent,
+// so an event redelivered by the outbox relay is only sent once per subscription.
+func (s *webhookService) Publish(ctx context.Context, event contracts.DomainEventDTO) error {
+	subs, err := s.subscriptionRepo.ListActiveForEvent(ctx, s.pool, event.Type)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if err := s.deliveryRepo.Enqueue(ctx, s.pool, sub.ID, event.ID, event.Type, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliverDue sends one batch of due deliveries and returns how many succeeded. Failures are retried
+// with exponential backoff; after maxAttempts they are moved to the dead-letter queue. Deliveries are
+// claimed for the lease up front so no transaction is held open while partners respond; one left
+// unmarked by a crash is sent again once its claim lapses.
+func (s *webhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.deliveryRepo.ClaimDue(ctx, s.pool, s.batchSize, time.Now().Add(s.lease))
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, d := range deliveries {
+		status, err := s.sender.Send(ctx, d.URL, d.Secret, d.Payload, d.EventID, d.EventType)
+		if err == nil && (status < 200 || status > 299) {
+			err = fmt.Errorf("webhook responded with status %d", status)
+		}
+		if err == nil {
+			if err := s.deliveryRepo.Delete(ctx, s.pool, d.ID); err != nil {
+				return delivered, err
+			}
+			delivered++
+			continue
+		}
+
+		var lastStatus *int
+		if status != 0 {
+			lastStatus = &status
+		}
+		if d.Attempts+1 >= s.maxAttempts {
+			log.Printf("webhook delivery %d of %s event %s dead-lettered: %v\n", d.ID, d.EventType, d.EventID, err)
+			if err := s.deliveryRepo.MoveToDeadLetter(ctx, s.pool, d.ID, lastStatus, err.Error()); err != nil {
+				return delivered, err
+			}
+			continue
+		}
+		if err := s.deliveryRepo.MarkFailed(ctx, s.pool, d.ID, lastStatus, err.Error(), time.Now().Add(retryBackoff(d.Attempts))); err != nil {
+			return delivered, err
+		}
+	}
+
+	return delivered, nil
+}
+
+// Run delivers due webhooks every interval until ctx is cancelled.
+func (s *webhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.DeliverDue(ctx); err != nil {
+				log.Printf("couldn't deliver webhooks: %v\n", err)
+			}
+		}
+	}
+}
+
+// validateWebhook checks a subscription URL and event type filter.
+func validateWebhook(rawURL string, eventTypes []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	for _, t := range eventTypes {
+		if _, ok := domainEventVersions[t]; !ok {
+			return fmt.Errorf("%w: %s", ErrInvalidWebhookEventType, t)
+		}
+	}
+	return nil
+}
+
+// generateWebhookSecret creates a signing secret: a prefix followed by 32 random bytes, base64url-encoded.
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/LittleAksMax/bids-auth-service/internal/contracts"
+	"github.com/LittleAksMax/bids-auth-service/internal/repository"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 11, want: 2048 * time.Second},
+		{attempts: 12, want: time.Hour},
+		{attempts: 40, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverDue(t *testing.T) {
+	const maxAttempts = 3
+	tests := []struct {
+		name           string
+		attempts       int // before this pass
+		status         int
+		sendErr        error
+		wantDelivered  bool
+		wantFailed     bool
+		wantDeadLetter bool
+		wantStatus     *int
+	}{
+		{name: "2xx delivers", status: 204, wantDelivered: true},
+		{name: "non-2xx retries", status: 500, wantFailed: true, wantStatus: intPtr(500)},
+		{name: "no response retries", sendErr: errors.New("connection refused"), wantFailed: true},
+		{name: "last attempt dead-letters", attempts: maxAttempts - 1, status: 503, wantDeadLetter: true, wantStatus: intPtr(503)},
+		{name: "last attempt without response dead-letters", attempts: maxAttempts - 1, sendErr: errors.New("timeout"), wantDeadLetter: true},
+		{name: "last attempt can still deliver", attempts: maxAttempts - 1, status: 200, wantDelivered: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivery := &contracts.WebhookDelivery{ID: 7, URL: "https://partner.example/hook", Secret: "whsec_test", EventID: uuid.New(), EventType: contracts.DomainEventUserDeleted, Payload: []byte(`{}`), Attempts: tt.attempts}
+			repo := &fakeWebhookDeliveryRepository{due: []*contracts.WebhookDelivery{delivery}}
+			sender := &fakeWebhookSender{status: tt.status, err: tt.sendErr}
+			svc := NewWebhookService(nil, nil, repo, sender, maxAttempts, 10, time.Minute)
+
+			before := time.Now()
+			delivered, err := svc.DeliverDue(context.Background())
+			if err != nil {
+				t.Fatalf("DeliverDue() error = %v", err)
+			}
+			if sender.sent != 1 {
+				t.Errorf("sent %d times, want 1", sender.sent)
+			}
+			if (delivered == 1) != tt.wantDelivered || (repo.deleted == 1) != tt.wantDelivered {
+				t.Errorf("delivered = %d, deleted = %d, want delivered %v", delivered, repo.deleted, tt.wantDelivered)
+			}
+			if (repo.failed != nil) != tt.wantFailed {
+				t.Errorf("marked failed = %v, want %v", repo.failed != nil, tt.wantFailed)
+			}
+			if (repo.deadLettered != nil) != tt.wantDeadLetter {
+				t.Errorf("dead-lettered = %v, want %v", repo.deadLettered != nil, tt.wantDeadLetter)
+			}
+			if tt.wantFailed {
+				// The next attempt waits out the backoff for the attempts made so far
+				wantNext := before.Add(retryBackoff(tt.attempts))
+				if repo.failed.nextAttemptAt.Before(wantNext) || repo.failed.nextAttemptAt.After(time.Now().Add(retryBackoff(tt.attempts))) {
+					t.Errorf("next attempt at %v, want about %v", repo.failed.nextAttemptAt, wantNext)
+				}
+				assertStatus(t, repo.failed.status, tt.wantStatus)
+			}
+			if tt.wantDeadLetter {
+				assertStatus(t, repo.deadLettered.status, tt.wantStatus)
+				if repo.deadLettered.lastError == "" {
+					t.Error("dead letter has no error")
+				}
+			}
+		})
+	}
+}
+
+func TestDeliverDueDeadLettersAfterMaxAttempts(t *testing.T) {
+	const maxAttempts = 4
+	delivery := &contracts.WebhookDelivery{ID: 7, URL: "https://partner.example/hook", Secret: "whsec_test", EventID: uuid.New(), EventType: contracts.DomainEventUserDeleted, Payload: []byte(`{}`)}
+	repo := &fakeWebhookDeliveryRepository{due: []*contracts.WebhookDelivery{delivery}, requeue: true}
+	sender := &fakeWebhookSender{status: 500}
+	svc := NewWebhookService(nil, nil, repo, sender, maxAttempts, 10, time.Minute)
+
+	for pass := 1; repo.deadLettered == nil; pass++ {
+		if pass > maxAttempts {
+			t.Fatalf("not dead-lettered after %d passes", maxAttempts)
+		}
+		if _, err := svc.DeliverDue(context.Background()); err != nil {
+			t.Fatalf("DeliverDue() error = %v", err)
+		}
+	}
+	if sender.sent != maxAttempts {
+		t.Errorf("sent %d times before dead-lettering, want %d", sender.sent, maxAttempts)
+	}
+	if _, err := svc.DeliverDue(context.Background()); err != nil || sender.sent != maxAttempts {
+		t.Errorf("dead letter sent again: sent %d times, err %v", sender.sent, err)
+	}
+}
+
+func intPtr(v int) *int { return &v }
+
+func assertStatus(t *testing.T, got, want *int) {
+	t.Helper()
+	if (got == nil) != (want == nil) || (got != nil && *got != *want) {
+		t.Errorf("status = %v, want %v", got, want)
+	}
+}
+
+type fakeWebhookSender struct {
+	status int
+	err    error
+	sent   int
+}
+
+func (s *fakeWebhookSender) Send(context.Context, string, string, []byte, uuid.UUID, string) (int, error) {
+	s.sent++
+	return s.status, s.err
+}
+
+type failedDelivery struct {
+	status        *int
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// fakeWebhookDeliveryRepository hands out due deliveries and records what happened to them. Failed
+// deliveries are handed out again on the next claim when requeue is set.
+type fakeWebhookDeliveryRepository struct {
+	repository.WebhookDeliveryRepository
+	due          []*contracts.WebhookDelivery
+	requeue      bool
+	claimed      []*contracts.WebhookDelivery
+	deleted      int
+	failed       *failedDelivery
+	deadLettered *failedDelivery
+}
+
+func (r *fakeWebhookDeliveryRepository) ClaimDue(context.Context, *sql.DB, int, time.Time) ([]*contracts.WebhookDelivery, error) {
+	due := r.due
+	r.due = nil
+	r.claimed = due
+	return due, nil
+}
+
+func (r *fakeWebhookDeliveryRepository) Delete(context.Context, repository.Execer, int64) error {
+	r.deleted++
+	return nil
+}
+
+func (r *fakeWebhookDeliveryRepository) MarkFailed(_ context.Context, _ repository.Execer, id int64, status *int, lastError string, nextAttemptAt time.Time) error {
+	r.failed = &failedDelivery{status: status, lastError: lastError, nextAttemptAt: nextAttemptAt}
+	if r.requeue {
+		for _, d := range r.claimed {
+			if d.ID == id {
+				next := *d
+				next.Attempts++
+				r.due = append(r.due, &next)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *fakeWebhookDeliveryRepository) MoveToDeadLetter(_ context.Context, _ repository.Execer, _ int64, status *int, lastError string) error {
+	r.deadLettered = &failedDelivery{status: status, lastError: lastError}
+	return nil
+}
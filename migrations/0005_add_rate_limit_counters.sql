@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+    key TEXT NOT NULL,
+    window_start TIMESTAMPTZ NOT NULL,
+    hits BIGINT NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_counters_expires_at_idx ON rate_limit_counters(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS rate_limit_counters;
@@ -0,0 +1,22 @@
+-- +goose Up
+ALTER TABLE password_credentials
+    ADD COLUMN password_algo TEXT NOT NULL DEFAULT 'argon2id',
+    ADD COLUMN hash_memory INTEGER NULL CHECK (hash_memory > 0),
+    ADD COLUMN hash_iterations INTEGER NULL CHECK (hash_iterations > 0),
+    ADD COLUMN hash_parallelism SMALLINT NULL CHECK (hash_parallelism > 0),
+    ADD COLUMN hash_salt_length INTEGER NULL CHECK (hash_salt_length > 0),
+    ADD COLUMN hash_key_length INTEGER NULL CHECK (hash_key_length > 0);
+
+-- Existing credentials were hashed with passwords.DefaultParams of bids-util v1.0.1. Record those
+-- values as literals so the hashes keep verifying when the library's defaults change.
+UPDATE password_credentials
+SET hash_memory = 65536, hash_iterations = 3, hash_parallelism = 2, hash_salt_length = 16, hash_key_length = 32;
+
+-- +goose Down
+ALTER TABLE password_credentials
+    DROP COLUMN hash_key_length,
+    DROP COLUMN hash_salt_length,
+    DROP COLUMN hash_parallelism,
+    DROP COLUMN hash_iterations,
+    DROP COLUMN hash_memory,
+    DROP COLUMN password_algo;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- Legacy (imported) hashes embed their salt in password_hash, so password_salt may be empty for them.
+ALTER TABLE password_credentials
+    DROP CONSTRAINT IF EXISTS password_credentials_password_salt_check,
+    ADD CONSTRAINT password_credentials_password_salt_check
+        CHECK (password_algo <> 'argon2id' OR password_salt <> ''),
+    ADD CONSTRAINT password_credentials_password_algo_check
+        CHECK (password_algo IN ('argon2id', 'bcrypt', 'pbkdf2-sha256', 'scrypt'));
+
+-- +goose Down
+-- Imported hashes are their users' only credential, so refuse to roll back until they have been
+-- replaced with Argon2id at login or removed.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM password_credentials WHERE password_algo <> 'argon2id') THEN
+        RAISE EXCEPTION 'password credentials hold imported legacy hashes; replace or remove them before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE password_credentials
+    DROP CONSTRAINT IF EXISTS password_credentials_password_algo_check,
+    DROP CONSTRAINT IF EXISTS password_credentials_password_salt_check,
+    ADD CONSTRAINT password_credentials_password_salt_check CHECK (password_salt <> '');
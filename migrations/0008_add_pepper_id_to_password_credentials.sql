@@ -0,0 +1,14 @@
+-- +goose Up
+-- Existing Argon2id hashes were produced with the original PASSWORD_PEPPER, which is pepper version 1.
+ALTER TABLE password_credentials
+    ADD COLUMN pepper_id SMALLINT NULL CHECK (pepper_id > 0);
+
+UPDATE password_credentials SET pepper_id = 1 WHERE password_algo = 'argon2id';
+
+CREATE INDEX IF NOT EXISTS password_credentials_pepper_id_idx ON password_credentials(pepper_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS password_credentials_pepper_id_idx;
+
+ALTER TABLE password_credentials
+    DROP COLUMN pepper_id;
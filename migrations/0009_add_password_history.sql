@@ -0,0 +1,21 @@
+-- +goose Up
+-- Previous password hashes, kept so a configurable number of recent passwords can't be reused.
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    password_hash TEXT NOT NULL CHECK (password_hash <> ''),
+    password_salt TEXT NOT NULL,
+    password_algo TEXT NOT NULL CHECK (password_algo IN ('argon2id', 'bcrypt', 'pbkdf2-sha256', 'scrypt')),
+    pepper_id SMALLINT NULL CHECK (pepper_id > 0),
+    hash_memory INTEGER NULL CHECK (hash_memory > 0),
+    hash_iterations INTEGER NULL CHECK (hash_iterations > 0),
+    hash_parallelism SMALLINT NULL CHECK (hash_parallelism > 0),
+    hash_salt_length INTEGER NULL CHECK (hash_salt_length > 0),
+    hash_key_length INTEGER NULL CHECK (hash_key_length > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS password_history_user_id_created_at_idx ON password_history(user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS password_history;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- Replaces the is_active flag dropped in 0002. Only active users can log in or refresh tokens.
+ALTER TABLE users
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'suspended', 'deactivated', 'pending_deletion')),
+    ADD COLUMN status_reason TEXT NULL,
+    ADD COLUMN status_changed_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS users_status_idx ON users(status) WHERE status <> 'active';
+
+-- +goose Down
+DROP INDEX IF EXISTS users_status_idx;
+
+ALTER TABLE users
+    DROP COLUMN status_changed_at,
+    DROP COLUMN status_reason,
+    DROP COLUMN status;
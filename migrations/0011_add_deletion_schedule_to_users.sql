@@ -0,0 +1,16 @@
+-- +goose Up
+-- Set while a user is pending_deletion; the account is purged once this time has passed.
+ALTER TABLE users
+    ADD COLUMN deletion_scheduled_at TIMESTAMPTZ NULL,
+    ADD CONSTRAINT users_deletion_scheduled_at_check
+        CHECK (deletion_scheduled_at IS NULL OR status = 'pending_deletion');
+
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_at_idx ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS users_deletion_scheduled_at_idx;
+
+ALTER TABLE users
+    DROP CONSTRAINT IF EXISTS users_deletion_scheduled_at_check,
+    DROP COLUMN deletion_scheduled_at;
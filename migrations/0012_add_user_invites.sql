@@ -0,0 +1,19 @@
+-- +goose Up
+-- Single-use invitations letting an admin-created user choose their own password.
+CREATE TABLE IF NOT EXISTS user_invites (
+    token_hash TEXT PRIMARY KEY CHECK (token_hash <> ''),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_invites_user_id_idx ON user_invites(user_id);
+
+-- Supports keyset pagination of the admin user listing.
+CREATE INDEX IF NOT EXISTS users_created_at_id_idx ON users(created_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_created_at_id_idx;
+
+DROP TABLE IF EXISTS user_invites;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Append-only log of authentication events. Subjects and actors are nulled rather than cascaded when
+-- a user is deleted so the event history survives without identifying them.
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL CHECK (event_type <> ''),
+    actor_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    subject_id UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}'::jsonb,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS auth_events_subject_id_idx ON auth_events(subject_id, id DESC);
+CREATE INDEX IF NOT EXISTS auth_events_actor_id_idx ON auth_events(actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS auth_events_event_type_idx ON auth_events(event_type, id DESC);
+CREATE INDEX IF NOT EXISTS auth_events_occurred_at_idx ON auth_events(occurred_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS auth_events;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Transactional outbox: domain events are inserted in the transaction that caused them and delivered
+-- to the configured sink by a relay worker, at least once.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE,
+    event_type TEXT NOT NULL CHECK (event_type <> ''),
+    version INTEGER NOT NULL CHECK (version > 0),
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT NULL,
+    published_at TIMESTAMPTZ NULL
+);
+
+-- The relay only ever scans undelivered events.
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events(next_attempt_at, id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_events_published_at_idx ON outbox_events(published_at) WHERE published_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_events;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- A session is a chain of rotated refresh tokens; session_id stays the same across rotations and is
+-- the sid of access and logout tokens. Existing tokens each start their own session.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS session_id UUID NULL;
+UPDATE refresh_tokens SET session_id = token_id WHERE session_id IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN session_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens(session_id);
+
+-- Pending OIDC back-channel logout notifications, one per relying client. session_id is NULL when
+-- every session of the user ended (e.g. the user was deleted). Removed once delivered or abandoned.
+CREATE TABLE IF NOT EXISTS backchannel_logout_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    client_id TEXT NOT NULL CHECK (client_id <> ''),
+    event_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    session_id UUID NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Makes queueing idempotent when the outbox relay redelivers an event.
+CREATE UNIQUE INDEX IF NOT EXISTS backchannel_logout_deliveries_unique_idx
+    ON backchannel_logout_deliveries(client_id, event_id, COALESCE(session_id, '00000000-0000-0000-0000-000000000000'::uuid));
+CREATE INDEX IF NOT EXISTS backchannel_logout_deliveries_next_attempt_at_idx ON backchannel_logout_deliveries(next_attempt_at, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS backchannel_logout_deliveries;
+
+DROP INDEX IF EXISTS refresh_tokens_session_id_idx;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS session_id;
@@ -0,0 +1,60 @@
+-- +goose Up
+-- Every way a user can sign in is a credential, discriminated by type. Type-specific data lives in
+-- a table per type referencing its credential: password_credentials and user_identities (oidc).
+-- Deleting a credential deletes its details.
+CREATE TABLE IF NOT EXISTS credentials (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('password', 'oidc', 'passkey')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS credentials_user_id_idx ON credentials(user_id);
+-- A user has at most one password
+CREATE UNIQUE INDEX IF NOT EXISTS credentials_user_password_idx ON credentials(user_id) WHERE type = 'password';
+
+ALTER TABLE password_credentials ADD COLUMN IF NOT EXISTS credential_id UUID NULL REFERENCES credentials(id) ON DELETE CASCADE;
+INSERT INTO credentials (user_id, type)
+SELECT user_id, 'password' FROM password_credentials WHERE credential_id IS NULL;
+UPDATE password_credentials pc SET credential_id = c.id
+FROM credentials c
+WHERE c.user_id = pc.user_id AND c.type = 'password' AND pc.credential_id IS NULL;
+ALTER TABLE password_credentials ALTER COLUMN credential_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS password_credentials_credential_id_idx ON password_credentials(credential_id);
+
+-- Identities keep their ID as their credential's ID; last use moves to the credential
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS credential_id UUID NULL REFERENCES credentials(id) ON DELETE CASCADE;
+INSERT INTO credentials (id, user_id, type, created_at, last_used_at)
+SELECT id, user_id, 'oidc', created_at, last_login_at FROM user_identities WHERE credential_id IS NULL;
+UPDATE user_identities SET credential_id = id WHERE credential_id IS NULL;
+ALTER TABLE user_identities ALTER COLUMN credential_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS user_identities_credential_id_idx ON user_identities(credential_id);
+ALTER TABLE user_identities DROP COLUMN IF EXISTS last_login_at;
+
+-- When the user behind a session last proved who they are, for re-authentication checks. NULL for
+-- sessions started before this was recorded, which count as not recently authenticated.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS authenticated_at TIMESTAMPTZ NULL;
+
+-- Social logins started by a signed-in user to link the identity to their account instead of
+-- signing in; allow_merge lets an identity already linked to another account merge that account in.
+ALTER TABLE oidc_login_states
+    ADD COLUMN IF NOT EXISTS link_user_id UUID NULL REFERENCES users(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS allow_merge BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE oidc_login_states
+    DROP COLUMN IF EXISTS allow_merge,
+    DROP COLUMN IF EXISTS link_user_id;
+
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS authenticated_at;
+
+ALTER TABLE user_identities ADD COLUMN IF NOT EXISTS last_login_at TIMESTAMPTZ NULL;
+UPDATE user_identities ui SET last_login_at = c.last_used_at FROM credentials c WHERE c.id = ui.credential_id;
+DROP INDEX IF EXISTS user_identities_credential_id_idx;
+ALTER TABLE user_identities DROP COLUMN IF EXISTS credential_id;
+
+DROP INDEX IF EXISTS password_credentials_credential_id_idx;
+ALTER TABLE password_credentials DROP COLUMN IF EXISTS credential_id;
+
+DROP TABLE IF EXISTS credentials;
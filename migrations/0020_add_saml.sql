@@ -0,0 +1,37 @@
+-- +goose Up
+-- Users signing in through enterprise SAML identity providers. Each identity is the detail of a saml
+-- credential, keyed by the connection's name in config and the NameID the IdP asserts for the user.
+-- provisioned marks the identity whose first sign-in created the user; only those users have their role
+-- kept in line with the IdP's.
+ALTER TABLE credentials DROP CONSTRAINT IF EXISTS credentials_type_check;
+ALTER TABLE credentials ADD CONSTRAINT credentials_type_check CHECK (type IN ('password', 'oidc', 'saml', 'passkey'));
+
+CREATE TABLE IF NOT EXISTS saml_identities (
+    credential_id UUID PRIMARY KEY REFERENCES credentials(id) ON DELETE CASCADE,
+    connection TEXT NOT NULL CHECK (connection <> ''),
+    name_id TEXT NOT NULL CHECK (name_id <> ''),
+    email CITEXT NULL,
+    provisioned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (connection, name_id)
+);
+
+-- In-flight SAML logins. The RelayState is looked up by its hash when the IdP posts its response, and
+-- the row is deleted on use so each AuthnRequest is answered at most once.
+CREATE TABLE IF NOT EXISTS saml_login_states (
+    state_hash TEXT PRIMARY KEY,
+    connection TEXT NOT NULL,
+    request_id TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS saml_login_states_expires_at_idx ON saml_login_states(expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS saml_login_states;
+DROP TABLE IF EXISTS saml_identities;
+DELETE FROM credentials WHERE type = 'saml';
+
+ALTER TABLE credentials DROP CONSTRAINT IF EXISTS credentials_type_check;
+ALTER TABLE credentials ADD CONSTRAINT credentials_type_check CHECK (type IN ('password', 'oidc', 'passkey'));
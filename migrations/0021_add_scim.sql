@@ -0,0 +1,31 @@
+-- +goose Up
+-- Bearer tokens SCIM clients provision users with, each issued to a tenant (e.g. a customer's
+-- directory). Only the SHA-256 hash of a token is stored; revoking a token deletes it.
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant TEXT NOT NULL CHECK (tenant <> ''),
+    token_hash TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS scim_tokens_tenant_idx ON scim_tokens(tenant);
+
+-- Users provisioned over SCIM, with the tenant that owns them and the SCIM attributes that have no
+-- place in users. A tenant only sees the users it provisioned.
+CREATE TABLE IF NOT EXISTS scim_users (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    tenant TEXT NOT NULL CHECK (tenant <> ''),
+    external_id TEXT NULL,
+    given_name TEXT NULL,
+    family_name TEXT NULL,
+    display_name TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS scim_users_tenant_idx ON scim_users(tenant, external_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS scim_users;
+DROP TABLE IF EXISTS scim_tokens;
@@ -0,0 +1,48 @@
+-- +goose Up
+-- Organizations (e.g. customers) users belong to, each with its own membership roles. The global
+-- users.role is unaffected.
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    slug CITEXT NOT NULL UNIQUE CHECK (slug <> ''),
+    name TEXT NOT NULL CHECK (name <> ''),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS memberships (
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS memberships_user_id_idx ON memberships(user_id);
+
+-- Single-use invitations to join an organization, addressed to an email. Only the SHA-256 hash of
+-- the token is stored.
+CREATE TABLE IF NOT EXISTS organization_invites (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email CITEXT NOT NULL CHECK (email <> ''),
+    role VARCHAR(16) NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+    token_hash TEXT NOT NULL UNIQUE CHECK (token_hash <> ''),
+    invited_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    accepted_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS organization_invites_organization_id_idx ON organization_invites(organization_id);
+
+-- The organization a session acts in, carried over when its refresh token is rotated.
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS organization_id UUID NULL REFERENCES organizations(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_invites;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,65 @@
+-- +goose Up
+-- Global roles a user can hold, replacing the fixed user/admin check on users.role. Built-in roles
+-- can't be deleted.
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(64) PRIMARY KEY CHECK (name <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    builtin BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Permissions are <resource>:<action> names, e.g. bids:write. Either part may be * to grant every
+-- resource or action.
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(128) PRIMARY KEY CHECK (name <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(64) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission_name VARCHAR(128) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission_name)
+);
+
+CREATE INDEX IF NOT EXISTS role_permissions_permission_name_idx ON role_permissions(permission_name);
+
+INSERT INTO roles (name, description, builtin) VALUES
+    ('user', 'Default role for new users', TRUE),
+    ('admin', 'Administers the auth service', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES ('*:*', 'Every action on every resource')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES ('admin', '*:*')
+ON CONFLICT DO NOTHING;
+
+-- Roles in use can't be deleted.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(64);
+ALTER TABLE users
+    ADD CONSTRAINT users_role_fkey FOREIGN KEY (role) REFERENCES roles(name);
+
+-- +goose Down
+-- Users holding custom roles can't be given back a user/admin role without guessing their access, so
+-- refuse to roll back until they have been moved to one.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE role NOT IN ('user', 'admin')) THEN
+        RAISE EXCEPTION 'users hold roles other than user and admin; change their roles before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(5);
+ALTER TABLE users
+    ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'admin'));
+
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
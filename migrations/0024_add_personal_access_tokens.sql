@@ -0,0 +1,18 @@
+-- +goose Up
+-- Long-lived tokens users script against the bids APIs with, limited to the permissions in scopes.
+-- Only the HMAC of a token is stored, never the token itself; revoking a token deletes it.
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL CHECK (name <> ''),
+    token_hash TEXT NOT NULL UNIQUE CHECK (token_hash <> ''),
+    scopes JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS personal_access_tokens_user_id_idx ON personal_access_tokens(user_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS personal_access_tokens;
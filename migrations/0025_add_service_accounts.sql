@@ -0,0 +1,34 @@
+-- +goose Up
+-- Non-human identities for scheduled jobs and integrations, owned by an organization or, without
+-- one, by the admins. They hold a global role from the roles allowed for service accounts.
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL CHECK (name <> ''),
+    description TEXT NOT NULL DEFAULT '',
+    role VARCHAR(64) NOT NULL REFERENCES roles(name),
+    organization_id UUID NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    created_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS service_accounts_organization_id_idx ON service_accounts(organization_id);
+CREATE INDEX IF NOT EXISTS service_accounts_role_idx ON service_accounts(role);
+
+-- API keys are sa_<prefix>_<secret>. The prefix identifies the key to people and is kept in the
+-- clear; only the HMAC of the whole key is stored. An account has at most two keys so one can be
+-- rotated while the other is in use; revoking a key deletes it.
+CREATE TABLE IF NOT EXISTS service_account_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    service_account_id UUID NOT NULL REFERENCES service_accounts(id) ON DELETE CASCADE,
+    prefix TEXT NOT NULL UNIQUE CHECK (prefix <> ''),
+    key_hash TEXT NOT NULL UNIQUE CHECK (key_hash <> ''),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS service_account_keys_service_account_id_idx ON service_account_keys(service_account_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS service_account_keys;
+DROP TABLE IF EXISTS service_accounts;
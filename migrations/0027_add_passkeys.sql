@@ -0,0 +1,46 @@
+-- +goose Up
+-- Passkeys: WebAuthn public key credentials. Each is the detail of a passkey credential, found on
+-- sign-in by the credential ID the authenticator chose (webauthn_id). sign_count and flags are updated
+-- on every sign-in so cloned authenticators can be detected.
+CREATE TABLE IF NOT EXISTS passkey_credentials (
+    credential_id UUID PRIMARY KEY REFERENCES credentials(id) ON DELETE CASCADE,
+    webauthn_id BYTEA NOT NULL UNIQUE,
+    user_handle BYTEA NOT NULL,
+    public_key BYTEA NOT NULL,
+    attestation_type TEXT NOT NULL DEFAULT '',
+    transports JSONB NOT NULL DEFAULT '[]'::jsonb,
+    aaguid BYTEA NULL,
+    sign_count BIGINT NOT NULL DEFAULT 0,
+    flags SMALLINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- In-flight passkey registrations and sign-ins. The state is looked up by its hash when the browser
+-- sends the authenticator's response, and the row is deleted on use so each challenge is answered at
+-- most once.
+CREATE TABLE IF NOT EXISTS passkey_ceremonies (
+    state_hash TEXT PRIMARY KEY,
+    user_id UUID NULL REFERENCES users(id) ON DELETE CASCADE,
+    organization_id UUID NULL,
+    session JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS passkey_ceremonies_expires_at_idx ON passkey_ceremonies(expires_at);
+
+-- +goose Down
+-- Dropping the details would leave passkey credentials nobody can sign in with, so refuse to roll back
+-- while any exist.
+-- +goose StatementBegin
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM credentials WHERE type = 'passkey') THEN
+        RAISE EXCEPTION 'users have registered passkeys; remove them before rolling back';
+    END IF;
+END
+$$;
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS passkey_ceremonies;
+DROP TABLE IF EXISTS passkey_credentials;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- External logins can select the organization the session they start acts in, like password logins.
+ALTER TABLE oidc_login_states
+    ADD COLUMN IF NOT EXISTS organization_id UUID NULL;
+ALTER TABLE saml_login_states
+    ADD COLUMN IF NOT EXISTS organization_id UUID NULL;
+
+-- +goose Down
+ALTER TABLE saml_login_states
+    DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE oidc_login_states
+    DROP COLUMN IF EXISTS organization_id;